@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// approveCmd represents the approve command
+var approveCmd = &cobra.Command{
+	Use:   "approve [plan.json]",
+	Short: "Sign the approval of a plan or removal for another operator",
+	Long: `Approve a plan from 'run plan', or the removal of packages from a host,
+for hosts that require approval in ` + internal.ApprovalConfigPath + `: 'run apply'
+and 'run remove' then refuse to run without an approval signed by a key
+they trust, by another user than the one running them.
+
+  required: true
+  public_keys:
+    alice: <public key printed by 'run approve keygen'>
+
+The file must be owned by root and not writable by the operators, and
+applies to every context. Each approver creates a key once with 'run
+approve keygen' on their own machine and has the public key added to the
+hosts' approval.yaml, named after their user name. An approval
+covers one plan file, byte for byte, or one set of packages to remove on
+one host, optionally only one --version of them. It can be used once, and
+expires after --valid.
+
+Examples:
+  run approve keygen
+  run approve plan.json --name alice
+  run approve --remove nginx --host web-1 --name alice -o remove-nginx.approval
+  run approve --remove php --version 8.1 --host web-1 -o remove-php81.approval`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		remove, _ := cmd.Flags().GetStringSlice("remove")
+		host, _ := cmd.Flags().GetString("host")
+		version, _ := cmd.Flags().GetString("version")
+		out, _ := cmd.Flags().GetString("out")
+		keyName, _ := cmd.Flags().GetString("name")
+		validity, _ := cmd.Flags().GetDuration("valid")
+		keyPath, err := approvalKeyPath(cmd)
+		if err != nil {
+			return err
+		}
+		if validity <= 0 {
+			return fmt.Errorf("--valid must be positive")
+		}
+		if keyName == "" {
+			current, err := user.Current()
+			if err != nil {
+				return err
+			}
+			keyName = current.Username
+		}
+
+		var approval internal.Approval
+		switch {
+		case len(args) == 1 && len(remove) == 0 && version == "":
+			if approval, err = internal.PlanApproval(args[0]); err != nil {
+				return err
+			}
+			if out == "" {
+				out = args[0] + ".approval"
+			}
+			printPlanForApproval(args[0])
+		case len(args) == 0 && len(remove) > 0:
+			if host == "" {
+				return fmt.Errorf("--host must name the host the packages are removed from")
+			}
+			if out == "" {
+				return fmt.Errorf("--out must name the approval file to write")
+			}
+			approval = internal.RemoveApproval(host, remove, version)
+		default:
+			return fmt.Errorf("give either a plan file or --remove with the packages to remove, and --version only with --remove")
+		}
+
+		token, err := internal.SignApproval(approval, keyPath, keyName, validity)
+		if err != nil {
+			return err
+		}
+		if err := internal.WriteApprovalToken(token, out); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Approval written to %s, valid until %s\n", out, token.Approval.ExpiresAt.Local().Format("2006-01-02 15:04"))
+		return nil
+	},
+}
+
+// approveKeygenCmd represents the approve keygen command
+var approveKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Create an approval key and print its public key",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keyPath, err := approvalKeyPath(cmd)
+		if err != nil {
+			return err
+		}
+		public, err := internal.GenerateApprovalKey(keyPath)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Approval key written to %s. Add the public key to %s on the hosts you approve for:\n\n", keyPath, internal.ApprovalConfigPath)
+		fmt.Printf("public_keys:\n  <your user name>: %s\n", public)
+		return nil
+	},
+}
+
+// approvalKeyPath returns --key, or the default approval key path.
+func approvalKeyPath(cmd *cobra.Command) (string, error) {
+	if keyPath, _ := cmd.Flags().GetString("key"); keyPath != "" {
+		return keyPath, nil
+	}
+	return internal.DefaultApprovalKeyPath()
+}
+
+// printPlanForApproval shows the plan being approved.
+func printPlanForApproval(path string) {
+	plan, err := internal.ReadPlan(path)
+	if err != nil {
+		return
+	}
+	printPlan(os.Stdout, plan)
+}
+
+// checkRemoveApproval checks the approval of removing packages from this
+// host when the host requires approval.
+func checkRemoveApproval(cmd *cobra.Command, packages []string) error {
+	tokenPath, _ := cmd.Flags().GetString("approval")
+	version, _ := cmd.Flags().GetString("version")
+	if allFlag, _ := cmd.Flags().GetBool("all"); allFlag {
+		// --all removes every version, whatever --version says.
+		version = ""
+	}
+	hostname, _ := os.Hostname()
+	return internal.RequireApproval(internal.RemoveApproval(hostname, packages, version), tokenPath)
+}
+
+func init() {
+	rootCmd.AddCommand(approveCmd)
+	approveCmd.AddCommand(approveKeygenCmd)
+
+	for _, command := range []*cobra.Command{approveCmd, approveKeygenCmd} {
+		command.Flags().String("key", "", "approval key (default ~/.run/approval.key)")
+	}
+	approveCmd.Flags().String("name", "", "name of your key in public_keys (default your user name)")
+	approveCmd.Flags().StringSlice("remove", nil, "approve removing these packages instead of a plan")
+	approveCmd.Flags().String("host", "", "host the packages are removed from, with --remove")
+	approveCmd.Flags().String("version", "", "approve removing only this version, with --remove")
+	approveCmd.Flags().StringP("out", "o", "", "approval file to write (default <plan>.approval)")
+	approveCmd.Flags().Duration("valid", internal.DefaultApprovalValidity, "how long the approval can be used")
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// addAptLockFlag adds --pause-unattended-upgrades to commands that run apt.
+func addAptLockFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("pause-unattended-upgrades", false, "stop unattended-upgrades from starting until run is done")
+}
+
+// holdAptLock keeps unattended-upgrades from taking the dpkg lock during
+// the command: it waits for a running upgrade to finish and, with
+// --pause-unattended-upgrades or system.pause_unattended_upgrades, pauses
+// the timers. The returned function resumes them.
+func holdAptLock(cmd *cobra.Command) (func(), error) {
+	if pause, _ := cmd.Flags().GetBool("pause-unattended-upgrades"); pause || internal.PauseUnattendedUpgradesConfigured() {
+		return internal.PauseUnattendedUpgrades()
+	}
+	return func() {}, internal.WaitForUnattendedUpgrades()
+}
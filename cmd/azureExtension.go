@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/amoga-io/run/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// azureExtensionCmd represents the azure-extension command
+var azureExtensionCmd = &cobra.Command{
+	Use:   "azure-extension <install|enable|disable|update|uninstall>",
+	Short: "Run as an Azure VM extension handler",
+	Long: `Run as the handler of an Azure VM extension, packaged with
+packaging/azure/build.sh. The VM agent calls it from the handler directory,
+which holds HandlerEnvironment.json and the bundled scripts.
+
+On enable the public settings are applied without prompts:
+  {"packages": ["node", "nginx"], "profile": "azure", "versions": {"java": "17"}}
+
+Progress and the result of each package are written to the status file the
+agent reports to Azure. The other operations have nothing to do.`,
+	Hidden:    true,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"install", "enable", "disable", "update", "uninstall"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch args[0] {
+		case "install", "disable", "update", "uninstall":
+			return nil
+		case "enable":
+			return azureExtensionEnable()
+		}
+		return fmt.Errorf("unknown operation '%s'", args[0])
+	},
+}
+
+// azureExtensionEnable installs the packages of the current settings and
+// reports the outcome in the extension status file.
+func azureExtensionEnable() error {
+	handlerDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	env, err := internal.LoadAzureHandlerEnvironment(handlerDir)
+	if err != nil {
+		return err
+	}
+	sequence, err := env.SequenceNumber()
+	if err != nil {
+		return err
+	}
+	settings, err := env.LoadSettings(sequence)
+	if err != nil {
+		if statusErr := env.WriteStatus(sequence, "Enable", internal.AzureStatusError, err.Error(), nil); statusErr != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  %v\n", statusErr)
+		}
+		return err
+	}
+	if err := env.WriteStatus(sequence, "Enable", internal.AzureStatusTransitioning, "Installing packages", nil); err != nil {
+		return err
+	}
+
+	// Scripts must not prompt: there is no one to answer
+	if devNull, err := os.Open(os.DevNull); err == nil {
+		os.Stdin = devNull
+	}
+	os.Setenv("DEBIAN_FRONTEND", "noninteractive")
+	if info, err := os.Stat("scripts"); err == nil && info.IsDir() {
+		internal.ScriptsDirOverride = "scripts"
+	}
+	internal.CloudProviderOverride = settings.Profile
+	if internal.CloudProviderOverride == "" {
+		internal.CloudProviderOverride = internal.CloudAzure
+	}
+
+	results := installPackages(settings.Packages, installOptions{Versions: settings.Versions})
+
+	var substatuses []internal.AzureSubstatus
+	for _, result := range results {
+		status := internal.AzureStatusSuccess
+		if result.Status == output.StatusFailed {
+			status = internal.AzureStatusError
+		}
+		substatuses = append(substatuses, internal.AzureSubstatus{Name: result.Package, Status: status, Message: result.Message})
+	}
+	status, message := internal.AzureStatusSuccess, fmt.Sprintf("Installed %d package(s)", len(results))
+	if failed := output.Failed(results); failed > 0 {
+		status, message = internal.AzureStatusError, fmt.Sprintf("%d of %d package(s) failed", failed, len(results))
+	}
+	if err := env.WriteStatus(sequence, "Enable", status, message, substatuses); err != nil {
+		return err
+	}
+	if status == internal.AzureStatusError {
+		return fmt.Errorf("%s", message)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(azureExtensionCmd)
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// backupCmd represents the backup command
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Back up and restore the run-managed configuration",
+	Long: `Back up the layer of a host that run manages, to rebuild it quickly
+after reimaging: the context's config, state, baseline and env file, the
+registry overlay, nginx sites, pm2 process lists and ecosystem files, and
+the units, env files and compose files of services, plus the files written
+by 'run generate env'.
+
+Secrets and env files are encrypted to the age or GPG recipients of the
+secrets store when secrets.encryption is set, and restored with its
+identity. Otherwise they are encrypted with a passphrase from
+RUN_BACKUP_PASSPHRASE, --passphrase-file or backup.passphrase_file:
+
+  backup:
+    dir: ~/run-backups            # where archives are written
+    keep: 7                       # archives of the host kept in dir
+    passphrase_file: ~/.run-backup-passphrase
+
+Copy the archives off the host; restoring on the reimaged host puts the
+files back, after which 'run profile apply' or 'run install' reinstalls
+the packages the restored state lists.
+
+Examples:
+  run backup create
+  run backup create -o /mnt/backups/web-1.tar.gz
+  run backup restore run-web-1-default-20260101-030000.tar.gz
+  run backup schedule`,
+}
+
+// backupCreateCmd represents the backup create command
+var backupCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Write a backup archive of the run-managed configuration",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		outputPath, _ := cmd.Flags().GetString("output")
+		noSecrets, _ := cmd.Flags().GetBool("no-secrets")
+		quiet, _ := cmd.Flags().GetBool("quiet")
+
+		passphrase, err := backupPassphrase(cmd)
+		if err != nil {
+			return err
+		}
+		path, manifest, err := internal.CreateBackup(outputPath, passphrase, noSecrets)
+		if err != nil {
+			return err
+		}
+		if quiet {
+			return nil
+		}
+		fmt.Printf("✅ Backed up %d file(s) and %d encrypted secret file(s) to %s\n", len(manifest.Files), len(manifest.Secrets), path)
+		return nil
+	},
+}
+
+// backupRestoreCmd represents the backup restore command
+var backupRestoreCmd = &cobra.Command{
+	Use:   "restore <archive>",
+	Short: "Restore the files of a backup archive",
+	Long: `Restore the files of a backup archive with their mode and owner. The
+context's files go into the active context, files under the home directory
+into the user's, and system files such as nginx sites and units back in
+place with sudo. Existing files are overwritten; you are asked first
+unless --yes is given.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		noSecrets, _ := cmd.Flags().GetBool("no-secrets")
+
+		manifest, err := internal.ReadBackupManifest(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Backup of %s (context %s) from %s:\n", manifest.Host, manifest.Context, manifest.CreatedAt.Local().Format("2006-01-02 15:04"))
+		for _, path := range manifest.Files {
+			fmt.Printf("  %s\n", path)
+		}
+		if !noSecrets {
+			for _, path := range manifest.Secrets {
+				fmt.Printf("  %s (encrypted)\n", path)
+			}
+		}
+
+		passphrase := ""
+		if len(manifest.Secrets) > 0 && !noSecrets && manifest.SecretsEncryption == "" {
+			if passphrase, err = backupPassphrase(cmd); err != nil {
+				return err
+			}
+			if passphrase == "" {
+				return fmt.Errorf("the backup holds encrypted secrets: set %s or pass --passphrase-file, or leave them out with --no-secrets", internal.BackupPassphraseEnv)
+			}
+		}
+		if !internal.Confirm("Restore these files, overwriting existing ones?") {
+			return fmt.Errorf("restore cancelled")
+		}
+
+		restored, err := internal.RestoreBackup(args[0], passphrase, noSecrets)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Restored %d file(s)\n", len(restored))
+		nginx, pm2 := false, false
+		for _, path := range restored {
+			nginx = nginx || strings.HasPrefix(path, "/etc/nginx/")
+			pm2 = pm2 || strings.HasSuffix(path, "/.pm2/dump.pm2")
+		}
+		if nginx {
+			fmt.Println("Reload nginx to use the restored sites: sudo nginx -t && sudo systemctl reload nginx")
+		}
+		if pm2 {
+			fmt.Println("Start the saved pm2 apps once node is installed: pm2 resurrect")
+		}
+		return nil
+	},
+}
+
+// backupScheduleCmd represents the backup schedule command
+var backupScheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Create backups daily with a systemd timer",
+	Long: `Install a daily systemd timer running 'run backup create' for the active
+context, writing to backup.dir and keeping the last backup.keep archives.
+Secrets are encrypted like the secrets store when secrets.encryption is
+set, and with backup.passphrase_file otherwise, which must then be set
+when there are any.
+
+Examples:
+  run backup schedule
+  run backup schedule --status
+  run backup schedule --disable`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if disable, _ := cmd.Flags().GetBool("disable"); disable {
+			if err := internal.DisableBackupSchedule(); err != nil {
+				return err
+			}
+			fmt.Println("✅ Scheduled backups disabled")
+			return nil
+		}
+		if status, _ := cmd.Flags().GetBool("status"); !status {
+			if err := internal.EnableBackupSchedule(); err != nil {
+				return err
+			}
+			fmt.Println("✅ Daily backups enabled")
+		}
+		enabled, next := internal.BackupScheduleStatus()
+		if !enabled {
+			fmt.Println("Scheduled backups are disabled. Enable them with: run backup schedule")
+			return nil
+		}
+		if next != "" {
+			fmt.Printf("Next backup: %s\n", next)
+		}
+		return nil
+	},
+}
+
+// backupPassphrase returns the passphrase from RUN_BACKUP_PASSPHRASE,
+// --passphrase-file or backup.passphrase_file.
+func backupPassphrase(cmd *cobra.Command) (string, error) {
+	file, _ := cmd.Flags().GetString("passphrase-file")
+	if file == "" {
+		config, err := internal.LoadConfig()
+		if err != nil {
+			return "", err
+		}
+		file = config.Backup.WithDefaults().PassphraseFile
+	}
+	return internal.BackupPassphrase(file)
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	backupCmd.AddCommand(backupCreateCmd)
+	backupCmd.AddCommand(backupRestoreCmd)
+	backupCmd.AddCommand(backupScheduleCmd)
+
+	for _, command := range []*cobra.Command{backupCreateCmd, backupRestoreCmd} {
+		command.Flags().String("passphrase-file", "", "file holding the passphrase secrets are encrypted with")
+		command.Flags().Bool("no-secrets", false, "leave out secrets and env files")
+	}
+	backupCreateCmd.Flags().StringP("output", "o", "", "write the archive here instead of backup.dir")
+	backupCreateCmd.Flags().BoolP("quiet", "q", false, "do not print the archive written")
+	backupScheduleCmd.Flags().Bool("disable", false, "stop and remove the timer")
+	backupScheduleCmd.Flags().Bool("status", false, "show whether the timer is enabled")
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// bootstrapCmd represents the bootstrap command
+var bootstrapCmd = &cobra.Command{
+	Use:   "bootstrap",
+	Short: "Set up run on other environments",
+}
+
+// bootstrapWSLCmd represents the bootstrap wsl command
+var bootstrapWSLCmd = &cobra.Command{
+	Use:   "wsl",
+	Short: "Create or update a WSL Ubuntu distribution with run and a profile",
+	Long: `Set up a WSL distribution on a Windows laptop: create it when missing
+(` + internal.DefaultWSLDistro + ` by default), update its packages, add the user with
+passwordless sudo as its default user, enable systemd, install run (or
+update it) and apply a profile. Running it again updates the distribution
+and converges the profile.
+
+Run it from Windows with run.exe, or from inside WSL with Windows interop.
+--script prints the same steps as a PowerShell script instead, for machines
+without run:
+
+  run bootstrap wsl --profile web --script > bootstrap-wsl.ps1
+  powershell -ExecutionPolicy Bypass -File bootstrap-wsl.ps1
+
+The user defaults to the Windows user name.
+
+Examples:
+  run bootstrap wsl --profile web
+  run bootstrap wsl --distro Ubuntu-22.04 --user dev`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		distro, _ := cmd.Flags().GetString("distro")
+		user, _ := cmd.Flags().GetString("user")
+		profile, _ := cmd.Flags().GetString("profile")
+		if user == "" {
+			user = internal.DefaultWSLUser()
+		}
+		bootstrap := internal.WSLBootstrap{Distro: distro, User: user, Profile: profile}
+		if err := bootstrap.Validate(); err != nil {
+			return err
+		}
+
+		if script, _ := cmd.Flags().GetBool("script"); script {
+			fmt.Print(internal.WSLBootstrapScript(bootstrap))
+			return nil
+		}
+		if _, err := exec.LookPath("wsl.exe"); err != nil {
+			return fmt.Errorf("wsl.exe not found: run this from Windows, or generate a PowerShell script with --script")
+		}
+		if err := internal.RunWSLBootstrap(bootstrap, os.Stdout, os.Stderr); err != nil {
+			return err
+		}
+		fmt.Printf("✅ %s is ready; open it with: wsl -d %s\n", distro, distro)
+		return nil
+	},
+}
+
+func init() {
+	bootstrapWSLCmd.Flags().String("distro", internal.DefaultWSLDistro, "WSL distribution to create or update")
+	bootstrapWSLCmd.Flags().String("user", "", "Linux user to set up (default: the Windows user name)")
+	bootstrapWSLCmd.Flags().String("profile", "", "profile to apply inside the distribution")
+	bootstrapWSLCmd.Flags().Bool("script", false, "print a PowerShell script instead of running wsl.exe")
+	bootstrapCmd.AddCommand(bootstrapWSLCmd)
+	rootCmd.AddCommand(bootstrapCmd)
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect and prune run's cache",
+	Long: `Inspect and prune ~/.run/cache: artifacts downloaded with 'run download',
+kept by their SHA-256 checksum, the scripts extracted from the binary, PHP
+builds and the Go caches of 'run update'.
+
+Artifacts a package script downloads are recorded in the state of the
+package and kept while it is installed. An install drops the artifacts it
+no longer used, such as the tarball of an earlier version, and a removal
+drops all of them; 'run cache gc' then removes them once unused for
+--older-than. 'run maintenance enable' runs it daily.
+
+Examples:
+  run cache stats
+  run cache gc --dry-run
+  run cache gc --older-than 24h`,
+}
+
+// cacheStatsCmd represents the cache stats command
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the size of the cache",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stats, err := internal.GetCacheStats()
+		if err != nil {
+			return err
+		}
+		if len(stats.Dirs) == 0 {
+			fmt.Println("The cache is empty")
+			return nil
+		}
+		var files int
+		var bytes int64
+		for _, dir := range stats.Dirs {
+			fmt.Printf("%-14s %6d file(s) %10s\n", dir.Name, dir.Files, formatBytes(dir.Bytes))
+			files += dir.Files
+			bytes += dir.Bytes
+		}
+		fmt.Printf("%-14s %6d file(s) %10s\n", "total", files, formatBytes(bytes))
+		fmt.Println()
+		for _, usage := range []internal.CacheUsage{stats.Referenced, stats.Unreferenced} {
+			fmt.Printf("%-14s %6d file(s) %10s\n", usage.Name, usage.Files, formatBytes(usage.Bytes))
+		}
+		return nil
+	},
+}
+
+// cacheGCCmd represents the cache gc command
+var cacheGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove cached files nothing uses",
+	Long: `Remove artifacts no installed package references, in any context, that
+were not used for --older-than, interrupted downloads older than a day and
+scripts extracted by other versions of run.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		olderThan, _ := cmd.Flags().GetDuration("older-than")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		quiet, _ := cmd.Flags().GetBool("quiet")
+		if olderThan < 0 {
+			return fmt.Errorf("--older-than must not be negative")
+		}
+
+		removed, freed, err := internal.CollectGarbage(olderThan, dryRun)
+		if err != nil {
+			return err
+		}
+		if quiet {
+			return nil
+		}
+		verb := "Removed"
+		if dryRun {
+			verb = "Would remove"
+		}
+		for _, path := range removed {
+			fmt.Printf("🧹 %s %s\n", verb, path)
+		}
+		fmt.Printf("✅ %s %d cache file(s), %s\n", verb, len(removed), formatBytes(freed))
+		return nil
+	},
+}
+
+// formatBytes formats a byte count for people.
+func formatBytes(bytes int64) string {
+	switch {
+	case bytes >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(bytes)/(1<<30))
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(bytes)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", bytes)
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheStatsCmd)
+	cacheCmd.AddCommand(cacheGCCmd)
+
+	cacheGCCmd.Flags().Duration("older-than", internal.DefaultCacheRetention, "keep unreferenced artifacts used more recently than this")
+	cacheGCCmd.Flags().Bool("dry-run", false, "list what would be removed without removing it")
+	cacheGCCmd.Flags().BoolP("quiet", "q", false, "do not print removed entries")
+}
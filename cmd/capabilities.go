@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// capabilitiesCmd represents the capabilities command
+var capabilitiesCmd = &cobra.Command{
+	Use:   "capabilities",
+	Short: "Print what each package supports",
+	Long: `Print a matrix of every package with the versions it can install, the
+Ubuntu releases and architectures its scripts support, whether it can be
+installed into a user's home, the services it runs and whether it can be
+removed.
+
+The matrix is generated from the registry (versions, releases,
+architectures, services and backends of each package), so documentation
+and dashboards built from it stay in step with the code.
+
+Formats: markdown, json
+
+Examples:
+  run capabilities
+  run capabilities --format json --out capabilities.json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		out, _ := cmd.Flags().GetString("out")
+
+		data, err := internal.RenderCapabilities(format, internal.Capabilities())
+		if err != nil {
+			return err
+		}
+
+		if out == "" {
+			fmt.Print(string(data))
+			return nil
+		}
+		if err := os.WriteFile(out, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", out, err)
+		}
+		fmt.Printf("✅ Capability matrix written to %s\n", out)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(capabilitiesCmd)
+	capabilitiesCmd.Flags().StringP("format", "f", "markdown", "matrix format: markdown or json")
+	capabilitiesCmd.Flags().StringP("out", "o", "", "write the matrix to a file instead of stdout")
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"github.com/amoga-io/run/internal"
+	"github.com/amoga-io/run/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check [package...]",
+	Short: "Check installed packages",
+	Long: `Verify that packages are installed and correctly set up.
+
+Without arguments every package in the registry is checked, along with the
+apps that have health probes. --system checks the host itself instead
+(registry integrity, hardening).
+
+Installed web services are probed over HTTP(S), nginx at http://127.0.0.1/ by
+default. Configure probes for packages, 'run service' names or pm2 apps in
+~/.run/config.yaml; latency is included with --format json:
+
+  health:
+    probes:
+      api:
+        - url: http://127.0.0.1:8080/healthz
+          status: 200
+
+Containers of projects registered with 'run compose register' must be
+running and healthy.
+
+Files written by 'run generate env' are checked against their template and
+current values, so hand edits and stale values show up.
+
+Examples:
+  run check
+  run check node nginx
+  run check --system`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+
+		var results []output.PackageResult
+		if system, _ := cmd.Flags().GetBool("system"); system {
+			for _, check := range internal.SystemChecks {
+				results = append(results, checkPackageResult(check.Name, check.Check()))
+			}
+			return renderResults(format, results)
+		}
+
+		packages := args
+		if len(packages) == 0 {
+			packages = append(internal.ListPackages(), internal.HealthProbeApps()...)
+		}
+
+		for _, packageName := range packages {
+			checks, err := internal.CheckPackage(packageName)
+			if err != nil {
+				return err
+			}
+			results = append(results, checkPackageResult(packageName, checks))
+		}
+		if len(args) == 0 {
+			for _, name := range internal.ListComposeProjects() {
+				results = append(results, checkPackageResult("compose:"+name, internal.CheckCompose(name)))
+			}
+			if generated := internal.CheckGenerated(); len(generated) > 0 {
+				results = append(results, checkPackageResult("generated files", generated))
+			}
+		}
+		return renderResults(format, results)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+	checkCmd.Flags().Bool("system", false, "check the host instead of packages")
+	addFormatFlag(checkCmd)
+}
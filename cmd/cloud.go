@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// cloudCmd represents the cloud command
+var cloudCmd = &cobra.Command{
+	Use:   "cloud",
+	Short: "Show the detected cloud provider and its profile",
+	Long: `Show the cloud provider of this host and the profile package scripts get.
+
+The provider is detected from the VM's DMI vendor or metadata endpoint
+(Azure, AWS or GCP), or set with cloud.provider in ~/.run/config.yaml.
+Profiles set the app user (azureuser on Azure, ubuntu on AWS) and the time
+servers, and can be overridden under cloud.profiles.
+
+Examples:
+  run cloud`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := internal.LoadConfig()
+		if err != nil {
+			return err
+		}
+		provider, profile, err := internal.ActiveCloudProfile(config)
+		if err != nil {
+			return err
+		}
+
+		if provider == "" {
+			provider = "none"
+		}
+		ntpServers := strings.Join(profile.NTPServers, " ")
+		if ntpServers == "" {
+			ntpServers = "distribution default"
+		}
+		fmt.Printf("Provider:    %s\n", provider)
+		fmt.Printf("App user:    %s\n", profile.AppUser())
+		fmt.Printf("NTP servers: %s\n", ntpServers)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cloudCmd)
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/amoga-io/run/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// composeCmd represents the compose command
+var composeCmd = &cobra.Command{
+	Use:   "compose",
+	Short: "Manage Docker Compose projects as boot-time services",
+	Long: `Register Docker Compose projects so a systemd unit brings them up at
+boot, and manage them by name. The containers of registered projects are
+included in 'run check', failing when a container is stopped or its
+healthcheck is unhealthy.
+
+Examples:
+  run compose register shop --file /srv/shop/docker-compose.yml
+  run compose up shop
+  run compose status shop
+  run compose down shop
+  run compose remove shop`,
+}
+
+// composeRegisterCmd represents the compose register command
+var composeRegisterCmd = &cobra.Command{
+	Use:   "register <name>",
+	Short: "Validate a compose file and bring it up at boot",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, _ := cmd.Flags().GetString("file")
+		if err := internal.RegisterCompose(args[0], file); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Compose project %s registered and enabled at boot. Start it now with: run compose up %s\n", args[0], args[0])
+		return nil
+	},
+}
+
+// composeUpCmd represents the compose up command
+var composeUpCmd = &cobra.Command{
+	Use:   "up <name>",
+	Short: "Bring a registered project up",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := internal.ComposeUp(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Compose project %s is up\n", args[0])
+		return nil
+	},
+}
+
+// composeDownCmd represents the compose down command
+var composeDownCmd = &cobra.Command{
+	Use:   "down <name>",
+	Short: "Bring a registered project down until the next boot",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := internal.ComposeDown(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Compose project %s is down\n", args[0])
+		return nil
+	},
+}
+
+// composeStatusCmd represents the compose status command
+var composeStatusCmd = &cobra.Command{
+	Use:   "status <name>",
+	Short: "Show the containers of a registered project",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+		return renderResults(format, []output.PackageResult{checkPackageResult("compose:"+args[0], internal.CheckCompose(args[0]))})
+	},
+}
+
+// composeRemoveCmd represents the compose remove command
+var composeRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Bring a project down and stop managing it",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !internal.Confirm(fmt.Sprintf("Bring down and unregister compose project %s?", args[0])) {
+			return fmt.Errorf("removal cancelled")
+		}
+		if err := internal.RemoveCompose(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Compose project %s removed\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(composeCmd)
+	composeCmd.AddCommand(composeRegisterCmd)
+	composeCmd.AddCommand(composeUpCmd)
+	composeCmd.AddCommand(composeDownCmd)
+	composeCmd.AddCommand(composeStatusCmd)
+	composeCmd.AddCommand(composeRemoveCmd)
+
+	composeRegisterCmd.Flags().String("file", "docker-compose.yml", "compose file of the project")
+	addFormatFlag(composeStatusCmd)
+}
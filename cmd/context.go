@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// contextCmd represents the context command
+var contextCmd = &cobra.Command{
+	Use:   "context",
+	Short: "Keep separate run configs and states on one host",
+	Long: `Named contexts, like kubectl's, let one host keep several separate run
+configs and states, for example per-project toolchains under their own
+update.prefix and users. Each context has its own config.yaml, state,
+profile baseline, secrets, local packages, env file and shims; scripts, the
+registry, logs and caches are shared. The policy is the host's: every
+context uses the policy of ~/.run/config.yaml, whatever its own config says.
+
+The default context keeps its state in ~/.run as before; others live in
+~/.run/contexts/<name>. --context or ` + internal.ContextEnv + ` selects one for a
+single command or shell, 'run context use' for all later commands.
+
+Only the default context's env file is sourced from ~/.profile; load another
+one in a shell with: . ~/.run/contexts/<name>/env
+
+Examples:
+  run context create shop
+  run context use shop
+  run --context shop profile apply web
+  run context list`,
+}
+
+// contextCreateCmd represents the context create command
+var contextCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create an empty context",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := internal.CreateContext(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Created context '%s' in %s\n", args[0], dir)
+		fmt.Printf("Switch to it with: run context use %s\n", args[0])
+		return nil
+	},
+}
+
+// contextUseCmd represents the context use command
+var contextUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Make a context the active one",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := internal.UseContext(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Switched to context '%s'\n", args[0])
+		if args[0] != internal.DefaultContext {
+			fmt.Printf("Load its environment with: . ~/.run/contexts/%s/env\n", args[0])
+		}
+		return nil
+	},
+}
+
+// contextListCmd represents the context list command
+var contextListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the contexts, marking the active one",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		names, err := internal.ContextNames()
+		if err != nil {
+			return err
+		}
+		current := internal.CurrentContext()
+		for _, name := range names {
+			marker := " "
+			if name == current {
+				marker = "*"
+			}
+			fmt.Printf("%s %s\n", marker, name)
+		}
+		return nil
+	},
+}
+
+func init() {
+	contextCmd.AddCommand(contextCreateCmd)
+	contextCmd.AddCommand(contextUseCmd)
+	contextCmd.AddCommand(contextListCmd)
+	rootCmd.AddCommand(contextCmd)
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// deployCmd represents the deploy command
+var deployCmd = &cobra.Command{
+	Use:   "deploy",
+	Short: "Deploy applications",
+}
+
+// deployGitCmd represents the deploy git command
+var deployGitCmd = &cobra.Command{
+	Use:   "git <repository>",
+	Short: "Deploy an app from a git repository",
+	Long: `Clone a repository into a directory, or fast-forward an existing checkout,
+as the app user; then run the build command and restart the app.
+
+The checkout belongs to --user, by default the app user of the cloud
+profile. --restart takes pm2:<app> for a pm2 app or the name of a systemd
+service, such as one created with 'run service create'.
+
+Examples:
+  run deploy git git@github.com:acme/api.git --dir /srv/api --build "npm ci" --restart pm2:api
+  run deploy git https://github.com/acme/shop.git --dir /srv/shop --branch release --user deploy --build "composer install --no-dev" --restart shop`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		deployment := internal.GitDeployment{Repo: args[0]}
+		deployment.Dir, _ = cmd.Flags().GetString("dir")
+		deployment.Branch, _ = cmd.Flags().GetString("branch")
+		deployment.User, _ = cmd.Flags().GetString("user")
+		deployment.Build, _ = cmd.Flags().GetString("build")
+		deployment.Restart, _ = cmd.Flags().GetString("restart")
+
+		commit, err := internal.DeployGit(deployment)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Deployed %s at %s to %s\n", args[0], commit, deployment.Dir)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(deployCmd)
+	deployCmd.AddCommand(deployGitCmd)
+	deployGitCmd.Flags().String("dir", "", "directory to deploy to (required)")
+	deployGitCmd.Flags().String("branch", "", "branch to deploy (default: the repository's default branch)")
+	deployGitCmd.Flags().String("user", "", "user owning the checkout (default: the app user of the cloud profile)")
+	deployGitCmd.Flags().String("build", "", "build command run in the checkout, e.g. \"npm ci\"")
+	deployGitCmd.Flags().String("restart", "", "app to restart: pm2:<app> or a systemd service")
+	deployGitCmd.MarkFlagRequired("dir")
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// depsCmd represents the deps command
+var depsCmd = &cobra.Command{
+	Use:   "deps [package...]",
+	Short: "Show the dependency tree of packages",
+	Long: `Show the packages each package depends on, as declared with 'depends'
+in the registry. Without arguments every package is shown.
+
+--graph prints the graph as Graphviz DOT or a Mermaid flowchart instead, for
+rendering in documentation and reviews.
+
+Examples:
+  run deps pm2
+  run deps --graph dot | dot -Tsvg > deps.svg
+  run deps pm2 nginx --graph mermaid`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		graph := internal.NewDependencyGraph()
+		packages := args
+		if len(packages) == 0 {
+			packages = graph.Packages()
+		}
+
+		if format, _ := cmd.Flags().GetString("graph"); format != "" {
+			output, err := graph.RenderGraph(format, packages)
+			if err != nil {
+				return err
+			}
+			fmt.Print(output)
+			return nil
+		}
+
+		for _, packageName := range packages {
+			tree, err := graph.GetDependencyTree(packageName)
+			if err != nil {
+				return err
+			}
+			fmt.Print(tree.Render())
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(depsCmd)
+	depsCmd.Flags().String("graph", "", "print the graph as dot or mermaid")
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/amoga-io/run/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose run and the host",
+	Long: `Run every diagnostic: registry integrity (unreachable scripts, tables
+referring to unknown packages), script lint findings (see 'run validate'),
+host checks and the managed environment.
+
+--fix repairs failing host checks where it can, such as enabling
+systemd-timesyncd for an unsynchronized clock, generating the locale set
+as system.locale in ~/.run/config.yaml or adding a 2G swapfile when memory
+is short, then checks again.
+
+Examples:
+  run doctor
+  run doctor --fix
+  run doctor --scripts-dir ./scripts`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+
+		fix, _ := cmd.Flags().GetBool("fix")
+		var results []output.PackageResult
+		for _, check := range internal.SystemChecks {
+			result := checkPackageResult(check.Name, check.Check())
+			if fix && check.Fix != nil && result.Status == output.StatusFailed {
+				fmt.Fprintf(internal.Console, "🔧 Fixing %s...\n", check.Name)
+				if err := check.Fix(); err != nil {
+					internal.Warn(check.Name, "Could not fix %s: %v", check.Name, err)
+				}
+				result = checkPackageResult(check.Name, check.Check())
+			}
+			results = append(results, result)
+		}
+		results = append(results, checkPackageResult("environment", internal.DiagnoseEnv()))
+		return renderResults(format, results)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	addFormatFlag(doctorCmd)
+	doctorCmd.Flags().Bool("fix", false, "repair failing host checks where possible")
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/amoga-io/run/internal/downloader"
+	"github.com/amoga-io/run/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// downloadCmd represents the download command
+var downloadCmd = &cobra.Command{
+	Use:   "download <url>",
+	Short: "Download an artifact through run's cache",
+	Long: `Download a package artifact, such as a release tarball, into the cache in
+~/.run/cache/artifacts and print its path. Package scripts use it instead
+of curl for large files:
+
+  tarball=$("${RUN_BIN:-run}" download "$URL" --sha256 "$SHA256")
+
+Interrupted downloads resume where they stopped, artifacts with a known
+checksum are verified and reused from the cache, downloads.rate_limit caps
+the speed, HTTPS_PROXY is honoured and downloads.artifact_mirrors are tried
+before the origin:
+
+  downloads:
+    artifact_mirrors:
+      https://github.com/: https://artifacts.internal/github/
+
+With --strict, or RUN_STRICT=1 in package scripts, --sha256 is required.
+Artifacts downloaded by a package script are recorded in the state of the
+package, so 'run cache gc' keeps them while it is installed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		checksum, _ := cmd.Flags().GetString("sha256")
+		outPath, _ := cmd.Flags().GetString("output")
+		if checksum == "" && (internal.Strict || os.Getenv(internal.StrictEnv) == "1") {
+			return fmt.Errorf("--sha256 is required in strict mode")
+		}
+
+		d, err := internal.ArtifactDownloader()
+		if err != nil {
+			return err
+		}
+		progress := output.NewProgress(os.Stderr)
+		path, err := d.Fetch(downloader.Request{
+			URL:    args[0],
+			SHA256: checksum,
+			Progress: func(done, total int64) {
+				if total > 0 {
+					progress.Update(float64(done)*100/float64(total), "Downloading "+args[0])
+				}
+			},
+		})
+		progress.Clear()
+		if err != nil {
+			return err
+		}
+		// The installing package keeps the artifact from `run cache gc`
+		if packageName := os.Getenv(internal.PackageEnv); packageName != "" {
+			if err := internal.RecordArtifact(packageName, path, args[0]); err != nil {
+				internal.Warn(packageName, "Artifact not recorded, run cache gc may remove it: %v", err)
+			}
+		}
+		if outPath != "" {
+			if err := copyArtifact(path, outPath); err != nil {
+				return err
+			}
+			path = outPath
+		}
+		fmt.Println(path)
+		return nil
+	},
+}
+
+// copyArtifact copies a cached artifact, leaving the cache intact for the
+// next install.
+func copyArtifact(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to write %s: %v", dst, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", dst, err)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(downloadCmd)
+
+	downloadCmd.Flags().String("sha256", "", "expected SHA-256 checksum of the artifact")
+	downloadCmd.Flags().StringP("output", "o", "", "copy the artifact here instead of printing its cached path")
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/amoga-io/run/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// envCmd represents the env command
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Show the environment managed by run",
+	Long: `Show the shell environment run maintains in ~/.run/env.
+
+The file holds toolchain variables such as JAVA_HOME and extra PATH entries,
+and is sourced from ~/.profile.
+
+Examples:
+  run env
+  run env doctor
+  run env doctor --fix`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		env, err := internal.LoadManagedEnv()
+		if err != nil {
+			return err
+		}
+		fmt.Print(env.Render())
+		return nil
+	},
+}
+
+// envDoctorCmd represents the env doctor command
+var envDoctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Verify the managed environment matches the installed tools",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+
+		if fix, _ := cmd.Flags().GetBool("fix"); fix {
+			if err := internal.UpdateJavaHome(); err != nil {
+				fmt.Fprintf(internal.Console, "⚠️  Could not update JAVA_HOME: %v\n", err)
+			}
+		}
+
+		return renderResults(format, []output.PackageResult{checkPackageResult("environment", internal.DiagnoseEnv())})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(envCmd)
+	envCmd.AddCommand(envDoctorCmd)
+	addFormatFlag(envDoctorCmd)
+	envDoctorCmd.Flags().Bool("fix", false, "recompute JAVA_HOME from the active JDK before checking")
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// execCmd represents the exec command
+var execCmd = &cobra.Command{
+	Use:   "exec --with <package>@<version> -- <command> [args...]",
+	Short: "Run a command with specific runtime versions",
+	Long: `Run a command with PATH pointed at installed versions of java, node,
+php or python, without changing the system-wide default ('run use').
+
+Versions installed with nvm, pyenv or sdkman are used when present,
+otherwise the system install of that version. java also sets JAVA_HOME.
+The command's exit code is passed through.
+
+Examples:
+  run exec --with node@20 -- npm test
+  run exec --with java@17 --with node@18 -- ./gradlew build
+  run exec --with php@8.2 -- php artisan test`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		specs, _ := cmd.Flags().GetStringSlice("with")
+		var runtimes []internal.Runtime
+		for _, spec := range specs {
+			runtime, err := internal.ParseRuntime(spec)
+			if err != nil {
+				return err
+			}
+			runtimes = append(runtimes, runtime)
+		}
+		if err := internal.ApplyRuntimes(runtimes); err != nil {
+			return err
+		}
+
+		child := exec.Command(args[0], args[1:]...)
+		child.Stdin, child.Stdout, child.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := child.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				os.Exit(exitErr.ExitCode())
+			}
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+	execCmd.Flags().StringSlice("with", nil, "runtime to use, as <package>@<version> (repeatable)")
+	// Flags after the command belong to it
+	execCmd.Flags().SetInterspersed(false)
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// generateCmd represents the generate command
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate application config and release files",
+}
+
+// generateEnvCmd represents the generate env command
+var generateEnvCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Render a .env file from a template",
+	Long: `Render a text/template into a .env or other config file of an app, with
+values from vars in ~/.run/config.yaml and secrets from the secrets store
+(see 'run secret'):
+
+  DB_USER={{ var "db_user" }}
+  DB_PASSWORD={{ secret "db_password" }}
+  LOG_DIR={{ env "HOME" }}/logs
+
+A missing var or secret is an error. The file is written with mode 0600 and
+remembered, so 'run check' reports when it no longer matches its template.
+
+Examples:
+  run generate env --template app.env.tmpl --out /srv/app/.env
+  run generate env --template app.env.tmpl --out /srv/app/.env --owner deploy`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		templatePath, _ := cmd.Flags().GetString("template")
+		outPath, _ := cmd.Flags().GetString("out")
+		owner, _ := cmd.Flags().GetString("owner")
+		if err := internal.GenerateFile(templatePath, outPath, owner); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Generated %s from %s\n", outPath, templatePath)
+		return nil
+	},
+}
+
+// generatePackagingCmd represents the generate packaging command
+var generatePackagingCmd = &cobra.Command{
+	Use:   "packaging",
+	Short: "Render the Homebrew formula of a release",
+	Long: `Render the Homebrew formula of a release for the tap, from the checksums
+goreleaser writes. .goreleaser.yaml builds the release archives and the .deb
+and .rpm packages:
+
+  goreleaser release --clean
+  run generate packaging --version 1.2.0 --checksums dist/checksums.txt --out dist
+
+Copy dist/run.rb to Formula/run.rb in the tap repository.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		version, _ := cmd.Flags().GetString("version")
+		checksumsPath, _ := cmd.Flags().GetString("checksums")
+		outDir, _ := cmd.Flags().GetString("out")
+
+		checksums, err := internal.ReadChecksums(checksumsPath)
+		if err != nil {
+			return err
+		}
+		formula, err := internal.HomebrewFormula(version, checksums)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(outDir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %v", outDir, err)
+		}
+		path := filepath.Join(outDir, "run.rb")
+		if err := os.WriteFile(path, []byte(formula), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", path, err)
+		}
+		fmt.Printf("✅ Wrote %s\n", path)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(generateCmd)
+	generateCmd.AddCommand(generateEnvCmd)
+	generateEnvCmd.Flags().String("template", "", "template to render (required)")
+	generateEnvCmd.Flags().String("out", "", "file to write (required)")
+	generateEnvCmd.Flags().String("owner", "", "user to own the file, written through sudo")
+	generateEnvCmd.MarkFlagRequired("template")
+	generateEnvCmd.MarkFlagRequired("out")
+
+	generateCmd.AddCommand(generatePackagingCmd)
+	generatePackagingCmd.Flags().String("version", "", "released version, e.g. 1.2.0 (required)")
+	generatePackagingCmd.Flags().String("checksums", "dist/checksums.txt", "checksums.txt of the release")
+	generatePackagingCmd.Flags().String("out", "dist", "directory to write run.rb to")
+	generatePackagingCmd.MarkFlagRequired("version")
+}
@@ -2,8 +2,12 @@ package cmd
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/amoga-io/run/internal"
+	"github.com/amoga-io/run/internal/output"
 	"github.com/spf13/cobra"
 )
 
@@ -11,54 +15,376 @@ import (
 var installCmd = &cobra.Command{
 	Use:   "install",
 	Short: "Install a package",
-	Long:  `Install a package in your specific method.`,
-	Args:  cobra.MinimumNArgs(0),
-	Run: func(cmd *cobra.Command, args []string) {
-		// Check --all flag first
-		if allFlag, _ := cmd.Flags().GetBool("all"); allFlag {
-			fmt.Println("Installing all packages...")
-			for packageName := range internal.InstallPackageRegistry {
-				fmt.Printf("Installing package: %s\n", packageName)
-				if err := internal.GetScriptAndExecute("install", packageName); err != nil {
-					fmt.Printf("Error installing package '%s': %v\n", packageName, err)
-				} else {
-					fmt.Printf("Successfully installed package: %s\n", packageName)
-				}
+	Long: `Install a package in your specific method.
+
+Registry dependencies ('run deps <package>') are installed first, in the
+order 'run plan' shows; those already installed unchanged are skipped.
+
+Java options:
+  --vendor selects the JDK distribution: openjdk (default), temurin or corretto
+
+Backends:
+  --backend chooses how a package with several install methods is installed;
+  'run list --backends' shows them. For node: nodesource (default, one
+  global major version), nvm (per user, exact versions side by side) or apt
+  (the Ubuntu release's version). node.backend in ~/.run/config.yaml sets it
+  permanently.
+
+Local packages:
+  --from-file installs a .deb, or a tarball with a run.yaml manifest:
+    name: mytool
+    version: 1.2.0
+    install: install.sh     # run from the extracted tarball
+    remove: remove.sh       # kept for 'run remove mytool'
+    check: mytool --version # must succeed, or the install is undone
+
+Strict mode:
+  --strict, for CI and golden images, fails on any warning or skipped step,
+  installs the registry's default version of packages not pinned by a
+  profile, requires a <file>.sha256 next to --from-file packages and makes
+  scripts fail rather than fall back (RUN_STRICT=1).
+
+Examples:
+  run install node nginx
+  run install java --vendor temurin
+  run install node --backend nvm
+  run install node nginx --log-group
+  run install nvidia --reboot-if-required --delay 5m
+  run install --from-file ./custom.deb`,
+	Args: cobra.MinimumNArgs(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+		if err := applyScriptOutput(cmd); err != nil {
+			return err
+		}
+		if err := checkRebootFlags(cmd); err != nil {
+			return err
+		}
+
+		vendor, _ := cmd.Flags().GetString("vendor")
+		if err := checkVendor(vendor); err != nil {
+			return err
+		}
+		options := installOptions{JavaVendor: vendor}
+		options.Backend, _ = cmd.Flags().GetString("backend")
+		if options.Backend != "" {
+			if err := checkBackend(args, options.Backend); err != nil {
+				return err
 			}
-			return
 		}
 
-		// No args provided and --all flag not set
-		if len(args) == 0 {
+		stopSudo, err := internal.PrepareSudo()
+		if err != nil {
+			return err
+		}
+		defer stopSudo()
+		resumeUpgrades, err := holdAptLock(cmd)
+		if err != nil {
+			return err
+		}
+		defer resumeUpgrades()
+
+		if err := internal.ApplyDownloadSettings(); err != nil {
+			internal.Warn("", "Download settings not applied: %v", err)
+		}
+
+		started := time.Now()
+		var results []output.PackageResult
+		if files, _ := cmd.Flags().GetStringSlice("from-file"); len(files) > 0 {
+			results = installLocalPackages(files)
+		} else if allFlag, _ := cmd.Flags().GetBool("all"); allFlag {
+			fmt.Fprintln(internal.Console, "Installing all packages...")
+			results = installPackages(internal.ListPackages(), options)
+		} else if len(args) == 0 {
+			// No args provided and --all flag not set
 			fmt.Println("Please specify a package to install or use --all flag to install all packages.")
-			return
+			return nil
+		} else {
+			results = installPackages(args, options)
 		}
 
-		// Multiple packages provided
-		if len(args) > 1 {
-			for _, packageName := range args {
-				fmt.Printf("Installing package: %s\n", packageName)
-				if err := internal.GetScriptAndExecute("install", packageName); err != nil {
-					fmt.Printf("Error installing package '%s': %v\n", packageName, err)
-				} else {
-					fmt.Printf("Successfully installed package: %s\n", packageName)
+		if noSuggestions, _ := cmd.Flags().GetBool("no-suggestions"); !noSuggestions {
+			showSuggestions(results)
+		}
+		results = attachWarnings(appendReboot(cmd, results))
+
+		if reportPath, _ := cmd.Flags().GetString("report"); reportPath != "" {
+			if err := writeReport(reportPath, started, results); err != nil {
+				internal.Warn("", "%v", err)
+			} else {
+				fmt.Fprintf(internal.Console, "📄 Report written to %s\n", reportPath)
+			}
+		}
+		return renderResults(format, results)
+	},
+}
+
+// checkVendor makes sure a --vendor names a known JDK distribution.
+func checkVendor(vendor string) error {
+	if _, exists := internal.JavaVendors[vendor]; vendor == "" || exists {
+		return nil
+	}
+	var vendors []string
+	for name := range internal.JavaVendors {
+		vendors = append(vendors, name)
+	}
+	sort.Strings(vendors)
+	return fmt.Errorf("unknown Java vendor '%s'. Available vendors: %s", vendor, strings.Join(vendors, ", "))
+}
+
+// checkBackend makes sure a --backend applies to the packages installed:
+// each of them with backends must offer it, and at least one must have them.
+func checkBackend(packages []string, backend string) error {
+	applies := false
+	for _, packageName := range packages {
+		if len(internal.PackageBackends[packageName]) == 0 {
+			continue
+		}
+		if _, err := internal.BackendScriptEnv(packageName, backend); err != nil {
+			return err
+		}
+		applies = true
+	}
+	if !applies {
+		return fmt.Errorf("--backend only applies to packages with install backends (%s)", strings.Join(internal.ListBackendPackages(), ", "))
+	}
+	return nil
+}
+
+// installOptions holds package-specific install settings from flags.
+type installOptions struct {
+	JavaVendor string
+	// Backend overrides the install backend of packages that have several.
+	Backend string
+	// Versions pin package versions, passed to scripts as <PACKAGE>_VERSION.
+	Versions map[string]string
+	// Converge skips packages whose step hash is unchanged since they were
+	// last installed this way, and records the hash of the others.
+	Converge bool
+	// Settings, from a plan, replace the other options with the exact
+	// environment planned for each package.
+	Settings map[string][]string
+}
+
+// scriptEnv returns the environment passed to a package's install script.
+func (o installOptions) scriptEnv(packageName string) []string {
+	if o.Settings != nil {
+		return o.Settings[packageName]
+	}
+	var env []string
+	if packageName == "java" && o.JavaVendor != "" {
+		env = append(env, "JAVA_VENDOR="+o.JavaVendor)
+	}
+	if o.Backend != "" && len(internal.PackageBackends[packageName]) > 0 {
+		env = append(env, strings.ToUpper(packageName)+"_BACKEND="+o.Backend)
+	}
+	version, exists := o.Versions[packageName]
+	if supported := internal.PackageSupport[packageName].Versions; !exists && internal.Strict && len(supported) > 0 {
+		// The registry's default, rather than whatever the script picks
+		version, exists = supported[0], true
+	}
+	if exists {
+		env = append(env, strings.ToUpper(packageName)+"_VERSION="+version)
+	}
+	return env
+}
+
+// installPackages runs the install script and install hooks of each package,
+// after its registry dependencies, in the order `run plan` resolves.
+// Dependencies whose step is unchanged are skipped, as a plan skips them.
+func installPackages(packages []string, options installOptions) []output.PackageResult {
+	order, dependencies, results := resolveInstallOrder(packages, options)
+	allowed, skipped := filterByPolicy("install", order)
+	results = append(results, skipped...)
+	var state *internal.State
+	if options.Converge || len(dependencies) > 0 {
+		var err error
+		if state, err = internal.LoadState(); err != nil {
+			internal.Warn("", "Applying every step: %v", err)
+			options.Converge, dependencies = false, nil
+		}
+	}
+	for _, packageName := range allowed {
+		started := time.Now()
+		result := output.PackageResult{Package: packageName, Operation: "install", Status: output.StatusFailed}
+		converge := options.Converge || dependencies[packageName]
+
+		env, err := internal.PackageScriptEnv(packageName)
+		if err == nil {
+			env = append(env, options.scriptEnv(packageName)...)
+		}
+		if err == nil && converge {
+			if hash, hashErr := internal.StepHash(packageName, env); hashErr == nil && state.StepUnchanged(packageName, hash) {
+				result.Status, result.Message = output.StatusSkipped, noChanges
+				results = append(results, result)
+				continue
+			}
+		}
+
+		fmt.Fprintf(internal.Console, "Installing package: %s\n", packageName)
+		if err == nil {
+			if err = internal.RunPreInstallHook(packageName); err != nil {
+				err = fmt.Errorf("failed to prepare install: %v", err)
+			} else {
+				err = internal.GetScriptAndExecute("install", packageName, env...)
+			}
+			if err == nil {
+				if err = internal.RunPostInstallHook(packageName); err != nil {
+					err = fmt.Errorf("failed to finish setup: %v", err)
+				} else if err = internal.ApplyPackageSysctls(packageName); err != nil {
+					err = fmt.Errorf("failed to set kernel parameters: %v", err)
 				}
 			}
-			return
 		}
 
-		// Install single package
-		packageName := args[0]
-		fmt.Printf("Installing package: %s\n", packageName)
-		if err := internal.GetScriptAndExecute("install", packageName); err != nil {
-			fmt.Printf("Error installing package '%s': %v\n", packageName, err)
+		result.Duration = time.Since(started)
+		if err != nil {
+			result.Message = err.Error()
 		} else {
-			fmt.Printf("Successfully installed package: %s\n", packageName)
+			result.Status, result.Message = output.StatusOK, "installed"
+			result.Version = internal.PackageVersion(packageName)
+			result.NextSteps = internal.PackageNextSteps[packageName]
+			if err := internal.ForwardPackageLogs(packageName); err != nil {
+				internal.Warn(packageName, "Log forwarding not updated: %v", err)
+			}
+			if err := internal.RecordVersions(packageName); err != nil {
+				internal.Warn(packageName, "Installed versions not recorded: %v", err)
+			}
+			if err := internal.RecordPackageFiles(packageName); err != nil {
+				internal.Warn(packageName, "Files not recorded for run verify: %v", err)
+			}
+			if err := internal.PruneArtifactRefs(packageName, started); err != nil {
+				internal.Warn(packageName, "Unused artifacts not released: %v", err)
+			}
+			if converge {
+				if err := recordStep(packageName, env); err != nil {
+					internal.Warn(packageName, "Step not recorded, it will run again: %v", err)
+				}
+			}
 		}
-	},
+		results = append(results, result)
+	}
+	recordHistory(results)
+	return results
+}
+
+// resolveInstallOrder puts the registry dependencies of packages before
+// them and returns which packages are only dependencies. A package whose
+// dependencies cannot be resolved fails on its own. The packages of a plan
+// are already resolved and kept as they are.
+func resolveInstallOrder(packages []string, options installOptions) ([]string, map[string]bool, []output.PackageResult) {
+	if options.Settings != nil {
+		return packages, nil, nil
+	}
+	requested := make(map[string]bool)
+	for _, packageName := range packages {
+		requested[packageName] = true
+	}
+	graph := internal.NewDependencyGraph()
+	dependencies := make(map[string]bool)
+	seen := make(map[string]bool)
+	var order []string
+	var results []output.PackageResult
+	for _, packageName := range packages {
+		resolved, err := graph.ResolveOrder([]string{packageName})
+		if err != nil {
+			results = append(results, output.PackageResult{Package: packageName, Operation: "install", Status: output.StatusFailed, Message: err.Error()})
+			continue
+		}
+		for _, name := range resolved {
+			if !seen[name] {
+				seen[name] = true
+				order = append(order, name)
+			}
+			if !requested[name] {
+				dependencies[name] = true
+			}
+		}
+	}
+	return order, dependencies, results
+}
+
+// noChanges is the message of packages skipped because their step is
+// unchanged.
+const noChanges = "no changes"
+
+// recordStep stores the step hash of a package just installed.
+func recordStep(packageName string, env []string) error {
+	hash, err := internal.StepHash(packageName, env)
+	if err != nil {
+		return err
+	}
+	return internal.RecordStep(packageName, hash)
+}
+
+// installLocalPackages installs packages from local .deb files or tarballs.
+// The package names are checked against the policy first, like registry
+// packages.
+func installLocalPackages(files []string) []output.PackageResult {
+	var results []output.PackageResult
+	for _, file := range files {
+		started := time.Now()
+		result := output.PackageResult{Package: file, Operation: "install", Status: output.StatusOK, Message: "installed"}
+		packageName, err := internal.LocalPackageName(file)
+		if err != nil {
+			result.Status, result.Message, result.Duration = output.StatusFailed, err.Error(), time.Since(started)
+			results = append(results, result)
+			continue
+		}
+		if allowed, skipped := filterByPolicy("install", []string{packageName}); len(allowed) == 0 {
+			results = append(results, skipped...)
+			continue
+		}
+
+		fmt.Fprintf(internal.Console, "Installing package from: %s\n", file)
+		name, err := internal.InstallLocalPackage(file)
+		if err != nil {
+			result.Status, result.Message = output.StatusFailed, err.Error()
+		} else {
+			result.Package = name
+		}
+		result.Duration = time.Since(started)
+		results = append(results, result)
+	}
+	recordHistory(results)
+	return results
+}
+
+// showSuggestions offers packages that go well with the ones just installed.
+// Each tip is shown once.
+func showSuggestions(results []output.PackageResult) {
+	var installed []string
+	for _, result := range results {
+		if result.Status == output.StatusOK {
+			installed = append(installed, result.Package)
+		}
+	}
+
+	suggestions, err := internal.Suggestions(installed)
+	if err != nil || len(suggestions) == 0 {
+		return
+	}
+	for _, suggestion := range suggestions {
+		fmt.Fprintf(internal.Console, "💡 %s is often used with %s: run install %s\n", suggestion.Suggested, suggestion.Package, suggestion.Suggested)
+	}
+	if err := internal.DismissSuggestions(suggestions); err != nil {
+		internal.Warn("", "%v", err)
+	}
 }
 
 func init() {
 	rootCmd.AddCommand(installCmd)
 	installCmd.Flags().BoolP("all", "a", false, "install all packages")
+	installCmd.Flags().StringSlice("from-file", nil, "install a local .deb or tarball with a run.yaml manifest")
+	installCmd.Flags().String("vendor", "", "JDK distribution for java: openjdk, temurin or corretto")
+	installCmd.Flags().String("backend", "", "install method for packages with several, e.g. nvm for node")
+	addFormatFlag(installCmd)
+	addScriptOutputFlags(installCmd)
+	addRebootFlags(installCmd)
+	addAptLockFlag(installCmd)
+	installCmd.Flags().Bool("no-suggestions", false, "do not suggest related packages")
+	installCmd.Flags().String("report", "", "write a provisioning report to a .md or .html file")
 }
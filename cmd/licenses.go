@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// licensesCmd represents the licenses command
+var licensesCmd = &cobra.Command{
+	Use:   "licenses",
+	Short: "Summarize licenses of managed packages",
+	Long: `Summarize the licenses of apt packages installed by run-managed packages,
+read from /usr/share/doc/<package>/copyright, together with the repository
+each package came from.
+
+Formats: table, csv, json
+
+Examples:
+  run licenses
+  run licenses --format csv --out licenses.csv`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		out, _ := cmd.Flags().GetString("out")
+
+		data, err := internal.RenderLicenses(format, internal.CollectLicenses())
+		if err != nil {
+			return err
+		}
+
+		if out == "" {
+			fmt.Print(string(data))
+			return nil
+		}
+		if err := os.WriteFile(out, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", out, err)
+		}
+		fmt.Printf("✅ License report written to %s\n", out)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(licensesCmd)
+	licensesCmd.Flags().StringP("format", "f", "table", "report format: table, csv or json")
+	licensesCmd.Flags().StringP("out", "o", "", "write the report to a file instead of stdout")
+}
@@ -5,6 +5,7 @@ package cmd
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/amoga-io/run/internal"
 	"github.com/spf13/cobra"
@@ -14,16 +15,45 @@ import (
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all available packages",
-	Long:  `List all available packages that can be installed using run.`,
+	Long: `List all available packages that can be installed using run.
+
+--backends lists the install backends of packages that offer a choice
+('run install --backend'), with their scope and version granularity.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if backends, _ := cmd.Flags().GetBool("backends"); backends {
+			listBackends()
+			return
+		}
 		for packageName := range internal.InstallPackageRegistry {
 			fmt.Println(packageName)
 		}
 	},
 }
 
+// listBackends prints the install backends of each package that has them.
+func listBackends() {
+	for _, packageName := range internal.ListBackendPackages() {
+		fmt.Printf("%s:\n", packageName)
+		backends := internal.PackageBackends[packageName]
+		var names []string
+		for name := range backends {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			backend := backends[name]
+			marker := ""
+			if backend.Default {
+				marker = " (default)"
+			}
+			fmt.Printf("  %-12s %-7s %-7s %s%s\n", name, backend.Scope, backend.Versions, backend.Description, marker)
+		}
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().Bool("backends", false, "list the install backends of packages")
 
 	// Here you will define your flags and configuration settings.
 
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// cleanCmd represents the clean command
+var cleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Remove leftover artifacts from ~/.run",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		quiet, _ := cmd.Flags().GetBool("quiet")
+
+		removed, err := internal.CleanArtifacts()
+		if err != nil {
+			return err
+		}
+		if quiet {
+			return nil
+		}
+		for _, path := range removed {
+			fmt.Printf("🧹 Removed %s\n", path)
+		}
+		fmt.Printf("✅ Cleaned %d artifact(s)\n", len(removed))
+		return nil
+	},
+}
+
+// logsCmd represents the logs command
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "List or rotate run logs",
+	Long: `List the logs run keeps in ~/.run/logs. Each package script appends
+its output to <package>.log, one timestamped line at a time.
+
+With --rotate, logs idle for a day are compressed and logs older than
+14 days are deleted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if rotate, _ := cmd.Flags().GetBool("rotate"); rotate {
+			compressed, deleted, err := internal.RotateLogs()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("✅ Compressed %d and deleted %d log file(s)\n", compressed, deleted)
+			return nil
+		}
+
+		logsDir, err := internal.LogsDir()
+		if err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(logsDir)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Println("No logs yet")
+			return nil
+		}
+		for _, entry := range entries {
+			fmt.Println(filepath.Join(logsDir, entry.Name()))
+		}
+		return nil
+	},
+}
+
+// maintenanceCmd represents the maintenance command
+var maintenanceCmd = &cobra.Command{
+	Use:   "maintenance",
+	Short: "Manage scheduled maintenance of ~/.run",
+	Long: `Manage a daily systemd timer that runs 'run clean --quiet',
+'run cache gc --quiet' and 'run logs --rotate', keeping ~/.run bounded on
+long-lived servers.
+
+Examples:
+  run maintenance enable
+  run maintenance status
+  run maintenance disable`,
+}
+
+// maintenanceEnableCmd represents the maintenance enable command
+var maintenanceEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Install and start the maintenance timer",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := internal.EnableMaintenance(); err != nil {
+			return err
+		}
+		fmt.Println("✅ Daily maintenance enabled")
+		return nil
+	},
+}
+
+// maintenanceDisableCmd represents the maintenance disable command
+var maintenanceDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Stop and remove the maintenance timer",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := internal.DisableMaintenance(); err != nil {
+			return err
+		}
+		fmt.Println("✅ Daily maintenance disabled")
+		return nil
+	},
+}
+
+// maintenanceStatusCmd represents the maintenance status command
+var maintenanceStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the maintenance timer is enabled",
+	Run: func(cmd *cobra.Command, args []string) {
+		enabled, next := internal.MaintenanceStatus()
+		if !enabled {
+			fmt.Println("Maintenance is disabled. Enable it with: run maintenance enable")
+			return
+		}
+		fmt.Println("Maintenance is enabled")
+		if next != "" {
+			fmt.Printf("Next run: %s\n", next)
+		}
+	},
+}
+
+// logsForwardCmd represents the logs forward command
+var logsForwardCmd = &cobra.Command{
+	Use:   "forward",
+	Short: "Forward the logs of installed services",
+	Long: `Write the log forwarding config selected by logs.forward in
+~/.run/config.yaml, covering the logs of installed packages (nginx,
+postgres) and the services created with 'run service':
+
+  logs:
+    forward: syslog           # syslog, vector or promtail
+    address: logs.internal:514
+
+syslog forwards over TCP through rsyslog. vector and promtail get a config in
+/etc/vector/run.yaml or /etc/promtail/run.yaml to load alongside their own.
+Forwarding is updated automatically when such packages or services are added.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := internal.ApplyLogForwarding()
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			fmt.Println("Log forwarding is off. Set logs.forward in ~/.run/config.yaml to enable it")
+			return nil
+		}
+		fmt.Printf("✅ Log forwarding written to %s\n", path)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cleanCmd)
+	rootCmd.AddCommand(logsCmd)
+	logsCmd.AddCommand(logsForwardCmd)
+	rootCmd.AddCommand(maintenanceCmd)
+	maintenanceCmd.AddCommand(maintenanceEnableCmd)
+	maintenanceCmd.AddCommand(maintenanceDisableCmd)
+	maintenanceCmd.AddCommand(maintenanceStatusCmd)
+
+	cleanCmd.Flags().BoolP("quiet", "q", false, "do not print removed artifacts")
+	logsCmd.Flags().Bool("rotate", false, "compress idle logs and delete old ones")
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// npmGlobalsCmd represents the npm-globals command
+var npmGlobalsCmd = &cobra.Command{
+	Use:   "npm-globals",
+	Short: "Show managed global npm packages",
+	Long: `Show the global npm packages managed by run and whether they are installed.
+
+The list is configured under node.global_packages in ~/.run/config.yaml:
+
+  node:
+    global_packages:
+      - pnpm@9.10.0
+      - yarn
+      - pm2
+
+Examples:
+  run npm-globals
+  run npm-globals sync`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := internal.LoadConfig()
+		if err != nil {
+			return err
+		}
+
+		statuses, err := internal.NpmGlobalsStatus(config)
+		if err != nil {
+			return err
+		}
+
+		for _, status := range statuses {
+			switch {
+			case status.InstalledVersion == "":
+				fmt.Printf("❌ %s: not installed\n", status.Package)
+			case status.UpToDate():
+				fmt.Printf("✅ %s: %s\n", status.Package, status.InstalledVersion)
+			default:
+				fmt.Printf("⚠️  %s: version %s installed\n", status.Package, status.InstalledVersion)
+			}
+		}
+		return nil
+	},
+}
+
+// npmGlobalsSyncCmd represents the npm-globals sync command
+var npmGlobalsSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Install or update the managed global npm packages",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := internal.SyncNpmGlobals(); err != nil {
+			return err
+		}
+		fmt.Println("✅ Global npm packages are in sync")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(npmGlobalsCmd)
+	npmGlobalsCmd.AddCommand(npmGlobalsSyncCmd)
+}
@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/amoga-io/run/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// summaryAnnotations is set by --summary-annotations: results are also
+// reported as GitHub Actions annotations.
+var summaryAnnotations bool
+
+// strictMode is set by --strict: warnings and skipped operations fail the
+// command like errors.
+var strictMode bool
+
+// addFormatFlag adds the --format, --summary-annotations and --strict flags
+// shared by commands that report package results.
+func addFormatFlag(cmd *cobra.Command) {
+	cmd.Flags().String("format", "text", "result format: text, json or markdown")
+	cmd.Flags().Bool("summary-annotations", false, "report results as GitHub Actions annotations and group script output")
+	cmd.Flags().Bool("strict", false, "fail on warnings and skipped steps; refuse unpinned images, unchecked files and script fallbacks")
+}
+
+// outputFormat reads --format and --summary-annotations. For
+// machine-readable formats progress output moves to stderr, so stdout holds
+// only the result.
+func outputFormat(cmd *cobra.Command) (string, error) {
+	format, _ := cmd.Flags().GetString("format")
+	if err := output.ValidateFormat(format); err != nil {
+		return "", err
+	}
+	if format != "text" {
+		internal.Console = os.Stderr
+	}
+	summaryAnnotations, _ = cmd.Flags().GetBool("summary-annotations")
+	strictMode, _ = cmd.Flags().GetBool("strict")
+	internal.Strict = strictMode
+	return format, nil
+}
+
+// addScriptOutputFlags adds the flags that shape script output, shared by
+// commands that run package scripts.
+func addScriptOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("prefix-output", false, "prefix each line of script output with its package name")
+	cmd.Flags().Bool("log-group", false, "print each package's script output as one block once it finishes")
+}
+
+// applyScriptOutput sets how script output is shown from --prefix-output
+// and --log-group, or as GitHub Actions groups under --summary-annotations.
+// It must run after outputFormat.
+func applyScriptOutput(cmd *cobra.Command) error {
+	prefix, _ := cmd.Flags().GetBool("prefix-output")
+	group, _ := cmd.Flags().GetBool("log-group")
+	switch {
+	case prefix && group:
+		return fmt.Errorf("--prefix-output and --log-group cannot be used together")
+	case prefix:
+		internal.ScriptOutputMode = internal.ScriptOutputPrefix
+	case group:
+		internal.ScriptOutputMode = internal.ScriptOutputGroup
+	case summaryAnnotations:
+		internal.ScriptOutputMode = internal.ScriptOutputActions
+	}
+	return nil
+}
+
+// renderResults prints the results and returns an error when any failed,
+// or under --strict when there were warnings or skipped operations. Under --summary-annotations
+// the results are also annotated and, inside a workflow, added to the job
+// summary.
+func renderResults(format string, results []output.PackageResult) error {
+	results = attachWarnings(results)
+	if err := output.Render(os.Stdout, format, results); err != nil {
+		return err
+	}
+	if summaryAnnotations {
+		output.RenderAnnotations(internal.Console, results)
+		if err := appendStepSummary(results); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+		}
+	}
+	if failed := output.Failed(results); failed > 0 {
+		return fmt.Errorf("%d problem(s) found", failed)
+	}
+	if !strictMode {
+		return nil
+	}
+	if warnings := output.Warnings(results); warnings > 0 {
+		return fmt.Errorf("%d warning(s) with --strict", warnings)
+	}
+	if skipped := deviations(results); skipped > 0 {
+		return fmt.Errorf("%d skipped operation(s) with --strict", skipped)
+	}
+	return nil
+}
+
+// deviations counts the operations that were skipped for another reason
+// than being unchanged, such as a policy or a reboot left pending.
+func deviations(results []output.PackageResult) int {
+	skipped := 0
+	for _, result := range results {
+		if result.Status == output.StatusSkipped && result.Message != noChanges {
+			skipped++
+		}
+	}
+	return skipped
+}
+
+// attachWarnings adds the warnings given so far to the results of their
+// packages. Warnings about the host, or a package without a result, are
+// added as a result of the host.
+func attachWarnings(results []output.PackageResult) []output.PackageResult {
+	var host []string
+	for _, warning := range internal.TakeWarnings() {
+		attached := false
+		for i := len(results) - 1; i >= 0 && warning.Package != ""; i-- {
+			if results[i].Package == warning.Package {
+				results[i].Warnings = append(results[i].Warnings, warning.Message)
+				attached = true
+				break
+			}
+		}
+		if !attached {
+			host = append(host, warning.Message)
+		}
+	}
+	if len(host) > 0 {
+		results = append(results, output.PackageResult{
+			Package:   "host",
+			Operation: "warn",
+			Status:    output.StatusOK,
+			Message:   fmt.Sprintf("%d warning(s)", len(host)),
+			Warnings:  host,
+		})
+	}
+	return results
+}
+
+// appendStepSummary adds the results as a markdown table to the job summary
+// file GitHub Actions names in GITHUB_STEP_SUMMARY, if any.
+func appendStepSummary(results []output.PackageResult) error {
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if path == "" {
+		return nil
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open job summary: %v", err)
+	}
+	defer file.Close()
+	return output.Render(file, "markdown", results)
+}
+
+// writeReport writes a provisioning report of a run that started at started.
+func writeReport(path string, started time.Time, results []output.PackageResult) error {
+	host := internal.GetHostInfo()
+	report := output.Report{
+		Command:   strings.Join(append([]string{internal.CLIName}, os.Args[1:]...), " "),
+		Hostname:  host.Hostname,
+		OS:        host.OS,
+		Kernel:    host.Kernel,
+		Arch:      host.Arch,
+		StartedAt: started,
+		Duration:  time.Since(started),
+		Results:   results,
+	}
+	if logsDir, err := internal.LogsDir(); err == nil {
+		if _, err := os.Stat(logsDir); err == nil {
+			report.LogsDir = logsDir
+		}
+	}
+	return output.WriteReport(path, report)
+}
+
+// checkPackageResult turns check results into the result of one package,
+// failed when any check failed.
+func checkPackageResult(name string, results []internal.CheckResult) output.PackageResult {
+	result := output.PackageResult{Package: name, Operation: "check", Status: output.StatusOK}
+	for _, check := range results {
+		result.Details = append(result.Details, output.Detail{Name: check.Name, OK: check.OK, Message: check.Message, LatencyMS: check.Latency.Milliseconds()})
+		if !check.OK {
+			result.Status = output.StatusFailed
+		}
+	}
+	return result
+}
@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// phpCmd represents the php command
+var phpCmd = &cobra.Command{
+	Use:   "php",
+	Short: "Manage PHP extensions and php-fpm pools",
+}
+
+// phpExtCmd represents the php ext command
+var phpExtCmd = &cobra.Command{
+	Use:   "ext",
+	Short: "Manage PHP extensions",
+	Long: `Manage extensions of the active PHP version.
+
+Extensions added with run are remembered and reinstalled automatically
+when switching versions with 'run use php <version>'. Versions built from
+source, when the PPA has none for the Ubuntu release, only have the
+extensions compiled in.
+
+Examples:
+  run php ext add redis
+  run php ext remove redis
+  run php ext list`,
+}
+
+// phpExtAddCmd represents the php ext add command
+var phpExtAddCmd = &cobra.Command{
+	Use:   "add <ext>...",
+	Short: "Install PHP extensions for the active version",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, ext := range args {
+			fmt.Printf("Adding PHP extension: %s\n", ext)
+			if err := internal.AddPHPExtension(ext); err != nil {
+				return err
+			}
+			fmt.Printf("✅ Extension %s is loaded\n", ext)
+		}
+		return nil
+	},
+}
+
+// phpExtRemoveCmd represents the php ext remove command
+var phpExtRemoveCmd = &cobra.Command{
+	Use:   "remove <ext>...",
+	Short: "Remove PHP extensions from the active version",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, ext := range args {
+			fmt.Printf("Removing PHP extension: %s\n", ext)
+			if err := internal.RemovePHPExtension(ext); err != nil {
+				return err
+			}
+			fmt.Printf("✅ Extension %s removed\n", ext)
+		}
+		return nil
+	},
+}
+
+// phpExtListCmd represents the php ext list command
+var phpExtListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List managed PHP extensions",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		version, err := internal.ActivePHPVersion()
+		if err != nil {
+			return err
+		}
+		state, err := internal.LoadState()
+		if err != nil {
+			return err
+		}
+		modules, err := internal.LoadedPHPModules()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("PHP %s\n", version)
+		if len(state.PHPExtensions) == 0 {
+			fmt.Println("No managed extensions")
+			return nil
+		}
+		for _, ext := range state.PHPExtensions {
+			if internal.IsPHPExtensionLoaded(modules, ext) {
+				fmt.Printf("  ✅ %s\n", ext)
+			} else {
+				fmt.Printf("  ❌ %s (not loaded)\n", ext)
+			}
+		}
+		return nil
+	},
+}
+
+// phpPoolCmd represents the php pool command
+var phpPoolCmd = &cobra.Command{
+	Use:   "pool",
+	Short: "Manage php-fpm pools",
+	Long: `Manage php-fpm pools of the active PHP version.
+
+Pools are written to /etc/php/<version>/fpm/pool.d/<name>.conf with sane
+defaults, validated with php-fpm -t and applied with a graceful reload.
+
+Examples:
+  run php pool create shop --user shop
+  run php pool create api --user api --listen /run/php/api.sock
+  run php pool remove shop
+  run php pool list`,
+}
+
+// phpPoolCreateCmd represents the php pool create command
+var phpPoolCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a php-fpm pool",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user, _ := cmd.Flags().GetString("user")
+		group, _ := cmd.Flags().GetString("group")
+		listen, _ := cmd.Flags().GetString("listen")
+		listenOwner, _ := cmd.Flags().GetString("listen-owner")
+		maxChildren, _ := cmd.Flags().GetInt("max-children")
+
+		pool := internal.PHPPool{
+			Name:        args[0],
+			User:        user,
+			Group:       group,
+			Listen:      listen,
+			ListenOwner: listenOwner,
+			MaxChildren: maxChildren,
+		}
+		if err := internal.CreatePHPPool(pool); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Pool %s created and php-fpm reloaded\n", pool.Name)
+		return nil
+	},
+}
+
+// phpPoolRemoveCmd represents the php pool remove command
+var phpPoolRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a php-fpm pool",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := internal.RemovePHPPool(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Pool %s removed and php-fpm reloaded\n", args[0])
+		return nil
+	},
+}
+
+// phpPoolListCmd represents the php pool list command
+var phpPoolListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List php-fpm pools",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pools, err := internal.ListPHPPools()
+		if err != nil {
+			return err
+		}
+		for _, pool := range pools {
+			fmt.Println(pool)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(phpCmd)
+	phpCmd.AddCommand(phpExtCmd)
+	phpExtCmd.AddCommand(phpExtAddCmd)
+	phpExtCmd.AddCommand(phpExtRemoveCmd)
+	phpExtCmd.AddCommand(phpExtListCmd)
+
+	phpCmd.AddCommand(phpPoolCmd)
+	phpPoolCmd.AddCommand(phpPoolCreateCmd)
+	phpPoolCmd.AddCommand(phpPoolRemoveCmd)
+	phpPoolCmd.AddCommand(phpPoolListCmd)
+	phpPoolCreateCmd.Flags().String("user", "", "user the pool workers run as")
+	phpPoolCreateCmd.Flags().String("group", "", "group the pool workers run as (default: same as --user)")
+	phpPoolCreateCmd.Flags().String("listen", "", "absolute socket path or host:port to listen on (default: /run/php/php<version>-<name>.sock)")
+	phpPoolCreateCmd.Flags().String("listen-owner", "", "owner and group of the socket, the web server user (default: www-data)")
+	phpPoolCreateCmd.Flags().Int("max-children", 5, "maximum number of worker processes")
+	phpPoolCreateCmd.MarkFlagRequired("user")
+}
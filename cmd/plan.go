@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/amoga-io/run/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// planCmd represents the plan command
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Write the resolved install plan of packages for review",
+	Long: `Resolve an install into a plan without changing anything: the packages
+with their dependencies in the order they would be installed, the script
+and settings of each, the version installed now, which are skipped as
+already installed this way, and the estimated disk space.
+
+Review the plan, then run exactly that with 'run apply'. It refuses the
+plan when the host changed since it was made: another install script,
+installed version, setting or dependency.
+
+Without --out the plan is printed as JSON.
+
+Examples:
+  run plan --packages node,nginx --out plan.json
+  run plan --profile web --out web.json
+  run plan --packages java --vendor temurin`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		packages, _ := cmd.Flags().GetStringSlice("packages")
+		profileName, _ := cmd.Flags().GetString("profile")
+		out, _ := cmd.Flags().GetString("out")
+		vendor, _ := cmd.Flags().GetString("vendor")
+
+		if (len(packages) == 0) == (profileName == "") {
+			return fmt.Errorf("give the packages to plan with --packages or a profile with --profile")
+		}
+		if err := checkVendor(vendor); err != nil {
+			return err
+		}
+		options := installOptions{JavaVendor: vendor}
+		if profileName != "" {
+			config, err := internal.LoadConfig()
+			if err != nil {
+				return err
+			}
+			profile, err := internal.GetProfile(config, profileName)
+			if err != nil {
+				return err
+			}
+			packages, options.Versions = profile.Packages, profile.Versions
+		}
+		options.Backend, _ = cmd.Flags().GetString("backend")
+		if options.Backend != "" {
+			if err := checkBackend(packages, options.Backend); err != nil {
+				return err
+			}
+		}
+
+		plan, err := internal.BuildPlan(packages, options.scriptEnv)
+		if err != nil {
+			return err
+		}
+		if out == "" {
+			data, err := json.MarshalIndent(plan, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+		if err := internal.WritePlan(plan, out); err != nil {
+			return err
+		}
+		printPlan(os.Stdout, plan)
+		fmt.Printf("✅ Plan written to %s. Run it with: run apply %s\n", out, out)
+		return nil
+	},
+}
+
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Use:   "apply <plan.json>",
+	Short: "Install exactly what a plan from 'run plan' lists",
+	Long: `Install the packages of a plan written by 'run plan', in its order and
+with its settings. Steps the plan skips are not run.
+
+The plan is refused when the host changed since it was made, so what runs
+is what was reviewed: it must be the same host and context, and every
+install script, setting, dependency and installed version must be as
+planned. Make a new plan when it is refused.
+
+When ` + internal.ApprovalConfigPath + ` requires approval, the plan also needs
+an approval signed by someone else with 'run approve', read from
+<plan>.approval or --approval.
+
+Examples:
+  run apply plan.json
+  run apply plan.json --format json
+  run apply plan.json --approval plan.json.approval`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+		if err := applyScriptOutput(cmd); err != nil {
+			return err
+		}
+		if err := checkRebootFlags(cmd); err != nil {
+			return err
+		}
+
+		plan, err := internal.ReadPlan(args[0])
+		if err != nil {
+			return err
+		}
+		approval, err := internal.PlanApproval(args[0])
+		if err != nil {
+			return err
+		}
+		tokenPath, _ := cmd.Flags().GetString("approval")
+		if _, err := os.Stat(args[0] + ".approval"); tokenPath == "" && err == nil {
+			tokenPath = args[0] + ".approval"
+		}
+		if err := internal.RequireApproval(approval, tokenPath); err != nil {
+			return err
+		}
+		changes, err := plan.Changes()
+		if err != nil {
+			return err
+		}
+		if len(changes) > 0 {
+			for _, change := range changes {
+				fmt.Fprintf(os.Stderr, "  %s\n", change)
+			}
+			return fmt.Errorf("the host changed since %s was planned; review a new plan from 'run plan'", args[0])
+		}
+		printPlan(internal.Console, plan)
+
+		stopSudo, err := internal.PrepareSudo()
+		if err != nil {
+			return err
+		}
+		defer stopSudo()
+		resumeUpgrades, err := holdAptLock(cmd)
+		if err != nil {
+			return err
+		}
+		defer resumeUpgrades()
+		if err := internal.ApplyDownloadSettings(); err != nil {
+			internal.Warn("", "Download settings not applied: %v", err)
+		}
+
+		var install []string
+		var results []output.PackageResult
+		options := installOptions{Converge: true, Settings: make(map[string][]string)}
+		for _, step := range plan.Steps {
+			if step.Action == internal.PlanSkip {
+				results = append(results, output.PackageResult{Package: step.Package, Operation: "install", Status: output.StatusSkipped, Message: noChanges})
+				continue
+			}
+			install = append(install, step.Package)
+			options.Settings[step.Package] = step.Settings
+		}
+		results = append(results, installPackages(install, options)...)
+		return renderResults(format, appendReboot(cmd, results))
+	},
+}
+
+// printPlan prints the steps of a plan as a table.
+func printPlan(w io.Writer, plan *internal.Plan) {
+	fmt.Fprintf(w, "Plan for %s (context %s) from %s:\n", plan.Host, plan.Context, plan.CreatedAt.Local().Format("2006-01-02 15:04"))
+	for i, step := range plan.Steps {
+		notes := []string{step.Script}
+		if step.Dependency {
+			notes = append(notes, "dependency")
+		}
+		if step.InstalledVersion != "" {
+			notes = append(notes, "installed "+step.InstalledVersion)
+		}
+		if step.EstimatedSize != "" && step.Action == internal.PlanInstall {
+			notes = append(notes, "~"+step.EstimatedSize)
+		}
+		notes = append(notes, step.Settings...)
+		fmt.Fprintf(w, "  %d. %-8s %-12s %s\n", i+1, step.Action, step.Package, strings.Join(notes, ", "))
+	}
+	if plan.EstimatedBytes > 0 {
+		fmt.Fprintf(w, "Estimated disk space: %s\n", formatBytes(plan.EstimatedBytes))
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+	rootCmd.AddCommand(applyCmd)
+
+	planCmd.Flags().StringSlice("packages", nil, "packages to install, comma-separated")
+	planCmd.Flags().String("profile", "", "plan the packages of a profile, with the versions it pins")
+	planCmd.Flags().StringP("out", "o", "", "write the plan to this file")
+	planCmd.Flags().String("vendor", "", "JDK distribution for java: openjdk, temurin or corretto")
+	planCmd.Flags().String("backend", "", "install method for packages with several, e.g. nvm for node")
+	applyCmd.Flags().String("approval", "", "approval from 'run approve' (default <plan>.approval when it exists)")
+	addFormatFlag(applyCmd)
+	addScriptOutputFlags(applyCmd)
+	addRebootFlags(applyCmd)
+	addAptLockFlag(applyCmd)
+}
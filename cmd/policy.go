@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/amoga-io/run/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// checkCommandPolicy rejects commands the host's role may not run. It is
+// called from the root command's persistentPreRun.
+func checkCommandPolicy(cmd *cobra.Command, args []string) error {
+	config, err := internal.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	// Gate on the top-level command, so `run php ext add` counts as "php"
+	topLevel := cmd
+	for topLevel.HasParent() && topLevel.Parent() != rootCmd {
+		topLevel = topLevel.Parent()
+	}
+	if topLevel == rootCmd {
+		return nil
+	}
+	return internal.CheckCommandPolicy(config, topLevel.Name())
+}
+
+// filterByPolicy validates all packages against the host's role before
+// anything runs. It returns the allowed packages, and a skipped result for
+// each package the policy rejects.
+func filterByPolicy(command string, packages []string) ([]string, []output.PackageResult) {
+	config, err := internal.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(internal.Console, "Error loading config: %v\n", err)
+		return nil, nil
+	}
+
+	var allowed []string
+	var skipped []output.PackageResult
+	for _, packageName := range packages {
+		if err := internal.CheckPackagePolicy(config, command, packageName); err != nil {
+			if !internal.IsPolicyViolation(err) {
+				err = fmt.Errorf("error checking policy: %v", err)
+			}
+			fmt.Fprintf(internal.Console, "🚫 %v\n", err)
+			skipped = append(skipped, output.PackageResult{
+				Package:   packageName,
+				Operation: command,
+				Status:    output.StatusSkipped,
+				Message:   err.Error(),
+			})
+			continue
+		}
+		allowed = append(allowed, packageName)
+	}
+	return allowed, skipped
+}
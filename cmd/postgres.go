@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// postgresCmd represents the postgres command
+var postgresCmd = &cobra.Command{
+	Use:   "postgres",
+	Short: "Manage PostgreSQL databases and users",
+}
+
+// postgresCreateUserCmd represents the postgres createuser command
+var postgresCreateUserCmd = &cobra.Command{
+	Use:   "createuser <name>",
+	Short: "Create a PostgreSQL login role",
+	Long: `Create a PostgreSQL login role.
+
+With --password a random password is generated for the role and printed.
+
+Examples:
+  run postgres createuser app --password
+  run postgres createuser admin --superuser`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		withPassword, _ := cmd.Flags().GetBool("password")
+		superuser, _ := cmd.Flags().GetBool("superuser")
+
+		password := ""
+		if withPassword {
+			var err error
+			if password, err = internal.GeneratePassword(24); err != nil {
+				return err
+			}
+		}
+
+		if err := internal.CreatePostgresUser(args[0], password, superuser); err != nil {
+			return err
+		}
+
+		fmt.Printf("✅ Created postgres user %s\n", args[0])
+		if password != "" {
+			fmt.Printf("🔑 Password: %s\n", password)
+		}
+		return nil
+	},
+}
+
+// postgresCreateDBCmd represents the postgres createdb command
+var postgresCreateDBCmd = &cobra.Command{
+	Use:   "createdb <name>",
+	Short: "Create a PostgreSQL database",
+	Long: `Create a PostgreSQL database owned by the given user.
+
+The owner role is created when it does not exist yet; with --password it
+gets a randomly generated password. --env-file writes the connection
+settings (DATABASE_URL, DB_HOST, DB_PORT, DB_NAME, DB_USER, DB_PASSWORD)
+to a .env file, updating keys that are already present. DATABASE_URL and
+DB_PASSWORD are only written with a generated password.
+
+Examples:
+  run postgres createdb shop --owner shop --password --env-file /srv/shop/.env`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database := args[0]
+		owner, _ := cmd.Flags().GetString("owner")
+		withPassword, _ := cmd.Flags().GetBool("password")
+		envFile, _ := cmd.Flags().GetString("env-file")
+
+		if owner == "" {
+			owner = database
+		}
+
+		ownerExists, err := internal.PostgresRoleExists(owner)
+		if err != nil {
+			return err
+		}
+
+		password := ""
+		if !ownerExists {
+			if withPassword {
+				if password, err = internal.GeneratePassword(24); err != nil {
+					return err
+				}
+			}
+			if err := internal.CreatePostgresUser(owner, password, false); err != nil {
+				return err
+			}
+			fmt.Printf("✅ Created postgres user %s\n", owner)
+		} else if withPassword {
+			fmt.Printf("⚠️  User %s already exists, keeping its password\n", owner)
+		}
+
+		if err := internal.CreatePostgresDatabase(database, owner); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Created database %s owned by %s\n", database, owner)
+
+		if envFile != "" {
+			if err := internal.UpdateDotEnv(envFile, internal.PostgresConnectionEnv(database, owner, password)); err != nil {
+				return err
+			}
+			fmt.Printf("📝 Connection settings written to %s\n", envFile)
+		} else if password != "" {
+			fmt.Printf("🔑 Password: %s\n", password)
+		}
+		return nil
+	},
+}
+
+// postgresUpgradeCmd represents the postgres upgrade command
+var postgresUpgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrade a PostgreSQL cluster to a new major version",
+	Long: `Upgrade a PostgreSQL cluster to a new major version.
+
+The upgrade process:
+  1. Installs the new version side by side with the old one
+  2. Migrates the data with pg_upgrade (dump/restore as fallback)
+  3. Validates that the new cluster is online and answers queries
+  4. Switches the systemd service to the new version
+
+The old cluster is stopped but its data directory is kept. Once the upgrade
+is verified, delete it with --finalize, which asks for confirmation and
+refuses unless the --to cluster is online and answers queries.
+
+Examples:
+  run postgres upgrade --from 15 --to 17
+  run postgres upgrade --from 15 --to 17 --finalize`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetString("to")
+		cluster, _ := cmd.Flags().GetString("cluster")
+		finalize, _ := cmd.Flags().GetBool("finalize")
+
+		if finalize {
+			if err := internal.CheckPostgresUpgraded(from, to, cluster); err != nil {
+				return err
+			}
+			if !internal.Confirm(fmt.Sprintf("Delete the old %s/%s cluster and its data? Cluster %s/%s is online.", from, cluster, to, cluster)) {
+				return fmt.Errorf("finalize cancelled, cluster %s/%s kept", from, cluster)
+			}
+			if err := internal.DropPostgresCluster(from, cluster); err != nil {
+				return err
+			}
+			fmt.Printf("✅ Old cluster %s/%s removed\n", from, cluster)
+			return nil
+		}
+
+		if err := internal.UpgradePostgres(from, to, cluster); err != nil {
+			return err
+		}
+		fmt.Printf("🎉 PostgreSQL cluster %s upgraded from %s to %s\n", cluster, from, to)
+		fmt.Printf("📁 Old cluster %s/%s kept. Remove it once verified with:\n", from, cluster)
+		fmt.Printf("   run postgres upgrade --from %s --to %s --finalize\n", from, to)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(postgresCmd)
+	postgresCmd.AddCommand(postgresCreateUserCmd)
+	postgresCmd.AddCommand(postgresCreateDBCmd)
+	postgresCmd.AddCommand(postgresUpgradeCmd)
+
+	postgresCreateUserCmd.Flags().Bool("password", false, "generate a password for the user")
+	postgresCreateUserCmd.Flags().Bool("superuser", false, "grant superuser privileges")
+
+	postgresCreateDBCmd.Flags().String("owner", "", "owner of the database, created if missing (default: database name)")
+	postgresCreateDBCmd.Flags().Bool("password", false, "generate a password when creating the owner")
+	postgresCreateDBCmd.Flags().String("env-file", "", "write connection settings to this .env file")
+
+	postgresUpgradeCmd.Flags().String("from", "", "current major version (e.g. 15)")
+	postgresUpgradeCmd.Flags().String("to", "", "target major version (e.g. 17)")
+	postgresUpgradeCmd.Flags().String("cluster", "main", "name of the cluster to upgrade")
+	postgresUpgradeCmd.Flags().Bool("finalize", false, "delete the old cluster after a verified upgrade")
+	postgresUpgradeCmd.MarkFlagRequired("from")
+	postgresUpgradeCmd.MarkFlagRequired("to")
+}
@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/amoga-io/run/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// profileCmd represents the profile command
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Install predefined sets of packages",
+	Long: `Profiles name a set of packages installed together. run ships the
+gha-runner preset for self-hosted GitHub Actions runners; add or replace
+profiles under profiles in ~/.run/config.yaml:
+
+  profiles:
+    web:
+      description: Web server
+      packages: [essentials, nginx, node, pm2]
+      users:
+        deploy: [www-data]
+      ssh_keys:
+        deploy: [github:octocat, https://keys.example.com/ops.pub]
+      images:
+        - redis:7.4@sha256:<digest>
+
+Examples:
+  run profile list
+  run profile bundle web --out web-images.tar
+  run profile apply gha-runner --summary-annotations`,
+}
+
+// profileListCmd represents the profile list command
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the available profiles",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := internal.LoadConfig()
+		if err != nil {
+			return err
+		}
+		profiles := internal.Profiles(config)
+		var names []string
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			profile := profiles[name]
+			fmt.Printf("%s: %s\n", name, strings.Join(profile.Packages, ", "))
+			if profile.Description != "" {
+				fmt.Printf("  %s\n", profile.Description)
+			}
+		}
+		return nil
+	},
+}
+
+// profileApplyCmd represents the profile apply command
+var profileApplyCmd = &cobra.Command{
+	Use:   "apply <profile>",
+	Short: "Install the packages of a profile",
+	Long: `Install the packages of a profile, with the versions it pins, after
+creating the users it lists and adding their SSH keys. A successful apply
+records the baseline that 'run watch' reports drift from.
+
+Images the profile lists are pulled once docker is installed, so apps do not
+wait for downloads on first start. A pinned digest (@sha256:...) must match;
+unpinned images print the digest to pin. --images-bundle loads images saved
+with 'run profile bundle' first, for hosts without registry access.
+
+Packages already installed by an earlier apply are skipped while their
+install script, its settings and the installed version are unchanged, so
+the profile can be re-applied from cron to converge a host. --force runs
+every step again.
+
+When a package leaves the host needing a reboot (kernel, libc or driver
+updates), the results say so. --reboot-if-required reboots after --delay
+(default 1m) unless something failed, for unattended provisioning.
+
+--strict makes golden-image builds deterministic: warnings and skipped
+steps fail the apply, images must be pinned by digest, packages the profile
+does not pin get the registry's default version, and scripts fail rather
+than fall back (RUN_STRICT=1).
+
+In GitHub Actions, --summary-annotations groups each package's output,
+annotates failures on the workflow run and adds the results to the job
+summary.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+		if err := applyScriptOutput(cmd); err != nil {
+			return err
+		}
+		if err := checkRebootFlags(cmd); err != nil {
+			return err
+		}
+
+		config, err := internal.LoadConfig()
+		if err != nil {
+			return err
+		}
+		profile, err := internal.GetProfile(config, args[0])
+		if err != nil {
+			return err
+		}
+		stopSudo, err := internal.PrepareSudo()
+		if err != nil {
+			return err
+		}
+		defer stopSudo()
+		resumeUpgrades, err := holdAptLock(cmd)
+		if err != nil {
+			return err
+		}
+		defer resumeUpgrades()
+
+		if err := internal.ApplyDownloadSettings(); err != nil {
+			internal.Warn("", "Download settings not applied: %v", err)
+		}
+		fmt.Fprintf(internal.Console, "Applying profile %s: %s\n", args[0], strings.Join(profile.Packages, ", "))
+		var users []string
+		for name := range profile.Users {
+			users = append(users, name)
+		}
+		sort.Strings(users)
+		for _, name := range users {
+			if err := internal.EnsureUser(name, profile.Users[name]); err != nil {
+				return err
+			}
+		}
+		var keyUsers []string
+		for name := range profile.SSHKeys {
+			keyUsers = append(keyUsers, name)
+		}
+		sort.Strings(keyUsers)
+		for _, name := range keyUsers {
+			if err := internal.DeploySSHKeys(name, profile.SSHKeys[name]); err != nil {
+				return err
+			}
+		}
+		force, _ := cmd.Flags().GetBool("force")
+		results := installPackages(profile.Packages, installOptions{Versions: profile.Versions, Converge: !force})
+		if len(profile.Images) > 0 {
+			bundle, _ := cmd.Flags().GetString("images-bundle")
+			results = append(results, prefetchImages(profile.Images, bundle, results)...)
+		}
+		if unchanged(results) {
+			fmt.Fprintf(internal.Console, "✅ Profile %s: no changes\n", args[0])
+		}
+		if output.Failed(results) == 0 {
+			// The baseline of `run watch`
+			if err := internal.SaveBaseline(profile.Packages); err != nil {
+				internal.Warn("", "Baseline not recorded: %v", err)
+			}
+		}
+		return renderResults(format, appendReboot(cmd, results))
+	},
+}
+
+// prefetchImages pulls the images of a profile, or loads them from a bundle
+// first, once docker installed successfully.
+func prefetchImages(images []string, bundle string, installed []output.PackageResult) []output.PackageResult {
+	for _, result := range installed {
+		if result.Package == "docker" && result.Status == output.StatusFailed {
+			return nil
+		}
+	}
+	var results []output.PackageResult
+	if bundle != "" {
+		if err := internal.LoadImageBundle(bundle); err != nil {
+			return []output.PackageResult{{Package: bundle, Operation: "load", Status: output.StatusFailed, Message: err.Error()}}
+		}
+	}
+	for _, image := range images {
+		started := time.Now()
+		result := output.PackageResult{Package: image, Operation: "pull", Status: output.StatusOK, Message: "available"}
+		if err := internal.PrefetchImage(image); err != nil {
+			result.Status, result.Message = output.StatusFailed, err.Error()
+		}
+		result.Duration = time.Since(started)
+		results = append(results, result)
+	}
+	return results
+}
+
+// profileBundleCmd represents the profile bundle command
+var profileBundleCmd = &cobra.Command{
+	Use:   "bundle <profile>",
+	Short: "Save the images of a profile for offline provisioning",
+	Long: `Pull the images a profile lists and save them to one tarball. Pass it
+to 'run profile apply --images-bundle' on hosts that cannot reach the
+registry.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := internal.LoadConfig()
+		if err != nil {
+			return err
+		}
+		profile, err := internal.GetProfile(config, args[0])
+		if err != nil {
+			return err
+		}
+		if len(profile.Images) == 0 {
+			return fmt.Errorf("profile '%s' lists no images", args[0])
+		}
+		out, _ := cmd.Flags().GetString("out")
+		if err := internal.SaveImageBundle(profile.Images, out); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Saved %d image(s) to %s\n", len(profile.Images), out)
+		return nil
+	},
+}
+
+// unchanged reports whether every package was skipped as already applied.
+func unchanged(results []output.PackageResult) bool {
+	for _, result := range results {
+		if result.Status != output.StatusSkipped || result.Message != noChanges {
+			return false
+		}
+	}
+	return len(results) > 0
+}
+
+func init() {
+	rootCmd.AddCommand(profileCmd)
+	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(profileApplyCmd)
+	profileCmd.AddCommand(profileBundleCmd)
+	addFormatFlag(profileApplyCmd)
+	addScriptOutputFlags(profileApplyCmd)
+	addRebootFlags(profileApplyCmd)
+	addAptLockFlag(profileApplyCmd)
+	profileApplyCmd.Flags().String("images-bundle", "", "load the profile's images from a tarball written by 'run profile bundle'")
+	profileBundleCmd.Flags().String("out", "images.tar", "tarball to write")
+	profileApplyCmd.Flags().Bool("force", false, "apply every step, even ones unchanged since the last apply")
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/amoga-io/run/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// addRebootFlags adds --reboot-if-required and --delay to commands that
+// install or remove packages.
+func addRebootFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("reboot-if-required", false, "reboot when a package asks for it and nothing failed")
+	cmd.Flags().Duration("delay", time.Minute, "wait before rebooting with --reboot-if-required")
+}
+
+// checkRebootFlags validates --delay before any work is done.
+func checkRebootFlags(cmd *cobra.Command) error {
+	if delay, _ := cmd.Flags().GetDuration("delay"); delay < 0 {
+		return fmt.Errorf("--delay cannot be negative")
+	}
+	return nil
+}
+
+// appendReboot adds the pending reboot of the host to the results, after
+// kernel, libc or driver updates, and schedules it under
+// --reboot-if-required when nothing failed. The results are unchanged when
+// no reboot is pending.
+func appendReboot(cmd *cobra.Command, results []output.PackageResult) []output.PackageResult {
+	pending, packages := internal.RebootRequired()
+	if !pending {
+		return results
+	}
+	result := output.PackageResult{Package: "host", Operation: "reboot", Status: output.StatusSkipped, Message: "required"}
+	if len(packages) > 0 {
+		result.Message = "required by " + strings.Join(packages, ", ")
+	}
+
+	reboot, _ := cmd.Flags().GetBool("reboot-if-required")
+	delay, _ := cmd.Flags().GetDuration("delay")
+	switch failed := output.Failed(results); {
+	case !reboot:
+		result.NextSteps = []string{"Reboot to finish: sudo reboot", "Or pass --reboot-if-required to reboot when needed"}
+	case failed > 0:
+		result.Message += fmt.Sprintf("; not rebooting after %d problem(s)", failed)
+	default:
+		at, err := internal.ScheduleReboot(delay)
+		if err != nil {
+			result.Status, result.Message = output.StatusFailed, err.Error()
+			break
+		}
+		result.Status = output.StatusOK
+		result.Message = fmt.Sprintf("scheduled for %s, cancel with: sudo shutdown -c", at.Format("15:04"))
+		if delay == 0 {
+			result.Message = "rebooting now"
+		}
+	}
+	return append(results, result)
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// registryCmd represents the registry command
+var registryCmd = &cobra.Command{
+	Use:   "registry",
+	Short: "Manage the package registry",
+	Long: `Manage the package registry.
+
+The registry built into run is merged with two overlays at startup:
+  ~/.run/registry/remote.yaml   latest registry, fetched by 'run registry update'
+  ~/.run/registry/user.yaml     your own packages and overrides
+
+Overlay format:
+  packages:
+    redis:
+      install: redis.sh
+      remove: remove-redis.sh`,
+}
+
+// registryUpdateCmd represents the registry update command
+var registryUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Fetch the latest registry without updating the binary",
+	Long: `Fetch the latest package registry and store it as the remote overlay.
+
+The registry is read from registry.url in ~/.run/config.yaml, or from the
+run repository by default.
+
+Examples:
+  run registry update`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := internal.LoadConfig()
+		if err != nil {
+			return err
+		}
+		url := config.Registry.URL
+		if url == "" {
+			url = internal.DefaultRegistryURL
+		}
+
+		fmt.Printf("🔄 Fetching registry from %s\n", url)
+		missing, err := internal.UpdateRemoteRegistry(url)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Registry updated: %d packages available\n", len(internal.InstallPackageRegistry))
+		if len(missing) > 0 {
+			fmt.Printf("⚠️  Scripts missing for: %s (run 'run update' to fetch them)\n", strings.Join(missing, ", "))
+		}
+		return nil
+	},
+}
+
+// registryNewCmd represents the registry new command
+var registryNewCmd = &cobra.Command{
+	Use:   "new <name>",
+	Short: "Start a new package from a template",
+	Long: `Write a registry file for a new package and a starter install and
+removal script, ready for 'run validate':
+  <dir>/<name>.yaml
+  <dir>/scripts/<name>.sh
+  <dir>/scripts/remove-<name>.sh
+
+The scripts fail on errors and unset variables, never wait for apt prompts
+and mark their steps in the package log. Fill in the TODOs, then merge the
+package into ~/.run/registry/user.yaml and copy the scripts to
+~/.run/scripts, or add them to a run checkout.
+
+Examples:
+  run registry new redis
+  run registry new redis --dir ./packages`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, _ := cmd.Flags().GetString("dir")
+		files, err := internal.ScaffoldPackage(args[0], dir)
+		for _, file := range files {
+			fmt.Printf("📝 %s\n", file)
+		}
+		if err != nil {
+			return err
+		}
+
+		// The new files must pass `run validate` as written
+		internal.ScriptsDirOverride = filepath.Join(dir, "scripts")
+		for _, err := range internal.ValidateFile(files[0]) {
+			fmt.Printf("⚠️  %v\n", err)
+		}
+		fmt.Printf("✅ Package %s created. Check it after editing with:\n", args[0])
+		fmt.Printf("   run validate --scripts-dir %s %s\n", internal.ScriptsDirOverride, files[0])
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(registryCmd)
+	registryCmd.AddCommand(registryUpdateCmd)
+	registryCmd.AddCommand(registryNewCmd)
+	registryNewCmd.Flags().String("dir", ".", "directory to write the package to")
+}
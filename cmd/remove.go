@@ -5,8 +5,11 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/amoga-io/run/internal"
+	"github.com/amoga-io/run/internal/output"
 	"github.com/spf13/cobra"
 )
 
@@ -14,53 +17,146 @@ import (
 var removeCmd = &cobra.Command{
 	Use:   "remove",
 	Short: "Remove a package",
-	Long:  `Remove a package from your specific method.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Remove a package from your specific method.
+
+For java, node and php, --version removes a single version installed side
+by side. It is refused while a pm2 app, nginx site or service still targets
+that version, or while it is the active version and others remain.
+
+When ` + internal.ApprovalConfigPath + ` requires approval, removals need an
+approval signed by someone else with 'run approve --remove', given with
+--approval.
+
+Examples:
+  run remove nginx
+  run remove php --version 8.1
+  run remove nginx --approval remove-nginx.approval`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+		if err := applyScriptOutput(cmd); err != nil {
+			return err
+		}
+		if err := checkRebootFlags(cmd); err != nil {
+			return err
+		}
+		packages := args
+		if allFlag, _ := cmd.Flags().GetBool("all"); allFlag {
+			packages = internal.ListRemovablePackages()
+		}
+		if len(packages) > 0 {
+			if err := checkRemoveApproval(cmd, packages); err != nil {
+				return err
+			}
+		}
+		stopSudo, err := internal.PrepareSudo()
+		if err != nil {
+			return err
+		}
+		defer stopSudo()
+		resumeUpgrades, err := holdAptLock(cmd)
+		if err != nil {
+			return err
+		}
+		defer resumeUpgrades()
+
 		// Check --all flag first
 		if allFlag, _ := cmd.Flags().GetBool("all"); allFlag {
-			fmt.Println("Removing all packages...")
-			for packageName := range internal.RemovePackageRegistry {
-				fmt.Printf("Removing package: %s\n", packageName)
-				if err := internal.GetScriptAndExecute("install", packageName); err != nil {
-					fmt.Printf("Error removing package '%s': %v\n", packageName, err)
-				} else {
-					fmt.Printf("Successfully removed package: %s\n", packageName)
-				}
+			if !internal.Confirm(fmt.Sprintf("Remove all packages (%s)?", strings.Join(packages, ", "))) {
+				return fmt.Errorf("removal cancelled")
 			}
-			return
+			fmt.Fprintln(internal.Console, "Removing all packages...")
+			return renderResults(format, appendReboot(cmd, removePackages(packages)))
 		}
 
 		// No args provided and --all flag not set
 		if len(args) == 0 {
 			fmt.Println("Please specify a package to remove or use --all flag to remove all installed packages.")
-			return
+			return nil
 		}
 
-		// Multiple packages provided
-		if len(args) > 1 {
-			for _, packageName := range args {
-				fmt.Printf("Removing package: %s\n", packageName)
-				if err := internal.GetScriptAndExecute("remove", packageName); err != nil {
-					fmt.Printf("Error removing package '%s': %v\n", packageName, err)
-				} else {
-					fmt.Printf("Successfully removed package: %s\n", packageName)
-				}
-			}
-			return
+		if version, _ := cmd.Flags().GetString("version"); version != "" {
+			return renderResults(format, appendReboot(cmd, removeVersions(args, version)))
 		}
+		return renderResults(format, appendReboot(cmd, removePackages(args)))
+	},
+}
+
+// removeVersions removes one version of each package, keeping the others.
+func removeVersions(packages []string, version string) []output.PackageResult {
+	allowed, results := filterByPolicy("remove", packages)
+	for _, packageName := range allowed {
+		fmt.Fprintf(internal.Console, "Removing %s %s\n", packageName, version)
+		started := time.Now()
+		result := output.PackageResult{Package: packageName, Operation: "remove", Status: output.StatusOK, Message: "removed " + version}
+		if err := internal.RemoveVersion(packageName, version); err != nil {
+			result.Status, result.Message = output.StatusFailed, err.Error()
+		}
+		result.Duration = time.Since(started)
+		results = append(results, result)
+	}
+	recordHistory(results)
+	return results
+}
 
-		// Install single package
-		packageName := args[0]
-		fmt.Printf("Removing package: %s\n", packageName)
-		if err := internal.GetScriptAndExecute("remove", packageName); err != nil {
-			fmt.Printf("Error removing package '%s': %v\n", packageName, err)
+// removePackages runs the removal script for each package and then cleans up
+// packages apt no longer needs, holding back system-critical ones.
+func removePackages(packages []string) []output.PackageResult {
+	allowed, results := filterByPolicy("remove", packages)
+	removed := 0
+	for _, packageName := range allowed {
+		fmt.Fprintf(internal.Console, "Removing package: %s\n", packageName)
+		started := time.Now()
+		var err error
+		if internal.IsLocalPackage(packageName) {
+			err = internal.RemoveLocalPackage(packageName)
 		} else {
-			fmt.Printf("Successfully removed package: %s\n", packageName)
+			err = internal.GetScriptAndExecute("remove", packageName)
 		}
-	},
+
+		result := output.PackageResult{Package: packageName, Operation: "remove", Duration: time.Since(started)}
+		if err != nil {
+			result.Status, result.Message = output.StatusFailed, err.Error()
+		} else {
+			result.Status, result.Message = output.StatusOK, "removed"
+			removed++
+			if err := internal.RemovePackageSysctls(packageName); err != nil {
+				internal.Warn(packageName, "Kernel parameters not removed: %v", err)
+			}
+			if err := internal.RecordVersions(packageName); err != nil {
+				internal.Warn(packageName, "Installed versions not recorded: %v", err)
+			}
+			if err := internal.ForgetPackageFiles(packageName); err != nil {
+				internal.Warn(packageName, "File records not removed: %v", err)
+			}
+			if err := internal.ForgetArtifacts(packageName); err != nil {
+				internal.Warn(packageName, "Cached artifacts not released: %v", err)
+			}
+		}
+		results = append(results, result)
+	}
+	recordHistory(results)
+
+	if removed == 0 {
+		return results
+	}
+
+	fmt.Fprintln(internal.Console, "Cleaning up unused dependencies...")
+	if _, err := internal.SafeAutoremove(); err != nil {
+		fmt.Fprintf(internal.Console, "Error cleaning up unused dependencies: %v\n", err)
+	}
+	return results
 }
 
 func init() {
 	rootCmd.AddCommand(removeCmd)
 	removeCmd.Flags().BoolP("all", "A", false, "remove all packages")
+	removeCmd.Flags().String("version", "", "remove only this version of java, node or php")
+	removeCmd.Flags().String("approval", "", "approval from 'run approve --remove', when the host requires approval")
+	addFormatFlag(removeCmd)
+	addScriptOutputFlags(removeCmd)
+	addRebootFlags(removeCmd)
+	addAptLockFlag(removeCmd)
 }
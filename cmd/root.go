@@ -4,8 +4,12 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"fmt"
 	"os"
+	"runtime/debug"
 
+	"github.com/amoga-io/run/internal"
+	"github.com/amoga-io/run/internal/system"
 	"github.com/spf13/cobra"
 )
 
@@ -14,39 +18,85 @@ var rootCmd = &cobra.Command{
 	Use:   "run",
 	Short: "Run is a CLI tool to manage your development environment",
 	Long:  `Run is a command-line tool for managing development tools and packages using the apt package manager. It supports installing, removing, listing, and searching packages.`,
+	// Runtime failures (failed checks, differences) are not usage errors
+	SilenceUsage: true,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	Run: func(cmd *cobra.Command, args []string) {
-		// If no subcommand is provided, display help
-		if len(args) == 0 {
-			cmd.Help()
-			return
-		}
-
 		// TODO: Implement correct version logic
 		if versionFlag, _ := cmd.Flags().GetBool("version"); versionFlag {
 			cmd.Println("Run version 1.0.0")
 			return
 		}
+
+		// If no subcommand is provided, display help
+		cmd.Help()
 	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	defer reportCrash()
 	err := rootCmd.Execute()
 	if err != nil {
 		os.Exit(1)
 	}
 }
 
-// verifyCmd represents the verify command for installation verification
+// reportCrash turns a panic into a crash report in ~/.run/crashes and a
+// short message, and offers to open a pre-filled GitHub issue.
+func reportCrash() {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	path, report, err := internal.WriteCrashReport(recovered, debug.Stack())
+	fmt.Fprintf(os.Stderr, "\n💥 run crashed: %v\n", recovered)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to save the crash report (%v):\n%s\n", err, report)
+	} else {
+		fmt.Fprintf(os.Stderr, "The crash report is in %s; please include it when reporting the problem.\n", path)
+	}
+	// Only someone at a terminal is offered the issue, never --yes in CI
+	if internal.Interactive() && internal.Confirm("Open a GitHub issue pre-filled with the report?") {
+		issueURL := internal.CrashIssueURL(recovered, report)
+		if err := internal.OpenURL(issueURL); err != nil {
+			fmt.Fprintf(os.Stderr, "Open this URL to report it:\n%s\n", issueURL)
+		}
+	}
+	os.Exit(2)
+}
+
+// verifyCmd represents the verify command. With packages it checks their
+// files (see verifyPackages); without, it is the self-test `run update`
+// runs on a new binary before keeping it.
 var verifyCmd = &cobra.Command{
-	Use:    "verify",
-	Short:  "Verify installation",
-	Hidden: true, // Hide from help menu
-	Run: func(cmd *cobra.Command, args []string) {
+	Use:   "verify [package...]",
+	Short: "Detect edits to the files of installed packages",
+	Long: `Check the key files of installed packages (binaries, unit files and
+configs, listed with 'files' in the registry) against the checksums, modes
+and owners recorded when they were installed, and show a diff for each
+edited config. Backups of files up to 1 MiB are kept, so edited or deleted
+configs can be restored; you are asked first unless --yes is given.
+
+Without packages, verify checks that run itself works.
+
+Examples:
+  run verify nginx
+  run verify postgres hardening --yes`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return verifyPackages(args)
+		}
+		if len(internal.ListPackages()) == 0 {
+			return fmt.Errorf("the embedded package registry is empty")
+		}
+		if _, err := internal.LoadConfig(); err != nil {
+			return err
+		}
 		cmd.Println("run CLI is installed and working correctly")
+		return nil
 	},
 }
 
@@ -59,7 +109,65 @@ var versionCmd = &cobra.Command{
 	},
 }
 
+// persistentPreRun runs before every command: it applies --context,
+// --scripts-dir, --offline and the confirmation flags, merges the registry overlays, checks registry integrity and
+// traces commands under --debug and enforces the host's command policy.
+func persistentPreRun(cmd *cobra.Command, args []string) error {
+	if context, _ := cmd.Flags().GetString("context"); context != "" {
+		internal.ContextOverride = context
+	}
+	// `run context use` and `create` check the context they are given
+	if cmd.Parent() != contextCmd {
+		if err := internal.CheckContext(internal.CurrentContext()); err != nil {
+			return err
+		}
+	}
+	if dir, _ := cmd.Flags().GetString("scripts-dir"); dir != "" {
+		internal.ScriptsDirOverride = dir
+	}
+	if offline, _ := cmd.Flags().GetBool("offline"); offline {
+		internal.OfflineOverride = true
+	}
+	assumeYes, _ := cmd.Flags().GetBool("yes")
+	assumeNo, _ := cmd.Flags().GetBool("assume-no")
+	switch {
+	case assumeYes && assumeNo:
+		return fmt.Errorf("--yes and --assume-no cannot be used together")
+	case assumeYes:
+		internal.ConfirmDefault = internal.ConfirmAssumeYes
+	case assumeNo:
+		internal.ConfirmDefault = internal.ConfirmAssumeNo
+	}
+	if dir := internal.CustomScriptsDir(); dir != "" {
+		fmt.Fprintf(os.Stderr, "⚠️  Using unofficial scripts from %s\n", dir)
+	}
+	for _, err := range internal.LoadRegistryOverlays() {
+		fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+	}
+	if debug, _ := cmd.Flags().GetBool("debug"); debug {
+		system.Trace = func(command string) {
+			fmt.Fprintf(os.Stderr, "+ %s\n", command)
+		}
+		for _, result := range internal.CheckRegistry() {
+			if !result.OK {
+				fmt.Fprintf(os.Stderr, "⚠️  registry: %s: %s\n", result.Name, result.Message)
+			}
+		}
+	}
+	return checkCommandPolicy(cmd, args)
+}
+
 func init() {
+	// Set here rather than in the literal: the hook refers back to rootCmd
+	rootCmd.PersistentPreRunE = persistentPreRun
+
+	rootCmd.PersistentFlags().String("context", "", "use this context's config and state (also "+internal.ContextEnv+"; see run context)")
+	rootCmd.PersistentFlags().Bool("debug", false, "report registry integrity problems and print commands before running them")
+	rootCmd.PersistentFlags().Bool("offline", false, "skip network checks and refuse commands that download (also "+internal.OfflineEnv+")")
+	rootCmd.PersistentFlags().BoolP("yes", "y", false, "answer yes to confirmations")
+	rootCmd.PersistentFlags().Bool("assume-no", false, "answer no to confirmations")
+	rootCmd.PersistentFlags().String("scripts-dir", "", "run package scripts from this directory instead of ~/.run/scripts (also "+internal.ScriptsDirEnv+")")
+
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	rootCmd.Flags().BoolP("version", "v", false, "Display run version")
 
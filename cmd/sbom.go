@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// sbomCmd represents the sbom command
+var sbomCmd = &cobra.Command{
+	Use:   "sbom",
+	Short: "Export a software bill of materials of managed packages",
+	Long: `Export a software bill of materials (SBOM) listing the apt packages
+installed by run-managed packages, with versions, suppliers and the
+repository each package came from.
+
+Formats: cyclonedx (CycloneDX 1.5 JSON), spdx (SPDX 2.3 JSON)
+
+Examples:
+  run sbom --format cyclonedx > sbom.json
+  run sbom --format spdx --out sbom.spdx.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		out, _ := cmd.Flags().GetString("out")
+
+		data, err := internal.GenerateSBOM(format, internal.CollectSBOMComponents())
+		if err != nil {
+			return err
+		}
+
+		if out == "" {
+			fmt.Println(string(data))
+			return nil
+		}
+		if err := os.WriteFile(out, append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", out, err)
+		}
+		fmt.Printf("✅ SBOM written to %s\n", out)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sbomCmd)
+	sbomCmd.Flags().StringP("format", "f", "cyclonedx", "SBOM format: cyclonedx or spdx")
+	sbomCmd.Flags().StringP("out", "o", "", "write the SBOM to a file instead of stdout")
+}
@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// secretCmd represents the secret command
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Manage the secrets store",
+	Long: `Manage the secrets store read by 'run generate env' templates with
+{{ secret "name" }}, or read secrets from Vault, Azure Key Vault or AWS SSM
+with secrets.backend (see 'run secret get --help').
+
+Without encryption the store is ~/.run/secrets.yaml, which must be chmod
+600. To keep no plaintext secrets on disk, encrypt it with an
+age identity or GPG keys of the host:
+
+  secrets:
+    encryption: age                   # or gpg
+    age_identity: ~/.config/run/age.key
+    recipients: [age1...]             # gpg: key ids; age: the identity's key when unset
+
+The store is then decrypted in memory only, when a template is rendered.
+'run secret rekey' encrypts an existing store, and re-encrypts it after the
+identity or recipients change.
+
+Examples:
+  printf %s "$DB_PASSWORD" | run secret set db_password
+  run secret get db_password
+  run secret list
+  run secret rekey --old-identity ~/.config/run/age-2025.key`,
+}
+
+// secretGetCmd represents the secret get command
+var secretGetCmd = &cobra.Command{
+	Use:   "get <name>",
+	Short: "Print a secret from the configured backend",
+	Long: `Print a secret resolved from secrets.backend, for scripts that need it
+at install time: DB_PASSWORD=$(run secret get db/password).
+
+  local           the secrets store (the default)
+  vault           field password of the KV v2 secret db, with VAULT_TOKEN
+                  or the token of vault login
+  azure-keyvault  the secret db-password of secrets.key_vault, through az;
+                  names with _ or - are refused, as they would map to it too
+  aws-ssm         the parameter <secrets.ssm_prefix>/db/password, through aws
+
+  secrets:
+    backend: vault
+    vault:
+      address: https://vault.internal:8200
+      mount: secret`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		value, err := internal.GetSecret(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(value)
+		return nil
+	},
+}
+
+// secretSetCmd represents the secret set command
+var secretSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Set a secret, read from stdin",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireLocalSecrets(); err != nil {
+			return err
+		}
+		secrets, err := internal.LoadSecrets()
+		if err != nil {
+			return err
+		}
+		value, err := readSecretValue(args[0])
+		if err != nil {
+			return err
+		}
+		if value == "" {
+			return fmt.Errorf("the value of %s is empty", args[0])
+		}
+		secrets[args[0]] = value
+		if err := internal.SaveSecrets(secrets); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Secret %s set\n", args[0])
+		return nil
+	},
+}
+
+// secretListCmd represents the secret list command
+var secretListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the names of the secrets",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireLocalSecrets(); err != nil {
+			return err
+		}
+		secrets, err := internal.LoadSecrets()
+		if err != nil {
+			return err
+		}
+		names := make([]string, 0, len(secrets))
+		for name := range secrets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	},
+}
+
+// secretRemoveCmd represents the secret remove command
+var secretRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a secret",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireLocalSecrets(); err != nil {
+			return err
+		}
+		secrets, err := internal.LoadSecrets()
+		if err != nil {
+			return err
+		}
+		if _, exists := secrets[args[0]]; !exists {
+			return fmt.Errorf("secret '%s' is not in the secrets store", args[0])
+		}
+		delete(secrets, args[0])
+		if err := internal.SaveSecrets(secrets); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Secret %s removed\n", args[0])
+		return nil
+	},
+}
+
+// secretRekeyCmd represents the secret rekey command
+var secretRekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Re-encrypt the secrets store with the configured keys",
+	Long: `Re-encrypt the secrets store with secrets.encryption and its keys. A
+plaintext store, or one encrypted with the other tool, is converted and
+removed. After replacing an age identity, pass the old one with
+--old-identity to decrypt the store; GPG finds its key in the keyring.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireLocalSecrets(); err != nil {
+			return err
+		}
+		oldIdentity, _ := cmd.Flags().GetString("old-identity")
+		oldPath, newPath, err := internal.RekeySecrets(oldIdentity)
+		if err != nil {
+			return err
+		}
+		if oldPath != newPath {
+			fmt.Printf("✅ Secrets moved from %s to %s\n", oldPath, newPath)
+			return nil
+		}
+		fmt.Printf("✅ Secrets in %s re-encrypted\n", newPath)
+		return nil
+	},
+}
+
+// requireLocalSecrets refuses to manage the local secrets store while
+// secrets are read from another backend.
+func requireLocalSecrets() error {
+	config, err := internal.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if backend := config.Secrets.SecretBackend(); backend != internal.SecretBackendLocal {
+		return fmt.Errorf("secrets.backend is %s: manage the secrets there, run secret only edits the local store", backend)
+	}
+	return nil
+}
+
+// readSecretValue reads a secret from stdin, prompting without echo at a
+// terminal.
+func readSecretValue(name string) (string, error) {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the secret: %v", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Value of %s: ", name)
+	stty := func(args ...string) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = os.Stdin
+		cmd.Run()
+	}
+	stty("-echo")
+	defer stty("echo")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	fmt.Fprintln(os.Stderr)
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read the secret: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func init() {
+	rootCmd.AddCommand(secretCmd)
+	secretCmd.AddCommand(secretGetCmd)
+	secretCmd.AddCommand(secretSetCmd)
+	secretCmd.AddCommand(secretListCmd)
+	secretCmd.AddCommand(secretRemoveCmd)
+	secretCmd.AddCommand(secretRekeyCmd)
+
+	secretRekeyCmd.Flags().String("old-identity", "", "age identity the store is currently encrypted to")
+}
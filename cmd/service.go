@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// serviceCmd represents the service command
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Run your own apps as systemd services",
+	Long: `Create sandboxed systemd services for apps that are not run by pm2.
+
+Services restart on failure, run as the given user and see a read-only file
+system apart from their working directory, /var/lib/<name> and
+/var/log/<name>. run remembers the services it created, so they can be
+listed and removed later.
+
+Examples:
+  run service create api --exec "/opt/api/bin/server --port 8080" --user deploy --env-file /opt/api/.env
+  run service list
+  run service remove api`,
+}
+
+// serviceCreateCmd represents the service create command
+var serviceCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create, enable and start a service",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		command, _ := cmd.Flags().GetString("exec")
+		user, _ := cmd.Flags().GetString("user")
+		envFile, _ := cmd.Flags().GetString("env-file")
+		workdir, _ := cmd.Flags().GetString("workdir")
+		if user == "" {
+			config, err := internal.LoadConfig()
+			if err != nil {
+				return err
+			}
+			_, profile, err := internal.ActiveCloudProfile(config)
+			if err != nil {
+				return err
+			}
+			user = profile.AppUser()
+		}
+
+		service := internal.ManagedService{Exec: command, User: user, EnvFile: envFile, WorkingDirectory: workdir}
+		if err := internal.CreateService(args[0], service); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Service %s created and started. Follow its logs with: journalctl -u %s -f\n", args[0], args[0])
+		if _, err := internal.ApplyLogForwarding(); err != nil {
+			fmt.Printf("⚠️  Log forwarding not updated: %v\n", err)
+		}
+		return nil
+	},
+}
+
+// serviceRemoveCmd represents the service remove command
+var serviceRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Stop and remove a service created by run",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !internal.Confirm(fmt.Sprintf("Stop and remove service %s?", args[0])) {
+			return fmt.Errorf("removal cancelled")
+		}
+		if err := internal.RemoveService(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Service %s removed\n", args[0])
+		if _, err := internal.ApplyLogForwarding(); err != nil {
+			fmt.Printf("⚠️  Log forwarding not updated: %v\n", err)
+		}
+		return nil
+	},
+}
+
+// serviceListCmd represents the service list command
+var serviceListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the services created by run",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		state, err := internal.LoadState()
+		if err != nil {
+			return err
+		}
+		if len(state.Services) == 0 {
+			fmt.Println("No services created yet")
+			return nil
+		}
+
+		var names []string
+		for name := range state.Services {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			service := state.Services[name]
+			status := "stopped"
+			if internal.ServiceActive(name) {
+				status = "running"
+			}
+			fmt.Printf("%s (%s, user %s): %s\n", name, status, service.User, service.Exec)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serviceCmd)
+	serviceCmd.AddCommand(serviceCreateCmd)
+	serviceCmd.AddCommand(serviceRemoveCmd)
+	serviceCmd.AddCommand(serviceListCmd)
+
+	serviceCreateCmd.Flags().String("exec", "", "command to run (required)")
+	serviceCreateCmd.Flags().String("user", "", "user to run as (default: the app user of the cloud profile, or you)")
+	serviceCreateCmd.Flags().String("env-file", "", "file of KEY=value lines loaded into the environment")
+	serviceCreateCmd.Flags().String("workdir", "", "working directory, left writable")
+	serviceCreateCmd.MarkFlagRequired("exec")
+}
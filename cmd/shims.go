@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// shimsCmd represents the shims command
+var shimsCmd = &cobra.Command{
+	Use:   "shims",
+	Short: "Refresh the shims of nvm, pyenv and sdkman default versions",
+	Long: `Write static shims in ~/.run/shims for the executables of the default
+nvm, pyenv and sdkman versions, and put that directory on PATH through
+~/.run/env. Unlike the version managers, the shims need no shell setup, so
+cron jobs and systemd units can call them by absolute path.
+
+'run use' refreshes the shims; run this after installing or switching
+versions with the version managers directly.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		shims, err := internal.SyncShims()
+		if err != nil {
+			return err
+		}
+		if len(shims) == 0 {
+			fmt.Println("No nvm, pyenv or sdkman default versions found")
+			return nil
+		}
+		dir, err := internal.ShimDir()
+		if err != nil {
+			return err
+		}
+		for _, shim := range shims {
+			fmt.Printf("%s/%s -> %s\n", dir, shim.Name, shim.Target)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(shimsCmd)
+}
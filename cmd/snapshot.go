@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// snapshotCmd represents the snapshot command
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Record installed packages and versions as JSON",
+	Long: `Record which packages are installed on this host and their versions.
+
+The JSON output can be compared with another host using 'run diff'.
+
+Examples:
+  run snapshot
+  run snapshot --out vm-a.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := json.MarshalIndent(internal.TakeSnapshot(), "", "  ")
+		if err != nil {
+			return err
+		}
+
+		out, _ := cmd.Flags().GetString("out")
+		if out == "" {
+			fmt.Println(string(data))
+			return nil
+		}
+		if err := os.WriteFile(out, append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", out, err)
+		}
+		fmt.Printf("✅ Snapshot written to %s\n", out)
+		return nil
+	},
+}
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff <left> <right>",
+	Short: "Compare packages between two hosts or snapshots",
+	Long: `Compare installed packages and versions between two snapshots.
+
+Each side is one of:
+  <file>                  a JSON file written by 'run snapshot'
+  local                   this host, inspected now
+  ssh://[user@]host       a remote host, inspected over SSH
+
+Examples:
+  run diff vm-a.json vm-b.json
+  run diff local ssh://azureuser@10.0.0.5`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		left, err := internal.LoadSnapshot(args[0])
+		if err != nil {
+			return err
+		}
+		right, err := internal.LoadSnapshot(args[1])
+		if err != nil {
+			return err
+		}
+
+		differences := internal.DiffSnapshots(left, right)
+		if len(differences) == 0 {
+			fmt.Printf("✅ No differences between %s and %s\n", left.Host, right.Host)
+			return nil
+		}
+
+		fmt.Printf("%-12s %-20s %-20s\n", "PACKAGE", left.Host, right.Host)
+		for _, difference := range differences {
+			fmt.Printf("%-12s %-20s %-20s\n", difference.Package, difference.Left.Describe(), difference.Right.Describe())
+		}
+		return fmt.Errorf("%d package(s) differ", len(differences))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+	rootCmd.AddCommand(diffCmd)
+	snapshotCmd.Flags().StringP("out", "o", "", "write the snapshot to a file instead of stdout")
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/amoga-io/run/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// statsCmd represents the stats command
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Summarize the install history of this host",
+	Long: `Summarize what run did on this host: the packages installed most, how
+often installing or removing each failed, how long installs take and
+which versions were installed, how often run was updated and rolled back,
+and how many crash reports were written.
+
+Installs, removals, updates and rollbacks are recorded in
+~/.run/history.jsonl, which keeps the last 5000 operations. Nothing is
+sent anywhere.
+
+Examples:
+  run stats
+  run stats --since 720h
+  run stats --format json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		if format != "text" && format != "json" {
+			return fmt.Errorf("unknown format '%s': use text or json", format)
+		}
+		var since time.Time
+		if window, _ := cmd.Flags().GetDuration("since"); window > 0 {
+			since = time.Now().Add(-window)
+		}
+
+		stats, err := internal.GetHistoryStats(since)
+		if err != nil {
+			return err
+		}
+		if format == "json" {
+			data, err := json.MarshalIndent(stats, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+		printHistoryStats(stats)
+		return nil
+	},
+}
+
+// printHistoryStats prints the history summary as a table.
+func printHistoryStats(stats internal.HistoryStats) {
+	if stats.Entries == 0 && stats.Crashes == 0 {
+		fmt.Println("No operations recorded yet")
+		return
+	}
+	if len(stats.Packages) > 0 {
+		fmt.Printf("%-16s %8s %8s %8s %8s %9s  %s\n", "PACKAGE", "INSTALLS", "REMOVALS", "FAILURES", "FAILED", "AVG TIME", "VERSIONS")
+		for _, pkg := range stats.Packages {
+			average := "-"
+			if pkg.AverageSeconds > 0 {
+				average = time.Duration(pkg.AverageSeconds * float64(time.Second)).Round(time.Second).String()
+			}
+			fmt.Printf("%-16s %8d %8d %8d %7.0f%% %9s  %s\n", pkg.Package, pkg.Installs, pkg.Removals, pkg.Failures,
+				pkg.FailureRate*100, average, strings.Join(pkg.Versions, ", "))
+		}
+		fmt.Println()
+	}
+	fmt.Printf("Updates of run: %d, rollbacks: %d\n", stats.Updates, stats.Rollbacks)
+	fmt.Printf("Crash reports: %d\n", stats.Crashes)
+}
+
+// recordHistory adds the installs and removals among results to the history
+// `run stats` summarizes. Skipped operations, such as unchanged steps, are
+// left out.
+func recordHistory(results []output.PackageResult) {
+	var entries []internal.HistoryEntry
+	for _, result := range results {
+		if result.Status == output.StatusSkipped {
+			continue
+		}
+		entry := internal.HistoryEntry{
+			Package:   result.Package,
+			Operation: result.Operation,
+			OK:        result.Status == output.StatusOK,
+			Version:   result.Version,
+			Seconds:   result.Duration.Seconds(),
+		}
+		if !entry.OK {
+			entry.Message = result.Message
+		}
+		entries = append(entries, entry)
+	}
+	if err := internal.RecordHistory(entries...); err != nil {
+		internal.Warn("", "History not recorded: %v", err)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+	statsCmd.Flags().Duration("since", 0, "only count operations in this window, e.g. 720h")
+	statsCmd.Flags().String("format", "text", "output format: text or json")
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// systemCmd represents the system command
+var systemCmd = &cobra.Command{
+	Use:   "system",
+	Short: "Manage host settings",
+}
+
+// swapCmd represents the system swap command
+var swapCmd = &cobra.Command{
+	Use:   "swap",
+	Short: "Manage the swapfile",
+	Long: `Manage ` + internal.SwapFile + `, the swapfile run creates.
+
+Small VMs (1GB of memory) run out of memory building native node modules or
+Python wheels; a swapfile lets the build finish. run check --system warns
+when memory plus swap is below 2G, and run doctor --fix creates a 2G
+swapfile.
+
+Examples:
+  run system swap create 2G
+  run system swap status
+  run system swap remove`,
+}
+
+// swapCreateCmd represents the system swap create command
+var swapCreateCmd = &cobra.Command{
+	Use:   "create <size>",
+	Short: "Create, enable and persist a swapfile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		size, err := internal.ParseSize(args[0])
+		if err != nil {
+			return err
+		}
+		if err := internal.CreateSwap(size); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Swapfile %s enabled and added to /etc/fstab\n", internal.SwapFile)
+		return nil
+	},
+}
+
+// swapStatusCmd represents the system swap status command
+var swapStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show active swap",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		areas, err := internal.SwapAreas()
+		if err != nil {
+			return err
+		}
+		if len(areas) == 0 {
+			fmt.Println("No swap enabled")
+		}
+		for _, area := range areas {
+			fmt.Printf("%s: %d MiB, %d MiB used\n", area.Path, area.Size>>20, area.Used>>20)
+		}
+		if internal.SwapInFstab() {
+			fmt.Printf("%s is enabled at boot\n", internal.SwapFile)
+		}
+		return nil
+	},
+}
+
+// swapRemoveCmd represents the system swap remove command
+var swapRemoveCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "Disable and delete the swapfile",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !internal.Confirm(fmt.Sprintf("Disable and delete %s?", internal.SwapFile)) {
+			return fmt.Errorf("removal cancelled")
+		}
+		if err := internal.RemoveSwap(); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Swapfile %s removed\n", internal.SwapFile)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(systemCmd)
+	systemCmd.AddCommand(swapCmd)
+	swapCmd.AddCommand(swapCreateCmd)
+	swapCmd.AddCommand(swapStatusCmd)
+	swapCmd.AddCommand(swapRemoveCmd)
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/amoga-io/run/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// testPackageCmd represents the test-package command
+var testPackageCmd = &cobra.Command{
+	Use:   "test-package <package>",
+	Short: "Test a package's install, check and remove in a container",
+	Long: `Test a package in a disposable Ubuntu container, so its scripts can be
+tried without touching this machine: 'run install', 'run check' and 'run
+remove' run in turn inside it, and the container is removed afterwards.
+
+The container gets this run binary, the scripts directory in use (see
+--scripts-dir) and the registry overlay in ~/.run/registry/user.yaml, so
+scripts and definitions being developed are what is tested. docker must be
+installed; it is run with sudo.
+
+Containers have no systemd, so services are installed but not started
+there. Packages that run containers themselves, such as docker, need
+--privileged.
+
+Examples:
+  run test-package node
+  run test-package --scripts-dir ./scripts nginx
+  run test-package php --image ubuntu:22.04 --env PHP_VERSION=8.2
+  run test-package docker --privileged --keep`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+		var options internal.PackageTestOptions
+		options.Image, _ = cmd.Flags().GetString("image")
+		options.Privileged, _ = cmd.Flags().GetBool("privileged")
+		keep, _ := cmd.Flags().GetBool("keep")
+		env, _ := cmd.Flags().GetStringArray("env")
+		packageName := args[0]
+
+		container, err := internal.StartTestContainer(packageName, options)
+		if err != nil {
+			return err
+		}
+		results := testPackageSteps(container, packageName, env)
+
+		if keep {
+			fmt.Fprintf(internal.Console, "Container kept. Inspect it with: sudo docker exec -it %s bash\n", container)
+		} else if err := internal.RemoveTestContainer(container); err != nil {
+			internal.Warn(packageName, "%v", err)
+		}
+		return renderResults(format, results)
+	},
+}
+
+// testPackageSteps runs the steps of a package test in a container. Once
+// the install succeeded, remove runs even when the check failed, so it is
+// tested too; after a failed install the other steps are skipped.
+func testPackageSteps(container, packageName string, env []string) []output.PackageResult {
+	var results []output.PackageResult
+	installed := false
+	_, removable := internal.RemovePackageRegistry[packageName]
+	for _, step := range internal.PackageTestSteps {
+		result := output.PackageResult{Package: packageName, Operation: step, Status: output.StatusFailed}
+		switch {
+		case step != "install" && !installed:
+			result.Status, result.Message = output.StatusSkipped, "not installed"
+		case step == "remove" && !removable:
+			result.Status, result.Message = output.StatusSkipped, "no removal script"
+		default:
+			fmt.Fprintf(internal.Console, "==> run %s %s\n", step, packageName)
+			started := time.Now()
+			err := internal.RunTestStep(container, step, packageName, env)
+			result.Duration = time.Since(started)
+			if err != nil {
+				result.Message = err.Error()
+			} else {
+				result.Status, result.Message = output.StatusOK, "passed"
+				installed = installed || step == "install"
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func init() {
+	rootCmd.AddCommand(testPackageCmd)
+	testPackageCmd.Flags().String("image", internal.DefaultTestImage, "Ubuntu image to test in")
+	testPackageCmd.Flags().Bool("privileged", false, "run the container privileged, for packages such as docker")
+	testPackageCmd.Flags().Bool("keep", false, "leave the container running to inspect it")
+	testPackageCmd.Flags().StringArray("env", nil, "set a variable for the package scripts, as KEY=value")
+	addFormatFlag(testPackageCmd)
+}
@@ -8,6 +8,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/amoga-io/run/internal"
+	"github.com/amoga-io/run/internal/system"
 	"github.com/spf13/cobra"
 )
 
@@ -17,29 +19,89 @@ var updateCmd = &cobra.Command{
 	Short: "Update CLI to latest version from Git",
 	Long: `Pull latest changes from Git repository and rebuild the binary.
 
+The repository and branch default to github.com/amoga-io/run and main. Point
+them at an internal fork or mirror in ~/.run/config.yaml; the remote must
+serve the ` + internal.ModulePath + ` module:
+
+  update:
+    remote: git@git.example.com:platform/run.git
+    branch: stable
+    ssh_key: ~/.ssh/run_deploy_key
+    prefix: ~/.local        # installs to ~/.local/bin (default /usr/local)
+
 The update process:
-  1. Fetches latest changes from the repository
+  1. Fetches latest changes from the repository and shows the new commits
+     and CHANGELOG entries, asking before applying them (--yes skips this)
   2. Handles any local changes gracefully
-  3. Rebuilds the binary with latest features
-  4. Installs the updated binary atomically
+  3. Rebuilds the binary with latest features, reusing the Go module and
+     build caches in ~/.run/cache; skipped when nothing changed (--force
+     rebuilds anyway)
+  4. Verifies the new binary (--version and a self-test)
+  5. Installs it atomically, keeping the previous binary as run.prev and
+     restoring it if the installed binary fails verification
 
 Requirements:
   • Git must be available
   • Go must be available for building
-  • Sudo access for binary installation
+  • Sudo access for binary installation, unless update.prefix is writable
 
 Examples:
-  run update`,
+  run update
+  run update --yes        # for unattended hosts
+  run update --rollback   # return to the previous binary`,
 	RunE: runUpdate,
 }
 
+// gitCommand returns a git command that reaches the update remote.
+func gitCommand(source internal.UpdateConfig, args ...string) *system.Cmd {
+	return system.Command("git", args...).WithEnv(source.GitEnv()...).WithTimeout(gitTimeout)
+}
+
+// gitTimeout bounds git commands that reach the update remote, so a stalled
+// connection cannot hang an unattended update.
+const gitTimeout = 5 * time.Minute
+
 func runUpdate(cmd *cobra.Command, args []string) error {
+	if rollback, _ := cmd.Flags().GetBool("rollback"); rollback {
+		fmt.Println("⏪ Rolling back to the previous binary...")
+		if err := rollbackBinary(); err != nil {
+			return err
+		}
+		if err := internal.RecordHistory(internal.HistoryEntry{Package: internal.CLIName, Operation: "rollback", OK: true}); err != nil {
+			internal.Warn("", "History not recorded: %v", err)
+		}
+		fmt.Println("✅ Rolled back. Run 'run update --rollback' again to undo.")
+		if version := getCurrentVersion(); version != "" {
+			fmt.Printf("📦 Current version: %s\n", version)
+		}
+		return nil
+	}
+	if upgrade := internal.PackageManagerUpgrade(); upgrade != "" {
+		return fmt.Errorf("run was installed by a package manager; update it with: %s", upgrade)
+	}
+	if err := internal.RequireOnline(); err != nil {
+		return err
+	}
 	fmt.Println("🔄 Updating run CLI...")
 
 	// Check for required dependencies
 	if err := checkUpdateDependencies(); err != nil {
 		return fmt.Errorf("dependency check failed: %w", err)
 	}
+	stopSudo, err := internal.PrepareSudo()
+	if err != nil {
+		return err
+	}
+	defer stopSudo()
+
+	config, err := internal.LoadConfig()
+	if err != nil {
+		return err
+	}
+	source := config.Update.WithDefaults()
+	if err := source.Validate(); err != nil {
+		return err
+	}
 
 	// Find the repository directory
 	homeDir := os.Getenv("HOME")
@@ -51,11 +113,8 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 	// Check if repository exists
 	if _, err := os.Stat(repoDir); os.IsNotExist(err) {
 		// Repository doesn't exist, clone it
-		fmt.Println("📥 Cloning repository...")
-		cloneCmd := exec.Command("git", "clone", "https://github.com/amoga-io/run.git", repoDir)
-		cloneCmd.Stdout = os.Stdout
-		cloneCmd.Stderr = os.Stderr
-		if err := cloneCmd.Run(); err != nil {
+		fmt.Printf("📥 Cloning %s...\n", source.Remote)
+		if err := gitCommand(source, "clone", "--branch", source.Branch, source.Remote, repoDir).Stream(os.Stdout, os.Stderr); err != nil {
 			return fmt.Errorf("failed to clone repository: %w", err)
 		}
 		fmt.Println("✅ Repository cloned successfully")
@@ -84,12 +143,18 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 	}
 
 	// Update repository
-	if err := updateRepository(); err != nil {
+	proceed, err := updateRepository(source)
+	if err != nil {
 		return fmt.Errorf("failed to update repository: %w", err)
 	}
+	if !proceed {
+		fmt.Println("Update cancelled")
+		return nil
+	}
 
 	// Build and install
-	if err := buildAndInstall(); err != nil {
+	force, _ := cmd.Flags().GetBool("force")
+	if err := buildAndInstall(force); err != nil {
 		return fmt.Errorf("failed to build and install: %w", err)
 	}
 
@@ -125,73 +190,131 @@ func checkUpdateDependencies() error {
 	return nil
 }
 
-// updateRepository updates the git repository
-func updateRepository() error {
+// updateRepository updates the git repository to the configured remote and
+// branch after showing what the update brings in and asking for
+// confirmation. It reports whether the update went ahead.
+func updateRepository(source internal.UpdateConfig) (bool, error) {
 	fmt.Println("🔄 Pulling latest changes...")
 
+	// Follow changes of update.remote, e.g. a move to an internal fork
+	if err := system.Command("git", "remote", "set-url", "origin", source.Remote).Run(); err != nil {
+		return false, fmt.Errorf("failed to set remote %s: %w", source.Remote, err)
+	}
+
 	// Fetch latest changes
-	fmt.Println("📡 Fetching from remote...")
-	fetchCmd := exec.Command("git", "fetch", "origin", "main")
-	if err := fetchCmd.Run(); err != nil {
-		return fmt.Errorf("failed to fetch latest changes: %w", err)
+	fmt.Printf("📡 Fetching %s from %s...\n", source.Branch, source.Remote)
+	if err := gitCommand(source, "fetch", "origin", source.Branch).Run(); err != nil {
+		return false, fmt.Errorf("failed to fetch latest changes: %w", err)
+	}
+	target := "origin/" + source.Branch
+	if err := internal.CheckUpdateModule(".", target); err != nil {
+		return false, fmt.Errorf("refusing to update from %s: %w", source.Remote, err)
+	}
+
+	if previewUpdate(target) && !internal.Confirm("Apply this update?") {
+		return false, nil
 	}
 
 	// Check if we have local changes
-	statusCmd := exec.Command("git", "status", "--porcelain")
-	statusOutput, _ := statusCmd.Output()
+	statusOutput, _ := system.Command("git", "status", "--porcelain").CaptureOutput()
 
 	if len(statusOutput) > 0 {
 		fmt.Println("⚠️  Local changes detected, stashing them...")
 		// Stash any local changes
-		stashCmd := exec.Command("git", "stash", "push", "-m", "Auto-stash before update")
-		stashCmd.Run() // Don't fail if nothing to stash
+		system.Command("git", "stash", "push", "-m", "Auto-stash before update").Run() // Don't fail if nothing to stash
 	}
 
 	// Hard reset to match remote (overwrites local changes)
 	fmt.Println("🔄 Applying latest changes...")
-	resetCmd := exec.Command("git", "reset", "--hard", "origin/main")
-	if err := resetCmd.Run(); err != nil {
-		return fmt.Errorf("failed to reset to latest changes: %w", err)
+	if err := system.Command("git", "reset", "--hard", target).Run(); err != nil {
+		return false, fmt.Errorf("failed to reset to latest changes: %w", err)
 	}
 
 	// Clean any untracked files
-	cleanCmd := exec.Command("git", "clean", "-fd")
-	cleanCmd.Run() // Don't fail on this
+	system.Command("git", "clean", "-fd").Run() // Don't fail on this
 
 	fmt.Println("✅ Repository updated to latest version")
-	return nil
+	return true, nil
+}
+
+// previewUpdate prints the commits between the installed version and the
+// target ref, and the CHANGELOG.md entries they add. It reports whether
+// there is anything new.
+func previewUpdate(target string) bool {
+	logOutput, err := system.Command("git", "log", "--oneline", "--no-decorate", "HEAD.."+target).CaptureOutput()
+	commits := strings.TrimSpace(string(logOutput))
+	if err != nil || commits == "" {
+		fmt.Println("✅ No new commits since the installed version")
+		return false
+	}
+
+	fmt.Printf("\n📝 %d new commit(s):\n", len(strings.Split(commits, "\n")))
+	for _, line := range strings.Split(commits, "\n") {
+		fmt.Printf("  %s\n", line)
+	}
+
+	diffOutput, _ := system.Command("git", "diff", "--unified=0", "HEAD", target, "--", "CHANGELOG.md").CaptureOutput()
+	var entries []string
+	for _, line := range strings.Split(string(diffOutput), "\n") {
+		if strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "+++") {
+			entries = append(entries, strings.TrimPrefix(line, "+"))
+		}
+	}
+	if len(entries) > 0 {
+		fmt.Println("\n📰 CHANGELOG:")
+		for _, entry := range entries {
+			fmt.Printf("  %s\n", entry)
+		}
+	}
+	fmt.Println()
+	return true
 }
 
-// buildAndInstall builds the binary and installs it
-func buildAndInstall() error {
+// buildAndInstall builds the binary and installs it. Go modules and build
+// artifacts are cached under ~/.run/cache, and the build is skipped when the
+// installed binary was built from the current commit, unless force is set.
+func buildAndInstall(force bool) error {
+	binaryName := "run"
+	commit := getCommitInfo()
+
+	state, err := internal.LoadState()
+	if err != nil {
+		return err
+	}
+	last := state.UpdateBuild
+	if _, statErr := os.Stat(filepath.Join(binaryInstallDir(), binaryName)); statErr == nil && !force && last != nil && last.Commit == commit {
+		fmt.Printf("⏭️  Installed binary is already built from %s, skipping the rebuild (saved ~%.0fs; use --force to rebuild)\n", commit, last.Seconds)
+		return nil
+	}
+
+	cacheEnv, err := internal.UpdateCacheEnv()
+	if err != nil {
+		return err
+	}
+	started := time.Now()
+
 	// Prepare Go modules
 	fmt.Println("📦 Preparing Go modules...")
-	modCmd := exec.Command("go", "mod", "tidy")
-	if err := modCmd.Run(); err != nil {
+	if err := system.Command("go", "mod", "download").WithEnv(cacheEnv...).WithTimeout(gitTimeout).Run(); err != nil {
 		return fmt.Errorf("failed to prepare Go modules: %w", err)
 	}
 
 	// Build new binary
 	fmt.Println("🔨 Building new binary...")
-	binaryName := "run"
 
 	// Get version information for build
 	version := getVersionInfo()
-	commit := getCommitInfo()
 	buildDate := time.Now().UTC().Format("2006-01-02T15:04:05Z")
 
 	fmt.Printf("📋 Building version: %s (commit: %s)\n", version, commit)
 
 	// Build with version information embedded
-	buildCmd := exec.Command("go", "build",
+	buildCmd := system.Command("go", "build",
 		"-ldflags", fmt.Sprintf(`-X 'main.Version=%s' -X 'main.GitCommit=%s' -X 'main.BuildDate=%s'`,
 			version, commit, buildDate),
-		"-o", binaryName, ".")
+		"-o", binaryName, ".").WithEnv(cacheEnv...)
 
-	buildCmd.Stdout = os.Stdout
-	buildCmd.Stderr = os.Stderr
-
-	if err := buildCmd.Run(); err != nil {
+	if err := buildCmd.Stream(os.Stdout, os.Stderr); err != nil {
 		return fmt.Errorf("failed to build new binary: %w", err)
 	}
 
@@ -200,53 +323,185 @@ func buildAndInstall() error {
 		return fmt.Errorf("binary was not created successfully")
 	}
 
+	build := &internal.UpdateBuild{Commit: commit, Seconds: time.Since(started).Seconds()}
+	build.SlowestSeconds = build.Seconds
+	if last != nil && last.SlowestSeconds > build.Seconds {
+		build.SlowestSeconds = last.SlowestSeconds
+		fmt.Printf("⚡ Built in %.0fs, %.0fs faster than the slowest build thanks to the cache\n", build.Seconds, build.SlowestSeconds-build.Seconds)
+	} else {
+		fmt.Printf("⏱️  Built in %.0fs\n", build.Seconds)
+	}
+
 	// Install the updated binary
 	fmt.Println("📥 Installing updated binary...")
 	if err := installBinary(binaryName); err != nil {
 		return fmt.Errorf("failed to install binary: %w", err)
 	}
 
+	state.UpdateBuild = build
+	if err := state.Save(); err != nil {
+		return err
+	}
+	if err := internal.RecordHistory(internal.HistoryEntry{Package: internal.CLIName, Operation: "update", OK: true, Version: version, Seconds: build.Seconds}); err != nil {
+		internal.Warn("", "History not recorded: %v", err)
+	}
 	fmt.Println("✅ Binary installed successfully")
 	return nil
 }
 
-// installBinary installs the binary atomically
+// binaryInstallDir returns where the CLI binary is installed: the bin
+// directory of update.prefix, /usr/local/bin by default.
+func binaryInstallDir() string {
+	config, err := internal.LoadConfig()
+	if err != nil {
+		return internal.UpdateConfig{}.WithDefaults().BinDir()
+	}
+	return config.Update.WithDefaults().BinDir()
+}
+
+// installDirCommand returns a command that changes the install directory,
+// run with sudo unless the user can write there, as with a prefix in their
+// home.
+func installDirCommand(dir, name string, args ...string) *system.Cmd {
+	command := system.Command(name, args...)
+	if !internal.DirWritable(dir) {
+		command.WithSudo()
+	}
+	return command
+}
+
+// ensureBinDirOnPath adds an install directory other than the default to
+// PATH through the managed env file.
+func ensureBinDirOnPath(dir string) error {
+	if dir == (internal.UpdateConfig{}).WithDefaults().BinDir() {
+		return nil
+	}
+	env, err := internal.LoadManagedEnv()
+	if err != nil {
+		return err
+	}
+	for _, entry := range env.Path {
+		if entry == dir {
+			return nil
+		}
+	}
+	env.AddPath(dir)
+	if err := env.Save(); err != nil {
+		return err
+	}
+	fmt.Printf("📍 Added %s to PATH in ~/.run/env; open a new shell to pick it up\n", dir)
+	return nil
+}
+
+// installBinary installs the binary atomically, keeping the previous one as
+// run.prev. The new binary is verified before and after it replaces the old
+// one; if it fails after, the previous binary is restored.
 func installBinary(binaryName string) error {
-	installDir := "/usr/local/bin"
+	installDir := binaryInstallDir()
+	// A prefix in the home directory is created as the user, others with sudo
+	if err := os.MkdirAll(installDir, 0755); err != nil {
+		if err := system.Command("mkdir", "-p", installDir).WithSudo().Run(); err != nil {
+			return fmt.Errorf("failed to create %s: %w", installDir, err)
+		}
+	}
 	finalBinary := filepath.Join(installDir, binaryName)
+	prevBinary := finalBinary + ".prev"
 
 	// Use atomic replacement to avoid "text file busy" errors
 	tempBinary := filepath.Join(installDir, binaryName+".new")
 
 	// Copy to temporary location
-	copyCmd := exec.Command("sudo", "cp", binaryName, tempBinary)
-	if err := copyCmd.Run(); err != nil {
+	if err := installDirCommand(installDir, "cp", binaryName, tempBinary).Run(); err != nil {
 		return fmt.Errorf("failed to copy binary: %w", err)
 	}
 
 	// Make executable
-	chmodCmd := exec.Command("sudo", "chmod", "+x", tempBinary)
-	if err := chmodCmd.Run(); err != nil {
+	if err := installDirCommand(installDir, "chmod", "+x", tempBinary).Run(); err != nil {
 		// Clean up temp file on failure
-		exec.Command("sudo", "rm", "-f", tempBinary).Run()
+		installDirCommand(installDir, "rm", "-f", tempBinary).Run()
 		return fmt.Errorf("failed to make binary executable: %w", err)
 	}
 
+	// Refuse a binary that does not start before touching the installed one
+	if err := verifyBinary(tempBinary); err != nil {
+		installDirCommand(installDir, "rm", "-f", tempBinary).Run()
+		return fmt.Errorf("new binary failed verification, keeping the installed one: %w", err)
+	}
+
+	// Keep the installed binary for rollback
+	hasPrevious := false
+	if _, err := os.Stat(finalBinary); err == nil {
+		if err := installDirCommand(installDir, "cp", "-p", finalBinary, prevBinary).Run(); err != nil {
+			installDirCommand(installDir, "rm", "-f", tempBinary).Run()
+			return fmt.Errorf("failed to keep the previous binary: %w", err)
+		}
+		hasPrevious = true
+	}
+
 	// Atomically replace the binary
-	mvCmd := exec.Command("sudo", "mv", tempBinary, finalBinary)
-	if err := mvCmd.Run(); err != nil {
+	if err := installDirCommand(installDir, "mv", tempBinary, finalBinary).Run(); err != nil {
 		// Clean up temp file on failure
-		exec.Command("sudo", "rm", "-f", tempBinary).Run()
+		installDirCommand(installDir, "rm", "-f", tempBinary).Run()
 		return fmt.Errorf("failed to replace binary: %w", err)
 	}
 
+	if err := verifyBinary(finalBinary); err != nil {
+		if !hasPrevious {
+			return fmt.Errorf("installed binary failed verification: %w", err)
+		}
+		fmt.Println("⚠️  Installed binary failed verification, restoring the previous one...")
+		if restoreErr := installDirCommand(installDir, "cp", "-p", prevBinary, finalBinary).Run(); restoreErr != nil {
+			return fmt.Errorf("installed binary failed verification (%v) and restoring %s failed: %w", err, prevBinary, restoreErr)
+		}
+		return fmt.Errorf("installed binary failed verification, previous binary restored: %w", err)
+	}
+	return ensureBinDirOnPath(installDir)
+}
+
+// verifyTimeout bounds each self-test of a new binary, so one that hangs
+// is rejected instead of blocking the update.
+const verifyTimeout = 30 * time.Second
+
+// verifyBinary checks that a binary starts and passes its self-test.
+func verifyBinary(path string) error {
+	for _, args := range [][]string{{"--version"}, {"verify"}} {
+		if err := system.Command(path, args...).WithTimeout(verifyTimeout).Run(); err != nil {
+			return fmt.Errorf("%s %s: %w", filepath.Base(path), strings.Join(args, " "), err)
+		}
+	}
+	return nil
+}
+
+// rollbackBinary swaps the installed binary with run.prev, so a second
+// rollback returns to the newer binary.
+func rollbackBinary() error {
+	installDir := binaryInstallDir()
+	finalBinary := filepath.Join(installDir, "run")
+	prevBinary := finalBinary + ".prev"
+	if _, err := os.Stat(prevBinary); os.IsNotExist(err) {
+		return fmt.Errorf("no previous binary to roll back to (%s does not exist)", prevBinary)
+	}
+	if err := verifyBinary(prevBinary); err != nil {
+		return fmt.Errorf("previous binary failed verification, keeping the installed one: %w", err)
+	}
+
+	swapBinary := finalBinary + ".swap"
+	steps := [][]string{
+		{"cp", "-p", finalBinary, swapBinary},
+		{"mv", prevBinary, finalBinary},
+		{"mv", swapBinary, prevBinary},
+	}
+	for _, step := range steps {
+		if err := installDirCommand(installDir, step[0], step[1:]...).Run(); err != nil {
+			return fmt.Errorf("failed to roll back: %s: %w", strings.Join(step, " "), err)
+		}
+	}
 	return nil
 }
 
 // getVersionInfo gets version information from git
 func getVersionInfo() string {
-	versionCmd := exec.Command("git", "describe", "--tags", "--always")
-	versionOutput, err := versionCmd.Output()
+	versionOutput, err := system.Command("git", "describe", "--tags", "--always").CaptureOutput()
 	if err != nil {
 		return "v0.0.0-dev"
 	}
@@ -255,8 +510,7 @@ func getVersionInfo() string {
 
 // getCommitInfo gets commit information from git
 func getCommitInfo() string {
-	commitCmd := exec.Command("git", "rev-parse", "--short", "HEAD")
-	commitOutput, err := commitCmd.Output()
+	commitOutput, err := system.Command("git", "rev-parse", "--short", "HEAD").CaptureOutput()
 	if err != nil {
 		return "unknown"
 	}
@@ -266,12 +520,10 @@ func getCommitInfo() string {
 // getCurrentVersion gets the current version of the installed binary
 func getCurrentVersion() string {
 	// Try version command first
-	versionCmd := exec.Command("run", "version")
-	versionOutput, err := versionCmd.Output()
+	versionOutput, err := system.Command("run", "version").CaptureOutput()
 	if err != nil {
 		// Try --version flag as fallback
-		versionCmd = exec.Command("run", "--version")
-		versionOutput, err = versionCmd.Output()
+		versionOutput, err = system.Command("run", "--version").CaptureOutput()
 		if err != nil {
 			return "unknown"
 		}
@@ -281,4 +533,6 @@ func getCurrentVersion() string {
 
 func init() {
 	rootCmd.AddCommand(updateCmd)
+	updateCmd.Flags().Bool("rollback", false, "swap back to the binary the last update replaced")
+	updateCmd.Flags().Bool("force", false, "rebuild even if the installed binary is built from the latest commit")
 }
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// useCmd represents the use command
+var useCmd = &cobra.Command{
+	Use:   "use <package> [version]",
+	Short: "Switch the active version of a package",
+	Long: `Switch the system-wide default version of a package that has several
+versions installed side by side, using update-alternatives, or the nvm
+default for node.
+
+Supported packages: java, node, php, python
+
+Static shims in ~/.run/shims point at the default versions of nvm, pyenv and
+sdkman, so cron jobs and systemd units that never source their shell setup
+get them too (e.g. ~/.run/shims/node). They are refreshed by 'run use' and
+'run shims'.
+
+Switching php also installs the extensions managed with 'run php ext add'
+for the new version.
+
+Without a version, use lists the installed versions of java, node or php
+and the apps that target each.
+
+Examples:
+  run use php
+  run use java 17
+  run use node 20
+  run use php 8.2
+  run use python 3.11`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		packageName := args[0]
+		if len(args) == 1 {
+			return listVersions(packageName)
+		}
+		version := args[1]
+		if err := internal.UseVersion(packageName, version); err != nil {
+			return err
+		}
+		if err := internal.RecordVersions(packageName); err != nil {
+			fmt.Printf("⚠️  Installed versions not recorded: %v\n", err)
+		}
+		if _, err := internal.SyncShims(); err != nil {
+			fmt.Printf("⚠️  Shims not updated: %v\n", err)
+		}
+		fmt.Printf("Now using %s %s\n", packageName, version)
+		return nil
+	},
+}
+
+// listVersions prints the installed versions of a package, marking the
+// active one, with the apps that target each.
+func listVersions(packageName string) error {
+	if !internal.IsVersionedPackage(packageName) {
+		return fmt.Errorf("versions are not tracked for package '%s'", packageName)
+	}
+	versions := internal.InspectVersions(packageName)
+	if len(versions.Installed) == 0 {
+		return fmt.Errorf("%s is not installed", packageName)
+	}
+	for _, version := range versions.Installed {
+		marker := "  "
+		if version == versions.Active {
+			marker = "* "
+		}
+		var consumers []string
+		for consumer, target := range versions.Consumers {
+			if target == version {
+				consumers = append(consumers, consumer)
+			}
+		}
+		sort.Strings(consumers)
+		if len(consumers) > 0 {
+			fmt.Printf("%s%s (used by %s)\n", marker, version, strings.Join(consumers, ", "))
+		} else {
+			fmt.Printf("%s%s\n", marker, version)
+		}
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(useCmd)
+}
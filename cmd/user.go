@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// userCmd represents the user command
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage users and groups",
+}
+
+// userEnsureCmd represents the user ensure command
+var userEnsureCmd = &cobra.Command{
+	Use:   "ensure <name>",
+	Short: "Create a user and add it to groups, if needed",
+	Long: `Create a user, with a home directory and a group of the same name, unless
+it exists, then add it to the given groups, creating those that do not
+exist. --ssh-key adds keys to its ~/.ssh/authorized_keys: a key, a file or
+https URL listing keys, or github:<username>. Running it again changes
+nothing, so it is safe in provisioning scripts. Changes are recorded in
+~/.run/logs/audit.log.
+
+Profiles can list users to ensure before their packages are installed:
+
+  profiles:
+    web:
+      packages: [nginx, pm2]
+      users:
+        deploy: [www-data]
+      ssh_keys:
+        deploy: [github:octocat]
+
+Examples:
+  run user ensure deploy
+  run user ensure deploy --groups docker,www-data
+  run user ensure deploy --ssh-key github:octocat`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		groups, _ := cmd.Flags().GetStringSlice("groups")
+		sshKeys, _ := cmd.Flags().GetStringSlice("ssh-key")
+		if err := internal.EnsureUser(args[0], groups); err != nil {
+			return err
+		}
+		if len(sshKeys) > 0 {
+			if err := internal.DeploySSHKeys(args[0], sshKeys); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("✅ User %s is set up\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(userCmd)
+	userCmd.AddCommand(userEnsureCmd)
+	userEnsureCmd.Flags().StringSlice("groups", nil, "supplementary groups (comma-separated)")
+	userEnsureCmd.Flags().StringSlice("ssh-key", nil, "authorized key source: a key, file, https URL or github:<username>")
+}
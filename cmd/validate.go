@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate <file>...",
+	Short: "Lint package definitions before adding them",
+	Long: `Lint package definition files and print every problem found.
+
+Files named run.yaml are checked as manifests for 'run install --from-file';
+other files are checked as registry overlays, including that their scripts
+exist in the scripts directory (see --scripts-dir).
+
+Scripts are linted too: install scripts must use set -e, and commands that
+wait for input (apt-get without -y, read -p, editors) and unquoted
+variables are flagged with their line. shellcheck replaces the built-in
+quoting check when it is installed; a "# shellcheck disable=SC2086" line
+allows intended word splitting.
+
+Examples:
+  run validate ~/.run/registry/user.yaml
+  run validate --scripts-dir ./scripts internal/registry.yaml
+  run validate ./mytool/run.yaml`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		problems := 0
+		for _, path := range args {
+			errs := internal.ValidateFile(path)
+			if len(errs) == 0 {
+				fmt.Printf("✅ %s\n", path)
+				continue
+			}
+			fmt.Printf("❌ %s\n", path)
+			for _, err := range errs {
+				fmt.Printf("  %v\n", err)
+			}
+			problems += len(errs)
+		}
+
+		if problems > 0 {
+			return fmt.Errorf("%d problem(s) found", problems)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
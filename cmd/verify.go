@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/amoga-io/run/internal"
+)
+
+// verifyPackages reports the key files of packages that changed since they
+// were installed, offering to restore those with a backup.
+func verifyPackages(packages []string) error {
+	var restorable []internal.FileChange
+	changed := 0
+	for _, packageName := range packages {
+		if _, exists := internal.InstallPackageRegistry[packageName]; !exists {
+			return fmt.Errorf("unknown package '%s'", packageName)
+		}
+		changes, err := internal.VerifyPackageFiles(packageName)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			fmt.Printf("✅ %s: files unchanged\n", packageName)
+			continue
+		}
+		changed += len(changes)
+		fmt.Printf("❌ %s: %d file(s) changed\n", packageName, len(changes))
+		for _, change := range changes {
+			switch change.Kind {
+			case internal.FileMode:
+				fmt.Printf("  %s: mode or owner changed (%s)\n", change.Record.Path, change.Detail)
+			default:
+				fmt.Printf("  %s: %s\n", change.Record.Path, change.Kind)
+			}
+			if change.Diff != "" {
+				for _, line := range strings.Split(strings.TrimRight(change.Diff, "\n"), "\n") {
+					fmt.Printf("    %s\n", line)
+				}
+			}
+			if change.Record.Backup != "" {
+				restorable = append(restorable, change)
+			} else {
+				fmt.Printf("    No backup; reinstall %s to restore it\n", packageName)
+			}
+		}
+	}
+	if changed == 0 {
+		return nil
+	}
+
+	if len(restorable) > 0 && internal.Confirm(fmt.Sprintf("Restore %d file(s) from their backups?", len(restorable))) {
+		for _, change := range restorable {
+			if err := internal.RestoreFile(change.Record); err != nil {
+				return fmt.Errorf("failed to restore %s: %v", change.Record.Path, err)
+			}
+			fmt.Printf("↩️  Restored %s\n", change.Record.Path)
+			changed--
+		}
+		if changed == 0 {
+			fmt.Println("Restart or reload the affected services to use the restored files.")
+			return nil
+		}
+	}
+	return fmt.Errorf("%d file(s) changed", changed)
+}
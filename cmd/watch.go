@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Report drift from the applied profile periodically",
+	Long: `Compare the host with its baseline every --interval and report drift:
+packages removed or at another version, and services created with
+'run service create' that stopped.
+
+The baseline is recorded by 'run profile apply' in ~/.run/baseline.json;
+--baseline compares against a 'run snapshot' file instead. Drift is
+appended to ~/.run/logs/drift.log and, whenever it changes, posted as JSON
+to watch.webhook in ~/.run/config.yaml:
+
+  watch:
+    webhook: https://hooks.example.com/run-drift
+
+With --once, watch checks a single time and exits non-zero on drift, for
+cron or a systemd timer.
+
+Examples:
+  run watch --interval 1h
+  run watch --once --baseline golden.json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		source, _ := cmd.Flags().GetString("baseline")
+		if source == "" {
+			path, err := internal.BaselinePath()
+			if err != nil {
+				return err
+			}
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				return fmt.Errorf("no baseline at %s: run 'run profile apply' or pass --baseline", path)
+			}
+			source = path
+		}
+		baseline, err := internal.LoadSnapshot(source)
+		if err != nil {
+			return err
+		}
+		config, err := internal.LoadConfig()
+		if err != nil {
+			return err
+		}
+		interval, _ := cmd.Flags().GetDuration("interval")
+		if interval < time.Minute {
+			return fmt.Errorf("--interval must be at least 1m")
+		}
+
+		once, _ := cmd.Flags().GetBool("once")
+		var last []internal.Drift
+		for first := true; ; first = false {
+			drift, err := internal.DetectDrift(baseline)
+			if err != nil {
+				return err
+			}
+			reportDrift(drift)
+			if err := internal.LogDrift(drift); err != nil {
+				fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+			}
+			// Notify on changes only, so persistent drift is not re-sent every interval
+			changed := !reflect.DeepEqual(drift, last) && (!first || len(drift) > 0)
+			if config.Watch.Webhook != "" && changed {
+				if err := internal.NotifyDrift(config.Watch.Webhook, drift); err != nil {
+					fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+				}
+			}
+			last = drift
+
+			if once {
+				if len(drift) > 0 {
+					return fmt.Errorf("%d drift(s) from %s", len(drift), source)
+				}
+				return nil
+			}
+			time.Sleep(interval)
+		}
+	},
+}
+
+// reportDrift prints a timestamped drift report.
+func reportDrift(drift []internal.Drift) {
+	now := time.Now().Format("2006-01-02 15:04")
+	if len(drift) == 0 {
+		fmt.Printf("✅ %s no drift\n", now)
+		return
+	}
+	fmt.Printf("⚠️  %s %d drift(s):\n", now, len(drift))
+	for _, d := range drift {
+		fmt.Printf("  %s\n", d)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+	watchCmd.Flags().Duration("interval", time.Hour, "time between checks")
+	watchCmd.Flags().String("baseline", "", "compare against this 'run snapshot' file instead of the applied profile")
+	watchCmd.Flags().Bool("once", false, "check once and exit non-zero on drift")
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/amoga-io/run/internal"
+	"github.com/spf13/cobra"
+)
+
+// whichCmd represents the which command
+var whichCmd = &cobra.Command{
+	Use:   "which <file-or-command>...",
+	Short: "Show which package provided a file or command",
+	Long: `Show what provided a file, or a command found on PATH: the run package,
+the Debian package that owns it, or the version manager (nvm, pyenv,
+sdkman) or npm that installed it. Symlinks, alternatives and the shims in
+~/.run/shims are followed to the real binary.
+
+When a run package provided it, which also tells how to remove it and
+which installed packages depend on it.
+
+Examples:
+  run which node
+  run which /usr/sbin/nginx
+  run which java php --format json`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		if format != "text" && format != "json" {
+			return fmt.Errorf("unknown format '%s': use text or json", format)
+		}
+
+		var owners []internal.Ownership
+		for _, target := range args {
+			owner, err := internal.Which(target)
+			if err != nil {
+				return err
+			}
+			owners = append(owners, owner)
+		}
+		if format == "json" {
+			data, err := json.MarshalIndent(owners, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+		for _, owner := range owners {
+			printOwnership(owner)
+		}
+		return nil
+	},
+}
+
+// printOwnership prints what provided a file and whether it can be removed.
+func printOwnership(owner internal.Ownership) {
+	fmt.Println(owner.Path)
+	if owner.Resolved != "" {
+		fmt.Printf("  → %s\n", owner.Resolved)
+	}
+	switch {
+	case owner.Source == "local":
+		fmt.Printf("  Local package %s (run install --from-file)\n", owner.Package)
+		fmt.Printf("  Remove with: run remove %s\n", owner.Package)
+	case owner.Package != "":
+		via := owner.Source
+		if owner.AptPackage != "" {
+			via = "apt package " + owner.AptPackage
+		}
+		fmt.Printf("  Package %s, through %s\n", owner.Package, via)
+		if _, removable := internal.RemovePackageRegistry[owner.Package]; !removable {
+			fmt.Printf("  run has no removal script for %s\n", owner.Package)
+		} else if len(owner.RequiredBy) > 0 {
+			fmt.Printf("  Required by installed %s; removing it breaks them\n", strings.Join(owner.RequiredBy, ", "))
+		} else {
+			fmt.Printf("  Remove with: run remove %s\n", owner.Package)
+		}
+	case owner.AptPackage != "":
+		fmt.Printf("  apt package %s, not managed by run\n", owner.AptPackage)
+	default:
+		fmt.Println("  Not managed by run or apt")
+	}
+}
+
+func init() {
+	whichCmd.Flags().StringP("format", "f", "text", "output format: text or json")
+	rootCmd.AddCommand(whichCmd)
+}
@@ -0,0 +1,387 @@
+package internal
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kinds of operation an approval is for.
+const (
+	ApprovalPlan   = "plan"
+	ApprovalRemove = "remove"
+)
+
+// DefaultApprovalValidity is how long an approval can be used by default.
+const DefaultApprovalValidity = 24 * time.Hour
+
+// ApprovalConfigPath is the host's approval policy. It is kept out of
+// ~/.run/config.yaml, in a file only root can change, so neither the
+// operator nor a context can turn approvals off.
+const ApprovalConfigPath = "/etc/run/approval.yaml"
+
+// ApprovalConfig requires a second person to approve destructive
+// operations: `run apply` of a plan and `run remove`. It is read from
+// ApprovalConfigPath.
+type ApprovalConfig struct {
+	// Required refuses those operations without an approval signed by one of
+	// PublicKeys.
+	Required bool `yaml:"required"`
+	// PublicKeys maps approver names to the ed25519 public keys printed by
+	// `run approve keygen`.
+	PublicKeys map[string]string `yaml:"public_keys"`
+}
+
+// LoadApprovalConfig reads the approval policy. Without ApprovalConfigPath
+// no approval is required. The file is refused unless only root can change
+// it and its directory.
+func LoadApprovalConfig() (*ApprovalConfig, error) {
+	settings := &ApprovalConfig{}
+	if _, err := os.Stat(ApprovalConfigPath); os.IsNotExist(err) {
+		return settings, nil
+	}
+	for _, path := range []string{filepath.Dir(ApprovalConfigPath), ApprovalConfigPath} {
+		if err := checkRootOnly(path); err != nil {
+			return nil, err
+		}
+	}
+	data, err := os.ReadFile(ApprovalConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", ApprovalConfigPath, err)
+	}
+	if err := yaml.Unmarshal(data, settings); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", ApprovalConfigPath, err)
+	}
+	return settings, nil
+}
+
+// checkRootOnly returns an error unless path is owned by root, not writable
+// by group or others, and not writable by the user running run.
+func checkRootOnly(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("cannot check %s: %v", path, err)
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Uid != 0 || info.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("%s must be owned by root and writable only by root: sudo chown root: %s && sudo chmod go-w %s", path, path, path)
+	}
+	// 2 is W_OK: root, or a user granted write access by an ACL, could
+	// change the approval policy it is checked against.
+	if syscall.Access(path, 2) == nil {
+		return fmt.Errorf("%s is writable by %s: approvals must be used by an operator who cannot change the approval policy", path, currentUser())
+	}
+	return nil
+}
+
+// Approval is what an approver signs: one plan or removal on one host, for
+// a limited time.
+type Approval struct {
+	Kind string `json:"kind"`
+	Host string `json:"host"`
+	// PlanSHA256 is the checksum of the approved plan file.
+	PlanSHA256 string `json:"plan_sha256,omitempty"`
+	// Packages are the packages approved for removal, sorted.
+	Packages []string `json:"packages,omitempty"`
+	// Version limits a removal to one version, as with run remove --version.
+	Version string `json:"version,omitempty"`
+	// Nonce makes the approval single-use: it is recorded when used.
+	Nonce string `json:"nonce"`
+	// Key names the public key in public_keys that verifies it, by
+	// convention the approver's user name.
+	Key string `json:"key"`
+	// ApprovedBy is the user and host that signed it, user@host.
+	ApprovedBy string    `json:"approved_by"`
+	ApprovedAt time.Time `json:"approved_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// ApprovalToken is a signed approval, as written by `run approve`.
+type ApprovalToken struct {
+	Approval Approval `json:"approval"`
+	// Signature is the base64 ed25519 signature of the approval's JSON.
+	Signature string `json:"signature"`
+}
+
+// currentUser returns the user name of whoever runs run.
+func currentUser() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
+
+// currentIdentity returns user@host of whoever runs run.
+func currentIdentity() string {
+	hostname, _ := os.Hostname()
+	return currentUser() + "@" + hostname
+}
+
+// DefaultApprovalKeyPath returns where `run approve keygen` writes the
+// private key by default (~/.run/approval.key).
+func DefaultApprovalKeyPath() (string, error) {
+	runDir, err := RunDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(runDir, "approval.key"), nil
+}
+
+// GenerateApprovalKey writes a new ed25519 private key, readable by its
+// owner only, and returns the public key to list in public_keys.
+func GenerateApprovalKey(path string) (string, error) {
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("%s already exists", path)
+	}
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(private.Seed()) + "\n"
+	if err := os.WriteFile(path, []byte(encoded), 0600); err != nil {
+		return "", fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return base64.StdEncoding.EncodeToString(public), nil
+}
+
+// readApprovalKey reads a private key written by GenerateApprovalKey.
+func readApprovalKey(path string) (ed25519.PrivateKey, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read approval key: %v", err)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("approval key %s must only be readable by its owner: chmod 600 %s", path, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read approval key: %v", err)
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%s is not an approval key from 'run approve keygen'", path)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// PlanApproval returns the approval of a plan file for the host it was made
+// for.
+func PlanApproval(planPath string) (Approval, error) {
+	plan, err := ReadPlan(planPath)
+	if err != nil {
+		return Approval{}, err
+	}
+	sum, err := planChecksum(planPath)
+	if err != nil {
+		return Approval{}, err
+	}
+	return Approval{Kind: ApprovalPlan, Host: plan.Host, PlanSHA256: sum}, nil
+}
+
+// RemoveApproval returns the approval of removing packages from a host, or
+// only one version of them when version is set.
+func RemoveApproval(host string, packages []string, version string) Approval {
+	sorted := append([]string(nil), packages...)
+	sort.Strings(sorted)
+	return Approval{Kind: ApprovalRemove, Host: host, Packages: sorted, Version: version}
+}
+
+// removalScope describes the versions a removal covers, for messages.
+func removalScope(version string) string {
+	if version == "" {
+		return "all versions"
+	}
+	return "version " + version
+}
+
+// planChecksum returns the hex SHA-256 checksum of a plan file.
+func planChecksum(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read plan %s: %v", path, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// SignApproval signs an approval with the private key at keyPath, naming
+// the approver's public key keyName, valid for validity.
+func SignApproval(approval Approval, keyPath, keyName string, validity time.Duration) (*ApprovalToken, error) {
+	private, err := readApprovalKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	approval.Key = keyName
+	approval.Nonce = hex.EncodeToString(nonce)
+	approval.ApprovedBy = currentIdentity()
+	approval.ApprovedAt = time.Now().UTC().Truncate(time.Second)
+	approval.ExpiresAt = approval.ApprovedAt.Add(validity)
+	payload, err := json.Marshal(approval)
+	if err != nil {
+		return nil, err
+	}
+	return &ApprovalToken{Approval: approval, Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(private, payload))}, nil
+}
+
+// WriteApprovalToken writes a token as indented JSON.
+func WriteApprovalToken(token *ApprovalToken, path string) error {
+	data, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write approval %s: %v", path, err)
+	}
+	return nil
+}
+
+// usedApprovalsPath returns the file recording the nonces of used
+// approvals (~/.run/approvals-used.json), shared by all contexts.
+func usedApprovalsPath() (string, error) {
+	runDir, err := RunDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(runDir, "approvals-used.json"), nil
+}
+
+// loadUsedApprovals reads the nonces of used approvals with their expiry.
+func loadUsedApprovals(path string) (map[string]time.Time, error) {
+	used := make(map[string]time.Time)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return used, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read used approvals: %v", err)
+	}
+	if err := json.Unmarshal(data, &used); err != nil {
+		return nil, fmt.Errorf("failed to parse used approvals %s: %v", path, err)
+	}
+	return used, nil
+}
+
+// useApproval records the nonce of an approval, refusing one already used.
+// Expired nonces are dropped, as their approvals are refused anyway.
+func useApproval(approval Approval, tokenPath string) error {
+	path, err := usedApprovalsPath()
+	if err != nil {
+		return err
+	}
+	used, err := loadUsedApprovals(path)
+	if err != nil {
+		return err
+	}
+	if _, exists := used[approval.Nonce]; exists {
+		return fmt.Errorf("approval %s was already used; each approval runs once", tokenPath)
+	}
+	now := time.Now()
+	for nonce, expiresAt := range used {
+		if now.After(expiresAt) {
+			delete(used, nonce)
+		}
+	}
+	used[approval.Nonce] = approval.ExpiresAt
+
+	data, err := json.MarshalIndent(used, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to record used approval: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to record used approval: %v", err)
+	}
+	return nil
+}
+
+// RequireApproval checks, when ApprovalConfigPath sets required, that the
+// token at tokenPath approves exactly the operation expected: signed by a
+// key in public_keys, unexpired, unused, and by another user than the one
+// running it. The approval is then recorded as used. Without required it
+// does nothing.
+func RequireApproval(expected Approval, tokenPath string) error {
+	settings, err := LoadApprovalConfig()
+	if err != nil {
+		return err
+	}
+	if !settings.Required {
+		return nil
+	}
+	if tokenPath == "" {
+		return fmt.Errorf("%s requires approval: this %s needs an approval from 'run approve', given with --approval", ApprovalConfigPath, expected.Kind)
+	}
+	data, err := os.ReadFile(tokenPath)
+	if err != nil {
+		return fmt.Errorf("failed to read approval %s: %v", tokenPath, err)
+	}
+	var token ApprovalToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return fmt.Errorf("failed to parse approval %s: %v", tokenPath, err)
+	}
+
+	approval := token.Approval
+	encodedKey, exists := settings.PublicKeys[approval.Key]
+	if !exists {
+		return fmt.Errorf("approval %s is signed with key '%s', which is not in the public_keys of %s", tokenPath, approval.Key, ApprovalConfigPath)
+	}
+	public, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(public) != ed25519.PublicKeySize {
+		return fmt.Errorf("public_keys.%s in %s is not a public key from 'run approve keygen'", approval.Key, ApprovalConfigPath)
+	}
+	signature, err := base64.StdEncoding.DecodeString(token.Signature)
+	if err != nil {
+		return fmt.Errorf("approval %s has an invalid signature", tokenPath)
+	}
+	payload, err := json.Marshal(approval)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(public, payload, signature) {
+		return fmt.Errorf("approval %s has an invalid signature", tokenPath)
+	}
+
+	operator := currentUser()
+	approver, _, _ := strings.Cut(approval.ApprovedBy, "@")
+	switch {
+	case approval.Kind != expected.Kind:
+		return fmt.Errorf("approval %s is for a %s, not a %s", tokenPath, approval.Kind, expected.Kind)
+	case approval.Host != expected.Host:
+		return fmt.Errorf("approval %s is for host %s, not %s", tokenPath, approval.Host, expected.Host)
+	case approval.PlanSHA256 != expected.PlanSHA256:
+		return fmt.Errorf("approval %s is for another plan, or the plan was edited after approval", tokenPath)
+	case strings.Join(approval.Packages, ",") != strings.Join(expected.Packages, ","):
+		return fmt.Errorf("approval %s is for removing %s, not %s", tokenPath, strings.Join(approval.Packages, ", "), strings.Join(expected.Packages, ", "))
+	case approval.Version != expected.Version:
+		return fmt.Errorf("approval %s is for removing %s, not %s", tokenPath, removalScope(approval.Version), removalScope(expected.Version))
+	case approval.Nonce == "":
+		return fmt.Errorf("approval %s has no nonce; sign it again with 'run approve'", tokenPath)
+	case time.Now().After(approval.ExpiresAt):
+		return fmt.Errorf("approval %s expired at %s", tokenPath, approval.ExpiresAt.Local().Format("2006-01-02 15:04"))
+	case approver == operator || approval.Key == operator:
+		return fmt.Errorf("approval %s was signed by %s with key '%s', who is running it: a second person must approve", tokenPath, approval.ApprovedBy, approval.Key)
+	}
+	return useApproval(approval, tokenPath)
+}
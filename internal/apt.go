@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// ProtectedPackagePatterns lists packages that autoremove must never take
+// away, even when apt considers them unused. Patterns use shell glob syntax.
+var ProtectedPackagePatterns = []string{
+	"linux-image-*",
+	"linux-headers-*",
+	"linux-modules-*",
+	"linux-generic*",
+	"linux-firmware",
+	"linux-base",
+	"grub-*",
+	"shim-signed",
+	"systemd*",
+	"libc6*",
+	"apt",
+	"dpkg",
+	"sudo",
+	"openssh-server",
+	"cloud-init",
+	"walinuxagent",
+}
+
+// IsProtectedPackage reports whether the apt package matches one of the
+// protected patterns.
+func IsProtectedPackage(name string) bool {
+	for _, pattern := range ProtectedPackagePatterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// SimulateAutoremove returns the packages `apt-get autoremove` would remove,
+// without changing anything on the system.
+func SimulateAutoremove() ([]string, error) {
+	output, err := exec.Command("apt-get", "-s", "autoremove").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to simulate autoremove: %v", err)
+	}
+	return parseSimulatedRemovals(output), nil
+}
+
+// parseSimulatedRemovals extracts package names from "Remv <pkg> [<version>]"
+// lines printed by apt-get in simulation mode.
+func parseSimulatedRemovals(output []byte) []string {
+	var packages []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "Remv" {
+			packages = append(packages, fields[1])
+		}
+	}
+	return packages
+}
+
+// SafeAutoremove removes unused packages like `apt-get autoremove`, once
+// confirmed, but holds back kernels and other system-critical packages by
+// marking them manually installed, which also keeps the packages they
+// depend on. It returns the packages that were skipped because they are
+// protected.
+func SafeAutoremove() ([]string, error) {
+	candidates, err := SimulateAutoremove()
+	if err != nil {
+		return nil, err
+	}
+
+	var skipped []string
+	for _, pkg := range candidates {
+		if IsProtectedPackage(pkg) {
+			skipped = append(skipped, pkg)
+		}
+	}
+
+	if len(skipped) > 0 {
+		Warn("", "Holding back protected packages from autoremove: %s", strings.Join(skipped, ", "))
+		if err := system.Command("apt-mark", append([]string{"manual"}, skipped...)...).WithSudo().Run(); err != nil {
+			return skipped, fmt.Errorf("failed to mark protected packages as manually installed: %v", err)
+		}
+		if candidates, err = SimulateAutoremove(); err != nil {
+			return skipped, err
+		}
+		for _, pkg := range candidates {
+			if IsProtectedPackage(pkg) {
+				return skipped, fmt.Errorf("autoremove would still remove the protected package %s; nothing was removed", pkg)
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		fmt.Fprintln(Console, "No unused packages to remove")
+		return skipped, nil
+	}
+
+	if !Confirm(fmt.Sprintf("Remove unused packages (%s)?", strings.Join(candidates, ", "))) {
+		fmt.Fprintln(Console, "Unused packages kept")
+		return skipped, nil
+	}
+	fmt.Fprintf(Console, "Removing unused packages: %s\n", strings.Join(candidates, ", "))
+	if err := runAptGet("autoremove", "-y"); err != nil {
+		return skipped, fmt.Errorf("failed to remove unused packages: %v", err)
+	}
+
+	return skipped, nil
+}
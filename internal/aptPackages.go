@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// PackageAptPatterns maps run packages to the apt packages they install, as
+// dpkg-query patterns. Packages installed through other means (pm2 via npm)
+// have no entry.
+var PackageAptPatterns = map[string][]string{
+	"docker":    {"docker-ce", "docker-ce-cli", "containerd.io", "docker-buildx-plugin", "docker-compose-plugin"},
+	"hardening": {"fail2ban", "unattended-upgrades"},
+	"java":      {"openjdk-*-jdk*", "temurin-*-jdk", "java-*-amazon-corretto-jdk"},
+	"nginx":     {"nginx"},
+	"node":      {"nodejs"},
+	"nvidia":    {"cuda-drivers*", "nvidia-driver-*", "cuda-toolkit-*", "cudnn*", "libcudnn*"},
+	"php":       {"php8.*"},
+	"postgres":  {"postgresql-[0-9]*", "postgresql-client-[0-9]*"},
+	"python":    {"python3", "python3-pip", "python3-dev", "python3-venv", "gunicorn"},
+}
+
+// AptPackage is an installed Debian package.
+type AptPackage struct {
+	Name         string
+	Version      string
+	Architecture string
+	Maintainer   string
+	// Origin is the repository URL the installed version came from, if known.
+	Origin string
+}
+
+// packageAptPatterns returns the dpkg-query patterns of a run package.
+func packageAptPatterns(packageName string) []string {
+	if packageName == "essentials" {
+		config, err := LoadConfig()
+		if err != nil {
+			return nil
+		}
+		enabled, err := EnabledEssentials(config)
+		if err != nil {
+			return nil
+		}
+		var patterns []string
+		for _, name := range enabled {
+			patterns = append(patterns, EssentialItems[name].Packages...)
+		}
+		return patterns
+	}
+	return PackageAptPatterns[packageName]
+}
+
+// InstalledAptPackages returns the apt packages installed for a run package,
+// with their repository origin.
+func InstalledAptPackages(packageName string) []AptPackage {
+	patterns := packageAptPatterns(packageName)
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	args := append([]string{"-W", "-f", "${db:Status-Abbrev}\t${Package}\t${Version}\t${Architecture}\t${Maintainer}\n"}, patterns...)
+	// dpkg-query exits non-zero when a pattern matches nothing but still
+	// prints the packages that did match
+	output, _ := exec.Command("dpkg-query", args...).Output()
+
+	var packages []AptPackage
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 5 || strings.TrimSpace(fields[0]) != "ii" {
+			continue
+		}
+		packages = append(packages, AptPackage{
+			Name:         fields[1],
+			Version:      fields[2],
+			Architecture: fields[3],
+			Maintainer:   fields[4],
+			Origin:       aptOrigin(fields[1]),
+		})
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].Name < packages[j].Name })
+	return packages
+}
+
+// aptOrigin returns the repository URL of the installed version of a package,
+// taken from the line following "***" in `apt-cache policy`.
+func aptOrigin(name string) string {
+	output, err := exec.Command("apt-cache", "policy", name).Output()
+	if err != nil {
+		return ""
+	}
+
+	installed := false
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == "***" {
+			installed = true
+			continue
+		}
+		if installed && len(fields) >= 2 {
+			if fields[1] == "/var/lib/dpkg/status" {
+				continue
+			}
+			return fields[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/amoga-io/run/internal/output"
+	"github.com/amoga-io/run/internal/system"
+)
+
+// parseAptStatus parses a line apt-get writes to APT::Status-Fd, such as
+// "pmstatus:postgresql-17:42.8:Installing postgresql-17". It reports false
+// for other lines.
+func parseAptStatus(line string) (float64, string, bool) {
+	fields := strings.SplitN(line, ":", 4)
+	if len(fields) != 4 {
+		return 0, "", false
+	}
+	if fields[0] != "pmstatus" && fields[0] != "dlstatus" {
+		return 0, "", false
+	}
+	percent, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return percent, fields[3], true
+}
+
+// runAptGet runs apt-get as root with args and shows its progress on the
+// console. apt-get writes its status lines to stdout, where they are turned
+// into progress; its other output passes through.
+func runAptGet(args ...string) error {
+	stdout, writer := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		progress := output.NewProgress(Console)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if percent, label, ok := parseAptStatus(line); ok {
+				progress.Update(percent, label)
+				continue
+			}
+			progress.Clear()
+			fmt.Fprintln(Console, line)
+		}
+		progress.Done()
+		// Never block apt-get on a line too long to scan
+		io.Copy(io.Discard, stdout)
+	}()
+
+	err := system.Command("apt-get", append([]string{"-o", "APT::Status-Fd=1"}, args...)...).WithSudo().Stream(writer, os.Stderr)
+	writer.Close()
+	<-done
+	return err
+}
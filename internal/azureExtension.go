@@ -0,0 +1,184 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AzureHandlerEnvironment is the HandlerEnvironment.json the Azure VM agent
+// places next to an extension handler, naming where settings are read from
+// and where status and logs go.
+type AzureHandlerEnvironment struct {
+	LogFolder    string
+	ConfigFolder string
+	StatusFolder string
+}
+
+// LoadAzureHandlerEnvironment reads HandlerEnvironment.json from the handler
+// directory.
+func LoadAzureHandlerEnvironment(handlerDir string) (*AzureHandlerEnvironment, error) {
+	path := filepath.Join(handlerDir, "HandlerEnvironment.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	var file []struct {
+		HandlerEnvironment struct {
+			LogFolder    string `json:"logFolder"`
+			ConfigFolder string `json:"configFolder"`
+			StatusFolder string `json:"statusFolder"`
+		} `json:"handlerEnvironment"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if len(file) == 0 {
+		return nil, fmt.Errorf("%s has no handlerEnvironment", path)
+	}
+	env := file[0].HandlerEnvironment
+	return &AzureHandlerEnvironment{LogFolder: env.LogFolder, ConfigFolder: env.ConfigFolder, StatusFolder: env.StatusFolder}, nil
+}
+
+// SequenceNumber returns the sequence number of the settings to apply: the
+// agent's ConfigSequenceNumber, or else the highest <n>.settings file.
+func (e *AzureHandlerEnvironment) SequenceNumber() (int, error) {
+	if value := os.Getenv("ConfigSequenceNumber"); value != "" {
+		return strconv.Atoi(value)
+	}
+	matches, err := filepath.Glob(filepath.Join(e.ConfigFolder, "*.settings"))
+	if err != nil {
+		return 0, err
+	}
+	sequence := -1
+	for _, match := range matches {
+		n, err := strconv.Atoi(strings.TrimSuffix(filepath.Base(match), ".settings"))
+		if err == nil && n > sequence {
+			sequence = n
+		}
+	}
+	if sequence < 0 {
+		return 0, fmt.Errorf("no settings found in %s", e.ConfigFolder)
+	}
+	return sequence, nil
+}
+
+// AzureExtensionSettings are the public settings of the extension: what to
+// install, with which cloud profile and which versions.
+type AzureExtensionSettings struct {
+	Packages []string `json:"packages"`
+	// Profile sets cloud.provider for the run; it defaults to azure.
+	Profile string `json:"profile"`
+	// Versions pin package versions, passed to scripts as <PACKAGE>_VERSION.
+	Versions map[string]string `json:"versions"`
+}
+
+// LoadSettings reads the public settings of a sequence number.
+// Protected settings are not used, so they are never decrypted.
+func (e *AzureHandlerEnvironment) LoadSettings(sequence int) (*AzureExtensionSettings, error) {
+	path := filepath.Join(e.ConfigFolder, strconv.Itoa(sequence)+".settings")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	var file struct {
+		RuntimeSettings []struct {
+			HandlerSettings struct {
+				PublicSettings AzureExtensionSettings `json:"publicSettings"`
+			} `json:"handlerSettings"`
+		} `json:"runtimeSettings"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if len(file.RuntimeSettings) == 0 {
+		return &AzureExtensionSettings{}, nil
+	}
+	settings := file.RuntimeSettings[0].HandlerSettings.PublicSettings
+	for _, packageName := range settings.Packages {
+		if _, exists := InstallPackageRegistry[packageName]; !exists {
+			return nil, fmt.Errorf("unknown package '%s' in settings. Available packages: %s", packageName, strings.Join(ListPackages(), ", "))
+		}
+	}
+	return &settings, nil
+}
+
+// Extension status values the VM agent understands.
+const (
+	AzureStatusTransitioning = "transitioning"
+	AzureStatusSuccess       = "success"
+	AzureStatusError         = "error"
+)
+
+// AzureSubstatus reports the outcome of one package.
+type AzureSubstatus struct {
+	Name    string
+	Status  string
+	Message string
+}
+
+// WriteStatus writes <sequence>.status for the VM agent, replacing it
+// atomically so the agent never reads a partial file.
+func (e *AzureHandlerEnvironment) WriteStatus(sequence int, operation, status, message string, substatuses []AzureSubstatus) error {
+	type formattedMessage struct {
+		Lang    string `json:"lang"`
+		Message string `json:"message"`
+	}
+	type substatus struct {
+		Name             string           `json:"name"`
+		Status           string           `json:"status"`
+		Code             int              `json:"code"`
+		FormattedMessage formattedMessage `json:"formattedMessage"`
+	}
+	code := 0
+	if status == AzureStatusError {
+		code = 1
+	}
+	entry := struct {
+		Version      string `json:"version"`
+		TimestampUTC string `json:"timestampUTC"`
+		Status       struct {
+			Name             string           `json:"name"`
+			Operation        string           `json:"operation"`
+			Status           string           `json:"status"`
+			Code             int              `json:"code"`
+			FormattedMessage formattedMessage `json:"formattedMessage"`
+			Substatus        []substatus      `json:"substatus,omitempty"`
+		} `json:"status"`
+	}{Version: "1.0", TimestampUTC: time.Now().UTC().Format(time.RFC3339)}
+	entry.Status.Name = CLIName
+	entry.Status.Operation = operation
+	entry.Status.Status = status
+	entry.Status.Code = code
+	entry.Status.FormattedMessage = formattedMessage{Lang: "en-US", Message: message}
+	for _, sub := range substatuses {
+		subCode := 0
+		if sub.Status == AzureStatusError {
+			subCode = 1
+		}
+		entry.Status.Substatus = append(entry.Status.Substatus, substatus{
+			Name:             sub.Name,
+			Status:           sub.Status,
+			Code:             subCode,
+			FormattedMessage: formattedMessage{Lang: "en-US", Message: sub.Message},
+		})
+	}
+
+	data, err := json.MarshalIndent([]interface{}{entry}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(e.StatusFolder, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", e.StatusFolder, err)
+	}
+	path := filepath.Join(e.StatusFolder, strconv.Itoa(sequence)+".status")
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", tempPath, err)
+	}
+	return os.Rename(tempPath, path)
+}
@@ -0,0 +1,983 @@
+package internal
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// BackupConfig configures `run backup`.
+type BackupConfig struct {
+	// Dir is where `run backup create` writes archives; ~/run-backups when
+	// unset. Copy them off the host: they are meant to survive reimaging.
+	Dir string `yaml:"dir"`
+	// Keep is how many archives of the host are kept in Dir;
+	// DefaultBackupKeep when unset.
+	Keep int `yaml:"keep"`
+	// PassphraseFile holds the passphrase secrets are encrypted with, for
+	// scheduled backups. BackupPassphraseEnv takes precedence.
+	PassphraseFile string `yaml:"passphrase_file"`
+}
+
+// DefaultBackupKeep is how many archives are kept when backup.keep is unset.
+const DefaultBackupKeep = 7
+
+// BackupPassphraseEnv names the environment variable holding the passphrase
+// secrets are encrypted with.
+const BackupPassphraseEnv = "RUN_BACKUP_PASSPHRASE"
+
+// backupUnit is the name of the systemd service/timer pair of scheduled
+// backups.
+const backupUnit = "run-backup"
+
+// Names inside a backup archive. Files are stored under context/ (relative
+// to the context directory), home/ (relative to the home directory) or
+// host/ (absolute), so a context or home in another place still restores.
+// Secrets and env files are stored in an inner archive. With
+// secrets.encryption set it is encrypted to the same age or GPG recipients
+// as the secrets store, otherwise with openssl and the backup passphrase.
+// Both can be decrypted by hand:
+//
+//	age --decrypt --identity key.txt secrets.tar.gz.age | tar xz
+//	openssl enc -d -aes-256-cbc -pbkdf2 -iter 200000 -in secrets.tar.gz.enc | tar xz
+const (
+	backupManifestName = "manifest.json"
+	backupSecretsName  = "secrets.tar.gz.enc"
+	backupKDFIter      = "200000"
+)
+
+// backupSecretsNames maps secrets.encryption to the name of the encrypted
+// inner archive; openssl is used when it is unset.
+var backupSecretsNames = map[string]string{
+	"":         backupSecretsName,
+	SecretsAge: "secrets.tar.gz.age",
+	SecretsGPG: "secrets.tar.gz.gpg",
+}
+
+// BackupManifest describes a backup archive.
+type BackupManifest struct {
+	Host      string    `json:"host"`
+	Context   string    `json:"context"`
+	CreatedAt time.Time `json:"created_at"`
+	// Files are the original paths of the files in the archive.
+	Files []string `json:"files"`
+	// Secrets are the original paths of the encrypted files.
+	Secrets []string `json:"secrets,omitempty"`
+	// SecretsEncryption is the secrets.encryption the secrets are encrypted
+	// with; they need the backup passphrase when it is empty.
+	SecretsEncryption string `json:"secrets_encryption,omitempty"`
+}
+
+// backupFile is a file to back up.
+type backupFile struct {
+	path   string
+	secret bool
+	// context files are restored into the active context.
+	context bool
+}
+
+// WithDefaults fills in the directory and the number of archives kept, and
+// expands ~ in the directory and the passphrase file.
+func (c BackupConfig) WithDefaults() BackupConfig {
+	if home, err := os.UserHomeDir(); err == nil {
+		if c.Dir == "" {
+			c.Dir = filepath.Join(home, "run-backups")
+		}
+		for _, path := range []*string{&c.Dir, &c.PassphraseFile} {
+			if rest, found := strings.CutPrefix(*path, "~/"); found {
+				*path = filepath.Join(home, rest)
+			}
+		}
+	}
+	if c.Keep <= 0 {
+		c.Keep = DefaultBackupKeep
+	}
+	return c
+}
+
+// BackupPassphrase returns the passphrase from BackupPassphraseEnv or from a
+// file, which must only be readable by its owner. It returns an empty
+// string when neither is set.
+func BackupPassphrase(file string) (string, error) {
+	if passphrase := os.Getenv(BackupPassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	if file == "" {
+		return "", nil
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase file: %v", err)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("passphrase file %s must only be readable by its owner: chmod 600 %s", file, file)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase file: %v", err)
+	}
+	passphrase, _, _ := strings.Cut(string(data), "\n")
+	if passphrase = strings.TrimSpace(passphrase); passphrase == "" {
+		return "", fmt.Errorf("passphrase file %s is empty", file)
+	}
+	return passphrase, nil
+}
+
+// backupFiles returns the files of the run-managed layer that exist: the
+// context's config, state, baseline, env file, secrets and file backups,
+// the user's registry overlay, nginx sites, pm2 process lists and
+// ecosystem files, and the units, env files, compose files and generated
+// files of the state.
+func backupFiles() ([]backupFile, error) {
+	contextDir, err := ContextDir()
+	if err != nil {
+		return nil, err
+	}
+	state, err := LoadState()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []backupFile
+	seen := make(map[string]bool)
+	addFile := func(file backupFile) {
+		if file.path == "" || seen[file.path] {
+			return
+		}
+		if _, err := statPrivileged(file.path); err != nil {
+			return
+		}
+		seen[file.path] = true
+		files = append(files, file)
+	}
+	add := func(path string, secret bool) {
+		addFile(backupFile{path: path, secret: secret})
+	}
+
+	for _, name := range []string{"config.yaml", "state.json", "baseline.json", "env"} {
+		addFile(backupFile{path: filepath.Join(contextDir, name), context: true})
+	}
+	for _, encryption := range mapKeys(secretsStoreNames) {
+		addFile(backupFile{path: filepath.Join(contextDir, secretsStoreNames[encryption]), secret: true, context: true})
+	}
+	for _, dir := range []string{"backups", "local"} {
+		filepath.WalkDir(filepath.Join(contextDir, dir), func(path string, entry os.DirEntry, err error) error {
+			if err == nil && entry.Type().IsRegular() {
+				addFile(backupFile{path: path, context: true})
+			}
+			return nil
+		})
+	}
+	if overlays, err := RegistryOverlayPaths(); err == nil {
+		add(overlays[len(overlays)-1], false)
+	}
+
+	for _, pattern := range backupHostPatterns {
+		matches, _ := filepath.Glob(pattern)
+		for _, path := range matches {
+			add(path, false)
+		}
+	}
+	for _, path := range pm2Files() {
+		add(path, false)
+	}
+	for _, file := range stateFiles(state) {
+		addFile(file)
+	}
+	return files, nil
+}
+
+// backupHostPatterns match the configuration files outside the home and
+// context directories that are always backed up.
+var backupHostPatterns = []string{"/etc/nginx/sites-available/*", "/etc/nginx/sites-enabled/*", "/etc/nginx/conf.d/*.conf"}
+
+// pm2FileNames are the names of the pm2 files pm2Files returns.
+var pm2FileNames = []string{"dump.pm2", "ecosystem.config.js", "ecosystem.config.cjs", "ecosystem.config.json"}
+
+// stateFiles returns the files a state records: the units and env files of
+// services, compose files and generated files.
+func stateFiles(state *State) []backupFile {
+	var files []backupFile
+	for _, name := range mapKeys(state.Services) {
+		files = append(files, backupFile{path: serviceUnitPath(name)}, backupFile{path: state.Services[name].EnvFile, secret: true})
+	}
+	for _, name := range mapKeys(state.ComposeProjects) {
+		files = append(files, backupFile{path: state.ComposeProjects[name].File})
+	}
+	for _, path := range mapKeys(state.GeneratedFiles) {
+		files = append(files, backupFile{path: path, secret: true})
+	}
+	return files
+}
+
+// pm2Files returns the saved process list of the app user's pm2 and the
+// ecosystem files in the directories of its apps.
+func pm2Files() []string {
+	username, err := appUser()
+	if err != nil {
+		return nil
+	}
+	var files []string
+	if account, err := user.Lookup(username); err == nil {
+		files = append(files, filepath.Join(account.HomeDir, ".pm2", "dump.pm2"))
+	}
+
+	cmd := system.Command("pm2", "jlist")
+	if !isCurrentUser(username) {
+		cmd = cmd.AsUser(username)
+	}
+	output, err := cmd.CaptureOutput()
+	if err != nil {
+		return files
+	}
+	var apps []struct {
+		Env struct {
+			Cwd string `json:"pm_cwd"`
+		} `json:"pm2_env"`
+	}
+	if err := json.Unmarshal(output, &apps); err != nil {
+		return files
+	}
+	for _, app := range apps {
+		if app.Env.Cwd == "" {
+			continue
+		}
+		for _, name := range pm2FileNames[1:] {
+			files = append(files, filepath.Join(app.Env.Cwd, name))
+		}
+	}
+	return files
+}
+
+// fileStat is the mode and owner of a file, and the target of a symlink.
+type fileStat struct {
+	mode     os.FileMode
+	uid, gid int
+	link     string
+}
+
+// statPrivileged stats a file without following symlinks, with sudo when
+// the user may not, as for files in the home of the app user.
+func statPrivileged(path string) (fileStat, error) {
+	info, err := os.Lstat(path)
+	if err == nil {
+		stat := fileStat{mode: info.Mode()}
+		if sys, ok := info.Sys().(*syscall.Stat_t); ok {
+			stat.uid, stat.gid = int(sys.Uid), int(sys.Gid)
+		}
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			stat.link, err = os.Readlink(path)
+		case !info.Mode().IsRegular():
+			err = fmt.Errorf("%s is not a regular file", path)
+		}
+		return stat, err
+	}
+	if !os.IsPermission(err) {
+		return fileStat{}, err
+	}
+	// "regular file|644|1001|1001"
+	output, err := system.Command("stat", "-c", "%F|%a|%u|%g", path).WithSudo().CaptureOutput()
+	if err != nil {
+		return fileStat{}, err
+	}
+	fields := strings.Split(strings.TrimSpace(string(output)), "|")
+	if len(fields) != 4 || !strings.HasPrefix(fields[0], "regular") {
+		return fileStat{}, fmt.Errorf("%s is not a regular file", path)
+	}
+	mode, _ := strconv.ParseUint(fields[1], 8, 32)
+	uid, _ := strconv.Atoi(fields[2])
+	gid, _ := strconv.Atoi(fields[3])
+	return fileStat{mode: os.FileMode(mode), uid: uid, gid: gid}, nil
+}
+
+// backupEntryName returns the name of a file inside a backup archive.
+func backupEntryName(file backupFile, contextDir, home string) string {
+	if rest, found := strings.CutPrefix(file.path, contextDir+"/"); found && file.context {
+		return "context/" + rest
+	}
+	if rest, found := strings.CutPrefix(file.path, home+"/"); found && home != "" {
+		return "home/" + rest
+	}
+	return "host" + file.path
+}
+
+// backupEntryPath returns where a file of a backup archive is restored.
+func backupEntryPath(name, contextDir, home string) (string, error) {
+	prefix, rest, _ := strings.Cut(name, "/")
+	if !filepath.IsLocal(rest) {
+		return "", fmt.Errorf("invalid path %s in backup", name)
+	}
+	switch prefix {
+	case "context":
+		return filepath.Join(contextDir, rest), nil
+	case "home":
+		return filepath.Join(home, rest), nil
+	case "host":
+		return "/" + filepath.Clean(rest), nil
+	}
+	return "", fmt.Errorf("invalid path %s in backup", name)
+}
+
+// writeBackupEntries adds files to an archive with their mode and owner,
+// and symlinks as symlinks.
+func writeBackupEntries(tw *tar.Writer, files []backupFile, contextDir, home string) error {
+	for _, file := range files {
+		stat, err := statPrivileged(file.path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", file.path, err)
+		}
+		header := &tar.Header{
+			Name:    backupEntryName(file, contextDir, home),
+			Mode:    int64(stat.mode.Perm()),
+			Uid:     stat.uid,
+			Gid:     stat.gid,
+			ModTime: time.Now(),
+		}
+		// Names restore the owner on a host where the ids differ
+		if account, err := user.LookupId(strconv.Itoa(stat.uid)); err == nil {
+			header.Uname = account.Username
+		}
+		if group, err := user.LookupGroupId(strconv.Itoa(stat.gid)); err == nil {
+			header.Gname = group.Name
+		}
+		var data []byte
+		if stat.link != "" {
+			header.Typeflag, header.Linkname = tar.TypeSymlink, stat.link
+		} else {
+			if data, err = readFilePrivileged(file.path); err != nil {
+				return fmt.Errorf("failed to read %s: %v", file.path, err)
+			}
+			header.Typeflag, header.Size = tar.TypeReg, int64(len(data))
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// opensslCrypt encrypts or decrypts data with a passphrase, passed to
+// openssl through the environment rather than its arguments.
+func opensslCrypt(data []byte, passphrase string, decrypt bool) ([]byte, error) {
+	args := []string{"enc", "-aes-256-cbc", "-pbkdf2", "-iter", backupKDFIter, "-pass", "env:" + BackupPassphraseEnv}
+	if decrypt {
+		args = append(args, "-d")
+	} else {
+		args = append(args, "-salt")
+	}
+	return system.Command("openssl", args...).
+		WithEnv(BackupPassphraseEnv + "=" + passphrase).
+		WithStdin(bytes.NewReader(data)).
+		CaptureOutput()
+}
+
+// CreateBackup writes an archive of the run-managed layer to path, or to a
+// new archive in backup.dir when path is empty, pruning the oldest archives
+// of the host there beyond backup.keep. Secrets and env files are encrypted
+// like the secrets store when secrets.encryption is set, and with
+// passphrase otherwise; without one they are left out when skipSecrets is
+// set, and refused otherwise. It returns the path of the archive.
+func CreateBackup(path, passphrase string, skipSecrets bool) (string, BackupManifest, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return "", BackupManifest{}, err
+	}
+	settings := config.Backup.WithDefaults()
+	contextDir, err := ContextDir()
+	if err != nil {
+		return "", BackupManifest{}, err
+	}
+	home, _ := os.UserHomeDir()
+	files, err := backupFiles()
+	if err != nil {
+		return "", BackupManifest{}, err
+	}
+
+	host, _ := os.Hostname()
+	manifest := BackupManifest{Host: host, Context: CurrentContext(), CreatedAt: time.Now().UTC()}
+	var plain, secret []backupFile
+	for _, file := range files {
+		switch {
+		case !file.secret:
+			plain = append(plain, file)
+			manifest.Files = append(manifest.Files, file.path)
+		case !skipSecrets:
+			secret = append(secret, file)
+			manifest.Secrets = append(manifest.Secrets, file.path)
+		}
+	}
+	secrets := config.Secrets.WithDefaults()
+	if len(secret) > 0 && secrets.Encryption != "" {
+		if err := secrets.Validate(); err != nil {
+			return "", manifest, err
+		}
+		manifest.SecretsEncryption = secrets.Encryption
+	} else if len(secret) > 0 && passphrase == "" {
+		return "", manifest, fmt.Errorf("a passphrase is needed to encrypt secrets: set %s or backup.passphrase_file, or leave them out with --no-secrets", BackupPassphraseEnv)
+	}
+
+	var encrypted []byte
+	if len(secret) > 0 {
+		var inner bytes.Buffer
+		gz := gzip.NewWriter(&inner)
+		tw := tar.NewWriter(gz)
+		if err := writeBackupEntries(tw, secret, contextDir, home); err != nil {
+			return "", manifest, err
+		}
+		if err := tw.Close(); err != nil {
+			return "", manifest, err
+		}
+		if err := gz.Close(); err != nil {
+			return "", manifest, err
+		}
+		if manifest.SecretsEncryption != "" {
+			encrypted, err = encryptSecretData(secrets, inner.Bytes())
+		} else {
+			encrypted, err = opensslCrypt(inner.Bytes(), passphrase, false)
+		}
+		if err != nil {
+			return "", manifest, fmt.Errorf("failed to encrypt secrets: %v", err)
+		}
+	}
+
+	pruneDir := ""
+	if path == "" {
+		if err := os.MkdirAll(settings.Dir, 0700); err != nil {
+			return "", manifest, fmt.Errorf("failed to create %s: %v", settings.Dir, err)
+		}
+		path = filepath.Join(settings.Dir, backupArchivePrefix(manifest)+manifest.CreatedAt.Format("20060102-150405")+".tar.gz")
+		pruneDir = settings.Dir
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".run-backup-*")
+	if err != nil {
+		return "", manifest, fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	gz := gzip.NewWriter(tmp)
+	tw := tar.NewWriter(gz)
+	manifestData, _ := json.MarshalIndent(manifest, "", "  ")
+	entries := []struct {
+		name string
+		data []byte
+	}{{backupManifestName, manifestData}}
+	if encrypted != nil {
+		entries = append(entries, struct {
+			name string
+			data []byte
+		}{backupSecretsNames[manifest.SecretsEncryption], encrypted})
+	}
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0600, Size: int64(len(entry.data)), ModTime: manifest.CreatedAt}
+		if err := tw.WriteHeader(header); err != nil {
+			tmp.Close()
+			return "", manifest, fmt.Errorf("failed to write %s: %v", path, err)
+		}
+		if _, err := tw.Write(entry.data); err != nil {
+			tmp.Close()
+			return "", manifest, fmt.Errorf("failed to write %s: %v", path, err)
+		}
+	}
+	if err := writeBackupEntries(tw, plain, contextDir, home); err != nil {
+		tmp.Close()
+		return "", manifest, err
+	}
+	if err := tw.Close(); err != nil {
+		tmp.Close()
+		return "", manifest, fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := gz.Close(); err != nil {
+		tmp.Close()
+		return "", manifest, fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", manifest, fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", manifest, fmt.Errorf("failed to write %s: %v", path, err)
+	}
+
+	if pruneDir != "" {
+		archives, _ := filepath.Glob(filepath.Join(pruneDir, backupArchivePrefix(manifest)+"*.tar.gz"))
+		// Timestamped names sort oldest first
+		sort.Strings(archives)
+		for len(archives) > settings.Keep {
+			os.Remove(archives[0])
+			archives = archives[1:]
+		}
+	}
+	return path, manifest, nil
+}
+
+// backupArchivePrefix is the start of the names of the archives of a host
+// and context in backup.dir.
+func backupArchivePrefix(manifest BackupManifest) string {
+	return CLIName + "-" + manifest.Host + "-" + manifest.Context + "-"
+}
+
+// readBackupArchive calls fn with each entry of a gzipped tar archive.
+func readBackupArchive(r io.Reader, fn func(header *tar.Header, data []byte) error) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if err := fn(header, data); err != nil {
+			return err
+		}
+	}
+}
+
+// ReadBackupManifest returns the manifest of a backup archive.
+func ReadBackupManifest(path string) (BackupManifest, error) {
+	var manifest BackupManifest
+	file, err := os.Open(path)
+	if err != nil {
+		return manifest, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	found := false
+	err = readBackupArchive(file, func(header *tar.Header, data []byte) error {
+		if header.Name != backupManifestName {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &manifest)
+	})
+	if err != nil {
+		return manifest, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	if !found {
+		return manifest, fmt.Errorf("%s is not a run backup", path)
+	}
+	return manifest, nil
+}
+
+// RestoreBackup puts back the files of a backup archive, with their mode
+// and owner: those of the context into the active context, those of the
+// home directory into the user's, and others in place with sudo. The
+// encrypted secrets need passphrase, or the age identity or GPG key of
+// secrets.encryption, unless skipSecrets leaves them out.
+// Archives with entries a backup would not hold are refused, see
+// restoreGuard. It returns the restored paths.
+func RestoreBackup(path, passphrase string, skipSecrets bool) ([]string, error) {
+	contextDir, err := ContextDir()
+	if err != nil {
+		return nil, err
+	}
+	home, _ := os.UserHomeDir()
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	guard, err := newRestoreGuard(path, contextDir, home)
+	if err != nil {
+		return nil, err
+	}
+	config, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	ageIdentity := config.Secrets.WithDefaults().AgeIdentity
+	// The archive is checked whole before anything is written, so a refused
+	// entry does not leave a partial restore.
+	var restored []string
+	var decrypted []byte
+	write := false
+	var restore func(header *tar.Header, data []byte) error
+	restore = func(header *tar.Header, data []byte) error {
+		switch header.Name {
+		case backupManifestName:
+			return nil
+		case backupSecretsNames[""], backupSecretsNames[SecretsAge], backupSecretsNames[SecretsGPG]:
+			if skipSecrets {
+				return nil
+			}
+			if decrypted == nil {
+				var err error
+				if decrypted, err = decryptBackupSecrets(header.Name, data, passphrase, ageIdentity); err != nil {
+					return err
+				}
+			}
+			return readBackupArchive(bytes.NewReader(decrypted), restore)
+		}
+		target, err := backupEntryPath(header.Name, contextDir, home)
+		if err != nil {
+			return err
+		}
+		if err := guard.check(header, target); err != nil {
+			return err
+		}
+		if !write {
+			return nil
+		}
+		if err := restoreBackupEntry(header, data, target, strings.HasPrefix(header.Name, "host/")); err != nil {
+			return err
+		}
+		restored = append(restored, target)
+		return nil
+	}
+	if err := readBackupArchive(file, restore); err != nil {
+		return nil, fmt.Errorf("failed to restore %s: %v", path, err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	write, guard.links = true, make(map[string]bool)
+	if err := readBackupArchive(file, restore); err != nil {
+		return restored, fmt.Errorf("failed to restore %s: %v", path, err)
+	}
+
+	for _, target := range restored {
+		if strings.HasPrefix(target, "/etc/systemd/system/") {
+			if err := system.Command("systemctl", "daemon-reload").WithSudo().Run(); err != nil {
+				return restored, fmt.Errorf("failed to reload systemd: %v", err)
+			}
+			break
+		}
+	}
+	return restored, nil
+}
+
+// restoreAppDirs are where, besides the app user's home, a restore may
+// write the env files, compose files, generated files and ecosystem files
+// of apps. Other host files are only restored as nginx sites
+// (backupHostPatterns), units of services and the app user's dump.pm2.
+var restoreAppDirs = []string{"/opt", "/srv", "/var/www"}
+
+// restoreGuard decides which entries of an archive may be restored.
+type restoreGuard struct {
+	contextDir, home string
+	// appHome is the home of the app user, whose pm2 files are backed up.
+	appHome string
+	// managed are the host files a backup of the archive's state, or of
+	// this host's, holds, once validated by validStateFile.
+	managed map[string]bool
+	// links are the symlinks restored so far.
+	links map[string]bool
+}
+
+// newRestoreGuard reads the state in the archive at path to learn which
+// host files it may restore besides nginx sites and pm2 files.
+func newRestoreGuard(path, contextDir, home string) (*restoreGuard, error) {
+	guard := &restoreGuard{contextDir: contextDir, home: home, managed: make(map[string]bool), links: make(map[string]bool)}
+	if username, err := appUser(); err == nil {
+		if account, err := user.Lookup(username); err == nil && account.HomeDir != "/" {
+			guard.appHome = filepath.Clean(account.HomeDir)
+		}
+	}
+	states := []*State{}
+	if state, err := LoadState(); err == nil {
+		states = append(states, state)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	err = readBackupArchive(file, func(header *tar.Header, data []byte) error {
+		if header.Name != "context/state.json" {
+			return nil
+		}
+		state := &State{}
+		if err := json.Unmarshal(data, state); err != nil {
+			return fmt.Errorf("failed to parse the state in the backup: %v", err)
+		}
+		states = append(states, state)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	for _, state := range states {
+		for _, file := range stateFiles(state) {
+			if guard.validStateFile(file.path) {
+				guard.managed[file.path] = true
+			}
+		}
+	}
+	return guard, nil
+}
+
+// validStateFile reports whether a file recorded in a state, which may come
+// from the archive, is one run could have written: the unit of a service
+// that shadows no unit of the system, or an app file.
+func (g *restoreGuard) validStateFile(path string) bool {
+	if path == "" || !filepath.IsAbs(path) || filepath.Clean(path) != path {
+		return false
+	}
+	dir, name := filepath.Split(path)
+	if dir == "/etc/systemd/system/" {
+		service, isService := strings.CutSuffix(name, ".service")
+		if !isService || !serviceNamePattern.MatchString(service) {
+			return false
+		}
+		for _, unitDir := range systemdUnitDirs[1:] {
+			if _, err := os.Stat(filepath.Join(unitDir, name)); err == nil {
+				return false
+			}
+		}
+		return true
+	}
+	return g.appPath(path)
+}
+
+// appPath reports whether target is under restoreAppDirs or the app user's
+// home, outside hidden directories such as .ssh and not a dotfile other
+// than an env file.
+func (g *restoreGuard) appPath(target string) bool {
+	roots := restoreAppDirs
+	if g.appHome != "" {
+		roots = append(roots[:len(roots):len(roots)], g.appHome)
+	}
+	inside := false
+	for _, root := range roots {
+		inside = inside || strings.HasPrefix(target, root+"/")
+	}
+	if !inside {
+		return false
+	}
+	parts := strings.Split(strings.TrimPrefix(target, "/"), "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ".") && (i < len(parts)-1 || !strings.HasPrefix(part, ".env")) {
+			return false
+		}
+	}
+	return true
+}
+
+// managedHostPath reports whether a file outside the home and context
+// directories is one run backs up.
+func (g *restoreGuard) managedHostPath(target string) bool {
+	for _, pattern := range backupHostPatterns {
+		if matched, _ := filepath.Match(pattern, target); matched {
+			return true
+		}
+	}
+	switch name := filepath.Base(target); {
+	case name == "dump.pm2":
+		return g.appHome != "" && target == filepath.Join(g.appHome, ".pm2", name)
+	case containsString(pm2FileNames, name):
+		return g.appPath(target)
+	}
+	return g.managed[target]
+}
+
+// check refuses an entry restored to target that is outside what run backs
+// up, that would be written through a symlink restored before it, or that
+// is a symlink pointing outside its restore root.
+func (g *restoreGuard) check(header *tar.Header, target string) error {
+	prefix, _, _ := strings.Cut(header.Name, "/")
+	if prefix == "host" && !g.managedHostPath(target) {
+		return fmt.Errorf("refusing to restore %s: run only restores nginx sites, units of its services, pm2 files and app files under %s or the app user's home", target, strings.Join(restoreAppDirs, ", "))
+	}
+	for dir := filepath.Dir(target); dir != "/" && dir != "."; dir = filepath.Dir(dir) {
+		if g.links[dir] {
+			return fmt.Errorf("refusing to restore %s through the symlink %s", target, dir)
+		}
+		if info, err := os.Lstat(dir); prefix == "host" && err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("refusing to restore %s through the symlink %s", target, dir)
+		}
+	}
+
+	switch header.Typeflag {
+	case tar.TypeReg:
+	case tar.TypeSymlink:
+		link := header.Linkname
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(target), link)
+		}
+		link = filepath.Clean(link)
+		var inside bool
+		switch prefix {
+		case "context":
+			inside = link == g.contextDir || strings.HasPrefix(link, g.contextDir+"/")
+		case "home":
+			inside = g.home != "" && strings.HasPrefix(link, g.home+"/")
+		case "host":
+			inside = g.managedHostPath(link)
+		}
+		if !inside {
+			return fmt.Errorf("refusing to restore the symlink %s: it points outside what is restored, to %s", target, header.Linkname)
+		}
+		g.links[target] = true
+	default:
+		return fmt.Errorf("refusing to restore %s: not a file or symlink", target)
+	}
+	return nil
+}
+
+// decryptBackupSecrets decrypts the inner archive of secrets named name:
+// with passphrase when openssl encrypted it, and like the secrets store
+// when age or gpg did.
+func decryptBackupSecrets(name string, data []byte, passphrase, ageIdentity string) ([]byte, error) {
+	for encryption, secretsName := range backupSecretsNames {
+		if secretsName != name || encryption == "" {
+			continue
+		}
+		decrypted, err := decryptSecretData(encryption, ageIdentity, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt secrets with %s: %v", encryption, err)
+		}
+		return decrypted, nil
+	}
+	if passphrase == "" {
+		return nil, fmt.Errorf("the backup holds encrypted secrets: set %s or pass --passphrase-file, or leave them out with --no-secrets", BackupPassphraseEnv)
+	}
+	decrypted, err := opensslCrypt(data, passphrase, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secrets, check the passphrase: %v", err)
+	}
+	return decrypted, nil
+}
+
+// restoreBackupEntry writes one file of a backup archive to target, as
+// root when privileged.
+func restoreBackupEntry(header *tar.Header, data []byte, target string, privileged bool) error {
+	mode := os.FileMode(header.Mode).Perm()
+	if !privileged {
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %v", filepath.Dir(target), err)
+		}
+		os.Remove(target)
+		if header.Typeflag == tar.TypeSymlink {
+			return os.Symlink(header.Linkname, target)
+		}
+		if err := os.WriteFile(target, data, mode); err != nil {
+			return fmt.Errorf("failed to write %s: %v", target, err)
+		}
+		return nil
+	}
+
+	if header.Typeflag == tar.TypeSymlink {
+		if err := system.Command("mkdir", "-p", filepath.Dir(target)).WithSudo().Run(); err != nil {
+			return fmt.Errorf("failed to create %s: %v", filepath.Dir(target), err)
+		}
+		return system.Command("ln", "-sfn", header.Linkname, target).WithSudo().Run()
+	}
+	owner, group := strconv.Itoa(header.Uid), strconv.Itoa(header.Gid)
+	if _, err := user.Lookup(header.Uname); err == nil && header.Uname != "" {
+		owner = header.Uname
+	}
+	if _, err := user.LookupGroup(header.Gname); err == nil && header.Gname != "" {
+		group = header.Gname
+	}
+	tmp, err := os.CreateTemp("", ".run-restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	tmp.Write(data)
+	tmp.Close()
+	return system.Command("install", "-D", "-o", owner, "-g", group, "-m", fmt.Sprintf("%04o", mode), tmp.Name(), target).WithSudo().Run()
+}
+
+// backupUnits renders the systemd service and timer of scheduled backups
+// of a context.
+func backupUnits(user, binary, context string) (string, string) {
+	command := binary + " backup create --quiet"
+	if context != DefaultContext {
+		command += " --context " + context
+	}
+	service := fmt.Sprintf(`# Managed by run - removed by 'run backup schedule --disable'
+[Unit]
+Description=run backup of the run-managed configuration
+
+[Service]
+Type=oneshot
+User=%s
+ExecStart=%s
+`, user, command)
+
+	timer := `# Managed by run - removed by 'run backup schedule --disable'
+[Unit]
+Description=Daily run backup
+
+[Timer]
+OnCalendar=daily
+RandomizedDelaySec=1h
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`
+	return service, timer
+}
+
+// EnableBackupSchedule installs and starts a daily timer running `run
+// backup create` for the active context. Secrets are encrypted like the
+// secrets store, or with backup.passphrase_file, which must then be set
+// when there are any.
+func EnableBackupSchedule() error {
+	config, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	settings := config.Backup.WithDefaults()
+	files, err := backupFiles()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.secret && settings.PassphraseFile == "" && config.Secrets.Encryption == "" {
+			return fmt.Errorf("set secrets.encryption or backup.passphrase_file in ~/.run/config.yaml to encrypt the secrets of scheduled backups")
+		}
+	}
+	if _, err := BackupPassphrase(settings.PassphraseFile); err != nil {
+		return err
+	}
+
+	binary, err := runBinary()
+	if err != nil {
+		return err
+	}
+	user := os.Getenv("USER")
+	if user == "" {
+		return fmt.Errorf("USER environment variable is not set")
+	}
+	service, timer := backupUnits(user, binary, CurrentContext())
+	return enableTimer(backupUnit, service, timer)
+}
+
+// DisableBackupSchedule stops the backup timer and removes its units.
+func DisableBackupSchedule() error {
+	return disableTimer(backupUnit)
+}
+
+// BackupScheduleStatus returns whether the backup timer is enabled and when
+// it runs next.
+func BackupScheduleStatus() (bool, string) {
+	return timerStatus(backupUnit)
+}
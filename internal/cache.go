@@ -0,0 +1,261 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PackageEnv tells a package script, and the run commands it calls, which
+// package is being installed.
+const PackageEnv = "RUN_PACKAGE"
+
+// DefaultCacheRetention is how long unreferenced artifacts stay in the cache
+// before `run cache gc` removes them, so a quick reinstall still hits it.
+const DefaultCacheRetention = 7 * 24 * time.Hour
+
+// partialRetention is how long an interrupted download is kept to resume.
+const partialRetention = 24 * time.Hour
+
+// ArtifactRef is a cached artifact a package script downloaded with `run
+// download`.
+type ArtifactRef struct {
+	SHA256 string    `json:"sha256"`
+	URL    string    `json:"url"`
+	UsedAt time.Time `json:"used_at"`
+}
+
+// CacheUsage is the size of a part of the cache.
+type CacheUsage struct {
+	Name  string
+	Files int
+	Bytes int64
+}
+
+// CacheStats is the size of each directory of ~/.run/cache, with the
+// artifacts split by whether an installed package still references them.
+type CacheStats struct {
+	Dirs         []CacheUsage
+	Referenced   CacheUsage
+	Unreferenced CacheUsage
+}
+
+// CacheDir returns the directory of run's caches (~/.run/cache), shared by
+// all contexts.
+func CacheDir() (string, error) {
+	runDir, err := RunDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(runDir, "cache"), nil
+}
+
+// RecordArtifact records that a package used a cached artifact, named by
+// its checksum in the content-addressed cache.
+func RecordArtifact(packageName, path, url string) error {
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	if state.Artifacts == nil {
+		state.Artifacts = make(map[string][]ArtifactRef)
+	}
+	ref := ArtifactRef{SHA256: filepath.Base(path), URL: url, UsedAt: time.Now().UTC()}
+	refs := state.Artifacts[packageName]
+	for i, existing := range refs {
+		if existing.SHA256 == ref.SHA256 {
+			refs = append(refs[:i], refs[i+1:]...)
+			break
+		}
+	}
+	state.Artifacts[packageName] = append(refs, ref)
+	return state.Save()
+}
+
+// PruneArtifactRefs drops the artifacts a package last used before an
+// install that succeeded without them, such as the tarball of an earlier
+// version.
+func PruneArtifactRefs(packageName string, before time.Time) error {
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	refs, exists := state.Artifacts[packageName]
+	if !exists {
+		return nil
+	}
+	var kept []ArtifactRef
+	for _, ref := range refs {
+		if !ref.UsedAt.Before(before) {
+			kept = append(kept, ref)
+		}
+	}
+	if len(kept) == len(refs) {
+		return nil
+	}
+	if len(kept) == 0 {
+		delete(state.Artifacts, packageName)
+	} else {
+		state.Artifacts[packageName] = kept
+	}
+	return state.Save()
+}
+
+// ForgetArtifacts drops the artifact references of a removed package, so
+// `run cache gc` can remove them.
+func ForgetArtifacts(packageName string) error {
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	if _, exists := state.Artifacts[packageName]; !exists {
+		return nil
+	}
+	delete(state.Artifacts, packageName)
+	return state.Save()
+}
+
+// referencedArtifacts returns the checksums of artifacts referenced by the
+// state of any context, since the cache is shared between them.
+func referencedArtifacts() (map[string]bool, error) {
+	names, err := ContextNames()
+	if err != nil {
+		return nil, err
+	}
+	referenced := make(map[string]bool)
+	for _, name := range names {
+		dir, err := contextDir(name)
+		if err != nil {
+			return nil, err
+		}
+		state, err := loadStateFile(filepath.Join(dir, "state.json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, refs := range state.Artifacts {
+			for _, ref := range refs {
+				referenced[ref.SHA256] = true
+			}
+		}
+	}
+	return referenced, nil
+}
+
+// dirUsage returns the number and size of the files under dir.
+func dirUsage(dir string) CacheUsage {
+	usage := CacheUsage{Name: filepath.Base(dir)}
+	filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			usage.Files++
+			usage.Bytes += info.Size()
+		}
+		return nil
+	})
+	return usage
+}
+
+// GetCacheStats measures the cache: artifacts from `run download`, the
+// extracted embedded scripts, PHP builds and the Go caches of `run update`.
+func GetCacheStats() (CacheStats, error) {
+	var stats CacheStats
+	cacheDir, err := CacheDir()
+	if err != nil {
+		return stats, err
+	}
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil && !os.IsNotExist(err) {
+		return stats, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			stats.Dirs = append(stats.Dirs, dirUsage(filepath.Join(cacheDir, entry.Name())))
+		}
+	}
+
+	referenced, err := referencedArtifacts()
+	if err != nil {
+		return stats, err
+	}
+	stats.Referenced.Name, stats.Unreferenced.Name = "referenced", "unreferenced"
+	artifacts, _ := os.ReadDir(filepath.Join(cacheDir, "artifacts", "sha256"))
+	for _, artifact := range artifacts {
+		info, err := artifact.Info()
+		if err != nil {
+			continue
+		}
+		usage := &stats.Unreferenced
+		if referenced[artifact.Name()] {
+			usage = &stats.Referenced
+		}
+		usage.Files++
+		usage.Bytes += info.Size()
+	}
+	return stats, nil
+}
+
+// CollectGarbage removes what no one needs from the cache: artifacts no
+// context references that were not used for olderThan, interrupted
+// downloads older than a day and scripts extracted by other versions of
+// run. With dryRun nothing is removed. It returns the removed paths and the
+// bytes freed.
+func CollectGarbage(olderThan time.Duration, dryRun bool) ([]string, int64, error) {
+	cacheDir, err := CacheDir()
+	if err != nil {
+		return nil, 0, err
+	}
+	referenced, err := referencedArtifacts()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var candidates []string
+	now := time.Now()
+	list := func(dir string, keep func(name string, age time.Duration) bool) {
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			age := now.Sub(info.ModTime())
+			if !keep(entry.Name(), age) {
+				candidates = append(candidates, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	list(filepath.Join(cacheDir, "artifacts", "sha256"), func(name string, age time.Duration) bool {
+		return referenced[name] || age < olderThan
+	})
+	list(filepath.Join(cacheDir, "artifacts", "partial"), func(name string, age time.Duration) bool {
+		return age < partialRetention
+	})
+	current, _ := embeddedScriptsHash()
+	list(filepath.Join(cacheDir, "scripts"), func(name string, age time.Duration) bool {
+		// Extractions in progress are left alone
+		return name == current || strings.HasSuffix(name, ".tmp") && age < time.Hour
+	})
+	sort.Strings(candidates)
+
+	var removed []string
+	var freed int64
+	for _, path := range candidates {
+		size := dirUsage(path).Bytes
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			size = info.Size()
+		}
+		if !dryRun {
+			if err := os.RemoveAll(path); err != nil {
+				return removed, freed, fmt.Errorf("failed to remove %s: %v", path, err)
+			}
+		}
+		removed = append(removed, path)
+		freed += size
+	}
+	return removed, freed, nil
+}
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// CapabilityFormats lists the formats of `run capabilities`.
+var CapabilityFormats = []string{"markdown", "json"}
+
+// Capability is what a package supports, for `run capabilities`. It is
+// built from the registry only, so generated documentation cannot drift
+// from the code.
+type Capability struct {
+	Package  string   `json:"package"`
+	Versions []string `json:"versions,omitempty"`
+	// SideBySide is set when several versions can be installed at once and
+	// switched with `run use`.
+	SideBySide    bool     `json:"side_by_side"`
+	Backends      []string `json:"backends,omitempty"`
+	Releases      []string `json:"releases"`
+	Architectures []string `json:"architectures"`
+	// UserModes are the backends that install into the user's home.
+	UserModes []string `json:"user_modes,omitempty"`
+	Services  []string `json:"services,omitempty"`
+	Removable bool     `json:"removable"`
+}
+
+// Capabilities returns the capabilities of every registry package, sorted
+// by name.
+func Capabilities() []Capability {
+	var capabilities []Capability
+	for _, name := range ListPackages() {
+		support := PackageSupport[name]
+		capability := Capability{
+			Package:       name,
+			Versions:      support.Versions,
+			SideBySide:    IsVersionedPackage(name),
+			Releases:      support.Releases,
+			Architectures: support.Architectures,
+			Services:      support.Services,
+		}
+		if len(capability.Releases) == 0 {
+			capability.Releases = SupportedReleases
+		}
+		if len(capability.Architectures) == 0 {
+			capability.Architectures = SupportedArchitectures
+		}
+		for _, backend := range mapKeys(PackageBackends[name]) {
+			capability.Backends = append(capability.Backends, backend)
+			if PackageBackends[name][backend].Scope == "user" {
+				capability.UserModes = append(capability.UserModes, backend)
+			}
+		}
+		_, capability.Removable = RemovePackageRegistry[name]
+		capabilities = append(capabilities, capability)
+	}
+	return capabilities
+}
+
+// RenderCapabilities writes the capability matrix as a markdown table or
+// as JSON.
+func RenderCapabilities(format string, capabilities []Capability) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "markdown":
+		fmt.Fprintln(&buf, "| Package | Versions | Releases | Architectures | User mode | Services | Removable |")
+		fmt.Fprintln(&buf, "|---|---|---|---|---|---|---|")
+		for _, c := range capabilities {
+			fmt.Fprintf(&buf, "| %s | %s | %s | %s | %s | %s | %s |\n", c.Package, describeVersions(c),
+				strings.Join(c.Releases, ", "), strings.Join(c.Architectures, ", "),
+				describeList(c.UserModes, "yes (%s)", "no"), describeList(c.Services, "%s", "none"), yesNo(c.Removable))
+		}
+	case "json":
+		data, err := json.MarshalIndent(capabilities, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(append(data, '\n'))
+	default:
+		return nil, fmt.Errorf("unknown capabilities format '%s': use %s", format, strings.Join(CapabilityFormats, ", "))
+	}
+	return buf.Bytes(), nil
+}
+
+// describeVersions lists the versions of a package with the default first,
+// and how they are installed.
+func describeVersions(c Capability) string {
+	var parts []string
+	if len(c.Versions) > 0 {
+		versions := append([]string{c.Versions[0] + " (default)"}, c.Versions[1:]...)
+		parts = append(parts, strings.Join(versions, ", "))
+	} else {
+		parts = append(parts, "not selectable")
+	}
+	if c.SideBySide {
+		parts = append(parts, "side by side")
+	}
+	if len(c.Backends) > 0 {
+		parts = append(parts, "backends: "+strings.Join(c.Backends, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// describeList formats a list with format, or returns empty when there
+// are no items.
+func describeList(items []string, format, empty string) string {
+	if len(items) == 0 {
+		return empty
+	}
+	return strings.ReplaceAll(fmt.Sprintf(format, strings.Join(items, ", ")), "|", `\|`)
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
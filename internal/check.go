@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// CheckResult is the outcome of verifying a single item of a package.
+type CheckResult struct {
+	Name    string
+	OK      bool
+	Message string
+	// Latency is the response time of health probes.
+	Latency time.Duration
+}
+
+// PackageCommands maps packages to the command whose presence shows the
+// package is installed.
+var PackageCommands = map[string]string{
+	"docker":   "docker",
+	"java":     "java",
+	"nginx":    "nginx",
+	"node":     "node",
+	"php":      "php",
+	"pm2":      "pm2",
+	"postgres": "psql",
+	"python":   "python3",
+}
+
+// PackageChecks holds package-specific checks that go beyond the presence of
+// the package command.
+var PackageChecks = map[string]func() []CheckResult{
+	"essentials": checkEssentials,
+	"hardening":  checkHardening,
+	"node":       checkNode,
+	"nvidia":     checkNvidia,
+}
+
+// CheckPackage verifies that a package is installed and correctly set up.
+// Health probes run once the package itself checks out.
+func CheckPackage(packageName string) ([]CheckResult, error) {
+	results, err := checkInstalled(packageName)
+	if err != nil {
+		// Services and pm2 apps may have probes without being packages
+		if health := checkHealth(packageName); len(health) > 0 {
+			return health, nil
+		}
+		return nil, err
+	}
+	for _, result := range results {
+		if !result.OK {
+			return results, nil
+		}
+	}
+	return append(results, checkHealth(packageName)...), nil
+}
+
+// checkInstalled verifies that a package is installed.
+func checkInstalled(packageName string) ([]CheckResult, error) {
+	if check, exists := PackageChecks[packageName]; exists {
+		return check(), nil
+	}
+
+	command, exists := PackageCommands[packageName]
+	if !exists {
+		if state, err := LoadState(); err == nil {
+			if pkg, local := state.LocalPackages[packageName]; local {
+				return checkLocalPackage(packageName, pkg), nil
+			}
+		}
+		return nil, fmt.Errorf("no check available for package '%s'", packageName)
+	}
+	return []CheckResult{checkCommand(command)}, nil
+}
+
+// checkCommand reports whether a command is available in PATH.
+func checkCommand(command string) CheckResult {
+	if _, err := exec.LookPath(command); err != nil {
+		return CheckResult{Name: command, OK: false, Message: "not found in PATH"}
+	}
+	return CheckResult{Name: command, OK: true, Message: "installed"}
+}
+
+// checkNode verifies node and the configured global npm packages.
+func checkNode() []CheckResult {
+	nodeResult := checkCommand("node")
+	if !nodeResult.OK {
+		return []CheckResult{nodeResult}
+	}
+	if output, err := exec.Command("node", "--version").Output(); err == nil {
+		nodeResult.Message = strings.TrimSpace(string(output))
+	}
+	results := []CheckResult{nodeResult}
+
+	config, err := LoadConfig()
+	if err != nil {
+		return append(results, CheckResult{Name: "config", OK: false, Message: err.Error()})
+	}
+
+	statuses, err := NpmGlobalsStatus(config)
+	if err != nil {
+		return append(results, CheckResult{Name: "npm globals", OK: false, Message: err.Error()})
+	}
+
+	for _, status := range statuses {
+		result := CheckResult{Name: status.Package.String(), OK: status.UpToDate()}
+		switch {
+		case status.InstalledVersion == "":
+			result.Message = "not installed"
+		case result.OK:
+			result.Message = status.InstalledVersion
+		default:
+			result.Message = fmt.Sprintf("version %s installed", status.InstalledVersion)
+		}
+		results = append(results, result)
+	}
+	return results
+}
@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// maxClockSkew is the largest clock difference tolerated. apt rejects
+// Release files that are "not valid yet", and TLS rejects certificates, well
+// before clocks are minutes apart.
+const maxClockSkew = time.Minute
+
+// clockReference is asked for the time through its HTTP Date header. Plain
+// HTTP is used because a wrong clock is exactly what breaks TLS.
+const clockReference = ubuntuArchive
+
+// checkClock reports whether the clock is synchronized and how far it is
+// from a reference server.
+func checkClock() []CheckResult {
+	results := []CheckResult{checkTimeSync()}
+	if IsOffline() {
+		return append(results, CheckResult{Name: "clock skew", OK: true, Message: "skipped: offline mode"})
+	}
+	return append(results, checkClockSkew())
+}
+
+// checkTimeSync asks systemd whether NTP is enabled and synchronized.
+func checkTimeSync() CheckResult {
+	output, err := exec.Command("timedatectl", "show", "--property=NTP", "--property=NTPSynchronized").Output()
+	if err != nil {
+		return CheckResult{Name: "time sync", OK: false, Message: "timedatectl unavailable"}
+	}
+	properties := map[string]string{}
+	for _, line := range strings.Split(string(output), "\n") {
+		if key, value, found := strings.Cut(line, "="); found {
+			properties[key] = value
+		}
+	}
+	switch {
+	case properties["NTP"] != "yes":
+		return CheckResult{Name: "time sync", OK: false, Message: "NTP is disabled (fix with run doctor --fix)"}
+	case properties["NTPSynchronized"] != "yes":
+		return CheckResult{Name: "time sync", OK: false, Message: "NTP enabled but not synchronized yet"}
+	}
+	return CheckResult{Name: "time sync", OK: true, Message: "synchronized"}
+}
+
+// checkClockSkew compares the clock with the Date header of clockReference.
+func checkClockSkew() CheckResult {
+	client := &http.Client{Timeout: 10 * time.Second}
+	started := time.Now()
+	resp, err := client.Head(clockReference)
+	if err != nil {
+		return CheckResult{Name: "clock skew", OK: false, Message: fmt.Sprintf("cannot reach %s: %v", clockReference, err)}
+	}
+	resp.Body.Close()
+	remote, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return CheckResult{Name: "clock skew", OK: false, Message: "no Date header from " + clockReference}
+	}
+
+	// Compare against the middle of the request; the header has 1s resolution
+	local := started.Add(time.Since(started) / 2)
+	skew := local.Sub(remote).Round(time.Second)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxClockSkew {
+		return CheckResult{Name: "clock skew", OK: false, Message: fmt.Sprintf("clock is off by %s (fix with run doctor --fix)", skew)}
+	}
+	return CheckResult{Name: "clock skew", OK: true, Message: fmt.Sprintf("within %s", maxClockSkew)}
+}
+
+// fixClock enables systemd-timesyncd, which corrects the clock on its own.
+func fixClock() error {
+	if err := system.Command("timedatectl", "set-ntp", "true").WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to enable NTP: %v", err)
+	}
+	if err := system.Command("systemctl", "enable", "--now", "systemd-timesyncd").WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to start systemd-timesyncd: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+)
+
+// Cloud providers run can detect.
+const (
+	CloudAzure = "azure"
+	CloudAWS   = "aws"
+	CloudGCP   = "gcp"
+	// CloudNone disables detection when set as cloud.provider.
+	CloudNone = "none"
+)
+
+// CloudConfig selects per-provider settings for package scripts.
+type CloudConfig struct {
+	// Provider overrides detection: azure, aws, gcp or none.
+	Provider string `yaml:"provider"`
+	// Profiles override DefaultCloudProfiles, keyed by provider.
+	Profiles map[string]CloudProfile `yaml:"profiles"`
+}
+
+// CloudProfile holds the settings that differ between cloud providers.
+type CloudProfile struct {
+	// User owns app directories and services (pm2, log directories).
+	// Empty means the user running run.
+	User string `yaml:"user"`
+	// NTPServers are the time servers systemd-timesyncd uses, usually the
+	// provider's own. Empty keeps the distribution default.
+	NTPServers []string `yaml:"ntp_servers"`
+}
+
+// DefaultCloudProfiles are the settings of each provider's Ubuntu images.
+var DefaultCloudProfiles = map[string]CloudProfile{
+	CloudAzure: {User: "azureuser"},
+	CloudAWS:   {User: "ubuntu", NTPServers: []string{"169.254.169.123"}},
+	CloudGCP:   {NTPServers: []string{"metadata.google.internal"}},
+}
+
+// cloudVendors maps the DMI system vendor to a provider, which avoids
+// probing metadata endpoints on most VMs.
+var cloudVendors = map[string]string{
+	"Microsoft Corporation": CloudAzure,
+	"Amazon EC2":            CloudAWS,
+	"Google":                CloudGCP,
+}
+
+// CloudProviderOverride is set by callers that know the provider, such as
+// the Azure extension handler, and takes precedence over cloud.provider.
+var CloudProviderOverride string
+
+// detectedCloud caches DetectCloud within a run.
+var detectedCloud *string
+
+// DetectCloud returns the provider of the VM run is on, or an empty string
+// when it is not a known cloud. The DMI vendor is checked first; the
+// metadata endpoints are only probed when it is inconclusive.
+func DetectCloud() string {
+	if detectedCloud != nil {
+		return *detectedCloud
+	}
+	provider := detectCloud()
+	detectedCloud = &provider
+	return provider
+}
+
+func detectCloud() string {
+	if data, err := os.ReadFile("/sys/class/dmi/id/sys_vendor"); err == nil {
+		if provider, exists := cloudVendors[strings.TrimSpace(string(data))]; exists {
+			return provider
+		}
+	}
+
+	client := &http.Client{Timeout: time.Second}
+	probes := []struct {
+		provider string
+		url      string
+		header   [2]string
+	}{
+		{CloudAzure, "http://169.254.169.254/metadata/instance?api-version=2021-02-01", [2]string{"Metadata", "true"}},
+		{CloudGCP, "http://metadata.google.internal/computeMetadata/v1/", [2]string{"Metadata-Flavor", "Google"}},
+		{CloudAWS, "http://169.254.169.254/latest/meta-data/", [2]string{}},
+	}
+	for _, probe := range probes {
+		req, err := http.NewRequest(http.MethodGet, probe.url, nil)
+		if err != nil {
+			continue
+		}
+		if probe.header[0] != "" {
+			req.Header.Set(probe.header[0], probe.header[1])
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		switch probe.provider {
+		case CloudAzure:
+			if resp.StatusCode == http.StatusOK {
+				return CloudAzure
+			}
+		case CloudGCP:
+			if resp.Header.Get("Metadata-Flavor") == "Google" {
+				return CloudGCP
+			}
+		case CloudAWS:
+			// IMDSv2-only instances answer 401 without a token
+			if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusUnauthorized {
+				return CloudAWS
+			}
+		}
+	}
+	return ""
+}
+
+// ActiveCloudProfile returns the provider of this host, from
+// CloudProviderOverride, config or detection, and its profile: the defaults
+// with the config's overrides.
+func ActiveCloudProfile(config *Config) (string, CloudProfile, error) {
+	provider := config.Cloud.Provider
+	if CloudProviderOverride != "" {
+		provider = CloudProviderOverride
+	}
+	switch provider {
+	case "":
+		provider = DetectCloud()
+	case CloudNone:
+		provider = ""
+	case CloudAzure, CloudAWS, CloudGCP:
+	default:
+		return "", CloudProfile{}, fmt.Errorf("unknown cloud provider '%s': use azure, aws, gcp or none", provider)
+	}
+
+	profile := DefaultCloudProfiles[provider]
+	if override, exists := config.Cloud.Profiles[provider]; exists {
+		if override.User != "" {
+			profile.User = override.User
+		}
+		if override.NTPServers != nil {
+			profile.NTPServers = override.NTPServers
+		}
+	}
+	return provider, profile, nil
+}
+
+// AppUser returns the user of a profile, falling back to the user running
+// run (the invoking user under sudo).
+func (p CloudProfile) AppUser() string {
+	if p.User != "" {
+		return p.User
+	}
+	if sudoUser := os.Getenv("SUDO_USER"); sudoUser != "" {
+		return sudoUser
+	}
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
+
+// CloudScriptEnv returns the environment that tells package scripts about
+// the cloud profile: RUN_CLOUD, RUN_APP_USER and RUN_NTP_SERVERS.
+func CloudScriptEnv() ([]string, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	provider, profile, err := ActiveCloudProfile(config)
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		"RUN_CLOUD=" + provider,
+		"RUN_APP_USER=" + profile.AppUser(),
+		"RUN_NTP_SERVERS=" + strings.Join(profile.NTPServers, " "),
+	}, nil
+}
@@ -0,0 +1,247 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// ComposeProject is a Docker Compose stack registered with
+// `run compose register`, brought up at boot by a systemd unit.
+type ComposeProject struct {
+	File      string    `json:"file"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ComposeContainer is a container of a compose project, as reported by
+// `docker compose ps`.
+type ComposeContainer struct {
+	Service string `json:"Service"`
+	Name    string `json:"Name"`
+	State   string `json:"State"`
+	// Health is healthy, unhealthy or starting for containers with a
+	// healthcheck, and empty otherwise.
+	Health string `json:"Health"`
+}
+
+// OK reports whether the container is running and, if it has a
+// healthcheck, healthy.
+func (c ComposeContainer) OK() bool {
+	return c.State == "running" && (c.Health == "" || c.Health == "healthy")
+}
+
+// composeUnit returns the systemd unit name of a compose project. The prefix
+// keeps projects from clashing with other units.
+func composeUnit(name string) string {
+	return "run-compose-" + name + ".service"
+}
+
+// composeArgs returns the docker arguments addressing a project.
+func composeArgs(name string, project ComposeProject, args ...string) []string {
+	return append([]string{"compose", "--project-name", name, "--file", project.File}, args...)
+}
+
+// renderComposeUnit renders a unit that brings the project up at boot and
+// down when stopped.
+func renderComposeUnit(name, docker string, project ComposeProject) string {
+	up := strings.Join(append([]string{docker}, composeArgs(name, project, "up", "--detach", "--remove-orphans")...), " ")
+	down := strings.Join(append([]string{docker}, composeArgs(name, project, "down")...), " ")
+	return fmt.Sprintf(`# Managed by run - removed by 'run compose remove %s'
+[Unit]
+Description=Docker Compose project %s
+Requires=docker.service
+After=docker.service network-online.target
+Wants=network-online.target
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+WorkingDirectory=%s
+ExecStart=%s
+ExecStop=%s
+TimeoutStartSec=10min
+
+[Install]
+WantedBy=multi-user.target
+`, name, name, filepath.Dir(project.File), up, down)
+}
+
+// lookupComposeProject returns a registered project.
+func lookupComposeProject(name string) (ComposeProject, error) {
+	state, err := LoadState()
+	if err != nil {
+		return ComposeProject{}, err
+	}
+	project, exists := state.ComposeProjects[name]
+	if !exists {
+		return ComposeProject{}, fmt.Errorf("compose project '%s' is not registered. Register it with: run compose register %s --file <docker-compose.yml>", name, name)
+	}
+	return project, nil
+}
+
+// RegisterCompose validates a compose file, writes and enables the unit that
+// brings it up at boot, and records the project in the state.
+func RegisterCompose(name, file string) error {
+	if !serviceNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid project name '%s': use letters, digits, '.', '_' and '-'", name)
+	}
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	if _, exists := state.ComposeProjects[name]; exists {
+		return fmt.Errorf("compose project '%s' is already registered. Remove it first with: run compose remove %s", name, name)
+	}
+	if file, err = filepath.Abs(file); err != nil {
+		return err
+	}
+	if _, err := os.Stat(file); err != nil {
+		return fmt.Errorf("cannot read compose file: %v", err)
+	}
+	docker, err := exec.LookPath("docker")
+	if err != nil {
+		return fmt.Errorf("docker is not installed. Install it with: run install docker")
+	}
+	project := ComposeProject{File: file}
+	if err := system.Command(docker, composeArgs(name, project, "config", "--quiet")...).Run(); err != nil {
+		return fmt.Errorf("invalid compose file %s: %v", file, err)
+	}
+
+	unit := composeUnit(name)
+	if err := system.WriteFileAsRoot(filepath.Join("/etc/systemd/system", unit), []byte(renderComposeUnit(name, docker, project)), 0644); err != nil {
+		return err
+	}
+	if err := system.Command("systemctl", "daemon-reload").WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to reload systemd: %v", err)
+	}
+	if err := system.Command("systemctl", "enable", unit).WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to enable %s: %v", unit, err)
+	}
+
+	if state.ComposeProjects == nil {
+		state.ComposeProjects = make(map[string]ComposeProject)
+	}
+	project.CreatedAt = time.Now().UTC()
+	state.ComposeProjects[name] = project
+	return state.Save()
+}
+
+// RemoveCompose brings a project down, deletes its unit and forgets it.
+func RemoveCompose(name string) error {
+	if _, err := lookupComposeProject(name); err != nil {
+		return err
+	}
+	unit := composeUnit(name)
+	system.Command("systemctl", "disable", "--now", unit).WithSudo().Run()
+	if err := system.RemoveFileAsRoot(filepath.Join("/etc/systemd/system", unit)); err != nil {
+		return err
+	}
+	if err := system.Command("systemctl", "daemon-reload").WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to reload systemd: %v", err)
+	}
+
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	delete(state.ComposeProjects, name)
+	return state.Save()
+}
+
+// ComposeUp starts a project through its unit, so systemd knows it is up.
+func ComposeUp(name string) error {
+	if _, err := lookupComposeProject(name); err != nil {
+		return err
+	}
+	if err := system.Command("systemctl", "start", composeUnit(name)).WithSudo().Stream(Console, os.Stderr); err != nil {
+		return fmt.Errorf("failed to bring up %s: %v. See: journalctl -u %s", name, err, composeUnit(name))
+	}
+	return nil
+}
+
+// ComposeDown stops a project through its unit. It comes up again at boot.
+func ComposeDown(name string) error {
+	if _, err := lookupComposeProject(name); err != nil {
+		return err
+	}
+	if err := system.Command("systemctl", "stop", composeUnit(name)).WithSudo().Stream(Console, os.Stderr); err != nil {
+		return fmt.Errorf("failed to bring down %s: %v", name, err)
+	}
+	return nil
+}
+
+// ComposeStatus returns the containers of a project.
+func ComposeStatus(name string) ([]ComposeContainer, error) {
+	project, err := lookupComposeProject(name)
+	if err != nil {
+		return nil, err
+	}
+	output, err := system.Command("docker", composeArgs(name, project, "ps", "--all", "--format", "json")...).CaptureOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the status of %s: %v", name, err)
+	}
+	return parseComposePs(output)
+}
+
+// parseComposePs reads `docker compose ps --format json`, which older
+// Compose releases print as an array and newer ones as one object per line.
+func parseComposePs(output []byte) ([]ComposeContainer, error) {
+	output = bytes.TrimSpace(output)
+	var containers []ComposeContainer
+	if bytes.HasPrefix(output, []byte("[")) {
+		if err := json.Unmarshal(output, &containers); err != nil {
+			return nil, fmt.Errorf("failed to parse docker compose ps: %v", err)
+		}
+		return containers, nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var container ComposeContainer
+		if err := json.Unmarshal(scanner.Bytes(), &container); err != nil {
+			return nil, fmt.Errorf("failed to parse docker compose ps: %v", err)
+		}
+		containers = append(containers, container)
+	}
+	return containers, nil
+}
+
+// ListComposeProjects returns the names of the registered projects, sorted.
+func ListComposeProjects() []string {
+	state, err := LoadState()
+	if err != nil {
+		return nil
+	}
+	return mapKeys(state.ComposeProjects)
+}
+
+// CheckCompose checks that every container of a project is running and
+// healthy.
+func CheckCompose(name string) []CheckResult {
+	containers, err := ComposeStatus(name)
+	if err != nil {
+		return []CheckResult{{Name: "containers", Message: err.Error()}}
+	}
+	if len(containers) == 0 {
+		return []CheckResult{{Name: "containers", Message: "no containers; bring the project up with: run compose up " + name}}
+	}
+	var results []CheckResult
+	for _, container := range containers {
+		message := container.State
+		if container.Health != "" {
+			message += ", " + container.Health
+		}
+		results = append(results, CheckResult{Name: container.Service, OK: container.OK(), Message: message})
+	}
+	return results
+}
@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds user settings loaded from ~/.run/config.yaml. Missing keys
+// keep the values from DefaultConfig.
+type Config struct {
+	Node       NodeConfig       `yaml:"node"`
+	Essentials EssentialsConfig `yaml:"essentials"`
+	Hardening  HardeningConfig  `yaml:"hardening"`
+	Policy     PolicyConfig     `yaml:"policy"`
+	Registry   RegistryConfig   `yaml:"registry"`
+	Downloads  DownloadsConfig  `yaml:"downloads"`
+	Cloud      CloudConfig      `yaml:"cloud"`
+	Logs       LogsConfig       `yaml:"logs"`
+	Health     HealthConfig     `yaml:"health"`
+	Network    NetworkConfig    `yaml:"network"`
+	System     SystemConfig     `yaml:"system"`
+	Update     UpdateConfig     `yaml:"update"`
+	Backup     BackupConfig     `yaml:"backup"`
+	Secrets    SecretsConfig    `yaml:"secrets"`
+	Watch      WatchConfig      `yaml:"watch"`
+	// Vars are values for `run generate env` templates.
+	Vars map[string]string `yaml:"vars"`
+	// Profiles add to or replace BuiltinProfiles (`run profile apply`).
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// NodeConfig configures the node package.
+type NodeConfig struct {
+	// GlobalPackages are npm packages installed globally alongside node,
+	// optionally pinned with @version (e.g. "pnpm@9.10.0").
+	GlobalPackages []string `yaml:"global_packages"`
+	// Backend installs node from nodesource (default), nvm or apt;
+	// --backend overrides it.
+	Backend string `yaml:"backend"`
+}
+
+// EssentialsConfig configures the essentials package.
+type EssentialsConfig struct {
+	// Items turns essentials items on or off (see `run check essentials`).
+	// Items not listed use their default.
+	Items map[string]bool `yaml:"items"`
+}
+
+// HardeningConfig configures the hardening package.
+type HardeningConfig struct {
+	// SSHKeyOnly disables SSH password authentication.
+	SSHKeyOnly bool `yaml:"ssh_key_only"`
+}
+
+// RegistryConfig configures where the package registry is fetched from.
+type RegistryConfig struct {
+	// URL overrides DefaultRegistryURL for `run registry update`.
+	URL string `yaml:"url"`
+}
+
+// DownloadsConfig limits and redirects the downloads of package installs.
+type DownloadsConfig struct {
+	// RateLimit caps download speed in KB/s for apt and for curl and wget
+	// run by package scripts. 0 means unlimited.
+	RateLimit int `yaml:"rate_limit"`
+	// Mirrors are Ubuntu archive mirrors apt tries in order before
+	// archive.ubuntu.com.
+	Mirrors []string `yaml:"mirrors"`
+	// ArtifactMirrors map URL prefixes of artifacts fetched with `run
+	// download` to mirrors tried before the origin.
+	ArtifactMirrors map[string]string `yaml:"artifact_mirrors"`
+}
+
+// DefaultConfig returns the settings used when no config file exists.
+func DefaultConfig() *Config {
+	return &Config{
+		Node: NodeConfig{
+			GlobalPackages: []string{"pnpm@9.10.0", "pm2"},
+		},
+	}
+}
+
+// RunDir returns the directory where run keeps its scripts and data (~/.run).
+func RunDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error getting home directory: %v", err)
+	}
+	return filepath.Join(home, "."+CLIName), nil
+}
+
+// ConfigPath returns the location of the user config file.
+func ConfigPath() (string, error) {
+	runDir, err := ContextDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(runDir, "config.yaml"), nil
+}
+
+// LoadConfig reads the user config file, falling back to defaults when it
+// does not exist. The policy is the host's: contexts other than the default
+// one use the policy of ~/.run/config.yaml, whatever their own config says,
+// so --context cannot lift it.
+func LoadConfig() (*Config, error) {
+	configPath, err := ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	config, err := readConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	runDir, err := RunDir()
+	if err != nil {
+		return nil, err
+	}
+	if hostPath := filepath.Join(runDir, "config.yaml"); hostPath != configPath {
+		host, err := readConfig(hostPath)
+		if err != nil {
+			return nil, err
+		}
+		config.Policy = host.Policy
+	}
+	return config, nil
+}
+
+// readConfig reads a config file over the defaults.
+func readConfig(configPath string) (*Config, error) {
+	config := DefaultConfig()
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return nil, fmt.Errorf("failed to read config %s: %v", configPath, err)
+	}
+
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %v", configPath, err)
+	}
+	return config, nil
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ConfirmMode decides how Confirm answers.
+type ConfirmMode int
+
+// Confirm modes, set from --yes and --assume-no.
+const (
+	// ConfirmAsk asks on the terminal, or answers no when there is none.
+	ConfirmAsk ConfirmMode = iota
+	ConfirmAssumeYes
+	ConfirmAssumeNo
+)
+
+// ConfirmDefault is how Confirm answers in this run.
+var ConfirmDefault = ConfirmAsk
+
+// NonInteractiveEnv names the environment variable that stops run from
+// asking questions even on a terminal, as DEBIAN_FRONTEND does for apt.
+const NonInteractiveEnv = "RUN_NONINTERACTIVE"
+
+// stdinIsTerminal reports whether someone can answer on stdin. /dev/null is
+// a character device too, as used by cron and the VM agents.
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	devNull, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, devNull)
+}
+
+// Interactive reports whether Confirm would ask someone, rather than answer
+// from --yes, --assume-no or the lack of a terminal.
+func Interactive() bool {
+	return ConfirmDefault == ConfirmAsk && os.Getenv(NonInteractiveEnv) == "" && stdinIsTerminal()
+}
+
+// Confirm asks a yes/no question before a destructive step, defaulting to
+// no. --yes and --assume-no answer without asking; without a terminal, or
+// with RUN_NONINTERACTIVE set, the answer is no so unattended runs never
+// hang or destroy anything by accident.
+func Confirm(question string) bool {
+	prompt := question + " [y/N]: "
+	switch {
+	case ConfirmDefault == ConfirmAssumeYes:
+		fmt.Fprintf(Console, "%sy (--yes)\n", prompt)
+		return true
+	case ConfirmDefault == ConfirmAssumeNo:
+		fmt.Fprintf(Console, "%sn (--assume-no)\n", prompt)
+		return false
+	case os.Getenv(NonInteractiveEnv) != "" || !stdinIsTerminal():
+		fmt.Fprintf(Console, "%sn (not interactive; pass --yes to confirm)\n", prompt)
+		return false
+	}
+
+	fmt.Fprint(Console, prompt)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
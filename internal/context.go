@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultContext is the context whose state lives directly in ~/.run, as
+// before contexts existed.
+const DefaultContext = "default"
+
+// ContextEnv names the environment variable that selects a context for one
+// shell or job, over the one chosen with `run context use`.
+const ContextEnv = "RUN_CONTEXT"
+
+// ContextOverride is set from --context and takes precedence over
+// RUN_CONTEXT.
+var ContextOverride string
+
+var contextNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// currentContextPath returns the file that holds the context chosen with
+// `run context use`.
+func currentContextPath() (string, error) {
+	runDir, err := RunDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(runDir, "context"), nil
+}
+
+// CurrentContext returns the active context: --context, RUN_CONTEXT, the
+// one chosen with `run context use`, or DefaultContext.
+func CurrentContext() string {
+	if ContextOverride != "" {
+		return ContextOverride
+	}
+	if name := os.Getenv(ContextEnv); name != "" {
+		return name
+	}
+	if path, err := currentContextPath(); err == nil {
+		if data, err := os.ReadFile(path); err == nil && strings.TrimSpace(string(data)) != "" {
+			return strings.TrimSpace(string(data))
+		}
+	}
+	return DefaultContext
+}
+
+// contextDir returns the directory of a context's state.
+func contextDir(name string) (string, error) {
+	runDir, err := RunDir()
+	if err != nil {
+		return "", err
+	}
+	if name == DefaultContext {
+		return runDir, nil
+	}
+	return filepath.Join(runDir, "contexts", name), nil
+}
+
+// ContextDir returns the directory holding the state of the active context:
+// its config, state, baseline, secrets, local packages, env file and shims.
+// It is ~/.run for the default context and ~/.run/contexts/<name> for
+// others. Scripts, the registry, logs and caches are shared.
+func ContextDir() (string, error) {
+	return contextDir(CurrentContext())
+}
+
+// CheckContext returns an error unless a context exists.
+func CheckContext(name string) error {
+	if name == DefaultContext {
+		return nil
+	}
+	if !contextNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid context name '%s': use lowercase letters, digits, - and _", name)
+	}
+	dir, err := contextDir(name)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("context '%s' does not exist; create it with: run context create %s", name, name)
+	}
+	return nil
+}
+
+// ContextNames returns the default context and the created ones, sorted.
+func ContextNames() ([]string, error) {
+	runDir, err := RunDir()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{DefaultContext}
+	entries, err := os.ReadDir(filepath.Join(runDir, "contexts"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && contextNamePattern.MatchString(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// CreateContext creates an empty context. Its config starts from the
+// defaults, so hosts can give it its own update.prefix, profiles and users.
+func CreateContext(name string) (string, error) {
+	if name == DefaultContext {
+		return "", fmt.Errorf("the %s context always exists", DefaultContext)
+	}
+	if !contextNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid context name '%s': use lowercase letters, digits, - and _", name)
+	}
+	dir, err := contextDir(name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return "", fmt.Errorf("context '%s' already exists", name)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+	return dir, nil
+}
+
+// UseContext makes a context the active one for later commands.
+func UseContext(name string) error {
+	if err := CheckContext(name); err != nil {
+		return err
+	}
+	path, err := currentContextPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(name+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
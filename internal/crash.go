@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// crashLogLines is how much of the current package log a crash report
+// keeps.
+const crashLogLines = 50
+
+// issueBodyLimit keeps the pre-filled issue URL within what browsers and
+// GitHub accept; the full report stays on disk.
+const issueBodyLimit = 6000
+
+var (
+	currentPackageMu sync.Mutex
+	// currentPackage is the package whose script ran last, for crash reports.
+	currentPackage string
+)
+
+// setCurrentPackage records the package being worked on.
+func setCurrentPackage(packageName string) {
+	currentPackageMu.Lock()
+	defer currentPackageMu.Unlock()
+	currentPackage = packageName
+}
+
+// CrashesDir returns the directory of crash reports (~/.run/crashes).
+func CrashesDir() (string, error) {
+	runDir, err := RunDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(runDir, "crashes"), nil
+}
+
+// WriteCrashReport writes what is known about a panic to
+// ~/.run/crashes/<timestamp>: the value and stack, the command line and
+// host, and the end of the log of the package being worked on. It returns
+// the report's path and its content.
+func WriteCrashReport(recovered any, stack []byte) (string, string, error) {
+	var report bytes.Buffer
+	fmt.Fprintf(&report, "panic: %v\n\n", recovered)
+	fmt.Fprintf(&report, "command: %s\n", strings.Join(os.Args, " "))
+	fmt.Fprintf(&report, "time: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&report, "go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if release := osRelease("PRETTY_NAME"); release != "" {
+		fmt.Fprintf(&report, "release: %s\n", release)
+	}
+
+	currentPackageMu.Lock()
+	packageName := currentPackage
+	currentPackageMu.Unlock()
+	if packageName != "" {
+		fmt.Fprintf(&report, "package: %s\n", packageName)
+	}
+	fmt.Fprintf(&report, "\nstack:\n%s\n", stack)
+	if packageName != "" {
+		if logPath, err := PackageLogPath(packageName); err == nil {
+			if data, err := os.ReadFile(logPath); err == nil {
+				fmt.Fprintf(&report, "\nlast lines of %s:\n%s\n", logPath, lastLines(string(data), crashLogLines))
+			}
+		}
+	}
+
+	crashesDir, err := CrashesDir()
+	if err != nil {
+		return "", report.String(), err
+	}
+	if err := os.MkdirAll(crashesDir, 0700); err != nil {
+		return "", report.String(), fmt.Errorf("failed to create %s: %v", crashesDir, err)
+	}
+	path := filepath.Join(crashesDir, time.Now().Format("20060102-150405"))
+	if err := os.WriteFile(path, report.Bytes(), 0600); err != nil {
+		return "", report.String(), fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return path, report.String(), nil
+}
+
+// lastLines returns the last n lines of text.
+func lastLines(text string, n int) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CrashIssueURL returns the URL of a new GitHub issue pre-filled with a
+// crash report, cut short to fit in a URL.
+func CrashIssueURL(recovered any, report string) string {
+	if len(report) > issueBodyLimit {
+		report = report[:issueBodyLimit] + "\n... (cut short; the full report is in ~/.run/crashes)"
+	}
+	query := url.Values{}
+	query.Set("title", fmt.Sprintf("Crash: %v", recovered))
+	query.Set("body", "```\n"+report+"\n```")
+	return "https://" + ModulePath + "/issues/new?" + query.Encode()
+}
+
+// OpenURL opens a URL in the desktop's browser, when there is one.
+func OpenURL(address string) error {
+	if _, err := exec.LookPath("xdg-open"); err != nil {
+		return fmt.Errorf("no browser to open it with")
+	}
+	return exec.Command("xdg-open", address).Start()
+}
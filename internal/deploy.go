@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// GitDeployment describes an app deployed from a git repository with `run
+// deploy git`.
+type GitDeployment struct {
+	Repo string
+	// Dir is the checkout the app runs from.
+	Dir string
+	// Branch is checked out; empty means the repository's default branch.
+	Branch string
+	// User owns the checkout and runs git and the build. Empty means the
+	// app user of the cloud profile.
+	User string
+	// Build is a shell command run in Dir after updating, e.g. npm ci.
+	Build string
+	// Restart is the app to restart afterwards: pm2:<app> for a pm2 app,
+	// or the name of a systemd service.
+	Restart string
+}
+
+// isCurrentUser reports whether run is running as username.
+func isCurrentUser(username string) bool {
+	current, err := user.Current()
+	return err == nil && current.Username == username
+}
+
+// asUser returns a command run as username, through sudo or runuser unless
+// that is the current user, in dir.
+func asUser(username, dir, name string, args ...string) *system.Cmd {
+	cmd := system.Command(name, args...).WithDir(dir)
+	if !isCurrentUser(username) {
+		cmd.AsUser(username)
+	}
+	return cmd
+}
+
+// restartApp restarts a pm2 app (pm2:<app>) or a systemd service.
+func restartApp(username, restart string) error {
+	if app, isPM2 := strings.CutPrefix(restart, "pm2:"); isPM2 {
+		fmt.Fprintf(Console, "Restarting pm2 app %s...\n", app)
+		if err := asUser(username, "", "pm2", "restart", app).Stream(Console, os.Stderr); err != nil {
+			return fmt.Errorf("failed to restart pm2 app %s: %v", app, err)
+		}
+		return nil
+	}
+	service := strings.TrimPrefix(restart, "service:")
+	fmt.Fprintf(Console, "Restarting service %s...\n", service)
+	if err := system.Command("systemctl", "restart", service).WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to restart service %s: %v", service, err)
+	}
+	return nil
+}
+
+// DeployGit clones the repository into Dir, or fast-forwards an existing
+// checkout, then runs the build and restarts the app. It returns the
+// deployed commit.
+func DeployGit(deployment GitDeployment) (string, error) {
+	if err := RequireOnline(); err != nil {
+		return "", err
+	}
+	if deployment.Repo == "" || deployment.Dir == "" {
+		return "", fmt.Errorf("a repository and a target directory are required")
+	}
+	dir, err := filepath.Abs(deployment.Dir)
+	if err != nil {
+		return "", err
+	}
+	username := deployment.User
+	if username == "" {
+		if username, err = appUser(); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		fmt.Fprintf(Console, "Updating %s...\n", dir)
+		steps := [][]string{{"fetch", "--prune", "origin"}}
+		if deployment.Branch != "" {
+			steps = append(steps, []string{"checkout", deployment.Branch})
+		}
+		steps = append(steps, []string{"pull", "--ff-only"})
+		for _, step := range steps {
+			if err := asUser(username, dir, "git", step...).Stream(Console, os.Stderr); err != nil {
+				return "", fmt.Errorf("git %s failed in %s: %v", step[0], dir, err)
+			}
+		}
+	} else {
+		fmt.Fprintf(Console, "Cloning %s into %s...\n", deployment.Repo, dir)
+		if err := os.MkdirAll(dir, 0755); err != nil || !isCurrentUser(username) {
+			// The parent of app directories (/srv, /opt) is usually root's
+			if err := system.Command("install", "-d", "-o", username, dir).WithSudo().Run(); err != nil {
+				return "", fmt.Errorf("failed to create %s: %v", dir, err)
+			}
+		}
+		args := []string{"clone"}
+		if deployment.Branch != "" {
+			args = append(args, "--branch", deployment.Branch)
+		}
+		if err := asUser(username, dir, "git", append(args, deployment.Repo, ".")...).Stream(Console, os.Stderr); err != nil {
+			return "", fmt.Errorf("failed to clone %s: %v", deployment.Repo, err)
+		}
+	}
+
+	if deployment.Build != "" {
+		fmt.Fprintf(Console, "Building: %s\n", deployment.Build)
+		if err := asUser(username, dir, "bash", "-c", deployment.Build).Stream(Console, os.Stderr); err != nil {
+			return "", fmt.Errorf("build failed: %v", err)
+		}
+	}
+	if deployment.Restart != "" {
+		if err := restartApp(username, deployment.Restart); err != nil {
+			return "", err
+		}
+	}
+
+	commit, err := asUser(username, dir, "git", "rev-parse", "--short", "HEAD").CaptureOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the deployed commit: %v", err)
+	}
+	return strings.TrimSpace(string(commit)), nil
+}
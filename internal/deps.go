@@ -0,0 +1,217 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DependencyGraph holds the dependencies between registry packages.
+type DependencyGraph struct {
+	dependencies map[string][]string
+}
+
+// DependencyNode is a package with its resolved dependencies.
+type DependencyNode struct {
+	Name         string
+	Dependencies []*DependencyNode
+}
+
+// NewDependencyGraph builds the graph of the merged registry.
+func NewDependencyGraph() *DependencyGraph {
+	graph := &DependencyGraph{dependencies: make(map[string][]string)}
+	for _, name := range ListPackages() {
+		graph.dependencies[name] = PackageDependencies[name]
+	}
+	return graph
+}
+
+// Packages returns the packages in the graph, sorted.
+func (g *DependencyGraph) Packages() []string {
+	return mapKeys(g.dependencies)
+}
+
+// Dependencies returns the direct dependencies of a package.
+func (g *DependencyGraph) Dependencies(name string) []string {
+	return g.dependencies[name]
+}
+
+// UnknownDependencies returns "package -> dependency" for every dependency
+// that is not a registry package.
+func (g *DependencyGraph) UnknownDependencies() []string {
+	var unknown []string
+	for _, name := range g.Packages() {
+		for _, dependency := range g.dependencies[name] {
+			if _, exists := g.dependencies[dependency]; !exists {
+				unknown = append(unknown, name+" -> "+dependency)
+			}
+		}
+	}
+	return unknown
+}
+
+// FindCycle returns a dependency cycle as a path that starts and ends with
+// the same package, or nil when the graph is acyclic.
+func (g *DependencyGraph) FindCycle() []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+	var path []string
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		state[name] = visiting
+		path = append(path, name)
+		for _, dependency := range g.dependencies[name] {
+			switch state[dependency] {
+			case visiting:
+				for i, step := range path {
+					if step == dependency {
+						return append(append([]string(nil), path[i:]...), dependency)
+					}
+				}
+			case unvisited:
+				if cycle := visit(dependency); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		return nil
+	}
+
+	for _, name := range g.Packages() {
+		if state[name] == unvisited {
+			if cycle := visit(name); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// ResolveOrder returns the packages together with all their dependencies,
+// ordered so that every package comes after the packages it depends on.
+func (g *DependencyGraph) ResolveOrder(packages []string) ([]string, error) {
+	if cycle := g.FindCycle(); cycle != nil {
+		return nil, fmt.Errorf("circular dependency: %s", strings.Join(cycle, " -> "))
+	}
+
+	seen := make(map[string]bool)
+	var order []string
+	var visit func(name, parent string) error
+	visit = func(name, parent string) error {
+		if seen[name] {
+			return nil
+		}
+		if _, exists := g.dependencies[name]; !exists {
+			if parent == "" {
+				return fmt.Errorf("unknown package '%s'", name)
+			}
+			return fmt.Errorf("package '%s' depends on unknown package '%s'", parent, name)
+		}
+		seen[name] = true
+		for _, dependency := range g.dependencies[name] {
+			if err := visit(dependency, name); err != nil {
+				return err
+			}
+		}
+		order = append(order, name)
+		return nil
+	}
+
+	for _, name := range packages {
+		if err := visit(name, ""); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// GetDependencyTree returns the resolved dependency tree of a package.
+func (g *DependencyGraph) GetDependencyTree(name string) (*DependencyNode, error) {
+	if _, err := g.ResolveOrder([]string{name}); err != nil {
+		return nil, err
+	}
+	return g.buildTree(name), nil
+}
+
+func (g *DependencyGraph) buildTree(name string) *DependencyNode {
+	node := &DependencyNode{Name: name}
+	for _, dependency := range g.dependencies[name] {
+		node.Dependencies = append(node.Dependencies, g.buildTree(dependency))
+	}
+	return node
+}
+
+// Render draws the tree with box-drawing characters, one package per line.
+func (n *DependencyNode) Render() string {
+	var b strings.Builder
+	b.WriteString(n.Name + "\n")
+	n.renderChildren(&b, "")
+	return b.String()
+}
+
+func (n *DependencyNode) renderChildren(b *strings.Builder, prefix string) {
+	for i, child := range n.Dependencies {
+		branch, indent := "├── ", "│   "
+		if i == len(n.Dependencies)-1 {
+			branch, indent = "└── ", "    "
+		}
+		b.WriteString(prefix + branch + child.Name + "\n")
+		child.renderChildren(b, prefix+indent)
+	}
+}
+
+// DependencyGraphFormats lists the supported `run deps --graph` values.
+var DependencyGraphFormats = []string{"dot", "mermaid"}
+
+// RenderGraph draws the packages and everything they depend on as a Graphviz
+// DOT or Mermaid flowchart, with edges pointing at dependencies.
+func (g *DependencyGraph) RenderGraph(format string, packages []string) (string, error) {
+	order, err := g.ResolveOrder(packages)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	switch format {
+	case "dot":
+		b.WriteString("digraph dependencies {\n")
+		b.WriteString("  rankdir=LR;\n")
+		for _, name := range order {
+			fmt.Fprintf(&b, "  %q;\n", name)
+			for _, dependency := range g.dependencies[name] {
+				fmt.Fprintf(&b, "  %q -> %q;\n", name, dependency)
+			}
+		}
+		b.WriteString("}\n")
+	case "mermaid":
+		b.WriteString("graph LR\n")
+		for _, name := range order {
+			if len(g.dependencies[name]) == 0 {
+				fmt.Fprintf(&b, "  %s\n", mermaidNode(name))
+			}
+			for _, dependency := range g.dependencies[name] {
+				fmt.Fprintf(&b, "  %s --> %s\n", mermaidNode(name), mermaidNode(dependency))
+			}
+		}
+	default:
+		return "", fmt.Errorf("unknown graph format '%s': use %s", format, strings.Join(DependencyGraphFormats, " or "))
+	}
+	return b.String(), nil
+}
+
+// mermaidNode returns a Mermaid node with an ID safe for any package name.
+func mermaidNode(name string) string {
+	id := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+	return fmt.Sprintf("%s[%q]", id, name)
+}
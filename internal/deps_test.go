@@ -0,0 +1,159 @@
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testGraph builds a dependency graph from package -> dependencies.
+func testGraph(dependencies map[string][]string) *DependencyGraph {
+	return &DependencyGraph{dependencies: dependencies}
+}
+
+func TestFindCycle(t *testing.T) {
+	tests := []struct {
+		name         string
+		dependencies map[string][]string
+		want         []string
+	}{
+		{
+			name:         "empty",
+			dependencies: map[string][]string{},
+		},
+		{
+			name: "acyclic",
+			dependencies: map[string][]string{
+				"pm2":        {"node"},
+				"node":       {"essentials"},
+				"essentials": nil,
+			},
+		},
+		{
+			name: "diamond",
+			dependencies: map[string][]string{
+				"app":        {"node", "python"},
+				"node":       {"essentials"},
+				"python":     {"essentials"},
+				"essentials": nil,
+			},
+		},
+		{
+			name:         "self",
+			dependencies: map[string][]string{"a": {"a"}},
+			want:         []string{"a", "a"},
+		},
+		{
+			name: "two packages",
+			dependencies: map[string][]string{
+				"a": {"b"},
+				"b": {"a"},
+			},
+			want: []string{"a", "b", "a"},
+		},
+		{
+			name: "behind an acyclic package",
+			dependencies: map[string][]string{
+				"a": {"b"},
+				"b": {"c"},
+				"c": {"d"},
+				"d": {"b"},
+			},
+			want: []string{"b", "c", "d", "b"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := testGraph(test.dependencies).FindCycle(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("FindCycle() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestResolveOrder(t *testing.T) {
+	graph := testGraph(map[string][]string{
+		"essentials": nil,
+		"node":       {"essentials"},
+		"pm2":        {"node"},
+		"python":     {"essentials"},
+		"app":        {"pm2", "python"},
+		"nginx":      nil,
+		"broken":     {"missing"},
+	})
+	tests := []struct {
+		name     string
+		packages []string
+		want     []string
+		err      string
+	}{
+		{name: "no dependencies", packages: []string{"nginx"}, want: []string{"nginx"}},
+		{name: "chain", packages: []string{"pm2"}, want: []string{"essentials", "node", "pm2"}},
+		{name: "shared dependency once", packages: []string{"app"}, want: []string{"essentials", "node", "pm2", "python", "app"}},
+		{name: "requested order kept", packages: []string{"nginx", "node"}, want: []string{"nginx", "essentials", "node"}},
+		{name: "dependency also requested", packages: []string{"node", "essentials"}, want: []string{"essentials", "node"}},
+		{name: "unknown package", packages: []string{"nope"}, err: "unknown package 'nope'"},
+		{name: "unknown dependency", packages: []string{"broken"}, err: "package 'broken' depends on unknown package 'missing'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := graph.ResolveOrder(test.packages)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("ResolveOrder(%v) error = %v, want %q", test.packages, err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveOrder(%v) error = %v", test.packages, err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ResolveOrder(%v) = %v, want %v", test.packages, got, test.want)
+			}
+		})
+	}
+}
+
+func TestResolveOrderCycle(t *testing.T) {
+	graph := testGraph(map[string][]string{
+		"nginx": nil,
+		"a":     {"b"},
+		"b":     {"a"},
+	})
+	// A cycle anywhere in the graph is refused, even for unrelated packages
+	_, err := graph.ResolveOrder([]string{"nginx"})
+	if err == nil || !strings.Contains(err.Error(), "circular dependency: a -> b -> a") {
+		t.Fatalf("ResolveOrder() error = %v, want the circular dependency", err)
+	}
+}
+
+func TestGetDependencyTree(t *testing.T) {
+	graph := testGraph(map[string][]string{
+		"essentials": nil,
+		"node":       {"essentials"},
+		"python":     {"essentials"},
+		"app":        {"node", "python"},
+		"broken":     {"missing"},
+	})
+
+	tree, err := graph.GetDependencyTree("app")
+	if err != nil {
+		t.Fatalf("GetDependencyTree() error = %v", err)
+	}
+	want := &DependencyNode{Name: "app", Dependencies: []*DependencyNode{
+		{Name: "node", Dependencies: []*DependencyNode{{Name: "essentials"}}},
+		{Name: "python", Dependencies: []*DependencyNode{{Name: "essentials"}}},
+	}}
+	if !reflect.DeepEqual(tree, want) {
+		t.Errorf("GetDependencyTree() = %s, want %s", tree.Render(), want.Render())
+	}
+	if got, want := tree.Render(), "app\n├── node\n│   └── essentials\n└── python\n    └── essentials\n"; got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+
+	for _, name := range []string{"broken", "nope"} {
+		if _, err := graph.GetDependencyTree(name); err == nil {
+			t.Errorf("GetDependencyTree(%q) succeeded, want an error", name)
+		}
+	}
+}
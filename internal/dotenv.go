@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// UpdateDotEnv sets keys in a .env file, replacing existing assignments and
+// appending new ones. Other lines are kept as they are. The file is created
+// with 0600 permissions since it usually holds credentials.
+func UpdateDotEnv(path string, values map[string]string) error {
+	var lines []string
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	}
+
+	written := make(map[string]bool)
+	for i, line := range lines {
+		key, _, found := strings.Cut(strings.TrimPrefix(strings.TrimSpace(line), "export "), "=")
+		if !found {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if value, exists := values[key]; exists {
+			lines[i] = formatDotEnvLine(key, value)
+			written[key] = true
+		}
+	}
+
+	var keys []string
+	for key := range values {
+		if !written[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		lines = append(lines, formatDotEnvLine(key, values[key]))
+	}
+
+	content := strings.Join(lines, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+// formatDotEnvLine renders KEY=value, quoting values that contain characters
+// with special meaning to dotenv parsers or shells. Line breaks are escaped
+// as \n and \r, which dotenv parsers expand in double quotes, so a value
+// cannot add lines.
+func formatDotEnvLine(key, value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\n\r#\"'$`\\") {
+		value = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`", "\n", `\n`, "\r", `\r`).Replace(value) + `"`
+	}
+	return key + "=" + value
+}
@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func FuzzUpdateDotEnv(f *testing.F) {
+	f.Add("APP=1\n# comment\nDB_PASSWORD=old\n", "s3cret")
+	f.Add("export DB_PASSWORD = old\n", `has "quotes" and $vars`)
+	f.Add("", "two\nlines")
+	f.Add("DB_PASSWORD\n", "")
+	f.Fuzz(func(t *testing.T, existing, value string) {
+		path := filepath.Join(t.TempDir(), ".env")
+		if err := os.WriteFile(path, []byte(existing), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := UpdateDotEnv(path, map[string]string{"DB_PASSWORD": value}); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The value takes one line, and the lines of other keys are kept
+		want := formatDotEnvLine("DB_PASSWORD", value)
+		if strings.ContainsAny(want, "\n\r") {
+			t.Fatalf("the value %q spans several lines: %q", value, want)
+		}
+		assigned := false
+		kept := 0
+		for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+			key, _, found := strings.Cut(strings.TrimPrefix(strings.TrimSpace(line), "export "), "=")
+			if found && strings.TrimSpace(key) == "DB_PASSWORD" {
+				if line != want {
+					t.Fatalf("DB_PASSWORD line is %q, want %q", line, want)
+				}
+				assigned = true
+				continue
+			}
+			kept++
+		}
+		if !assigned {
+			t.Fatalf("DB_PASSWORD not written: %q", data)
+		}
+		other := 0
+		if existing != "" {
+			for _, line := range strings.Split(strings.TrimRight(existing, "\n"), "\n") {
+				key, _, found := strings.Cut(strings.TrimPrefix(strings.TrimSpace(line), "export "), "=")
+				if !found || strings.TrimSpace(key) != "DB_PASSWORD" {
+					other++
+				}
+			}
+		}
+		if kept != other {
+			t.Fatalf("%d other lines after the update, want %d: %q", kept, other, data)
+		}
+	})
+}
@@ -0,0 +1,281 @@
+// Package downloader fetches package artifacts into a content-addressed
+// cache: interrupted downloads resume with Range requests, checksums are
+// verified, mirrors are tried before the origin and the proxy comes from
+// HTTP_PROXY/HTTPS_PROXY.
+package downloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sha256Pattern matches a hex SHA-256 checksum.
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Request is an artifact to download.
+type Request struct {
+	URL string
+	// SHA256 is the expected hex checksum. With it a cached copy is used
+	// without downloading, and a mismatch is an error.
+	SHA256 string
+	// Progress is called as bytes arrive, with the total when known and -1
+	// otherwise.
+	Progress func(done, total int64)
+}
+
+// Downloader downloads artifacts into a cache directory.
+type Downloader struct {
+	// CacheDir holds sha256/<checksum> for complete artifacts and
+	// partial/ for interrupted downloads.
+	CacheDir string
+	// Mirrors map URL prefixes to mirror prefixes tried before the origin:
+	// "https://github.com/" to "https://artifacts.internal/github/".
+	Mirrors map[string]string
+	// RateLimit caps the download speed in KB/s; 0 means unlimited.
+	RateLimit int
+	// Client is the HTTP client; one honouring the proxy environment when
+	// nil.
+	Client *http.Client
+	// Retries is how many times a failed download of a URL resumes; 3 when
+	// zero.
+	Retries int
+}
+
+// New returns a downloader caching into cacheDir.
+func New(cacheDir string) *Downloader {
+	return &Downloader{CacheDir: cacheDir}
+}
+
+// cachePath returns where an artifact with a checksum is cached.
+func (d *Downloader) cachePath(checksum string) string {
+	return filepath.Join(d.CacheDir, "sha256", checksum)
+}
+
+// partialPath returns where an interrupted download of a URL is kept.
+func (d *Downloader) partialPath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(d.CacheDir, "partial", hex.EncodeToString(sum[:]))
+}
+
+// urls returns the URLs to try for an artifact: its mirrors, then the
+// origin.
+func (d *Downloader) urls(url string) []string {
+	prefixes := make([]string, 0, len(d.Mirrors))
+	for prefix := range d.Mirrors {
+		prefixes = append(prefixes, prefix)
+	}
+	// The most specific prefix first
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+	var urls []string
+	for _, prefix := range prefixes {
+		if rest, found := strings.CutPrefix(url, prefix); found {
+			urls = append(urls, d.Mirrors[prefix]+rest)
+		}
+	}
+	return append(urls, url)
+}
+
+// Fetch returns the cached path of an artifact, downloading it first when
+// it is not cached. Artifacts are cached by their checksum, so the same
+// file fetched from different URLs is stored once.
+func (d *Downloader) Fetch(req Request) (string, error) {
+	checksum := strings.ToLower(req.SHA256)
+	if checksum != "" && !sha256Pattern.MatchString(checksum) {
+		return "", fmt.Errorf("invalid SHA-256 checksum '%s'", req.SHA256)
+	}
+	if checksum != "" {
+		if _, err := os.Stat(d.cachePath(checksum)); err == nil {
+			// The modification time tells garbage collection when it was
+			// last used
+			now := time.Now()
+			os.Chtimes(d.cachePath(checksum), now, now)
+			return d.cachePath(checksum), nil
+		}
+	}
+	for _, dir := range []string{"sha256", "partial"} {
+		if err := os.MkdirAll(filepath.Join(d.CacheDir, dir), 0755); err != nil {
+			return "", fmt.Errorf("failed to create %s: %v", filepath.Join(d.CacheDir, dir), err)
+		}
+	}
+
+	var errs []string
+	for _, url := range d.urls(req.URL) {
+		path, err := d.fetchURL(url, checksum, req.Progress)
+		if err == nil {
+			return path, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return "", fmt.Errorf("failed to download %s: %s", req.URL, strings.Join(errs, "; "))
+}
+
+// fetchURL downloads one URL into the cache, resuming its partial download
+// across retries.
+func (d *Downloader) fetchURL(url, checksum string, progress func(done, total int64)) (string, error) {
+	retries := d.Retries
+	if retries == 0 {
+		retries = 3
+	}
+	partial := d.partialPath(url)
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		var retry bool
+		if retry, err = d.download(url, partial, progress); err == nil || !retry {
+			break
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", url, err)
+	}
+
+	actual, err := fileSHA256(partial)
+	if err != nil {
+		return "", err
+	}
+	if checksum != "" && actual != checksum {
+		os.Remove(partial)
+		return "", fmt.Errorf("%s: checksum mismatch: expected %s, got %s", url, checksum, actual)
+	}
+	path := d.cachePath(actual)
+	if err := os.Rename(partial, path); err != nil {
+		return "", fmt.Errorf("failed to cache %s: %v", url, err)
+	}
+	return path, nil
+}
+
+// download appends the rest of url to the partial file, asking for a Range
+// when part of it is there. It reports whether a failure is worth retrying.
+func (d *Downloader) download(url, partial string, progress func(done, total int64)) (bool, error) {
+	var offset int64
+	if info, err := os.Stat(partial); err == nil {
+		offset = info.Size()
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	client := d.Client
+	if client == nil {
+		client = &http.Client{Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			ResponseHeaderTimeout: 30 * time.Second,
+		}}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// "bytes */<size>": the partial file is complete, or stale and
+		// started over
+		if resp.Header.Get("Content-Range") == fmt.Sprintf("bytes */%d", offset) {
+			return false, nil
+		}
+		os.Remove(partial)
+		return true, fmt.Errorf("stale partial download")
+	case resp.StatusCode == http.StatusOK:
+		// The server ignored the Range
+		flags |= os.O_TRUNC
+		offset = 0
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("server returned %s", resp.Status)
+	default:
+		return false, fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	file, err := os.OpenFile(partial, flags, 0644)
+	if err != nil {
+		return false, err
+	}
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	var body io.Reader = resp.Body
+	if d.RateLimit > 0 {
+		body = &rateLimitedReader{reader: body, bytesPerSecond: int64(d.RateLimit) * 1024, start: time.Now()}
+	}
+	if progress != nil {
+		body = &progressReader{reader: body, done: offset, total: total, progress: progress}
+	}
+	_, copyErr := io.Copy(file, body)
+	if err := file.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+	if copyErr != nil {
+		return true, copyErr
+	}
+	return false, nil
+}
+
+// fileSHA256 returns the hex SHA-256 checksum of a file.
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// rateLimitedReader sleeps as needed to keep the average rate under a limit.
+type rateLimitedReader struct {
+	reader         io.Reader
+	bytesPerSecond int64
+	start          time.Time
+	read           int64
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	// Small reads keep the rate smooth
+	if int64(len(p)) > r.bytesPerSecond/4+1 {
+		p = p[:r.bytesPerSecond/4+1]
+	}
+	n, err := r.reader.Read(p)
+	r.read += int64(n)
+	expected := time.Duration(float64(r.read) / float64(r.bytesPerSecond) * float64(time.Second))
+	if elapsed := time.Since(r.start); expected > elapsed {
+		time.Sleep(expected - elapsed)
+	}
+	return n, err
+}
+
+// progressReader reports the bytes read so far.
+type progressReader struct {
+	reader   io.Reader
+	done     int64
+	total    int64
+	progress func(done, total int64)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.done += int64(n)
+	r.progress(r.done, r.total)
+	return n, err
+}
@@ -0,0 +1,174 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/amoga-io/run/internal/downloader"
+	"github.com/amoga-io/run/internal/system"
+)
+
+// aptDownloadsConf is the apt drop-in holding the download rate limit.
+const aptDownloadsConf = "/etc/apt/apt.conf.d/90run-downloads"
+
+// aptMirrorList lists the mirrors apt tries for the Ubuntu archive, through
+// its mirror+file method.
+const aptMirrorList = "/etc/apt/run-mirrors.txt"
+
+// ubuntuArchive is the default Ubuntu archive, kept as the last mirror.
+const ubuntuArchive = "http://archive.ubuntu.com/ubuntu"
+
+// aptSourceFiles are the apt sources that point at the Ubuntu archive, in
+// the classic and the deb822 format.
+var aptSourceFiles = []string{"/etc/apt/sources.list", "/etc/apt/sources.list.d/ubuntu.sources"}
+
+// ubuntuArchivePattern matches the Ubuntu archive and its country mirrors.
+var ubuntuArchivePattern = regexp.MustCompile(`https?://([a-z]{2}\.)?archive\.ubuntu\.com/ubuntu/?`)
+
+// validateDownloads checks the downloads section of the config.
+func validateDownloads(config DownloadsConfig) error {
+	if config.RateLimit < 0 {
+		return fmt.Errorf("downloads.rate_limit must not be negative")
+	}
+	for _, mirror := range config.Mirrors {
+		if !strings.HasPrefix(mirror, "http://") && !strings.HasPrefix(mirror, "https://") {
+			return fmt.Errorf("downloads.mirrors: %s is not an http(s) URL", mirror)
+		}
+	}
+	for prefix, mirror := range config.ArtifactMirrors {
+		if !strings.HasPrefix(mirror, "http://") && !strings.HasPrefix(mirror, "https://") {
+			return fmt.Errorf("downloads.artifact_mirrors: %s for %s is not an http(s) URL", mirror, prefix)
+		}
+	}
+	return nil
+}
+
+// ArtifactDownloader returns the downloader package artifacts are fetched
+// with, caching in ~/.run/cache/artifacts and honouring the rate limit and
+// artifact mirrors of the downloads config.
+func ArtifactDownloader() (*downloader.Downloader, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := validateDownloads(config.Downloads); err != nil {
+		return nil, err
+	}
+	cacheDir, err := CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	d := downloader.New(filepath.Join(cacheDir, "artifacts"))
+	d.Mirrors = config.Downloads.ArtifactMirrors
+	d.RateLimit = config.Downloads.RateLimit
+	return d, nil
+}
+
+// ApplyDownloadSettings brings apt in line with the downloads config: it
+// writes or removes the rate limit drop-in and points the Ubuntu archive
+// sources at the configured mirrors, or back at archive.ubuntu.com. Files
+// already in the wanted state are left alone, so this needs sudo only after
+// the config changes.
+func ApplyDownloadSettings() error {
+	config, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	downloads := config.Downloads
+	if err := validateDownloads(downloads); err != nil {
+		return err
+	}
+
+	var aptConf string
+	if downloads.RateLimit > 0 {
+		limit := strconv.Itoa(downloads.RateLimit)
+		aptConf = "// Written by run from downloads.rate_limit in ~/.run/config.yaml\n" +
+			"Acquire::http::Dl-Limit \"" + limit + "\";\n" +
+			"Acquire::https::Dl-Limit \"" + limit + "\";\n"
+	}
+	if err := syncRootFile(aptDownloadsConf, aptConf); err != nil {
+		return err
+	}
+
+	var mirrorList string
+	if len(downloads.Mirrors) > 0 {
+		mirrorList = strings.Join(append(append([]string{}, downloads.Mirrors...), ubuntuArchive), "\n") + "\n"
+	}
+	if err := syncRootFile(aptMirrorList, mirrorList); err != nil {
+		return err
+	}
+
+	for _, path := range aptSourceFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var updated string
+		if mirrorList != "" {
+			updated = ubuntuArchivePattern.ReplaceAllString(string(data), "mirror+file:"+aptMirrorList)
+		} else {
+			updated = strings.ReplaceAll(string(data), "mirror+file:"+aptMirrorList, ubuntuArchive)
+		}
+		if err := syncRootFile(path, updated); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncRootFile writes content to a root-owned file unless it already holds
+// it. Empty content removes the file.
+func syncRootFile(path, content string) error {
+	current, err := os.ReadFile(path)
+	exists := err == nil
+	if content == "" {
+		if !exists {
+			return nil
+		}
+		return system.RemoveFileAsRoot(path)
+	}
+	if exists && string(current) == content {
+		return nil
+	}
+	return system.WriteFileAsRoot(path, []byte(content), 0644)
+}
+
+// DownloadScriptEnv returns the environment that makes curl and wget in
+// package scripts honour downloads.rate_limit, through a curlrc and wgetrc
+// kept in ~/.run/downloads.
+func DownloadScriptEnv() ([]string, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := validateDownloads(config.Downloads); err != nil {
+		return nil, err
+	}
+	if config.Downloads.RateLimit == 0 {
+		return nil, nil
+	}
+
+	runDir, err := RunDir()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(runDir, "downloads")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+
+	limit := strconv.Itoa(config.Downloads.RateLimit) + "k"
+	curlrc := filepath.Join(dir, ".curlrc")
+	if err := os.WriteFile(curlrc, []byte("limit-rate = "+limit+"\n"), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %v", curlrc, err)
+	}
+	wgetrc := filepath.Join(dir, "wgetrc")
+	if err := os.WriteFile(wgetrc, []byte("limit_rate = "+limit+"\n"), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %v", wgetrc, err)
+	}
+	return []string{"CURL_HOME=" + dir, "WGETRC=" + wgetrc}, nil
+}
@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WatchConfig configures `run watch`.
+type WatchConfig struct {
+	// Webhook receives a JSON POST whenever the drift found changes.
+	Webhook string `yaml:"webhook"`
+}
+
+// Drift is a difference between the host and its baseline.
+type Drift struct {
+	Subject  string `json:"subject"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+func (d Drift) String() string {
+	return fmt.Sprintf("%s: expected %s, found %s", d.Subject, d.Expected, d.Actual)
+}
+
+// BaselinePath returns where the snapshot of the last applied profile is
+// kept (~/.run/baseline.json).
+func BaselinePath() (string, error) {
+	runDir, err := ContextDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(runDir, "baseline.json"), nil
+}
+
+// SaveBaseline records the current state of packages as the baseline
+// `run watch` compares the host against.
+func SaveBaseline(packages []string) error {
+	path, err := BaselinePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(SnapshotPackages(packages), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode baseline: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write baseline: %v", err)
+	}
+	return nil
+}
+
+// DetectDrift compares the packages of the baseline with this host, and
+// checks that the services created with `run service create` are running.
+func DetectDrift(baseline *Snapshot) ([]Drift, error) {
+	current := SnapshotPackages(mapKeys(baseline.Packages))
+
+	var drift []Drift
+	for _, difference := range DiffSnapshots(baseline, current) {
+		drift = append(drift, Drift{
+			Subject:  difference.Package,
+			Expected: difference.Left.Describe(),
+			Actual:   difference.Right.Describe(),
+		})
+	}
+
+	state, err := LoadState()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range mapKeys(state.Services) {
+		if !ServiceActive(name) {
+			drift = append(drift, Drift{Subject: "service " + name, Expected: "running", Actual: "stopped"})
+		}
+	}
+	return drift, nil
+}
+
+// DriftLogPath returns the log drift reports are appended to.
+func DriftLogPath() (string, error) {
+	logsDir, err := LogsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(logsDir, "drift.log"), nil
+}
+
+// LogDrift appends a timestamped drift report to the drift log.
+func LogDrift(drift []Drift) error {
+	path, err := DriftLogPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	now := time.Now().Format(time.RFC3339)
+	if len(drift) == 0 {
+		_, err = fmt.Fprintf(file, "%s no drift\n", now)
+		return err
+	}
+	for _, d := range drift {
+		if _, err := fmt.Fprintf(file, "%s %s\n", now, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NotifyDrift posts a drift report to a webhook as JSON:
+//
+//	{"host": "web-1", "drift": [{"subject": "nginx", "expected": "1.24.0", "actual": "not installed"}]}
+//
+// An empty drift list reports that the host is back on its baseline.
+func NotifyDrift(url string, drift []Drift) error {
+	host, _ := os.Hostname()
+	if drift == nil {
+		drift = []Drift{}
+	}
+	body, err := json.Marshal(map[string]interface{}{"host": host, "drift": drift})
+	if err != nil {
+		return fmt.Errorf("failed to encode drift report: %v", err)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to notify %s: %v", url, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to notify %s: %s", url, resp.Status)
+	}
+	return nil
+}
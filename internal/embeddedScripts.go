@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/amoga-io/run/scripts"
+)
+
+// embeddedScriptsHash identifies the scripts built into this binary, so a
+// new binary never runs the scripts extracted by an older one.
+func embeddedScriptsHash() (string, error) {
+	hash := sha256.New()
+	err := fs.WalkDir(scripts.FS, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := scripts.FS.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s %d\n", path, len(data))
+		hash.Write(data)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:12], nil
+}
+
+// EmbeddedScriptsDir extracts the scripts built into the binary to
+// ~/.run/cache/scripts/<hash> on first use and returns that directory. They
+// are used when there is neither a checkout in ~/.run nor a packaged install,
+// as with a binary downloaded on its own.
+func EmbeddedScriptsDir() (string, error) {
+	runDir, err := RunDir()
+	if err != nil {
+		return "", err
+	}
+	hash, err := embeddedScriptsHash()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the embedded scripts: %v", err)
+	}
+	dir := filepath.Join(runDir, "cache", "scripts", hash)
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+
+	// Extracted next to the final directory and renamed, so an interrupted
+	// extraction is never used
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %v", filepath.Dir(dir), err)
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), hash+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to extract the embedded scripts: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+	entries, err := scripts.FS.ReadDir(".")
+	if err != nil {
+		return "", fmt.Errorf("failed to read the embedded scripts: %v", err)
+	}
+	for _, entry := range entries {
+		data, err := scripts.FS.ReadFile(entry.Name())
+		if err != nil {
+			return "", fmt.Errorf("failed to read the embedded %s: %v", entry.Name(), err)
+		}
+		if err := os.WriteFile(filepath.Join(tmp, entry.Name()), data, 0755); err != nil {
+			return "", fmt.Errorf("failed to extract %s: %v", entry.Name(), err)
+		}
+	}
+	if err := os.Chmod(tmp, 0755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		// Another run extracted the same scripts first
+		if _, statErr := os.Stat(dir); statErr == nil {
+			return dir, nil
+		}
+		return "", fmt.Errorf("failed to extract the embedded scripts: %v", err)
+	}
+	return dir, nil
+}
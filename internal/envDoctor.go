@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// javaVersionPattern matches the version in the first line of `java -version`,
+// e.g. `openjdk version "17.0.9"` or `java version "1.8.0_392"`.
+var javaVersionPattern = regexp.MustCompile(`version "(\d+)(?:\.(\d+))?`)
+
+// javaMajorVersion runs `<binary> -version` and returns the major version.
+func javaMajorVersion(binary string) (int, error) {
+	output, err := exec.Command(binary, "-version").CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("failed to run %s -version: %v", binary, err)
+	}
+	match := javaVersionPattern.FindStringSubmatch(string(output))
+	if match == nil {
+		return 0, fmt.Errorf("unrecognized output of %s -version", binary)
+	}
+	major, _ := strconv.Atoi(match[1])
+	if major == 1 && match[2] != "" {
+		major, _ = strconv.Atoi(match[2])
+	}
+	return major, nil
+}
+
+// DiagnoseEnv verifies that the managed env file is loaded by the shell and
+// that the toolchain variables in it agree with the installed tools.
+func DiagnoseEnv() []CheckResult {
+	var results []CheckResult
+
+	envPath, err := ManagedEnvPath()
+	if err != nil {
+		return []CheckResult{{Name: "env file", OK: false, Message: err.Error()}}
+	}
+	if _, err := os.Stat(envPath); err != nil {
+		results = append(results, CheckResult{Name: "env file", OK: false, Message: envPath + " does not exist"})
+	} else {
+		results = append(results, CheckResult{Name: "env file", OK: true, Message: envPath})
+	}
+
+	results = append(results, checkProfileSourcesEnv())
+
+	env, err := LoadManagedEnv()
+	if err != nil {
+		return append(results, CheckResult{Name: "env file", OK: false, Message: err.Error()})
+	}
+
+	for _, dir := range env.Path {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			results = append(results, CheckResult{Name: "PATH", OK: false, Message: dir + " does not exist"})
+		}
+	}
+
+	if _, err := exec.LookPath("java"); err == nil || env.Vars["JAVA_HOME"] != "" {
+		results = append(results, checkJavaHome(env)...)
+	}
+	return results
+}
+
+// checkProfileSourcesEnv reports whether ~/.profile loads the managed env file.
+func checkProfileSourcesEnv() CheckResult {
+	result := CheckResult{Name: "~/.profile"}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+	data, _ := os.ReadFile(filepath.Join(home, ".profile"))
+	if !strings.Contains(string(data), profileSourceLine) {
+		result.Message = "does not source ~/.run/env"
+		return result
+	}
+	result.OK = true
+	result.Message = "sources ~/.run/env"
+	return result
+}
+
+// checkJavaHome compares the managed JAVA_HOME with the active JDK.
+func checkJavaHome(env *ManagedEnv) []CheckResult {
+	javaHome := env.Vars["JAVA_HOME"]
+	if javaHome == "" {
+		return []CheckResult{{Name: "JAVA_HOME", OK: false, Message: "not set in the managed env file"}}
+	}
+
+	homeJava := filepath.Join(javaHome, "bin", "java")
+	if _, err := os.Stat(homeJava); err != nil {
+		return []CheckResult{{Name: "JAVA_HOME", OK: false, Message: homeJava + " does not exist"}}
+	}
+	results := []CheckResult{{Name: "JAVA_HOME", OK: true, Message: javaHome}}
+
+	if active, err := ActiveJavaHome(); err == nil && active != javaHome {
+		results = append(results, CheckResult{Name: "active JDK", OK: false, Message: fmt.Sprintf("%s is active but JAVA_HOME points to %s", active, javaHome)})
+	}
+
+	homeMajor, homeErr := javaMajorVersion(homeJava)
+	pathMajor, pathErr := javaMajorVersion("java")
+	switch {
+	case homeErr != nil:
+		results = append(results, CheckResult{Name: "java -version", OK: false, Message: homeErr.Error()})
+	case pathErr != nil:
+		results = append(results, CheckResult{Name: "java -version", OK: false, Message: pathErr.Error()})
+	case homeMajor != pathMajor:
+		results = append(results, CheckResult{Name: "java -version", OK: false, Message: fmt.Sprintf("java in PATH is %d but JAVA_HOME is %d", pathMajor, homeMajor)})
+	default:
+		results = append(results, CheckResult{Name: "java -version", OK: true, Message: strconv.Itoa(pathMajor)})
+	}
+
+	if shellHome := os.Getenv("JAVA_HOME"); shellHome != javaHome {
+		results = append(results, CheckResult{Name: "shell", OK: false, Message: "JAVA_HOME is outdated in this shell; run: . ~/.run/env"})
+	}
+	return results
+}
@@ -0,0 +1,184 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// managedEnvHeader starts the managed env file so users know not to edit it.
+const managedEnvHeader = "# Managed by run - changes are overwritten. Sourced from ~/.profile.\n"
+
+// profileSourceLine loads the managed env file from the user's profile.
+const profileSourceLine = `[ -f "$HOME/.run/env" ] && . "$HOME/.run/env"`
+
+// ManagedEnv is the shell environment run maintains in ~/.run/env, such as
+// JAVA_HOME and extra PATH entries.
+type ManagedEnv struct {
+	Vars map[string]string
+	// Path entries are prepended to PATH in order.
+	Path []string
+}
+
+// ManagedEnvPath returns the location of the managed env file.
+func ManagedEnvPath() (string, error) {
+	runDir, err := ContextDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(runDir, "env"), nil
+}
+
+// LoadManagedEnv reads the managed env file, returning an empty environment
+// when it does not exist.
+func LoadManagedEnv() (*ManagedEnv, error) {
+	envPath, err := ManagedEnvPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(envPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %v", envPath, err)
+	}
+	return parseManagedEnv(data), nil
+}
+
+// parseManagedEnv reads the content of a managed env file written by Render.
+func parseManagedEnv(data []byte) *ManagedEnv {
+	env := &ManagedEnv{Vars: make(map[string]string)}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, found := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !found || strings.HasPrefix(line, "#") {
+			continue
+		}
+		value = strings.TrimSuffix(strings.TrimPrefix(value, `"`), `"`)
+		if key == "PATH" {
+			value = strings.TrimSuffix(value, ":$PATH")
+			for _, entry := range strings.Split(value, ":") {
+				if entry != "" {
+					env.Path = append(env.Path, unescapeShellValue(entry))
+				}
+			}
+			continue
+		}
+		env.Vars[key] = unescapeShellValue(value)
+	}
+	return env
+}
+
+// Set assigns a variable; an empty value removes it.
+func (e *ManagedEnv) Set(key, value string) {
+	if value == "" {
+		delete(e.Vars, key)
+		return
+	}
+	e.Vars[key] = value
+}
+
+// AddPath prepends a directory to PATH if it is not there yet.
+func (e *ManagedEnv) AddPath(dir string) {
+	if !containsString(e.Path, dir) {
+		e.Path = append(e.Path, dir)
+	}
+}
+
+// RemovePath drops a directory from the managed PATH entries.
+func (e *ManagedEnv) RemovePath(dir string) {
+	e.Path = removeString(e.Path, dir)
+}
+
+// Render returns the content of the managed env file.
+func (e *ManagedEnv) Render() string {
+	var b strings.Builder
+	b.WriteString(managedEnvHeader)
+
+	var keys []string
+	for key := range e.Vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, "export %s=\"%s\"\n", key, escapeShellValue(e.Vars[key]))
+	}
+
+	if len(e.Path) > 0 {
+		var entries []string
+		for _, entry := range e.Path {
+			entries = append(entries, escapeShellValue(entry))
+		}
+		fmt.Fprintf(&b, "export PATH=\"%s:$PATH\"\n", strings.Join(entries, ":"))
+	}
+	return b.String()
+}
+
+// Save writes the managed env file and makes sure ~/.profile sources it.
+func (e *ManagedEnv) Save() error {
+	envPath, err := ManagedEnvPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(envPath), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(envPath), err)
+	}
+	if err := os.WriteFile(envPath, []byte(e.Render()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", envPath, err)
+	}
+	return ensureProfileSourcesEnv()
+}
+
+// ensureProfileSourcesEnv appends the source line to ~/.profile once. The
+// env files of contexts other than the default are sourced by hand, so
+// one login shell does not mix the toolchains of several contexts.
+func ensureProfileSourcesEnv() error {
+	if CurrentContext() != DefaultContext {
+		return nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("error getting home directory: %v", err)
+	}
+	profilePath := filepath.Join(home, ".profile")
+
+	data, err := os.ReadFile(profilePath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %v", profilePath, err)
+	}
+	if strings.Contains(string(data), profileSourceLine) {
+		return nil
+	}
+
+	file, err := os.OpenFile(profilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", profilePath, err)
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintf(file, "\n# Added by run\n%s\n", profileSourceLine); err != nil {
+		return fmt.Errorf("failed to update %s: %v", profilePath, err)
+	}
+	return nil
+}
+
+// escapeShellValue escapes characters that are special inside double quotes.
+func escapeShellValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`").Replace(value)
+}
+
+// unescapeShellValue reverses escapeShellValue. It works on bytes so values
+// that are not valid UTF-8 come back unchanged.
+func unescapeShellValue(value string) string {
+	var b strings.Builder
+	escaped := false
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,29 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func FuzzParseManagedEnv(f *testing.F) {
+	for _, seed := range []string{"/usr/lib/jvm/java-21", `a "quoted" $HOME \ value`, "with`backtick`", `trailing\`, ""} {
+		f.Add(seed, "/opt/bin")
+	}
+	f.Fuzz(func(t *testing.T, value, pathEntry string) {
+		// Arbitrary content must parse without panicking
+		parseManagedEnv([]byte(value))
+
+		if value == "" || strings.ContainsAny(value+pathEntry, "\n\r") || pathEntry == "" || strings.Contains(pathEntry, ":") {
+			// Values are single lines and PATH entries have no ':'
+			return
+		}
+		env := &ManagedEnv{Vars: map[string]string{"JAVA_HOME": value}, Path: []string{pathEntry}}
+		parsed := parseManagedEnv([]byte(env.Render()))
+		if got := parsed.Vars["JAVA_HOME"]; got != value {
+			t.Fatalf("JAVA_HOME = %q after a round trip, want %q", got, value)
+		}
+		if len(parsed.Path) != 1 || parsed.Path[0] != pathEntry {
+			t.Fatalf("PATH = %q after a round trip, want [%q]", parsed.Path, pathEntry)
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// EssentialItem is a toggleable part of the essentials package.
+type EssentialItem struct {
+	Description string
+	// Packages are the apt packages installed for the item.
+	Packages []string
+	// Commands must be in PATH once the item is installed.
+	Commands []string
+	// Service is enabled and started after installation, if set.
+	Service string
+	// Default tells whether the item is installed when config does not say.
+	Default bool
+}
+
+// EssentialItems lists everything the essentials package can install. Items
+// are toggled under essentials.items in ~/.run/config.yaml.
+var EssentialItems = map[string]EssentialItem{
+	"build-tools": {
+		Description: "C/C++ compilers and make",
+		Packages:    []string{"build-essential", "g++", "make"},
+		Commands:    []string{"gcc", "g++", "make"},
+		Default:     true,
+	},
+	"python3": {
+		Description: "Python 3 interpreter (needed by node-gyp)",
+		Packages:    []string{"python3"},
+		Commands:    []string{"python3"},
+		Default:     true,
+	},
+	"redis": {
+		Description: "Redis in-memory data store",
+		Packages:    []string{"redis-server"},
+		Commands:    []string{"redis-server", "redis-cli"},
+		Service:     "redis-server",
+		Default:     true,
+	},
+	"ncdu": {
+		Description: "Interactive disk usage analyzer",
+		Packages:    []string{"ncdu"},
+		Commands:    []string{"ncdu"},
+		Default:     true,
+	},
+	"jq": {
+		Description: "Command-line JSON processor",
+		Packages:    []string{"jq"},
+		Commands:    []string{"jq"},
+		Default:     true,
+	},
+	"curl": {
+		Description: "URL transfer tool",
+		Packages:    []string{"curl"},
+		Commands:    []string{"curl"},
+		Default:     true,
+	},
+	"wget": {
+		Description: "Non-interactive downloader",
+		Packages:    []string{"wget"},
+		Commands:    []string{"wget"},
+		Default:     true,
+	},
+	"git": {
+		Description: "Git version control",
+		Packages:    []string{"git"},
+		Commands:    []string{"git"},
+		Default:     true,
+	},
+	"htop": {
+		Description: "Interactive process viewer",
+		Packages:    []string{"htop"},
+		Commands:    []string{"htop"},
+	},
+	"unzip": {
+		Description: "zip archive tools",
+		Packages:    []string{"unzip", "zip"},
+		Commands:    []string{"unzip", "zip"},
+	},
+}
+
+// EnabledEssentials returns the names of the essential items enabled by
+// config, sorted. Unknown item names in config are reported as an error.
+func EnabledEssentials(config *Config) ([]string, error) {
+	for name := range config.Essentials.Items {
+		if _, exists := EssentialItems[name]; !exists {
+			return nil, fmt.Errorf("unknown essentials item '%s' in config", name)
+		}
+	}
+
+	var enabled []string
+	for name, item := range EssentialItems {
+		if toggle, exists := config.Essentials.Items[name]; exists {
+			if toggle {
+				enabled = append(enabled, name)
+			}
+		} else if item.Default {
+			enabled = append(enabled, name)
+		}
+	}
+	sort.Strings(enabled)
+	return enabled, nil
+}
+
+// EssentialsScriptEnv returns the environment for essentials.sh: the apt
+// packages and services of the enabled items.
+func EssentialsScriptEnv() ([]string, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	enabled, err := EnabledEssentials(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var packages, services []string
+	for _, name := range enabled {
+		item := EssentialItems[name]
+		packages = append(packages, item.Packages...)
+		if item.Service != "" {
+			services = append(services, item.Service)
+		}
+	}
+
+	return []string{
+		"ESSENTIALS_PACKAGES=" + strings.Join(packages, " "),
+		"ESSENTIALS_SERVICES=" + strings.Join(services, " "),
+	}, nil
+}
+
+// checkEssentials reports the status of each enabled essentials item.
+func checkEssentials() []CheckResult {
+	config, err := LoadConfig()
+	if err != nil {
+		return []CheckResult{{Name: "config", OK: false, Message: err.Error()}}
+	}
+	enabled, err := EnabledEssentials(config)
+	if err != nil {
+		return []CheckResult{{Name: "config", OK: false, Message: err.Error()}}
+	}
+
+	var results []CheckResult
+	for _, name := range enabled {
+		item := EssentialItems[name]
+		result := CheckResult{Name: name, OK: true, Message: "installed"}
+
+		var missing []string
+		for _, command := range item.Commands {
+			if _, err := exec.LookPath(command); err != nil {
+				missing = append(missing, command)
+			}
+		}
+
+		switch {
+		case len(missing) > 0:
+			result.OK = false
+			result.Message = "missing " + strings.Join(missing, ", ")
+		case item.Service != "" && !checkService(item.Service).OK:
+			result.OK = false
+			result.Message = item.Service + " is not running"
+		}
+		results = append(results, result)
+	}
+	return results
+}
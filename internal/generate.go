@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// GeneratedFile is a file written by `run generate env`, recorded so `run
+// check` can tell when it drifts from its template.
+type GeneratedFile struct {
+	Template string `json:"template"`
+	Owner    string `json:"owner,omitempty"`
+}
+
+// RenderTemplate renders a text/template with values from config and
+// secrets from secrets.backend:
+//
+//	DATABASE_URL=postgres://{{ var "db_user" }}:{{ secret "db_password" }}@localhost/app
+//	HOME_DIR={{ env "HOME" }}
+//
+// Missing vars and secrets are errors, so a file is never written with an
+// empty value by accident.
+func RenderTemplate(templatePath string) (string, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return "", err
+	}
+	funcs := template.FuncMap{
+		"var": func(name string) (string, error) {
+			value, exists := config.Vars[name]
+			if !exists {
+				return "", fmt.Errorf("var '%s' is not set under vars in ~/.run/config.yaml", name)
+			}
+			return value, nil
+		},
+		"secret": GetSecret,
+		"env":    os.Getenv,
+	}
+
+	data, err := os.ReadFile(templatePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read template: %v", err)
+	}
+	tmpl, err := template.New(filepath.Base(templatePath)).Funcs(funcs).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return "", fmt.Errorf("invalid template %s: %v", templatePath, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, nil); err != nil {
+		return "", fmt.Errorf("failed to render %s: %v", templatePath, err)
+	}
+	return out.String(), nil
+}
+
+// GenerateFile renders a template to outPath with 0600 permissions, since
+// it usually holds credentials, and records it for drift detection. With an
+// owner the file is written through sudo and handed to that user.
+func GenerateFile(templatePath, outPath, owner string) error {
+	templatePath, err := filepath.Abs(templatePath)
+	if err != nil {
+		return err
+	}
+	outPath, err = filepath.Abs(outPath)
+	if err != nil {
+		return err
+	}
+	content, err := RenderTemplate(templatePath)
+	if err != nil {
+		return err
+	}
+
+	if owner == "" {
+		if err := os.WriteFile(outPath, []byte(content), 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %v", outPath, err)
+		}
+	} else {
+		if err := system.Command("install", "-m", "0600", "-o", owner, "/dev/stdin", outPath).WithSudo().WithStdin(strings.NewReader(content)).Run(); err != nil {
+			return fmt.Errorf("failed to write %s: %v", outPath, err)
+		}
+	}
+
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	if state.GeneratedFiles == nil {
+		state.GeneratedFiles = make(map[string]GeneratedFile)
+	}
+	state.GeneratedFiles[outPath] = GeneratedFile{Template: templatePath, Owner: owner}
+	return state.Save()
+}
+
+// readGenerated reads a generated file, through sudo when it belongs to
+// another user.
+func readGenerated(path string, file GeneratedFile) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if os.IsPermission(err) && file.Owner != "" {
+		return system.Command("cat", path).WithSudo().CaptureOutput()
+	}
+	return data, err
+}
+
+// CheckGenerated reports generated files that no longer match their
+// template and current values, because either changed or the file was
+// edited by hand.
+func CheckGenerated() []CheckResult {
+	state, err := LoadState()
+	if err != nil {
+		return []CheckResult{{Name: "state", OK: false, Message: err.Error()}}
+	}
+	var results []CheckResult
+	for _, path := range mapKeys(state.GeneratedFiles) {
+		file := state.GeneratedFiles[path]
+		regenerate := fmt.Sprintf("run generate env --template %s --out %s", file.Template, path)
+		if file.Owner != "" {
+			regenerate += " --owner " + file.Owner
+		}
+
+		want, err := RenderTemplate(file.Template)
+		if err != nil {
+			results = append(results, CheckResult{Name: path, OK: false, Message: err.Error()})
+			continue
+		}
+		current, err := readGenerated(path, file)
+		switch {
+		case err != nil:
+			results = append(results, CheckResult{Name: path, OK: false, Message: fmt.Sprintf("missing (regenerate with %s)", regenerate)})
+		case string(current) != want:
+			results = append(results, CheckResult{Name: path, OK: false, Message: fmt.Sprintf("differs from its template (regenerate with %s)", regenerate)})
+		default:
+			results = append(results, CheckResult{Name: path, OK: true, Message: "up to date with " + file.Template})
+		}
+	}
+	return results
+}
@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"os"
+	"strconv"
+)
+
+// sshdHardeningDropIn is the sshd_config drop-in written by hardening.sh.
+const sshdHardeningDropIn = "/etc/ssh/sshd_config.d/01-run-hardening.conf"
+
+// HardeningScriptEnv returns the environment for hardening.sh.
+func HardeningScriptEnv() ([]string, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return []string{"HARDENING_SSH_KEY_ONLY=" + strconv.FormatBool(config.Hardening.SSHKeyOnly)}, nil
+}
+
+// checkHardening reports whether the pieces of the hardening package are active.
+func checkHardening() []CheckResult {
+	return []CheckResult{
+		checkService("fail2ban"),
+		checkService("unattended-upgrades"),
+		checkSSHDHardening(),
+	}
+}
+
+// checkService reports whether a systemd service is running.
+func checkService(service string) CheckResult {
+	if !ServiceActive(service) {
+		return CheckResult{Name: service, OK: false, Message: "not running"}
+	}
+	return CheckResult{Name: service, OK: true, Message: "running"}
+}
+
+// checkSSHDHardening reports whether the sshd drop-in is in place.
+func checkSSHDHardening() CheckResult {
+	if _, err := os.Stat(sshdHardeningDropIn); err != nil {
+		return CheckResult{Name: "sshd", OK: false, Message: "hardening drop-in not applied"}
+	}
+	return CheckResult{Name: "sshd", OK: true, Message: "hardening drop-in applied"}
+}
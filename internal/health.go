@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HealthConfig configures HTTP health probes run by `run check`.
+type HealthConfig struct {
+	// Probes are keyed by package, `run service` name or pm2 app. Probes
+	// given for a package replace its DefaultHealthProbes.
+	Probes map[string][]HealthProbe `yaml:"probes"`
+}
+
+// HealthProbe is a request that must get the expected response.
+type HealthProbe struct {
+	URL string `yaml:"url"`
+	// Status is the expected status code. 0 accepts any status below 500.
+	Status int `yaml:"status"`
+	// Timeout in seconds; 5 when unset.
+	Timeout int `yaml:"timeout"`
+	// Insecure skips TLS certificate verification, for self-signed certificates.
+	Insecure bool `yaml:"insecure"`
+}
+
+// DefaultHealthProbes are probed for installed packages without configured probes.
+var DefaultHealthProbes = map[string][]HealthProbe{
+	"nginx": {{URL: "http://127.0.0.1/"}},
+}
+
+// healthProbes returns the probes of a package or app.
+func healthProbes(config *Config, name string) []HealthProbe {
+	if probes, exists := config.Health.Probes[name]; exists {
+		return probes
+	}
+	return DefaultHealthProbes[name]
+}
+
+// HealthProbeApps returns the apps with configured probes that are not
+// registry packages, such as services and pm2 apps.
+func HealthProbeApps() []string {
+	config, err := LoadConfig()
+	if err != nil {
+		return nil
+	}
+	var apps []string
+	for _, name := range mapKeys(config.Health.Probes) {
+		if _, exists := InstallPackageRegistry[name]; !exists {
+			apps = append(apps, name)
+		}
+	}
+	return apps
+}
+
+// probe requests the URL of a probe and reports whether the response was
+// as expected, with the time it took.
+func (p HealthProbe) probe() CheckResult {
+	timeout := time.Duration(p.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+	if p.Insecure {
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+
+	started := time.Now()
+	resp, err := client.Get(p.URL)
+	latency := time.Since(started)
+	if err != nil {
+		return CheckResult{Name: p.URL, OK: false, Message: fmt.Sprintf("no response: %v", err), Latency: latency}
+	}
+	resp.Body.Close()
+
+	ok := resp.StatusCode < 500
+	if p.Status != 0 {
+		ok = resp.StatusCode == p.Status
+	}
+	message := fmt.Sprintf("HTTP %d in %s", resp.StatusCode, latency.Round(time.Millisecond))
+	if !ok && p.Status != 0 {
+		message += fmt.Sprintf(", expected %d", p.Status)
+	}
+	return CheckResult{Name: p.URL, OK: ok, Message: message, Latency: latency}
+}
+
+// checkHealth probes the health endpoints of a package or app.
+func checkHealth(name string) []CheckResult {
+	config, err := LoadConfig()
+	if err != nil {
+		return []CheckResult{{Name: "health", OK: false, Message: err.Error()}}
+	}
+	var results []CheckResult
+	for _, probe := range healthProbes(config, name) {
+		results = append(results, probe.probe())
+	}
+	return results
+}
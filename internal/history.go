@@ -0,0 +1,225 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// historyLimit is how many entries the history keeps; older ones are
+// dropped as new ones are recorded.
+const historyLimit = 5000
+
+// HistoryEntry is an operation run performed on this host: an install or
+// removal of a package, or an update or rollback of run itself.
+type HistoryEntry struct {
+	Time      time.Time `json:"time"`
+	Context   string    `json:"context,omitempty"`
+	Package   string    `json:"package"`
+	Operation string    `json:"operation"`
+	OK        bool      `json:"ok"`
+	Version   string    `json:"version,omitempty"`
+	Seconds   float64   `json:"seconds,omitempty"`
+	Message   string    `json:"message,omitempty"`
+}
+
+// HistoryPath returns the location of the operation history
+// (~/.run/history.jsonl). It never leaves the host.
+func HistoryPath() (string, error) {
+	runDir, err := RunDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(runDir, "history.jsonl"), nil
+}
+
+// LoadHistory reads the operation history, oldest first. Lines that cannot
+// be parsed are skipped.
+func LoadHistory() ([]HistoryEntry, error) {
+	path, err := HistoryPath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read history %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// RecordHistory appends entries to the operation history, stamped with the
+// time and active context, keeping the last historyLimit entries.
+func RecordHistory(entries ...HistoryEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	path, err := HistoryPath()
+	if err != nil {
+		return err
+	}
+	history, err := LoadHistory()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for _, entry := range entries {
+		if entry.Time.IsZero() {
+			entry.Time = now
+		}
+		if entry.Context == "" {
+			entry.Context = CurrentContext()
+		}
+		history = append(history, entry)
+	}
+	if len(history) > historyLimit {
+		history = history[len(history)-historyLimit:]
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write history %s: %v", path, err)
+	}
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range history {
+		if err := encoder.Encode(entry); err != nil {
+			file.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write history %s: %v", path, err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write history %s: %v", path, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// PackageStats summarizes the history of one package.
+type PackageStats struct {
+	Package  string   `json:"package"`
+	Installs int      `json:"installs"`
+	Removals int      `json:"removals"`
+	Failures int      `json:"failures"`
+	Versions []string `json:"versions,omitempty"`
+	// FailureRate is the share of installs and removals that failed.
+	FailureRate float64 `json:"failure_rate"`
+	// AverageSeconds is the average duration of successful installs.
+	AverageSeconds float64 `json:"average_seconds"`
+}
+
+// HistoryStats summarizes the operation history of the host.
+type HistoryStats struct {
+	Since     time.Time      `json:"since"`
+	Entries   int            `json:"entries"`
+	Packages  []PackageStats `json:"packages"`
+	Updates   int            `json:"updates"`
+	Rollbacks int            `json:"rollbacks"`
+	Crashes   int            `json:"crashes"`
+}
+
+// GetHistoryStats summarizes the history recorded after since, the zero
+// time for all of it: per package how often it was installed and removed,
+// how often that failed and how long installs take, how often run was
+// updated and rolled back, and how many crash reports were written.
+// Packages are sorted by installs, most first.
+func GetHistoryStats(since time.Time) (HistoryStats, error) {
+	stats := HistoryStats{Since: since}
+	history, err := LoadHistory()
+	if err != nil {
+		return stats, err
+	}
+
+	packages := make(map[string]*PackageStats)
+	installSeconds := make(map[string]float64)
+	installed := make(map[string]int)
+	for _, entry := range history {
+		if entry.Time.Before(since) {
+			continue
+		}
+		stats.Entries++
+		switch entry.Operation {
+		case "update":
+			if entry.OK {
+				stats.Updates++
+			}
+			continue
+		case "rollback":
+			if entry.OK {
+				stats.Rollbacks++
+			}
+			continue
+		}
+		pkg, exists := packages[entry.Package]
+		if !exists {
+			pkg = &PackageStats{Package: entry.Package}
+			packages[entry.Package] = pkg
+		}
+		if !entry.OK {
+			pkg.Failures++
+		}
+		if entry.Operation == "remove" {
+			pkg.Removals++
+			continue
+		}
+		pkg.Installs++
+		if entry.OK {
+			installed[entry.Package]++
+			installSeconds[entry.Package] += entry.Seconds
+			if entry.Version != "" && !containsString(pkg.Versions, entry.Version) {
+				pkg.Versions = append(pkg.Versions, entry.Version)
+			}
+		}
+	}
+
+	for _, name := range mapKeys(packages) {
+		pkg := packages[name]
+		if runs := pkg.Installs + pkg.Removals; runs > 0 {
+			pkg.FailureRate = float64(pkg.Failures) / float64(runs)
+		}
+		if installed[name] > 0 {
+			pkg.AverageSeconds = installSeconds[name] / float64(installed[name])
+		}
+		stats.Packages = append(stats.Packages, *pkg)
+	}
+	sort.SliceStable(stats.Packages, func(i, j int) bool {
+		return stats.Packages[i].Installs > stats.Packages[j].Installs
+	})
+
+	if dir, err := CrashesDir(); err == nil {
+		reports, _ := os.ReadDir(dir)
+		for _, report := range reports {
+			if info, err := report.Info(); err == nil && !info.ModTime().Before(since) {
+				stats.Crashes++
+			}
+		}
+	}
+	return stats, nil
+}
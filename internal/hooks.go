@@ -0,0 +1,84 @@
+package internal
+
+// PreInstallHooks run before a package's install script, to prepare what
+// the script expects to exist.
+var PreInstallHooks = map[string]func() error{
+	"nginx":  ensureAppUser,
+	"nvidia": checkGPU,
+	"pm2":    ensureAppUser,
+}
+
+// RunPreInstallHook runs the pre-install hook of a package, if it has one.
+func RunPreInstallHook(packageName string) error {
+	hook, exists := PreInstallHooks[packageName]
+	if !exists {
+		return nil
+	}
+	return hook()
+}
+
+// PostInstallHooks run after a package's install script has succeeded.
+var PostInstallHooks = map[string]func() error{
+	"docker": setupDockerGroup,
+	"java":   setupJava,
+	"node":   setupNode,
+	"nvidia": setupNvidia,
+	"python": RegisterPythonAlternatives,
+}
+
+// RunPostInstallHook runs the post-install hook of a package, if it has one.
+func RunPostInstallHook(packageName string) error {
+	hook, exists := PostInstallHooks[packageName]
+	if !exists {
+		return nil
+	}
+	return hook()
+}
+
+// ScriptEnvProviders compute settings passed to a package's install script,
+// usually from config.
+var ScriptEnvProviders = map[string]func() ([]string, error){
+	"essentials": EssentialsScriptEnv,
+	"hardening":  HardeningScriptEnv,
+}
+
+// configuredBackend returns the install backend chosen in the config for a
+// package, or an empty string for the default.
+func configuredBackend(packageName string) string {
+	if packageName != "node" {
+		return ""
+	}
+	config, err := LoadConfig()
+	if err != nil {
+		return ""
+	}
+	return config.Node.Backend
+}
+
+// PackageScriptEnv returns the install script environment of a package,
+// including the download and cloud settings every script gets.
+func PackageScriptEnv(packageName string) ([]string, error) {
+	env, err := DownloadScriptEnv()
+	if err != nil {
+		return nil, err
+	}
+	cloudEnv, err := CloudScriptEnv()
+	if err != nil {
+		return nil, err
+	}
+	env = append(env, cloudEnv...)
+	backendEnv, err := BackendScriptEnv(packageName, configuredBackend(packageName))
+	if err != nil {
+		return nil, err
+	}
+	env = append(env, backendEnv...)
+	provider, exists := ScriptEnvProviders[packageName]
+	if !exists {
+		return env, nil
+	}
+	packageEnv, err := provider()
+	if err != nil {
+		return nil, err
+	}
+	return append(env, packageEnv...), nil
+}
@@ -0,0 +1,40 @@
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// HostInfo identifies the machine run operates on, for reports.
+type HostInfo struct {
+	Hostname string
+	OS       string
+	Kernel   string
+	Arch     string
+}
+
+// GetHostInfo collects the host name, distribution, kernel and architecture.
+func GetHostInfo() HostInfo {
+	hostname, _ := os.Hostname()
+	info := HostInfo{Hostname: hostname, OS: osRelease("PRETTY_NAME"), Arch: runtime.GOARCH}
+	if output, err := exec.Command("uname", "-r").Output(); err == nil {
+		info.Kernel = strings.TrimSpace(string(output))
+	}
+	return info
+}
+
+// osRelease returns a field of /etc/os-release, or an empty string.
+func osRelease(key string) string {
+	data, err := os.ReadFile("/etc/os-release")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, found := strings.CutPrefix(line, key+"="); found {
+			return strings.Trim(value, `"`)
+		}
+	}
+	return ""
+}
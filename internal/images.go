@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// imageDigestPattern matches the digest of a pinned image reference such as
+// nginx:1.27@sha256:<64 hex digits>.
+var imageDigestPattern = regexp.MustCompile(`@(sha256:[a-f0-9]{64})$`)
+
+// dockerCommand returns a docker command run as root, since the user may
+// not have picked up membership of the docker group yet.
+func dockerCommand(args ...string) *system.Cmd {
+	return system.Command("docker", args...).WithSudo()
+}
+
+// imageRepository returns the repository of an image reference, without tag
+// or digest.
+func imageRepository(ref string) string {
+	ref = imageDigestPattern.ReplaceAllString(ref, "")
+	if slash, colon := strings.LastIndex(ref, "/"), strings.LastIndex(ref, ":"); colon > slash {
+		ref = ref[:colon]
+	}
+	return ref
+}
+
+// imageDigests returns the repository digests of a local image, or nil
+// when it is not present.
+func imageDigests(ref string) []string {
+	output, err := dockerCommand("image", "inspect", "--format", `{{join .RepoDigests "\n"}}`, ref).CaptureOutput()
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(output))
+}
+
+// LoadImageBundle loads the images of a bundle written by SaveImageBundle.
+func LoadImageBundle(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("cannot read image bundle: %v", err)
+	}
+	fmt.Fprintf(Console, "Loading images from %s...\n", path)
+	if err := dockerCommand("load", "--input", path).Stream(Console, os.Stderr); err != nil {
+		return fmt.Errorf("failed to load image bundle %s: %v", path, err)
+	}
+	return nil
+}
+
+// PrefetchImage makes an image available locally: images already present
+// are kept, others are pulled with progress. A pinned digest must match the
+// image; an unpinned image gets its digest printed for pinning.
+func PrefetchImage(ref string) error {
+	digests := imageDigests(ref)
+	if digests == nil {
+		if err := RequireOnline(); err != nil {
+			return fmt.Errorf("image %s is not present and cannot be pulled: %v", ref, err)
+		}
+		fmt.Fprintf(Console, "Pulling image %s...\n", ref)
+		if err := dockerCommand("pull", ref).Stream(Console, os.Stderr); err != nil {
+			return fmt.Errorf("failed to pull %s: %v", ref, err)
+		}
+		digests = imageDigests(ref)
+	}
+
+	repository := imageRepository(ref)
+	if match := imageDigestPattern.FindStringSubmatch(ref); match != nil {
+		for _, digest := range digests {
+			if strings.HasSuffix(digest, "@"+match[1]) {
+				return nil
+			}
+		}
+		return fmt.Errorf("image %s does not match its pinned digest (found %s)", ref, strings.Join(digests, ", "))
+	}
+	pin := ""
+	for _, digest := range digests {
+		if strings.HasPrefix(digest, repository+"@") || strings.HasPrefix(digest, "docker.io/library/"+repository+"@") {
+			pin = ref + "@" + strings.SplitN(digest, "@", 2)[1]
+			fmt.Fprintf(Console, "📌 %s resolved to %s; pin it as %s\n", ref, digest, pin)
+			break
+		}
+	}
+	if Strict {
+		return fmt.Errorf("image %s is not pinned to a digest, required with --strict; pin it as %s", ref, pin)
+	}
+	return nil
+}
+
+// SaveImageBundle prefetches images and writes them to one tarball, for
+// provisioning hosts without registry access.
+func SaveImageBundle(images []string, path string) error {
+	for _, ref := range images {
+		if err := PrefetchImage(ref); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(Console, "Saving %d image(s) to %s...\n", len(images), path)
+	if err := dockerCommand(append([]string{"save", "--output", path}, images...)...).Run(); err != nil {
+		return fmt.Errorf("failed to save images: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// maxBackupSize is the largest file kept as a backup for restoring;
+// larger files, such as binaries, are restored by reinstalling.
+const maxBackupSize = 1 << 20
+
+// FileRecord is the recorded content of a key file of a package.
+type FileRecord struct {
+	Path   string      `json:"path"`
+	SHA256 string      `json:"sha256"`
+	Mode   os.FileMode `json:"mode"`
+	UID    int         `json:"uid"`
+	GID    int         `json:"gid"`
+	// Backup is a copy of the file in ~/.run/backups, for files up to
+	// maxBackupSize.
+	Backup     string    `json:"backup,omitempty"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// File change kinds reported by VerifyPackageFiles.
+const (
+	FileModified = "modified"
+	FileMissing  = "missing"
+	FileMode     = "mode"
+)
+
+// FileChange is a key file that no longer matches its record.
+type FileChange struct {
+	Record FileRecord
+	Kind   string
+	// Detail is the current mode and owner for mode changes.
+	Detail string
+	// Diff is a unified diff from the backup, for modified text files.
+	Diff string
+}
+
+// backupsDir returns where backups of package files are kept.
+func backupsDir(packageName string) (string, error) {
+	runDir, err := ContextDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(runDir, "backups", packageName), nil
+}
+
+// packageFilePaths expands the key files of a package declared in the
+// registry, leaving out those that do not exist.
+func packageFilePaths(packageName string) []string {
+	var paths []string
+	for _, pattern := range PackageFiles[packageName] {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			continue
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// readFilePrivileged reads a file, with sudo when the user may not, as for
+// configs readable by their service only.
+func readFilePrivileged(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err == nil || !os.IsPermission(err) {
+		return data, err
+	}
+	return system.Command("cat", path).WithSudo().CaptureOutput()
+}
+
+// RecordPackageFiles records the checksum, mode and owner of the key files
+// of a package, with a backup of the small ones, replacing earlier records.
+func RecordPackageFiles(packageName string) error {
+	paths := packageFilePaths(packageName)
+	if len(paths) == 0 {
+		return nil
+	}
+	backups, err := backupsDir(packageName)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(backups, 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %v", backups, err)
+	}
+
+	var records []FileRecord
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		data, err := readFilePrivileged(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		sum := sha256.Sum256(data)
+		record := FileRecord{Path: path, SHA256: hex.EncodeToString(sum[:]), Mode: info.Mode().Perm(), RecordedAt: time.Now().UTC()}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			record.UID, record.GID = int(stat.Uid), int(stat.Gid)
+		}
+		if len(data) <= maxBackupSize {
+			// Named by content, so unchanged files share their backup
+			record.Backup = filepath.Join(backups, record.SHA256)
+			if err := os.WriteFile(record.Backup, data, 0600); err != nil {
+				return fmt.Errorf("failed to back up %s: %v", path, err)
+			}
+		}
+		records = append(records, record)
+	}
+
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	if state.Files == nil {
+		state.Files = make(map[string][]FileRecord)
+	}
+	state.Files[packageName] = records
+	if err := state.Save(); err != nil {
+		return err
+	}
+	return pruneBackups(backups, records)
+}
+
+// pruneBackups deletes the backups no record refers to anymore.
+func pruneBackups(dir string, records []FileRecord) error {
+	kept := make(map[string]bool)
+	for _, record := range records {
+		kept[record.Backup] = true
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if path := filepath.Join(dir, entry.Name()); !kept[path] {
+			os.Remove(path)
+		}
+	}
+	return nil
+}
+
+// ForgetPackageFiles drops the records and backups of a removed package.
+func ForgetPackageFiles(packageName string) error {
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	if _, exists := state.Files[packageName]; !exists {
+		return nil
+	}
+	delete(state.Files, packageName)
+	if err := state.Save(); err != nil {
+		return err
+	}
+	backups, err := backupsDir(packageName)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(backups)
+}
+
+// VerifyPackageFiles compares the key files of a package with their
+// records and returns those that were edited, removed or had their mode or
+// owner changed.
+func VerifyPackageFiles(packageName string) ([]FileChange, error) {
+	state, err := LoadState()
+	if err != nil {
+		return nil, err
+	}
+	records, exists := state.Files[packageName]
+	if !exists {
+		if len(PackageFiles[packageName]) == 0 {
+			return nil, fmt.Errorf("the registry lists no files to verify for %s", packageName)
+		}
+		return nil, fmt.Errorf("no files recorded for %s; they are recorded when it is installed", packageName)
+	}
+
+	var changes []FileChange
+	for _, record := range records {
+		info, err := os.Stat(record.Path)
+		if err != nil {
+			changes = append(changes, FileChange{Record: record, Kind: FileMissing})
+			continue
+		}
+		data, err := readFilePrivileged(record.Path)
+		if err != nil {
+			return changes, fmt.Errorf("failed to read %s: %v", record.Path, err)
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != record.SHA256 {
+			changes = append(changes, FileChange{Record: record, Kind: FileModified, Diff: backupDiff(record, data)})
+			continue
+		}
+		uid, gid := record.UID, record.GID
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			uid, gid = int(stat.Uid), int(stat.Gid)
+		}
+		if info.Mode().Perm() != record.Mode || uid != record.UID || gid != record.GID {
+			changes = append(changes, FileChange{
+				Record: record,
+				Kind:   FileMode,
+				Detail: fmt.Sprintf("%04o %d:%d, recorded %04o %d:%d", info.Mode().Perm(), uid, gid, record.Mode, record.UID, record.GID),
+			})
+		}
+	}
+	return changes, nil
+}
+
+// backupDiff returns a unified diff from the backup of a file to its
+// current content, or an empty string without a backup.
+func backupDiff(record FileRecord, current []byte) string {
+	if record.Backup == "" {
+		return ""
+	}
+	tmp, err := os.CreateTemp("", CLIName+"-verify-")
+	if err != nil {
+		return ""
+	}
+	defer os.Remove(tmp.Name())
+	tmp.Write(current)
+	tmp.Close()
+	// diff exits with 1 when the files differ
+	output, _ := exec.Command("diff", "-u", "--label", record.Path+" (recorded)", "--label", record.Path,
+		record.Backup, tmp.Name()).Output()
+	return string(output)
+}
+
+// RestoreFile puts back the recorded content, mode and owner of a file from
+// its backup.
+func RestoreFile(record FileRecord) error {
+	if record.Backup == "" {
+		return fmt.Errorf("no backup of %s; reinstall its package to restore it", record.Path)
+	}
+	if _, err := os.Stat(record.Backup); err != nil {
+		return fmt.Errorf("backup of %s is gone: %v", record.Path, err)
+	}
+	return system.Command("install", "-D",
+		"-o", strconv.Itoa(record.UID), "-g", strconv.Itoa(record.GID), "-m", fmt.Sprintf("%04o", record.Mode),
+		record.Backup, record.Path).WithSudo().Run()
+}
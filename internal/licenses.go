@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LicenseFormats lists the supported `run licenses --format` values.
+var LicenseFormats = []string{"table", "csv", "json"}
+
+// licenseHints recognise licenses in copyright files that do not use the
+// machine-readable DEP-5 format.
+var licenseHints = []struct {
+	Phrase  string
+	License string
+}{
+	{"GNU General Public License", "GPL"},
+	{"GNU Lesser General Public License", "LGPL"},
+	{"Apache License", "Apache-2.0"},
+	{"Permission is hereby granted, free of charge", "MIT"},
+	{"Redistribution and use in source and binary forms", "BSD"},
+}
+
+// PackageLicense is the license information of an apt package installed by a
+// run package.
+type PackageLicense struct {
+	RunPackage string   `json:"run_package"`
+	Package    string   `json:"package"`
+	Version    string   `json:"version"`
+	Origin     string   `json:"origin,omitempty"`
+	Licenses   []string `json:"licenses"`
+}
+
+// CollectLicenses returns the licenses of all apt packages installed by run packages.
+func CollectLicenses() []PackageLicense {
+	var licenses []PackageLicense
+	for _, component := range CollectSBOMComponents() {
+		licenses = append(licenses, PackageLicense{
+			RunPackage: component.RunPackage,
+			Package:    component.Name,
+			Version:    component.Version,
+			Origin:     component.Origin,
+			Licenses:   AptPackageLicenses(component.Name),
+		})
+	}
+	return licenses
+}
+
+// AptPackageLicenses returns the licenses declared in
+// /usr/share/doc/<package>/copyright, or nil when none can be determined.
+func AptPackageLicenses(name string) []string {
+	data, err := os.ReadFile(filepath.Join("/usr/share/doc", name, "copyright"))
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if value, found := strings.CutPrefix(scanner.Text(), "License:"); found {
+			if value = strings.TrimSpace(value); value != "" {
+				seen[value] = true
+			}
+		}
+	}
+
+	if len(seen) == 0 {
+		for _, hint := range licenseHints {
+			if bytes.Contains(data, []byte(hint.Phrase)) {
+				seen[hint.License] = true
+			}
+		}
+	}
+
+	var licenses []string
+	for license := range seen {
+		licenses = append(licenses, license)
+	}
+	sort.Strings(licenses)
+	return licenses
+}
+
+// RenderLicenses renders the license report in the given format.
+func RenderLicenses(format string, licenses []PackageLicense) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "table":
+		fmt.Fprintf(&buf, "%-12s %-30s %-30s %s\n", "PACKAGE", "APT PACKAGE", "VERSION", "LICENSES")
+		for _, l := range licenses {
+			fmt.Fprintf(&buf, "%-12s %-30s %-30s %s\n", l.RunPackage, l.Package, l.Version, describeLicenses(l.Licenses))
+		}
+	case "csv":
+		writer := csv.NewWriter(&buf)
+		writer.Write([]string{"run_package", "package", "version", "origin", "licenses"})
+		for _, l := range licenses {
+			writer.Write([]string{l.RunPackage, l.Package, l.Version, l.Origin, strings.Join(l.Licenses, "; ")})
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return nil, err
+		}
+	case "json":
+		data, err := json.MarshalIndent(licenses, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(append(data, '\n'))
+	default:
+		return nil, fmt.Errorf("unknown license report format '%s': use %s", format, strings.Join(LicenseFormats, ", "))
+	}
+	return buf.Bytes(), nil
+}
+
+func describeLicenses(licenses []string) string {
+	if len(licenses) == 0 {
+		return "unknown"
+	}
+	return strings.Join(licenses, ", ")
+}
@@ -0,0 +1,329 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LocalManifestName is the install manifest expected at the root of a
+// tarball passed to `run install --from-file`.
+const LocalManifestName = "run.yaml"
+
+// LocalManifest describes how to install a tarball package.
+type LocalManifest struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+	// Install and Remove are scripts inside the tarball, relative to the manifest.
+	Install string `yaml:"install"`
+	Remove  string `yaml:"remove"`
+	// Check is a shell command that succeeds when the package works.
+	Check string `yaml:"check"`
+}
+
+// LocalPackage records a package installed from a local file.
+type LocalPackage struct {
+	Kind        string    `json:"kind"`
+	Source      string    `json:"source"`
+	Version     string    `json:"version,omitempty"`
+	Check       string    `json:"check,omitempty"`
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+// localPackageDir returns where the files kept for removing a local package live.
+func localPackageDir(name string) (string, error) {
+	runDir, err := ContextDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(runDir, "local", name), nil
+}
+
+// IsLocalPackage reports whether name was installed with `run install --from-file`.
+func IsLocalPackage(name string) bool {
+	state, err := LoadState()
+	if err != nil {
+		return false
+	}
+	_, exists := state.LocalPackages[name]
+	return exists
+}
+
+// InstallLocalPackage installs a .deb or a tarball with a run.yaml manifest,
+// verifies it and records it in the state. A package that fails
+// verification is removed again. It returns the package name.
+func InstallLocalPackage(path string) (string, error) {
+	source, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(source); err != nil {
+		return "", fmt.Errorf("cannot read %s: %v", path, err)
+	}
+	if Strict {
+		if err := verifyChecksum(source); err != nil {
+			return "", err
+		}
+	}
+
+	var name string
+	var pkg LocalPackage
+	if strings.HasSuffix(source, ".deb") {
+		name, pkg, err = installDeb(source)
+	} else {
+		name, pkg, err = installTarball(source)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := verifyLocalPackage(name, pkg); err != nil {
+		fmt.Fprintf(Console, "🔄 Verification failed, removing %s\n", name)
+		if removeErr := removeLocalPackageFiles(name, pkg); removeErr != nil {
+			Warn(name, "Failed to remove %s: %v", name, removeErr)
+		}
+		return "", fmt.Errorf("verification of %s failed: %v", name, err)
+	}
+
+	state, err := LoadState()
+	if err != nil {
+		return "", err
+	}
+	if state.LocalPackages == nil {
+		state.LocalPackages = make(map[string]LocalPackage)
+	}
+	pkg.InstalledAt = time.Now().UTC()
+	state.LocalPackages[name] = pkg
+	return name, state.Save()
+}
+
+// LocalPackageName returns the name a .deb or tarball installs as, without
+// installing it, so policy can be checked first.
+func LocalPackageName(path string) (string, error) {
+	if strings.HasSuffix(path, ".deb") {
+		fields, err := debFields(path)
+		if err != nil {
+			return "", err
+		}
+		return fields["Package"], nil
+	}
+
+	listing, err := exec.Command("tar", "-tf", path).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to list %s: %v", path, err)
+	}
+	for _, entry := range strings.Split(string(listing), "\n") {
+		// At the root or in a single top-level directory, as findLocalManifest
+		if filepath.Base(entry) != LocalManifestName || strings.Count(strings.TrimPrefix(entry, "./"), "/") > 1 {
+			continue
+		}
+		data, err := exec.Command("tar", "-xOf", path, entry).Output()
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s from %s: %v", entry, path, err)
+		}
+		manifest, err := parseLocalManifest(data)
+		if err != nil {
+			return "", err
+		}
+		return manifest.Name, nil
+	}
+	return "", fmt.Errorf("%s: no %s manifest found", path, LocalManifestName)
+}
+
+// debFields reads the Package and Version fields of a .deb.
+func debFields(source string) (map[string]string, error) {
+	output, err := exec.Command("dpkg-deb", "-f", source, "Package", "Version").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", source, err)
+	}
+
+	fields := make(map[string]string)
+	for _, line := range strings.Split(string(output), "\n") {
+		if key, value, found := strings.Cut(line, ":"); found {
+			fields[key] = strings.TrimSpace(value)
+		}
+	}
+	if fields["Package"] == "" {
+		return nil, fmt.Errorf("%s has no Package field", source)
+	}
+	return fields, nil
+}
+
+// installDeb installs a .deb with apt so its dependencies are resolved.
+func installDeb(source string) (string, LocalPackage, error) {
+	fields, err := debFields(source)
+	if err != nil {
+		return "", LocalPackage{}, err
+	}
+	name := fields["Package"]
+
+	if err := runAptGet("install", "-y", source); err != nil {
+		return "", LocalPackage{}, fmt.Errorf("failed to install %s: %v", source, err)
+	}
+	return name, LocalPackage{Kind: "deb", Source: source, Version: fields["Version"]}, nil
+}
+
+// installTarball extracts a tarball, runs the install script of its manifest
+// and keeps the remove script for later.
+func installTarball(source string) (string, LocalPackage, error) {
+	workDir, err := os.MkdirTemp("", CLIName+"-local-")
+	if err != nil {
+		return "", LocalPackage{}, err
+	}
+	defer os.RemoveAll(workDir)
+
+	if output, err := exec.Command("tar", "-xf", source, "-C", workDir).CombinedOutput(); err != nil {
+		return "", LocalPackage{}, fmt.Errorf("failed to extract %s: %v: %s", source, err, strings.TrimSpace(string(output)))
+	}
+
+	manifestPath, err := findLocalManifest(workDir)
+	if err != nil {
+		return "", LocalPackage{}, fmt.Errorf("%s: %v", source, err)
+	}
+	manifest, err := loadLocalManifest(manifestPath)
+	if err != nil {
+		return "", LocalPackage{}, err
+	}
+	baseDir := filepath.Dir(manifestPath)
+
+	if err := ExecuteScript(manifest.Name, filepath.Join(baseDir, manifest.Install), "RUN_PACKAGE_DIR="+baseDir); err != nil {
+		return "", LocalPackage{}, err
+	}
+
+	pkg := LocalPackage{Kind: "tarball", Source: source, Version: manifest.Version, Check: manifest.Check}
+	if manifest.Remove == "" {
+		return manifest.Name, pkg, nil
+	}
+
+	keepDir, err := localPackageDir(manifest.Name)
+	if err != nil {
+		return "", LocalPackage{}, err
+	}
+	data, err := os.ReadFile(filepath.Join(baseDir, manifest.Remove))
+	if err != nil {
+		return "", LocalPackage{}, fmt.Errorf("failed to read remove script: %v", err)
+	}
+	if err := os.MkdirAll(keepDir, 0755); err != nil {
+		return "", LocalPackage{}, err
+	}
+	if err := os.WriteFile(filepath.Join(keepDir, "remove.sh"), data, 0755); err != nil {
+		return "", LocalPackage{}, fmt.Errorf("failed to keep remove script: %v", err)
+	}
+	return manifest.Name, pkg, nil
+}
+
+// findLocalManifest looks for run.yaml at the root of the extracted tarball
+// or inside its single top-level directory.
+func findLocalManifest(dir string) (string, error) {
+	candidates := []string{filepath.Join(dir, LocalManifestName)}
+	if nested, err := filepath.Glob(filepath.Join(dir, "*", LocalManifestName)); err == nil {
+		candidates = append(candidates, nested...)
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no %s manifest found", LocalManifestName)
+}
+
+func loadLocalManifest(path string) (*LocalManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+	return parseLocalManifest(data)
+}
+
+// parseLocalManifest parses and checks the content of a run.yaml manifest.
+func parseLocalManifest(data []byte) (*LocalManifest, error) {
+	manifest := &LocalManifest{}
+	if err := yaml.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %v", err)
+	}
+	if manifest.Name == "" || manifest.Install == "" {
+		return nil, fmt.Errorf("manifest must set name and install")
+	}
+	if _, exists := InstallPackageRegistry[manifest.Name]; exists {
+		return nil, fmt.Errorf("'%s' is a registry package", manifest.Name)
+	}
+	return manifest, nil
+}
+
+// verifyLocalPackage checks a freshly installed local package.
+func verifyLocalPackage(name string, pkg LocalPackage) error {
+	for _, result := range checkLocalPackage(name, pkg) {
+		if !result.OK {
+			return fmt.Errorf("%s: %s", result.Name, result.Message)
+		}
+	}
+	return nil
+}
+
+// checkLocalPackage verifies a local package: a .deb must be installed in
+// dpkg and the manifest check command, if any, must succeed.
+func checkLocalPackage(name string, pkg LocalPackage) []CheckResult {
+	if pkg.Kind == "deb" {
+		output, err := exec.Command("dpkg-query", "-W", "-f", "${db:Status-Abbrev}", name).Output()
+		if err != nil || strings.TrimSpace(string(output)) != "ii" {
+			return []CheckResult{{Name: name, OK: false, Message: "not installed"}}
+		}
+		return []CheckResult{{Name: name, OK: true, Message: pkg.Version}}
+	}
+
+	if pkg.Check == "" {
+		return []CheckResult{{Name: name, OK: true, Message: pkg.Version}}
+	}
+	if output, err := exec.Command("sh", "-c", pkg.Check).CombinedOutput(); err != nil {
+		return []CheckResult{{Name: name, OK: false, Message: fmt.Sprintf("'%s' failed: %s", pkg.Check, strings.TrimSpace(string(output)))}}
+	}
+	return []CheckResult{{Name: name, OK: true, Message: pkg.Version}}
+}
+
+// RemoveLocalPackage removes a package installed from a local file and drops
+// it from the state.
+func RemoveLocalPackage(name string) error {
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	pkg, exists := state.LocalPackages[name]
+	if !exists {
+		return fmt.Errorf("'%s' was not installed from a local file", name)
+	}
+
+	if err := removeLocalPackageFiles(name, pkg); err != nil {
+		return err
+	}
+
+	delete(state.LocalPackages, name)
+	return state.Save()
+}
+
+func removeLocalPackageFiles(name string, pkg LocalPackage) error {
+	if pkg.Kind == "deb" {
+		if err := runAptGet("remove", "-y", name); err != nil {
+			return fmt.Errorf("failed to remove %s: %v", name, err)
+		}
+		return nil
+	}
+
+	keepDir, err := localPackageDir(name)
+	if err != nil {
+		return err
+	}
+	removeScript := filepath.Join(keepDir, "remove.sh")
+	if _, err := os.Stat(removeScript); err == nil {
+		if err := ExecuteScript(name, removeScript); err != nil {
+			return err
+		}
+	} else {
+		Warn(name, "%s has no remove script; its files were left in place", name)
+	}
+	return os.RemoveAll(keepDir)
+}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// SystemConfig configures host-level settings checked by `run doctor`.
+type SystemConfig struct {
+	// Locale is generated and made the system default by `run doctor
+	// --fix`; en_US.UTF-8 when unset.
+	Locale string `yaml:"locale"`
+	// PauseUnattendedUpgrades stops apt's periodic timers while run
+	// installs or removes packages, as --pause-unattended-upgrades does.
+	PauseUnattendedUpgrades bool `yaml:"pause_unattended_upgrades"`
+}
+
+// defaultLocale is used when system.locale is not configured.
+const defaultLocale = "en_US.UTF-8"
+
+// configuredLocale returns the locale the host should use.
+func configuredLocale() string {
+	config, err := LoadConfig()
+	if err != nil || config.System.Locale == "" {
+		return defaultLocale
+	}
+	return config.System.Locale
+}
+
+// normalizeLocale turns a locale name into the form `locale -a` lists, e.g.
+// en_US.UTF-8 into en_US.utf8.
+func normalizeLocale(locale string) string {
+	name, codeset, found := strings.Cut(locale, ".")
+	if !found {
+		return locale
+	}
+	return name + "." + strings.ToLower(strings.ReplaceAll(codeset, "-", ""))
+}
+
+// isUTF8Locale reports whether a locale uses UTF-8.
+func isUTF8Locale(locale string) bool {
+	return strings.HasSuffix(normalizeLocale(locale), ".utf8")
+}
+
+// checkLocale reports whether the environment and the system default use a
+// UTF-8 locale that is generated. Postgres initializes clusters from it and
+// add-apt-repository fails on PPAs with non-ASCII names without it.
+func checkLocale() []CheckResult {
+	return []CheckResult{checkSessionLocale(), checkSystemLocale()}
+}
+
+// checkSessionLocale checks LC_ALL, or LANG, of the current environment.
+func checkSessionLocale() CheckResult {
+	name, locale := "LC_ALL", os.Getenv("LC_ALL")
+	if locale == "" {
+		name, locale = "LANG", os.Getenv("LANG")
+	}
+	switch {
+	case locale == "":
+		return CheckResult{Name: "LANG", OK: false, Message: "neither LANG nor LC_ALL is set (fix with run doctor --fix)"}
+	case !isUTF8Locale(locale):
+		return CheckResult{Name: name, OK: false, Message: fmt.Sprintf("%s is not a UTF-8 locale", locale)}
+	case !localeGenerated(locale):
+		return CheckResult{Name: name, OK: false, Message: fmt.Sprintf("%s is not generated (fix with run doctor --fix)", locale)}
+	}
+	return CheckResult{Name: name, OK: true, Message: locale}
+}
+
+// checkSystemLocale checks the default locale in /etc/default/locale.
+func checkSystemLocale() CheckResult {
+	data, err := os.ReadFile("/etc/default/locale")
+	locale := ""
+	if err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if value, found := strings.CutPrefix(strings.TrimSpace(line), "LANG="); found {
+				locale = strings.Trim(value, `"`)
+			}
+		}
+	}
+	switch {
+	case locale == "":
+		return CheckResult{Name: "system locale", OK: false, Message: "no default LANG (fix with run doctor --fix)"}
+	case !isUTF8Locale(locale):
+		return CheckResult{Name: "system locale", OK: false, Message: fmt.Sprintf("%s is not a UTF-8 locale (fix with run doctor --fix)", locale)}
+	}
+	return CheckResult{Name: "system locale", OK: true, Message: locale}
+}
+
+// localeGenerated reports whether `locale -a` lists a locale.
+func localeGenerated(locale string) bool {
+	output, err := exec.Command("locale", "-a").Output()
+	if err != nil {
+		return false
+	}
+	want := normalizeLocale(locale)
+	for _, available := range strings.Fields(string(output)) {
+		if normalizeLocale(available) == want {
+			return true
+		}
+	}
+	return false
+}
+
+// fixLocale generates the configured locale and makes it the system
+// default. Running shells keep their environment until the next login.
+func fixLocale() error {
+	locale := configuredLocale()
+	if err := system.Command("locale-gen", locale).WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to generate %s: %v", locale, err)
+	}
+	if err := system.Command("update-locale", "LANG="+locale).WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to set the default locale: %v", err)
+	}
+	fmt.Fprintf(Console, "Locale set to %s; log in again to use it\n", locale)
+	return nil
+}
@@ -0,0 +1,280 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/amoga-io/run/internal/system"
+	"gopkg.in/yaml.v3"
+)
+
+// LogsConfig configures forwarding of the logs of services run installs.
+type LogsConfig struct {
+	// Forward selects how logs leave the host: syslog (through rsyslog),
+	// vector or promtail. Empty disables forwarding.
+	Forward string `yaml:"forward"`
+	// Address is where logs go: host:port of a syslog server over TCP, or
+	// the HTTP endpoint of the vector sink or the Loki push API.
+	Address string `yaml:"address"`
+}
+
+// Log forwarding targets for logs.forward.
+const (
+	LogForwardSyslog   = "syslog"
+	LogForwardVector   = "vector"
+	LogForwardPromtail = "promtail"
+)
+
+// logForwardFiles are the files log forwarding writes for each target.
+var logForwardFiles = map[string]string{
+	LogForwardSyslog:   "/etc/rsyslog.d/60-run-forward.conf",
+	LogForwardVector:   "/etc/vector/run.yaml",
+	LogForwardPromtail: "/etc/promtail/run.yaml",
+}
+
+// PackageLogFiles are the log files of packages, forwarded once the package
+// is installed.
+var PackageLogFiles = map[string][]string{
+	"nginx":    {"/var/log/nginx/*.log"},
+	"postgres": {"/var/log/postgresql/*.log"},
+}
+
+// LogSource is a set of logs of one package or service.
+type LogSource struct {
+	Name string
+	// Files are log file globs.
+	Files []string
+	// Unit is a systemd unit read from the journal.
+	Unit string
+}
+
+// logForwardHeader starts every file log forwarding writes.
+const logForwardHeader = "# Managed by run - regenerated by 'run logs forward'\n"
+
+// rsyslogNamePattern guards names used inside rsyslog expressions.
+var rsyslogNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// LogSources returns the logs to forward: those of installed packages with
+// known log files, and the journal of services created with `run service`.
+func LogSources() ([]LogSource, error) {
+	var sources []LogSource
+	for _, name := range mapKeys(PackageLogFiles) {
+		if isInstalled(name) {
+			sources = append(sources, LogSource{Name: name, Files: PackageLogFiles[name]})
+		}
+	}
+
+	state, err := LoadState()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range mapKeys(state.Services) {
+		sources = append(sources, LogSource{Name: name, Unit: name + ".service"})
+	}
+	return sources, nil
+}
+
+// validateLogForwarding checks the logs section of the config.
+func validateLogForwarding(config LogsConfig) error {
+	switch config.Forward {
+	case "":
+		return nil
+	case LogForwardSyslog:
+		if _, _, err := net.SplitHostPort(config.Address); err != nil {
+			return fmt.Errorf("logs.address must be host:port for syslog forwarding: %v", err)
+		}
+	case LogForwardVector, LogForwardPromtail:
+		if !strings.HasPrefix(config.Address, "http://") && !strings.HasPrefix(config.Address, "https://") {
+			return fmt.Errorf("logs.address must be an http(s) URL for %s forwarding", config.Forward)
+		}
+	default:
+		return fmt.Errorf("unknown logs.forward '%s': use %s, %s or %s", config.Forward, LogForwardSyslog, LogForwardVector, LogForwardPromtail)
+	}
+	return nil
+}
+
+// renderRsyslogForwarding tails the log files with imfile and sends their
+// lines, and the journal lines of services, to the syslog server over TCP.
+func renderRsyslogForwarding(address string, sources []LogSource) string {
+	host, port, _ := net.SplitHostPort(address)
+	var conf strings.Builder
+	conf.WriteString(logForwardHeader)
+	conf.WriteString("module(load=\"imfile\")\n\n")
+
+	var names []string
+	for _, source := range sources {
+		if !rsyslogNamePattern.MatchString(source.Name) {
+			continue
+		}
+		for _, file := range source.Files {
+			fmt.Fprintf(&conf, "input(type=\"imfile\" File=\"%s\" Tag=\"%s\")\n", file, source.Name)
+		}
+		names = append(names, fmt.Sprintf("$programname == '%s'", source.Name))
+	}
+	if len(names) == 0 {
+		return conf.String()
+	}
+	fmt.Fprintf(&conf, "\nif %s then {\n", strings.Join(names, " or "))
+	fmt.Fprintf(&conf, "    action(type=\"omfwd\" target=\"%s\" port=\"%s\" protocol=\"tcp\")\n}\n", host, port)
+	return conf.String()
+}
+
+// renderVectorForwarding returns a vector config with file and journald
+// sources and an HTTP sink.
+func renderVectorForwarding(address string, sources []LogSource) (string, error) {
+	var files, units []string
+	for _, source := range sources {
+		files = append(files, source.Files...)
+		if source.Unit != "" {
+			units = append(units, source.Unit)
+		}
+	}
+
+	vectorSources := map[string]interface{}{}
+	var inputs []string
+	if len(files) > 0 {
+		vectorSources["run_files"] = map[string]interface{}{"type": "file", "include": files}
+		inputs = append(inputs, "run_files")
+	}
+	if len(units) > 0 {
+		vectorSources["run_journald"] = map[string]interface{}{"type": "journald", "include_units": units}
+		inputs = append(inputs, "run_journald")
+	}
+	config := map[string]interface{}{
+		"sources": vectorSources,
+		"sinks": map[string]interface{}{
+			"run_forward": map[string]interface{}{
+				"type":     "http",
+				"inputs":   inputs,
+				"uri":      address,
+				"encoding": map[string]string{"codec": "json"},
+			},
+		},
+	}
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	return logForwardHeader + string(data), nil
+}
+
+// renderPromtailForwarding returns a promtail config with a scrape job per
+// package and one journal job for services.
+func renderPromtailForwarding(address string, sources []LogSource) (string, error) {
+	var scrapeConfigs []interface{}
+	var units []string
+	for _, source := range sources {
+		if source.Unit != "" {
+			units = append(units, regexp.QuoteMeta(source.Unit))
+		}
+		for _, file := range source.Files {
+			scrapeConfigs = append(scrapeConfigs, map[string]interface{}{
+				"job_name": source.Name,
+				"static_configs": []interface{}{map[string]interface{}{
+					"targets": []string{"localhost"},
+					"labels":  map[string]string{"job": source.Name, "__path__": file},
+				}},
+			})
+		}
+	}
+	if len(units) > 0 {
+		scrapeConfigs = append(scrapeConfigs, map[string]interface{}{
+			"job_name": "run-services",
+			"journal":  map[string]interface{}{"labels": map[string]string{"job": "run-services"}},
+			"relabel_configs": []interface{}{
+				map[string]interface{}{
+					"source_labels": []string{"__journal__systemd_unit"},
+					"regex":         "(" + strings.Join(units, "|") + ")",
+					"action":        "keep",
+				},
+				map[string]interface{}{
+					"source_labels": []string{"__journal__systemd_unit"},
+					"target_label":  "unit",
+				},
+			},
+		})
+	}
+
+	config := map[string]interface{}{
+		"clients":        []interface{}{map[string]string{"url": address}},
+		"scrape_configs": scrapeConfigs,
+	}
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	return logForwardHeader + string(data), nil
+}
+
+// ApplyLogForwarding writes the forwarding config of logs.forward for the
+// current log sources and removes that of other targets. It returns the
+// file written, or an empty string when forwarding is off.
+func ApplyLogForwarding() (string, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return "", err
+	}
+	logs := config.Logs
+	if err := validateLogForwarding(logs); err != nil {
+		return "", err
+	}
+
+	for _, target := range mapKeys(logForwardFiles) {
+		if target == logs.Forward {
+			continue
+		}
+		_, statErr := os.Stat(logForwardFiles[target])
+		if err := syncRootFile(logForwardFiles[target], ""); err != nil {
+			return "", err
+		}
+		// rsyslog keeps forwarding until it rereads its config
+		if target == LogForwardSyslog && statErr == nil {
+			system.Command("systemctl", "restart", "rsyslog").WithSudo().Run()
+		}
+	}
+	if logs.Forward == "" {
+		return "", nil
+	}
+
+	sources, err := LogSources()
+	if err != nil {
+		return "", err
+	}
+	var content string
+	switch logs.Forward {
+	case LogForwardSyslog:
+		content = renderRsyslogForwarding(logs.Address, sources)
+	case LogForwardVector:
+		content, err = renderVectorForwarding(logs.Address, sources)
+	case LogForwardPromtail:
+		content, err = renderPromtailForwarding(logs.Address, sources)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	path := logForwardFiles[logs.Forward]
+	current, _ := os.ReadFile(path)
+	if err := syncRootFile(path, content); err != nil {
+		return "", err
+	}
+	if logs.Forward == LogForwardSyslog && string(current) != content {
+		if err := system.Command("systemctl", "restart", "rsyslog").WithSudo().Run(); err != nil {
+			return "", fmt.Errorf("failed to restart rsyslog: %v", err)
+		}
+	}
+	return path, nil
+}
+
+// ForwardPackageLogs updates log forwarding after a package was installed,
+// if the package has log files and forwarding is configured.
+func ForwardPackageLogs(packageName string) error {
+	if _, exists := PackageLogFiles[packageName]; !exists {
+		return nil
+	}
+	_, err := ApplyLogForwarding()
+	return err
+}
@@ -0,0 +1,248 @@
+package internal
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// LogRetention is how long rotated logs are kept in ~/.run/logs.
+const LogRetention = 14 * 24 * time.Hour
+
+// maintenanceUnit is the name of the systemd service/timer pair.
+const maintenanceUnit = "run-maintenance"
+
+// LogsDir returns the directory holding run's logs (~/.run/logs).
+func LogsDir() (string, error) {
+	runDir, err := RunDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(runDir, "logs"), nil
+}
+
+// CleanArtifacts deletes leftovers in ~/.run that are safe to remove: temp
+// files from interrupted writes and the binary built by `run update`. It
+// returns the removed paths.
+func CleanArtifacts() ([]string, error) {
+	runDir, err := RunDir()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := filepath.Glob(filepath.Join(runDir, "*.tmp"))
+	if err != nil {
+		return nil, err
+	}
+	candidates = append(candidates, filepath.Join(runDir, CLIName))
+
+	var removed []string
+	for _, path := range candidates {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %v", path, err)
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// RotateLogs compresses logs that have not been written to for a day and
+// deletes logs older than LogRetention. It returns the number of compressed
+// and deleted files.
+func RotateLogs() (int, int, error) {
+	logsDir, err := LogsDir()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	entries, err := os.ReadDir(logsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, fmt.Errorf("failed to read %s: %v", logsDir, err)
+	}
+
+	compressed, deleted := 0, 0
+	now := time.Now()
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(logsDir, entry.Name())
+		age := now.Sub(info.ModTime())
+
+		switch {
+		case age > LogRetention:
+			if err := os.Remove(path); err != nil {
+				return compressed, deleted, fmt.Errorf("failed to remove %s: %v", path, err)
+			}
+			deleted++
+		case strings.HasSuffix(entry.Name(), ".log") && age > 24*time.Hour:
+			if err := gzipFile(path); err != nil {
+				return compressed, deleted, err
+			}
+			compressed++
+		}
+	}
+	return compressed, deleted, nil
+}
+
+// gzipFile replaces path with path.gz, keeping its modification time so
+// retention still counts from the last write.
+func gzipFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("failed to create %s.gz: %v", path, err)
+	}
+
+	writer := gzip.NewWriter(dst)
+	if _, err := io.Copy(writer, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to compress %s: %v", path, err)
+	}
+	if err := writer.Close(); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to compress %s: %v", path, err)
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("failed to compress %s: %v", path, err)
+	}
+
+	os.Chtimes(path+".gz", info.ModTime(), info.ModTime())
+	return os.Remove(path)
+}
+
+// maintenanceUnits renders the systemd service and timer for the current user.
+func maintenanceUnits(user, binary string) (string, string) {
+	service := fmt.Sprintf(`# Managed by run - removed by 'run maintenance disable'
+[Unit]
+Description=run maintenance (clean artifacts, prune the cache, rotate logs)
+
+[Service]
+Type=oneshot
+User=%s
+ExecStart=%s clean --quiet
+ExecStart=%s cache gc --quiet
+ExecStart=%s logs --rotate
+`, user, binary, binary, binary)
+
+	timer := `# Managed by run - removed by 'run maintenance disable'
+[Unit]
+Description=Daily run maintenance
+
+[Timer]
+OnCalendar=daily
+RandomizedDelaySec=1h
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`
+	return service, timer
+}
+
+// runBinary returns the path of the run binary, for units that run it.
+func runBinary() (string, error) {
+	binary, err := exec.LookPath(CLIName)
+	if err != nil {
+		if binary, err = os.Executable(); err != nil {
+			return "", fmt.Errorf("failed to locate the run binary: %v", err)
+		}
+	}
+	return binary, nil
+}
+
+// enableTimer installs a systemd service/timer pair and starts the timer.
+func enableTimer(unit, service, timer string) error {
+	if err := system.WriteFileAsRoot("/etc/systemd/system/"+unit+".service", []byte(service), 0644); err != nil {
+		return err
+	}
+	if err := system.WriteFileAsRoot("/etc/systemd/system/"+unit+".timer", []byte(timer), 0644); err != nil {
+		return err
+	}
+
+	if err := system.Command("systemctl", "daemon-reload").WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to reload systemd: %v", err)
+	}
+	if err := system.Command("systemctl", "enable", "--now", unit+".timer").WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to enable %s.timer: %v", unit, err)
+	}
+	return nil
+}
+
+// disableTimer stops a timer and removes its service/timer pair.
+func disableTimer(unit string) error {
+	system.Command("systemctl", "disable", "--now", unit+".timer").WithSudo().Run()
+
+	for _, name := range []string{unit + ".service", unit + ".timer"} {
+		if err := system.RemoveFileAsRoot("/etc/systemd/system/" + name); err != nil {
+			return err
+		}
+	}
+
+	if err := system.Command("systemctl", "daemon-reload").WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to reload systemd: %v", err)
+	}
+	return nil
+}
+
+// timerStatus returns whether a timer is enabled and when it runs next.
+func timerStatus(unit string) (bool, string) {
+	if err := exec.Command("systemctl", "is-enabled", "--quiet", unit+".timer").Run(); err != nil {
+		return false, ""
+	}
+	output, err := exec.Command("systemctl", "show", unit+".timer", "--property=NextElapseUSecRealtime", "--value").Output()
+	if err != nil {
+		return true, ""
+	}
+	return true, strings.TrimSpace(string(output))
+}
+
+// EnableMaintenance installs and starts the daily maintenance timer.
+func EnableMaintenance() error {
+	binary, err := runBinary()
+	if err != nil {
+		return err
+	}
+	user := os.Getenv("USER")
+	if user == "" {
+		return fmt.Errorf("USER environment variable is not set")
+	}
+
+	service, timer := maintenanceUnits(user, binary)
+	return enableTimer(maintenanceUnit, service, timer)
+}
+
+// DisableMaintenance stops the timer and removes its units.
+func DisableMaintenance() error {
+	return disableTimer(maintenanceUnit)
+}
+
+// MaintenanceStatus returns whether the timer is enabled and when it runs next.
+func MaintenanceStatus() (bool, string) {
+	return timerStatus(maintenanceUnit)
+}
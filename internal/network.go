@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// OfflineEnv names the environment variable that turns on offline mode, like
+// --offline.
+const OfflineEnv = "RUN_OFFLINE"
+
+// OfflineOverride is set from --offline.
+var OfflineOverride bool
+
+// IsOffline reports whether run must not reach out to the network: checks
+// that need it are skipped and commands that download refuse to run.
+func IsOffline() bool {
+	return OfflineOverride || os.Getenv(OfflineEnv) != ""
+}
+
+// errOffline is returned by operations that need the network in offline mode.
+var errOffline = fmt.Errorf("not available in offline mode (--offline or %s)", OfflineEnv)
+
+// RequireOnline returns an error in offline mode.
+func RequireOnline() error {
+	if IsOffline() {
+		return errOffline
+	}
+	return nil
+}
+
+// NetworkConfig configures the connectivity preflight.
+type NetworkConfig struct {
+	// Endpoints are URLs that must be reachable over HTTP(S). Unset, the
+	// apt mirror, NodeSource and GitHub are checked.
+	Endpoints []string `yaml:"endpoints"`
+}
+
+// networkEndpoints returns the endpoints the network check probes.
+func networkEndpoints(config *Config) []string {
+	if len(config.Network.Endpoints) > 0 {
+		return config.Network.Endpoints
+	}
+	mirror := ubuntuArchive
+	if len(config.Downloads.Mirrors) > 0 {
+		mirror = config.Downloads.Mirrors[0]
+	}
+	return []string{mirror, "https://deb.nodesource.com", "https://github.com"}
+}
+
+// checkNetwork verifies that the endpoints installs download from resolve and
+// answer over HTTP(S), through the proxy from HTTP_PROXY/HTTPS_PROXY when
+// one is set. Any HTTP response counts as reachable.
+func checkNetwork() []CheckResult {
+	if IsOffline() {
+		return []CheckResult{{Name: "network", OK: true, Message: "skipped: offline mode"}}
+	}
+	config, err := LoadConfig()
+	if err != nil {
+		return []CheckResult{{Name: "network", OK: false, Message: err.Error()}}
+	}
+
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment},
+		// A redirect already shows the endpoint is reachable
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	var results []CheckResult
+	for _, endpoint := range networkEndpoints(config) {
+		results = append(results, checkEndpoint(client, endpoint))
+	}
+	return results
+}
+
+// checkEndpoint resolves and requests one endpoint.
+func checkEndpoint(client *http.Client, endpoint string) CheckResult {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Host == "" {
+		return CheckResult{Name: endpoint, OK: false, Message: "not a URL"}
+	}
+	req, err := http.NewRequest(http.MethodHead, endpoint, nil)
+	if err != nil {
+		return CheckResult{Name: endpoint, OK: false, Message: err.Error()}
+	}
+
+	// Behind a proxy the proxy resolves names, so only check DNS without one
+	proxy, err := http.ProxyFromEnvironment(req)
+	if err != nil {
+		return CheckResult{Name: endpoint, OK: false, Message: fmt.Sprintf("invalid proxy setting: %v", err)}
+	}
+	via := ""
+	if proxy != nil {
+		via = " via proxy " + proxy.Host
+	} else if _, err := net.LookupHost(parsed.Hostname()); err != nil {
+		return CheckResult{Name: endpoint, OK: false, Message: fmt.Sprintf("DNS lookup failed: %v", err)}
+	}
+
+	started := time.Now()
+	resp, err := client.Do(req)
+	latency := time.Since(started)
+	if err != nil {
+		message := err.Error()
+		if strings.Contains(message, "certificate") {
+			message += " (check the system clock and CA certificates)"
+		}
+		return CheckResult{Name: endpoint, OK: false, Message: "unreachable" + via + ": " + message, Latency: latency}
+	}
+	resp.Body.Close()
+	return CheckResult{Name: endpoint, OK: true, Message: fmt.Sprintf("reachable%s (HTTP %d in %s)", via, resp.StatusCode, latency.Round(time.Millisecond)), Latency: latency}
+}
@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// NpmPackage is a global npm package spec such as "pnpm@9.10.0".
+type NpmPackage struct {
+	Name    string
+	Version string // empty means latest
+}
+
+// ParseNpmPackage splits a spec into name and version. Scoped names
+// ("@scope/pkg@1.0.0") are supported.
+func ParseNpmPackage(spec string) NpmPackage {
+	if i := strings.LastIndex(spec, "@"); i > 0 {
+		return NpmPackage{Name: spec[:i], Version: spec[i+1:]}
+	}
+	return NpmPackage{Name: spec}
+}
+
+func (p NpmPackage) String() string {
+	if p.Version == "" {
+		return p.Name
+	}
+	return p.Name + "@" + p.Version
+}
+
+// NpmGlobalStatus describes a configured global package on this host.
+type NpmGlobalStatus struct {
+	Package          NpmPackage
+	InstalledVersion string // empty when not installed
+}
+
+// UpToDate reports whether the installed version satisfies the configured one.
+func (s NpmGlobalStatus) UpToDate() bool {
+	if s.InstalledVersion == "" {
+		return false
+	}
+	return s.Package.Version == "" || s.Package.Version == s.InstalledVersion
+}
+
+// ListNpmGlobals returns the installed global npm packages and their versions.
+func ListNpmGlobals() (map[string]string, error) {
+	// npm exits non-zero on peer dependency problems but still prints the tree
+	output, err := exec.Command("npm", "ls", "-g", "--depth=0", "--json").Output()
+	if len(output) == 0 && err != nil {
+		return nil, fmt.Errorf("failed to list global npm packages: %v", err)
+	}
+
+	var tree struct {
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal(output, &tree); err != nil {
+		return nil, fmt.Errorf("failed to parse npm output: %v", err)
+	}
+
+	installed := make(map[string]string)
+	for name, dep := range tree.Dependencies {
+		installed[name] = dep.Version
+	}
+	return installed, nil
+}
+
+// NpmGlobalsStatus compares the configured global packages with the ones
+// installed on this host.
+func NpmGlobalsStatus(config *Config) ([]NpmGlobalStatus, error) {
+	installed, err := ListNpmGlobals()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []NpmGlobalStatus
+	for _, spec := range config.Node.GlobalPackages {
+		pkg := ParseNpmPackage(spec)
+		statuses = append(statuses, NpmGlobalStatus{Package: pkg, InstalledVersion: installed[pkg.Name]})
+	}
+	return statuses, nil
+}
+
+// SyncNpmGlobals installs or updates the configured global npm packages.
+func SyncNpmGlobals() error {
+	if _, err := exec.LookPath("npm"); err != nil {
+		return fmt.Errorf("npm is not installed. Install it with: run install node")
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	statuses, err := NpmGlobalsStatus(config)
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		if status.UpToDate() && status.Package.Version != "" {
+			fmt.Fprintf(Console, "%s is up to date\n", status.Package)
+			continue
+		}
+
+		fmt.Fprintf(Console, "Installing global npm package: %s\n", status.Package)
+		cmd := exec.Command("npm", "install", "-g", status.Package.String())
+		cmd.Stdout = Console
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to install %s: %v", status.Package, err)
+		}
+	}
+	return nil
+}
+
+// EnablePM2Startup makes the user's pm2 start at boot with systemd and
+// saves its process list, so apps come back after a reboot. It does nothing
+// when pm2 is not among the global packages.
+func EnablePM2Startup() error {
+	pm2, err := exec.LookPath("pm2")
+	if err != nil {
+		return nil
+	}
+	current, err := user.Current()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(Console, "Configuring pm2 to start at boot...")
+	if err := runRootStreaming("env", "PATH="+os.Getenv("PATH")+":/usr/bin", pm2, "startup", "systemd", "-u", current.Username, "--hp", current.HomeDir); err != nil {
+		return fmt.Errorf("failed to set up pm2 startup: %v", err)
+	}
+	if err := system.Command(pm2, "save").Run(); err != nil {
+		return fmt.Errorf("failed to save the pm2 process list: %v", err)
+	}
+	return nil
+}
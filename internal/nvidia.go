@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// cudaDir is where NVIDIA's CUDA toolkit packages install the active
+// toolkit.
+const cudaDir = "/usr/local/cuda"
+
+// nvccReleasePattern finds the CUDA version in `nvcc --version`, e.g.
+// "release 12.6, V12.6.77".
+var nvccReleasePattern = regexp.MustCompile(`release (\d+\.\d+)`)
+
+// NvidiaGPUs returns the NVIDIA GPUs lspci finds, or nil when there are
+// none.
+func NvidiaGPUs() []string {
+	output, err := exec.Command("lspci").Output()
+	if err != nil {
+		return nil
+	}
+	var gpus []string
+	for _, line := range strings.Split(string(output), "\n") {
+		lower := strings.ToLower(line)
+		if strings.Contains(lower, "nvidia") && (strings.Contains(lower, "vga") || strings.Contains(lower, "3d controller")) {
+			gpus = append(gpus, strings.TrimSpace(line))
+		}
+	}
+	return gpus
+}
+
+// checkGPU refuses the nvidia package on hosts without an NVIDIA GPU, before
+// any driver is downloaded.
+func checkGPU() error {
+	if len(NvidiaGPUs()) == 0 {
+		return fmt.Errorf("no NVIDIA GPU found (lspci); choose a GPU VM size such as Azure NC or ND series")
+	}
+	return nil
+}
+
+// setupNvidia puts the CUDA toolkit on PATH once it is installed.
+func setupNvidia() error {
+	if _, err := os.Stat(cudaDir + "/bin"); err != nil {
+		return nil
+	}
+	env, err := LoadManagedEnv()
+	if err != nil {
+		return err
+	}
+	env.AddPath(cudaDir + "/bin")
+	return env.Save()
+}
+
+// checkNvidia reports whether a GPU is present, the driver talks to it and
+// the CUDA toolkit is installed.
+func checkNvidia() []CheckResult {
+	gpus := NvidiaGPUs()
+	if len(gpus) == 0 {
+		return []CheckResult{{Name: "gpu", OK: false, Message: "no NVIDIA GPU found"}}
+	}
+	results := []CheckResult{{Name: "gpu", OK: true, Message: fmt.Sprintf("%d found", len(gpus))}}
+
+	driver := CheckResult{Name: "driver"}
+	output, err := exec.Command("nvidia-smi", "--query-gpu=name,driver_version,memory.used,memory.total,temperature.gpu", "--format=csv,noheader").Output()
+	switch {
+	case err == nil:
+		driver.OK = true
+		driver.Message = strings.Join(strings.Split(strings.TrimSpace(string(output)), "\n"), "; ")
+	case rebootRequiredFor("nvidia"):
+		driver.Message = "installed, reboot to load it: sudo reboot"
+	default:
+		if _, lookErr := exec.LookPath("nvidia-smi"); lookErr != nil {
+			driver.Message = "not installed"
+		} else {
+			// Usually Secure Boot rejecting the unsigned module, or a kernel
+			// update without a rebuilt module
+			driver.Message = "nvidia-smi cannot reach the driver; check Secure Boot and `sudo dkms status`"
+		}
+	}
+	results = append(results, driver)
+
+	cuda := CheckResult{Name: "cuda", Message: "toolkit not installed"}
+	if output, err := exec.Command(cudaDir+"/bin/nvcc", "--version").Output(); err == nil {
+		cuda.OK = true
+		cuda.Message = "installed"
+		if match := nvccReleasePattern.FindStringSubmatch(string(output)); match != nil {
+			cuda.Message = match[1]
+		}
+	}
+	return append(results, cuda)
+}
@@ -0,0 +1,49 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// actionsData escapes the message of a GitHub Actions workflow command.
+var actionsData = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+
+// actionsProperty escapes a property value of a workflow command.
+var actionsProperty = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+
+// ActionsGroupStart returns the workflow command that opens a collapsible
+// log group in GitHub Actions.
+func ActionsGroupStart(name string) string {
+	return "::group::" + actionsData.Replace(name)
+}
+
+// ActionsGroupEnd is the workflow command that closes a log group.
+const ActionsGroupEnd = "::endgroup::"
+
+// RenderAnnotations writes a GitHub Actions annotation for each result: an
+// error for failures, so they show on the workflow run, and a notice for
+// the rest. Warnings become warning annotations.
+func RenderAnnotations(w io.Writer, results []PackageResult) {
+	for _, result := range results {
+		title := actionsProperty.Replace(fmt.Sprintf("%s %s", result.Operation, result.Package))
+		failedDetail := false
+		for _, detail := range result.Details {
+			if !detail.OK {
+				failedDetail = true
+				fmt.Fprintf(w, "::error title=%s::%s\n", title, actionsData.Replace(detail.Name+": "+detail.Message))
+			}
+		}
+		switch {
+		case result.Status == StatusFailed && !failedDetail:
+			fmt.Fprintf(w, "::error title=%s::%s\n", title, actionsData.Replace(result.describe()))
+		case result.Status == StatusSkipped:
+			fmt.Fprintf(w, "::warning title=%s::%s\n", title, actionsData.Replace(result.describe()))
+		case result.Status == StatusOK:
+			fmt.Fprintf(w, "::notice title=%s::%s\n", title, actionsData.Replace(result.describe()))
+		}
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(w, "::warning title=%s::%s\n", title, actionsData.Replace(warning))
+		}
+	}
+}
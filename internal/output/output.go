@@ -0,0 +1,224 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Formats lists the supported values of the --format flag of commands that
+// report package results.
+var Formats = []string{"text", "json", "markdown"}
+
+// Status is the outcome of an operation on a package.
+type Status string
+
+const (
+	StatusOK      Status = "ok"
+	StatusFailed  Status = "failed"
+	StatusSkipped Status = "skipped"
+)
+
+// Detail is one verified item of a package, such as a single check.
+type Detail struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message"`
+	// LatencyMS is the response time of health probes.
+	LatencyMS int64 `json:"latency_ms,omitempty"`
+}
+
+// PackageResult is the outcome of installing, removing or checking one package.
+type PackageResult struct {
+	Package   string        `json:"package"`
+	Operation string        `json:"operation"`
+	Status    Status        `json:"status"`
+	Message   string        `json:"message,omitempty"`
+	Version   string        `json:"version,omitempty"`
+	Duration  time.Duration `json:"-"`
+	Details   []Detail      `json:"details,omitempty"`
+	NextSteps []string      `json:"next_steps,omitempty"`
+	// Warnings are problems that did not fail the operation.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// MarshalJSON adds the duration in seconds.
+func (r PackageResult) MarshalJSON() ([]byte, error) {
+	type plain PackageResult
+	return json.Marshal(struct {
+		plain
+		Seconds float64 `json:"seconds,omitempty"`
+	}{plain(r), r.Duration.Round(time.Millisecond).Seconds()})
+}
+
+// Failed reports how many results did not succeed. Failed details of a
+// successful result count individually.
+func Failed(results []PackageResult) int {
+	failed := 0
+	for _, result := range results {
+		if result.Status == StatusFailed && len(result.Details) == 0 {
+			failed++
+		}
+		for _, detail := range result.Details {
+			if !detail.OK {
+				failed++
+			}
+		}
+	}
+	return failed
+}
+
+// Warnings counts the warnings of all results.
+func Warnings(results []PackageResult) int {
+	warnings := 0
+	for _, result := range results {
+		warnings += len(result.Warnings)
+	}
+	return warnings
+}
+
+// ValidateFormat returns an error for unsupported formats.
+func ValidateFormat(format string) error {
+	for _, supported := range Formats {
+		if format == supported {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown format '%s': use %s", format, strings.Join(Formats, ", "))
+}
+
+// Render writes the results in the given format.
+func Render(w io.Writer, format string, results []PackageResult) error {
+	switch format {
+	case "text":
+		renderText(w, results)
+	case "json":
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(data))
+	case "markdown":
+		renderMarkdown(w, results)
+	default:
+		return ValidateFormat(format)
+	}
+	return nil
+}
+
+func icon(ok bool) string {
+	if ok {
+		return "✅"
+	}
+	return "❌"
+}
+
+func (r PackageResult) icon() string {
+	if r.Status == StatusSkipped {
+		return "⏭️ "
+	}
+	return icon(r.Status == StatusOK)
+}
+
+// Elapsed returns the rounded duration, or an empty string when it was too
+// short to matter.
+func (r PackageResult) Elapsed() string {
+	if rounded := r.Duration.Round(100 * time.Millisecond); rounded > 0 {
+		return rounded.String()
+	}
+	return ""
+}
+
+func (r PackageResult) describe() string {
+	message := r.Message
+	if message == "" {
+		message = string(r.Status)
+	}
+	if r.Version != "" {
+		message += " " + r.Version
+	}
+	if elapsed := r.Elapsed(); elapsed != "" {
+		message += fmt.Sprintf(" (%s)", elapsed)
+	}
+	return message
+}
+
+// renderText prints detailed results grouped by package, and other results
+// as a one-line-per-package summary.
+func renderText(w io.Writer, results []PackageResult) {
+	defer renderWarnings(w, results)
+	var summary []PackageResult
+	for _, result := range results {
+		if len(result.Details) == 0 {
+			summary = append(summary, result)
+			continue
+		}
+		fmt.Fprintf(w, "%s:\n", result.Package)
+		for _, detail := range result.Details {
+			fmt.Fprintf(w, "  %s %s: %s\n", icon(detail.OK), detail.Name, detail.Message)
+		}
+	}
+
+	if len(summary) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Summary:")
+	for _, result := range summary {
+		fmt.Fprintf(w, "  %s %s: %s\n", result.icon(), result.Package, result.describe())
+	}
+
+	printedHeader := false
+	for _, result := range summary {
+		if len(result.NextSteps) == 0 {
+			continue
+		}
+		if !printedHeader {
+			fmt.Fprintln(w, "\nNext steps:")
+			printedHeader = true
+		}
+		fmt.Fprintf(w, "  %s:\n", result.Package)
+		for _, step := range result.NextSteps {
+			fmt.Fprintf(w, "    • %s\n", step)
+		}
+	}
+}
+
+// renderWarnings prints the warnings of all results as one section, so
+// they are not lost in the output of the operations.
+func renderWarnings(w io.Writer, results []PackageResult) {
+	if Warnings(results) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nWarnings:")
+	for _, result := range results {
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(w, "  ⚠️  %s: %s\n", result.Package, warning)
+		}
+	}
+}
+
+func renderMarkdown(w io.Writer, results []PackageResult) {
+	fmt.Fprintln(w, "| Package | Operation | Status | Details | Duration |")
+	fmt.Fprintln(w, "|---|---|---|---|---|")
+	for _, result := range results {
+		details := []string{}
+		if result.Message != "" {
+			details = append(details, result.Message)
+		}
+		for _, detail := range result.Details {
+			details = append(details, fmt.Sprintf("%s %s: %s", icon(detail.OK), detail.Name, detail.Message))
+		}
+		for _, warning := range result.Warnings {
+			details = append(details, "⚠️ "+warning)
+		}
+		fmt.Fprintf(w, "| %s | %s | %s %s | %s | %s |\n",
+			markdownEscape(result.Package), result.Operation, result.icon(), result.Status,
+			markdownEscape(strings.Join(details, "<br>")), result.Elapsed())
+	}
+}
+
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
@@ -0,0 +1,133 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"sync"
+)
+
+// prefixColors are the ANSI colors prefixes are drawn in, picked per name so
+// a package keeps its color across runs.
+var prefixColors = []string{"36", "32", "33", "35", "34", "31"}
+
+// ColorEnabled reports whether w is a terminal that should get colors,
+// honouring NO_COLOR.
+func ColorEnabled(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return isTerminal(w)
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorFor returns the ANSI color of a name.
+func colorFor(name string) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(name))
+	return prefixColors[hash.Sum32()%uint32(len(prefixColors))]
+}
+
+// PrefixWriter writes each line it receives to w as "[name] line", so the
+// output of several packages stays readable when interleaved. Partial lines
+// are held until their newline arrives or Flush is called.
+type PrefixWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+// NewPrefixWriter returns a PrefixWriter for name, with the prefix in the
+// name's color when color is set.
+func NewPrefixWriter(w io.Writer, name string, color bool) *PrefixWriter {
+	prefix := "[" + name + "] "
+	if color {
+		prefix = fmt.Sprintf("\033[%sm[%s]\033[0m ", colorFor(name), name)
+	}
+	return &PrefixWriter{w: w, prefix: prefix}
+}
+
+func (p *PrefixWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		if _, err := fmt.Fprintf(p.w, "%s%s\n", p.prefix, p.buf[:i]); err != nil {
+			return len(b), err
+		}
+		p.buf = p.buf[i+1:]
+	}
+}
+
+// Flush writes a trailing line that has no newline.
+func (p *PrefixWriter) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.buf) == 0 {
+		return nil
+	}
+	_, err := fmt.Fprintf(p.w, "%s%s\n", p.prefix, p.buf)
+	p.buf = nil
+	return err
+}
+
+// GroupWriter collects the output of one package and writes it to w as a
+// single block on Flush, like a GitHub Actions log group.
+type GroupWriter struct {
+	mu    sync.Mutex
+	w     io.Writer
+	name  string
+	color bool
+	buf   bytes.Buffer
+}
+
+// NewGroupWriter returns a GroupWriter for name, with the header in the
+// name's color when color is set.
+func NewGroupWriter(w io.Writer, name string, color bool) *GroupWriter {
+	return &GroupWriter{w: w, name: name, color: color}
+}
+
+func (g *GroupWriter) Write(b []byte) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.buf.Write(b)
+}
+
+// Flush writes the collected output under a header naming the package.
+func (g *GroupWriter) Flush() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	header := "▼ " + g.name
+	if g.color {
+		header = fmt.Sprintf("\033[%sm%s\033[0m", colorFor(g.name), header)
+	}
+	if _, err := fmt.Fprintln(g.w, header); err != nil {
+		return err
+	}
+	if g.buf.Len() > 0 {
+		if _, err := g.w.Write(g.buf.Bytes()); err != nil {
+			return err
+		}
+		if !bytes.HasSuffix(g.buf.Bytes(), []byte("\n")) {
+			fmt.Fprintln(g.w)
+		}
+	}
+	g.buf.Reset()
+	return nil
+}
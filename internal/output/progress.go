@@ -0,0 +1,65 @@
+package output
+
+import (
+	"fmt"
+	"io"
+)
+
+// progressStep is how far a percentage must move before it is printed again
+// when the output is not a terminal, so logs get a line per step rather than
+// one per update.
+const progressStep = 10
+
+// Progress shows the percentage of a long-running operation. On a terminal
+// it redraws a single line; elsewhere it prints a line every progressStep
+// percent.
+type Progress struct {
+	w       io.Writer
+	tty     bool
+	drawn   bool
+	printed int
+}
+
+// NewProgress returns a Progress writing to w.
+func NewProgress(w io.Writer) *Progress {
+	return &Progress{w: w, tty: isTerminal(w), printed: -progressStep}
+}
+
+// Update shows percent (0 to 100) with a label of the current step.
+func (p *Progress) Update(percent float64, label string) {
+	if percent < 0 {
+		percent = 0
+	} else if percent > 100 {
+		percent = 100
+	}
+	if p.tty {
+		fmt.Fprintf(p.w, "\r\033[K[%3.0f%%] %s", percent, label)
+		p.drawn = true
+		return
+	}
+	if int(percent) < p.printed {
+		// A new phase, such as unpacking after downloading, starts over
+		p.printed = -progressStep
+	}
+	if int(percent)-p.printed >= progressStep {
+		p.printed = int(percent) / progressStep * progressStep
+		fmt.Fprintf(p.w, "[%3.0f%%] %s\n", percent, label)
+	}
+}
+
+// Clear removes the progress line from a terminal so other output can be
+// printed; the next Update draws it again.
+func (p *Progress) Clear() {
+	if p.drawn {
+		fmt.Fprint(p.w, "\r\033[K")
+		p.drawn = false
+	}
+}
+
+// Done ends the progress line.
+func (p *Progress) Done() {
+	if p.drawn {
+		fmt.Fprintln(p.w)
+		p.drawn = false
+	}
+}
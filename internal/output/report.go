@@ -0,0 +1,139 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Report describes a provisioning run for `--report`, to be attached to
+// change tickets.
+type Report struct {
+	Command   string
+	Hostname  string
+	OS        string
+	Kernel    string
+	Arch      string
+	StartedAt time.Time
+	Duration  time.Duration
+	Results   []PackageResult
+	// LogsDir is where run keeps its logs, if it exists.
+	LogsDir string
+}
+
+// Warnings returns one line for each package that failed or was skipped,
+// and for each warning.
+func (r Report) Warnings() []string {
+	var warnings []string
+	for _, result := range r.Results {
+		if result.Status != StatusOK {
+			warnings = append(warnings, fmt.Sprintf("%s (%s): %s", result.Package, result.Status, result.Message))
+		}
+		for _, warning := range result.Warnings {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", result.Package, warning))
+		}
+	}
+	return warnings
+}
+
+// WriteReport writes the report as HTML when path ends in .html or .htm,
+// and as markdown otherwise.
+func WriteReport(path string, report Report) error {
+	var data []byte
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		var buf bytes.Buffer
+		if err := htmlReport.Execute(&buf, report); err != nil {
+			return fmt.Errorf("failed to render report: %v", err)
+		}
+		data = buf.Bytes()
+	default:
+		data = markdownReport(report)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write report %s: %v", path, err)
+	}
+	return nil
+}
+
+func markdownReport(report Report) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# Provisioning report: %s\n\n", report.Hostname)
+	fmt.Fprintln(&b, "| | |")
+	fmt.Fprintln(&b, "|---|---|")
+	fmt.Fprintf(&b, "| Command | `%s` |\n", markdownEscape(report.Command))
+	fmt.Fprintf(&b, "| Host | %s |\n", report.Hostname)
+	fmt.Fprintf(&b, "| OS | %s |\n", report.OS)
+	fmt.Fprintf(&b, "| Kernel | %s (%s) |\n", report.Kernel, report.Arch)
+	fmt.Fprintf(&b, "| Started | %s |\n", report.StartedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "| Duration | %s |\n", report.Duration.Round(time.Second))
+
+	fmt.Fprintln(&b, "\n## Packages")
+	fmt.Fprintln(&b, "| Package | Status | Version | Duration | Details |")
+	fmt.Fprintln(&b, "|---|---|---|---|---|")
+	for _, result := range report.Results {
+		fmt.Fprintf(&b, "| %s | %s %s | %s | %s | %s |\n",
+			markdownEscape(result.Package), result.icon(), result.Status, result.Version,
+			result.Elapsed(), markdownEscape(result.Message))
+	}
+
+	fmt.Fprintln(&b, "\n## Warnings")
+	warnings := report.Warnings()
+	if len(warnings) == 0 {
+		fmt.Fprintln(&b, "None.")
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(&b, "- %s\n", warning)
+	}
+
+	if report.LogsDir != "" {
+		fmt.Fprintln(&b, "\n## Logs")
+		fmt.Fprintf(&b, "Logs are in [%s](file://%s).\n", report.LogsDir, report.LogsDir)
+	}
+	return b.Bytes()
+}
+
+var htmlReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	"rfc3339": func(t time.Time) string { return t.Format(time.RFC3339) },
+	"seconds": func(d time.Duration) string { return d.Round(time.Second).String() },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Provisioning report: {{.Hostname}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+.ok { color: #1a7f37; } .failed { color: #cf222e; } .skipped { color: #9a6700; }
+</style>
+</head>
+<body>
+<h1>Provisioning report: {{.Hostname}}</h1>
+<table>
+<tr><th>Command</th><td><code>{{.Command}}</code></td></tr>
+<tr><th>Host</th><td>{{.Hostname}}</td></tr>
+<tr><th>OS</th><td>{{.OS}}</td></tr>
+<tr><th>Kernel</th><td>{{.Kernel}} ({{.Arch}})</td></tr>
+<tr><th>Started</th><td>{{rfc3339 .StartedAt}}</td></tr>
+<tr><th>Duration</th><td>{{seconds .Duration}}</td></tr>
+</table>
+<h2>Packages</h2>
+<table>
+<tr><th>Package</th><th>Status</th><th>Version</th><th>Duration</th><th>Details</th></tr>
+{{range .Results}}<tr><td>{{.Package}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{.Version}}</td><td>{{.Elapsed}}</td><td>{{.Message}}</td></tr>
+{{end}}</table>
+<h2>Warnings</h2>
+{{with .Warnings}}<ul>
+{{range .}}<li>{{.}}</li>
+{{end}}</ul>{{else}}<p>None.</p>{{end}}
+{{if .LogsDir}}<h2>Logs</h2>
+<p>Logs are in <a href="file://{{.LogsDir}}">{{.LogsDir}}</a>.</p>
+{{end}}</body>
+</html>
+`))
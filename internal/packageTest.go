@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DefaultTestImage is the image packages are tested in by default.
+const DefaultTestImage = "ubuntu:24.04"
+
+// PackageTestSteps are the commands `run test-package` runs in the
+// container, in order.
+var PackageTestSteps = []string{"install", "check", "remove"}
+
+// Where the run binary, scripts and user overlay are mounted in the test
+// container.
+const (
+	testBinaryPath  = "/usr/local/bin/run"
+	testScriptsPath = "/opt/run-test/scripts"
+	testOverlayPath = "/root/.run/registry/user.yaml"
+)
+
+// testPackages are the tools an Ubuntu server image has but the container
+// image lacks, which package scripts expect.
+var testPackages = []string{"ca-certificates", "curl", "gnupg", "lsb-release"}
+
+// PackageTestOptions configures a test of a package in a container.
+type PackageTestOptions struct {
+	// Image is the Ubuntu image to test in; DefaultTestImage when empty.
+	Image string
+	// Privileged runs the container privileged, as docker needs.
+	Privileged bool
+}
+
+// StartTestContainer starts a disposable container to test a package in,
+// with this run binary, the scripts directory in use and the user's
+// registry overlay mounted read-only, and the tools scripts expect
+// installed. It returns the container name.
+func StartTestContainer(packageName string, options PackageTestOptions) (string, error) {
+	if _, exists := InstallPackageRegistry[packageName]; !exists {
+		return "", fmt.Errorf("unknown package '%s'", packageName)
+	}
+	if runtime.GOOS != "linux" {
+		return "", fmt.Errorf("testing packages needs the Linux build of run, to mount into the container")
+	}
+	if err := RequireOnline(); err != nil {
+		return "", err
+	}
+	if _, err := dockerCommand("version", "--format", "{{.Server.Version}}").CaptureOutput(); err != nil {
+		return "", fmt.Errorf("docker is not available: %v. Install it with: run install docker", err)
+	}
+	binary, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if binary, err = filepath.EvalSymlinks(binary); err != nil {
+		return "", err
+	}
+
+	image := options.Image
+	if image == "" {
+		image = DefaultTestImage
+	}
+	name := fmt.Sprintf("run-test-%s-%d", packageName, time.Now().Unix())
+	args := []string{"run", "--detach", "--name", name, "--label", "run.test-package=" + packageName,
+		"--volume", binary + ":" + testBinaryPath + ":ro"}
+	if custom := CustomScriptsDir(); custom != "" {
+		dir, err := filepath.Abs(custom)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, "--volume", dir+":"+testScriptsPath+":ro", "--env", ScriptsDirEnv+"="+testScriptsPath)
+	}
+	if paths, err := RegistryOverlayPaths(); err == nil {
+		overlay := paths[len(paths)-1]
+		if _, err := os.Stat(overlay); err == nil {
+			args = append(args, "--volume", overlay+":"+testOverlayPath+":ro")
+		}
+	}
+	if options.Privileged {
+		args = append(args, "--privileged")
+	}
+	args = append(args, image, "sleep", "infinity")
+
+	fmt.Fprintf(Console, "Starting %s in %s...\n", image, name)
+	if err := dockerCommand(args...).Stream(Console, os.Stderr); err != nil {
+		return "", fmt.Errorf("failed to start a container from %s: %v", image, err)
+	}
+	prepare := "apt-get update -qq && apt-get install -y -qq " + strings.Join(testPackages, " ")
+	if err := dockerCommand("exec", "--env", "DEBIAN_FRONTEND=noninteractive", name, "sh", "-c", prepare).Stream(Console, os.Stderr); err != nil {
+		RemoveTestContainer(name)
+		return "", fmt.Errorf("failed to prepare %s: %v", name, err)
+	}
+	return name, nil
+}
+
+// RunTestStep runs `run <step> <package>` in a test container, streaming
+// its output. env is added to its environment, as KEY=value.
+func RunTestStep(container, step, packageName string, env []string) error {
+	args := []string{"exec", "--env", "DEBIAN_FRONTEND=noninteractive"}
+	for _, variable := range env {
+		args = append(args, "--env", variable)
+	}
+	args = append(args, container, testBinaryPath, "--yes", step, packageName)
+	return dockerCommand(args...).Stream(Console, os.Stderr)
+}
+
+// RemoveTestContainer stops and removes a test container.
+func RemoveTestContainer(container string) error {
+	if _, err := dockerCommand("rm", "--force", container).CaptureOutput(); err != nil {
+		return fmt.Errorf("failed to remove container %s: %v", container, err)
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// PackageVersions records the versions of a package installed side by side
+// and what depends on each.
+type PackageVersions struct {
+	Installed []string `json:"installed"`
+	Active    string   `json:"active,omitempty"`
+	// Consumers map apps to the version they target: pm2:<app>,
+	// nginx:<site> or service:<name>.
+	Consumers map[string]string `json:"consumers,omitempty"`
+}
+
+// versionedPackages extract the version an apt package belongs to: from its
+// name for packages installed side by side, from its version for node.
+var versionedPackages = map[string]struct {
+	pattern  *regexp.Regexp
+	fromName bool
+}{
+	"java": {regexp.MustCompile(`-(\d+)-`), true},
+	"node": {regexp.MustCompile(`^(\d+)\.`), false},
+	"php":  {regexp.MustCompile(`^php(\d+\.\d+)`), true},
+}
+
+// IsVersionedPackage reports whether installed versions of a package are
+// tracked.
+func IsVersionedPackage(packageName string) bool {
+	_, exists := versionedPackages[packageName]
+	return exists
+}
+
+// aptPackagesByVersion groups the apt packages of a package by the version
+// they belong to.
+func aptPackagesByVersion(packageName string) map[string][]string {
+	versioned := versionedPackages[packageName]
+	byVersion := make(map[string][]string)
+	for _, pkg := range InstalledAptPackages(packageName) {
+		source := pkg.Version
+		if versioned.fromName {
+			source = pkg.Name
+		}
+		if match := versioned.pattern.FindStringSubmatch(source); match != nil {
+			byVersion[match[1]] = append(byVersion[match[1]], pkg.Name)
+		}
+	}
+	return byVersion
+}
+
+// ListInstalledVersions returns the versions of a package installed through
+// apt, e.g. the Java majors or PHP major.minor versions.
+func ListInstalledVersions(packageName string) []string {
+	versions := mapKeys(aptPackagesByVersion(packageName))
+	sort.Slice(versions, func(i, j int) bool { return compareVersions(versions[i], versions[j]) < 0 })
+	return versions
+}
+
+// compareVersions orders dotted numeric versions such as "8.1" and "8.10".
+func compareVersions(a, b string) int {
+	left, right := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(left) && i < len(right); i++ {
+		l, _ := strconv.Atoi(left[i])
+		r, _ := strconv.Atoi(right[i])
+		if l != r {
+			return l - r
+		}
+	}
+	return len(left) - len(right)
+}
+
+// activeVersion returns the version of a package in use by default.
+func activeVersion(packageName string) string {
+	switch packageName {
+	case "java":
+		home, err := ActiveJavaHome()
+		if err != nil {
+			return ""
+		}
+		if match := jdkMajorPattern.FindStringSubmatch(filepath.Base(home)); match != nil {
+			return match[1]
+		}
+	case "node":
+		if version := PackageVersion("node"); version != "" {
+			return strings.SplitN(version, ".", 2)[0]
+		}
+	case "php":
+		version, _ := ActivePHPVersion()
+		return version
+	}
+	return ""
+}
+
+var (
+	phpFPMSocketPattern = regexp.MustCompile(`php(\d+\.\d+)-fpm\.sock`)
+	phpBinaryPattern    = regexp.MustCompile(`/usr/bin/php(\d+\.\d+)`)
+)
+
+// versionConsumers maps the apps that target a version of a package to that
+// version: pm2 apps by their node version, nginx sites by the PHP-FPM
+// socket they pass to, and services by the JDK or PHP binary they run.
+func versionConsumers(packageName string) map[string]string {
+	consumers := make(map[string]string)
+	switch packageName {
+	case "node":
+		for app, version := range pm2NodeVersions() {
+			consumers["pm2:"+app] = version
+		}
+	case "php":
+		sites, _ := filepath.Glob("/etc/nginx/sites-enabled/*")
+		for _, site := range sites {
+			data, err := os.ReadFile(site)
+			if err != nil {
+				continue
+			}
+			if match := phpFPMSocketPattern.FindSubmatch(data); match != nil {
+				consumers["nginx:"+filepath.Base(site)] = string(match[1])
+			}
+		}
+	}
+
+	state, err := LoadState()
+	if err != nil {
+		return consumers
+	}
+	for name, service := range state.Services {
+		var match []string
+		switch packageName {
+		case "java":
+			if strings.HasPrefix(service.Exec, jvmDir+"/") {
+				match = jdkMajorPattern.FindStringSubmatch(strings.SplitN(strings.TrimPrefix(service.Exec, jvmDir+"/"), "/", 2)[0])
+			}
+		case "php":
+			match = phpBinaryPattern.FindStringSubmatch(service.Exec)
+		}
+		if match != nil {
+			consumers["service:"+name] = match[1]
+		}
+	}
+	return consumers
+}
+
+// pm2NodeVersions returns the node major version each pm2 app of the app
+// user runs on.
+func pm2NodeVersions() map[string]string {
+	versions := make(map[string]string)
+	user, err := appUser()
+	if err != nil {
+		return versions
+	}
+	cmd := system.Command("pm2", "jlist")
+	if !isCurrentUser(user) {
+		cmd = cmd.AsUser(user)
+	}
+	output, err := cmd.CaptureOutput()
+	if err != nil {
+		return versions
+	}
+	var apps []struct {
+		Name string `json:"name"`
+		Env  struct {
+			NodeVersion string `json:"node_version"`
+		} `json:"pm2_env"`
+	}
+	if err := json.Unmarshal(output, &apps); err != nil {
+		return versions
+	}
+	for _, app := range apps {
+		if app.Env.NodeVersion != "" {
+			versions[app.Name] = strings.SplitN(app.Env.NodeVersion, ".", 2)[0]
+		}
+	}
+	return versions
+}
+
+// InspectVersions returns the installed versions of a package, the active
+// one and their consumers.
+func InspectVersions(packageName string) PackageVersions {
+	return PackageVersions{
+		Installed: ListInstalledVersions(packageName),
+		Active:    activeVersion(packageName),
+		Consumers: versionConsumers(packageName),
+	}
+}
+
+// RecordVersions stores the installed versions of a package in the state
+// file. Packages whose versions are not tracked are ignored.
+func RecordVersions(packageName string) error {
+	if !IsVersionedPackage(packageName) {
+		return nil
+	}
+	versions := InspectVersions(packageName)
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	if len(versions.Installed) == 0 {
+		delete(state.Versions, packageName)
+	} else {
+		if state.Versions == nil {
+			state.Versions = make(map[string]PackageVersions)
+		}
+		state.Versions[packageName] = versions
+	}
+	return state.Save()
+}
+
+// RemoveVersion removes one version of a package, keeping the others. It
+// refuses while apps still target the version, or while it is the active
+// version and others are installed.
+func RemoveVersion(packageName, version string) error {
+	if !IsVersionedPackage(packageName) {
+		return fmt.Errorf("versions are not tracked for package '%s'", packageName)
+	}
+	aptPackages := aptPackagesByVersion(packageName)[version]
+	if len(aptPackages) == 0 {
+		return fmt.Errorf("%s %s is not installed", packageName, version)
+	}
+
+	versions := InspectVersions(packageName)
+	var consumers []string
+	for _, consumer := range mapKeys(versions.Consumers) {
+		if versions.Consumers[consumer] == version {
+			consumers = append(consumers, consumer)
+		}
+	}
+	if len(consumers) > 0 {
+		return fmt.Errorf("%s %s is still used by %s; move them to another version first", packageName, version, strings.Join(consumers, ", "))
+	}
+	if version == versions.Active && len(versions.Installed) > 1 {
+		return fmt.Errorf("%s %s is the active version; switch first with: run use %s <version>", packageName, version, packageName)
+	}
+
+	args := append([]string{"purge", "-y"}, aptPackages...)
+	if err := runRootStreaming("apt-get", args...); err != nil {
+		return fmt.Errorf("failed to remove %s %s: %v", packageName, version, err)
+	}
+	return RecordVersions(packageName)
+}
@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// releaseURL is where release archives are downloaded from.
+const releaseURL = "https://github.com/amoga-io/run/releases/download"
+
+// homebrewFormula is the formula of the Homebrew tap. run manages Ubuntu
+// hosts, so only Linux archives are listed.
+var homebrewFormula = template.Must(template.New("run.rb").Parse(`class Run < Formula
+  desc "Ubuntu server package manager for development and production tools"
+  homepage "https://github.com/amoga-io/run"
+  version "{{ .Version }}"
+
+  on_linux do
+    on_intel do
+      url "{{ .URL }}/v{{ .Version }}/{{ .AMD64 }}"
+      sha256 "{{ index .Checksums .AMD64 }}"
+    end
+    on_arm do
+      url "{{ .URL }}/v{{ .Version }}/{{ .ARM64 }}"
+      sha256 "{{ index .Checksums .ARM64 }}"
+    end
+  end
+
+  def install
+    bin.install "run"
+    (share/"run").install "share/run/scripts"
+  end
+
+  test do
+    system bin/"run", "verify"
+  end
+end
+`))
+
+// ReadChecksums reads a checksums.txt of sha256 sums, keyed by file name.
+func ReadChecksums(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checksums: %v", err)
+	}
+	defer file.Close()
+
+	checksums := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 {
+			checksums[strings.TrimPrefix(fields[1], "*")] = fields[0]
+		}
+	}
+	return checksums, scanner.Err()
+}
+
+// HomebrewFormula renders the formula of a release from its checksums, as
+// written by goreleaser (.goreleaser.yaml).
+func HomebrewFormula(version string, checksums map[string]string) (string, error) {
+	version = strings.TrimPrefix(version, "v")
+	data := struct {
+		Version, URL, AMD64, ARM64 string
+		Checksums                  map[string]string
+	}{
+		Version:   version,
+		URL:       releaseURL,
+		AMD64:     fmt.Sprintf("run_%s_linux_amd64.tar.gz", version),
+		ARM64:     fmt.Sprintf("run_%s_linux_arm64.tar.gz", version),
+		Checksums: checksums,
+	}
+	for _, archive := range []string{data.AMD64, data.ARM64} {
+		if checksums[archive] == "" {
+			return "", fmt.Errorf("no checksum for %s: is %s the version that was released?", archive, version)
+		}
+	}
+	var formula strings.Builder
+	if err := homebrewFormula.Execute(&formula, data); err != nil {
+		return "", err
+	}
+	return formula.String(), nil
+}
+
+// PackageManagerUpgrade returns the command that upgrades run when it was
+// installed by a package manager, which `run update` must not overwrite, or
+// an empty string.
+func PackageManagerUpgrade() string {
+	executable, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+	switch {
+	case strings.Contains(executable, "/Cellar/"):
+		return "brew upgrade run"
+	case executable == "/usr/bin/"+CLIName:
+		if _, err := exec.LookPath("dnf"); err == nil {
+			return "sudo dnf upgrade run"
+		}
+		return "sudo apt-get install --only-upgrade run"
+	}
+	return ""
+}
@@ -0,0 +1,208 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// phpModuleNames maps extension package suffixes to the module name reported
+// by `php -m` when the two differ.
+var phpModuleNames = map[string]string{
+	"mysql":   "mysqli",
+	"opcache": "zend opcache",
+}
+
+// phpSourcePrefix is where php.sh installs the versions it builds from
+// source, when the PPA has none for the Ubuntu release.
+const phpSourcePrefix = "/opt/php"
+
+// isPHPSourceBuild reports whether a PHP version was built from source by
+// php.sh. Such builds have no php<version>-<ext> packages: their extensions
+// are the ones compiled in.
+func isPHPSourceBuild(version string) bool {
+	target, err := os.Readlink("/usr/bin/php" + version)
+	return err == nil && strings.HasPrefix(filepath.Clean(target), phpSourcePrefix+"/")
+}
+
+// ActivePHPVersion returns the major.minor version of the php binary in PATH.
+func ActivePHPVersion() (string, error) {
+	output, err := exec.Command("php", "-r", `echo PHP_MAJOR_VERSION.".".PHP_MINOR_VERSION;`).Output()
+	if err != nil {
+		return "", fmt.Errorf("php is not installed. Install it with: run install php")
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// LoadedPHPModules returns the lower-cased module names reported by `php -m`.
+func LoadedPHPModules() (map[string]bool, error) {
+	return loadedPHPModules("php")
+}
+
+// loadedPHPModules returns the lower-cased module names of a php binary.
+func loadedPHPModules(binary string) (map[string]bool, error) {
+	output, err := exec.Command(binary, "-m").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list php modules: %v", err)
+	}
+
+	modules := make(map[string]bool)
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "[") {
+			continue
+		}
+		modules[strings.ToLower(line)] = true
+	}
+	return modules, nil
+}
+
+// IsPHPExtensionLoaded reports whether an extension appears in the modules
+// returned by LoadedPHPModules.
+func IsPHPExtensionLoaded(modules map[string]bool, ext string) bool {
+	if module, exists := phpModuleNames[ext]; exists {
+		return modules[module]
+	}
+	return modules[ext]
+}
+
+// AddPHPExtension installs php<version>-<ext> for the active PHP version,
+// restarts php-fpm, verifies the module loads and records it in state.
+func AddPHPExtension(ext string) error {
+	version, err := ActivePHPVersion()
+	if err != nil {
+		return err
+	}
+
+	if err := installPHPExtensions(version, []string{ext}); err != nil {
+		return err
+	}
+
+	modules, err := LoadedPHPModules()
+	if err != nil {
+		return err
+	}
+	if !IsPHPExtensionLoaded(modules, ext) {
+		return fmt.Errorf("extension '%s' was installed but is not loaded by php %s", ext, version)
+	}
+
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	if !containsString(state.PHPExtensions, ext) {
+		state.PHPExtensions = append(state.PHPExtensions, ext)
+		sort.Strings(state.PHPExtensions)
+	}
+	return state.Save()
+}
+
+// RemovePHPExtension uninstalls an extension from the active PHP version and
+// stops managing it.
+func RemovePHPExtension(ext string) error {
+	version, err := ActivePHPVersion()
+	if err != nil {
+		return err
+	}
+	if isPHPSourceBuild(version) {
+		return fmt.Errorf("php %s was built from source, with its extensions compiled in: '%s' cannot be removed", version, ext)
+	}
+
+	if err := runAptGet("remove", "-y", phpExtensionPackage(version, ext)); err != nil {
+		return fmt.Errorf("failed to remove extension '%s': %v", ext, err)
+	}
+
+	if err := restartPHPFPM(version); err != nil {
+		return err
+	}
+
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	state.PHPExtensions = removeString(state.PHPExtensions, ext)
+	return state.Save()
+}
+
+// EnsurePHPExtensions installs the managed extensions for a PHP version. It is
+// used after switching versions so the new version has the same extensions.
+func EnsurePHPExtensions(version string) error {
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	if len(state.PHPExtensions) == 0 {
+		return nil
+	}
+	fmt.Fprintf(Console, "Installing managed extensions for php %s: %s\n", version, strings.Join(state.PHPExtensions, ", "))
+	return installPHPExtensions(version, state.PHPExtensions)
+}
+
+// installPHPExtensions installs the php<version>-<ext> packages of
+// extensions. Versions built from source must have them compiled in.
+func installPHPExtensions(version string, exts []string) error {
+	if isPHPSourceBuild(version) {
+		return checkCompiledPHPExtensions(version, exts)
+	}
+	args := []string{"install", "-y"}
+	for _, ext := range exts {
+		args = append(args, phpExtensionPackage(version, ext))
+	}
+
+	if err := runAptGet(args...); err != nil {
+		return fmt.Errorf("failed to install php extensions: %v", err)
+	}
+
+	return restartPHPFPM(version)
+}
+
+// checkCompiledPHPExtensions returns an error unless a PHP version built
+// from source loads all of exts, as it has no packages to install them.
+func checkCompiledPHPExtensions(version string, exts []string) error {
+	modules, err := loadedPHPModules("php" + version)
+	if err != nil {
+		return err
+	}
+	var missing []string
+	for _, ext := range exts {
+		if !IsPHPExtensionLoaded(modules, ext) {
+			missing = append(missing, ext)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("php %s was built from source and has no php%s-<ext> packages: %s not compiled in. Install them with pecl, or use a version the PPA provides", version, version, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func phpExtensionPackage(version, ext string) string {
+	return fmt.Sprintf("php%s-%s", version, ext)
+}
+
+// restartPHPFPM restarts the php-fpm service of a version if it is installed.
+func restartPHPFPM(version string) error {
+	service := fmt.Sprintf("php%s-fpm", version)
+	if err := exec.Command("systemctl", "cat", service).Run(); err != nil {
+		return nil
+	}
+	if err := system.Command("systemctl", "restart", service).WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to restart %s: %v", service, err)
+	}
+	return nil
+}
+
+func usePHP(version string) error {
+	binary := "/usr/bin/php" + version
+	if _, err := os.Stat(binary); err != nil {
+		return fmt.Errorf("php %s is not installed", version)
+	}
+	if err := system.SetAlternative("php", binary); err != nil {
+		return err
+	}
+	return EnsurePHPExtensions(version)
+}
@@ -0,0 +1,226 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+var poolNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// defaultPoolListenOwner owns pool sockets unless --listen-owner is given,
+// so that nginx can connect to them.
+const defaultPoolListenOwner = "www-data"
+
+// PHPPool describes a php-fpm pool managed by run.
+type PHPPool struct {
+	Name        string
+	User        string
+	Group       string
+	Listen      string
+	ListenOwner string
+	MaxChildren int
+}
+
+// phpPoolDir returns the pool.d directory of a PHP version.
+func phpPoolDir(version string) string {
+	return filepath.Join("/etc/php", version, "fpm", "pool.d")
+}
+
+// DefaultPHPPoolSocket returns the socket path used when --listen is not given.
+func DefaultPHPPoolSocket(version, name string) string {
+	return fmt.Sprintf("/run/php/php%s-%s.sock", version, name)
+}
+
+// Render returns the pool configuration file content.
+func (p PHPPool) Render() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "; Managed by run - changes may be overwritten\n")
+	fmt.Fprintf(&b, "[%s]\n", p.Name)
+	fmt.Fprintf(&b, "user = %s\n", p.User)
+	fmt.Fprintf(&b, "group = %s\n", p.Group)
+	fmt.Fprintf(&b, "listen = %s\n", p.Listen)
+	fmt.Fprintf(&b, "listen.owner = %s\n", p.ListenOwner)
+	fmt.Fprintf(&b, "listen.group = %s\n", p.ListenOwner)
+	fmt.Fprintf(&b, "listen.mode = 0660\n")
+	fmt.Fprintf(&b, "\n")
+	fmt.Fprintf(&b, "pm = dynamic\n")
+	fmt.Fprintf(&b, "pm.max_children = %d\n", p.MaxChildren)
+	fmt.Fprintf(&b, "pm.start_servers = %d\n", max(1, p.MaxChildren/4))
+	fmt.Fprintf(&b, "pm.min_spare_servers = %d\n", max(1, p.MaxChildren/4))
+	fmt.Fprintf(&b, "pm.max_spare_servers = %d\n", max(1, p.MaxChildren/2))
+	fmt.Fprintf(&b, "pm.max_requests = 500\n")
+	fmt.Fprintf(&b, "\n")
+	fmt.Fprintf(&b, "catch_workers_output = yes\n")
+	fmt.Fprintf(&b, "php_admin_flag[log_errors] = on\n")
+	return b.String()
+}
+
+// checkPoolName refuses pool names that are not a plain file name, and the
+// distribution's default pool.
+func checkPoolName(name string) error {
+	if !poolNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid pool name '%s': use lowercase letters, digits, '-' and '_'", name)
+	}
+	if name == "www" {
+		return fmt.Errorf("pool 'www' is the distribution default and cannot be managed by run")
+	}
+	return nil
+}
+
+// checkPoolListen accepts an absolute socket path or a host:port address,
+// which are written to the pool config as they are.
+func checkPoolListen(listen string) error {
+	if strings.ContainsAny(listen, " \t\r\n;") {
+		return fmt.Errorf("invalid --listen '%s': it cannot contain spaces, newlines or ';'", listen)
+	}
+	if strings.HasPrefix(listen, "/") {
+		if filepath.Clean(listen) != listen || strings.HasSuffix(listen, "/") {
+			return fmt.Errorf("invalid --listen '%s': use a clean absolute socket path such as /run/php/shop.sock", listen)
+		}
+		return nil
+	}
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil || host == "" {
+		return fmt.Errorf("invalid --listen '%s': use an absolute socket path or host:port, such as 127.0.0.1:9001", listen)
+	}
+	if number, err := strconv.Atoi(port); err != nil || number < 1 || number > 65535 {
+		return fmt.Errorf("invalid --listen '%s': the port must be between 1 and 65535", listen)
+	}
+	return nil
+}
+
+// checkPool refuses pools whose user, group or socket owner does not exist,
+// or whose listen address is not one, before they reach the config file.
+func checkPool(pool PHPPool) error {
+	if _, err := user.Lookup(pool.User); err != nil {
+		return fmt.Errorf("invalid --user '%s': no such user", pool.User)
+	}
+	if _, err := user.LookupGroup(pool.Group); err != nil {
+		return fmt.Errorf("invalid --group '%s': no such group", pool.Group)
+	}
+	// The socket owner is also its group
+	if _, err := user.Lookup(pool.ListenOwner); err != nil {
+		return fmt.Errorf("invalid --listen-owner '%s': no such user", pool.ListenOwner)
+	}
+	if _, err := user.LookupGroup(pool.ListenOwner); err != nil {
+		return fmt.Errorf("invalid --listen-owner '%s': no group of that name", pool.ListenOwner)
+	}
+	return checkPoolListen(pool.Listen)
+}
+
+// CreatePHPPool writes a pool config for the active PHP version, validates the
+// php-fpm configuration and reloads the service. An invalid config is removed
+// again so php-fpm keeps running with the previous pools.
+func CreatePHPPool(pool PHPPool) error {
+	if err := checkPoolName(pool.Name); err != nil {
+		return err
+	}
+
+	version, err := ActivePHPVersion()
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(phpPoolDir(version)); err != nil {
+		return fmt.Errorf("php %s has no php-fpm pool directory %s; install php-fpm with: run install php", version, phpPoolDir(version))
+	}
+	poolPath := filepath.Join(phpPoolDir(version), pool.Name+".conf")
+	if _, err := os.Stat(poolPath); err == nil {
+		return fmt.Errorf("pool '%s' already exists at %s", pool.Name, poolPath)
+	}
+
+	if pool.Group == "" {
+		pool.Group = pool.User
+	}
+	if pool.ListenOwner == "" {
+		pool.ListenOwner = defaultPoolListenOwner
+	}
+	if pool.Listen == "" {
+		pool.Listen = DefaultPHPPoolSocket(version, pool.Name)
+	}
+	if pool.MaxChildren <= 0 {
+		pool.MaxChildren = 5
+	}
+	if err := checkPool(pool); err != nil {
+		return err
+	}
+
+	if err := system.WriteFileAsRoot(poolPath, []byte(pool.Render()), 0644); err != nil {
+		return err
+	}
+
+	if err := validatePHPFPM(version); err != nil {
+		system.RemoveFileAsRoot(poolPath)
+		return err
+	}
+
+	return reloadPHPFPM(version)
+}
+
+// RemovePHPPool deletes a pool config of the active PHP version and reloads php-fpm.
+func RemovePHPPool(name string) error {
+	if err := checkPoolName(name); err != nil {
+		return err
+	}
+	version, err := ActivePHPVersion()
+	if err != nil {
+		return err
+	}
+
+	poolPath := filepath.Join(phpPoolDir(version), name+".conf")
+	if _, err := os.Stat(poolPath); os.IsNotExist(err) {
+		return fmt.Errorf("pool '%s' does not exist for php %s", name, version)
+	}
+
+	if err := system.RemoveFileAsRoot(poolPath); err != nil {
+		return err
+	}
+	if err := validatePHPFPM(version); err != nil {
+		return err
+	}
+	return reloadPHPFPM(version)
+}
+
+// ListPHPPools returns the pool names configured for the active PHP version.
+func ListPHPPools() ([]string, error) {
+	version, err := ActivePHPVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(phpPoolDir(version), "*.conf"))
+	if err != nil {
+		return nil, err
+	}
+
+	var pools []string
+	for _, file := range files {
+		pools = append(pools, strings.TrimSuffix(filepath.Base(file), ".conf"))
+	}
+	return pools, nil
+}
+
+// validatePHPFPM runs php-fpm's config test for a version.
+func validatePHPFPM(version string) error {
+	if err := system.Command("php-fpm"+version, "-t").WithSudo().Run(); err != nil {
+		return fmt.Errorf("php-fpm configuration test failed: %v", err)
+	}
+	return nil
+}
+
+// reloadPHPFPM gracefully reloads the php-fpm service of a version.
+func reloadPHPFPM(version string) error {
+	service := fmt.Sprintf("php%s-fpm", version)
+	if err := system.Command("systemctl", "reload", service).WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to reload %s: %v", service, err)
+	}
+	return nil
+}
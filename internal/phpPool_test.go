@@ -0,0 +1,45 @@
+package internal
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func FuzzCheckPoolName(f *testing.F) {
+	for _, seed := range []string{"shop", "api-v2", "www", "../x", "a/b", ".", "..", "Shop", "-x", ""} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, name string) {
+		if err := checkPoolName(name); err != nil {
+			return
+		}
+		// An accepted name is a file directly in pool.d, other than www.conf
+		dir := phpPoolDir("8.3")
+		path := filepath.Join(dir, name+".conf")
+		if filepath.Dir(path) != dir || filepath.Base(path) != name+".conf" || name == "www" {
+			t.Fatalf("checkPoolName accepted %q, which writes %s", name, path)
+		}
+	})
+}
+
+func FuzzCheckPoolListen(f *testing.F) {
+	for _, seed := range []string{"/run/php/shop.sock", "127.0.0.1:9001", "[::1]:9001", "9001", "shop.sock", "/run/php/../x.sock", "/run/a.sock\nuser = root", ":9001", "localhost:0", ""} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, listen string) {
+		if err := checkPoolListen(listen); err != nil {
+			return
+		}
+		// An accepted address is one line of the pool config
+		if strings.ContainsAny(listen, "\r\n;") {
+			t.Fatalf("checkPoolListen accepted %q, which breaks the pool config", listen)
+		}
+		if !filepath.IsAbs(listen) {
+			if _, _, err := net.SplitHostPort(listen); err != nil {
+				t.Fatalf("checkPoolListen accepted %q, neither a socket path nor host:port", listen)
+			}
+		}
+	})
+}
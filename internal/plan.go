@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PlanVersion is the format version of plan files.
+const PlanVersion = 1
+
+// Actions of plan steps.
+const (
+	PlanInstall = "install"
+	PlanSkip    = "skip"
+)
+
+// Plan is the resolved execution plan of an install, written by `run plan`
+// for review and executed as is by `run apply`.
+type Plan struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Host      string    `json:"host"`
+	Context   string    `json:"context"`
+	// Packages are the packages asked for; Steps adds their dependencies.
+	Packages []string   `json:"packages"`
+	Steps    []PlanStep `json:"steps"`
+	// EstimatedBytes is the estimated disk space of the install steps.
+	EstimatedBytes int64 `json:"estimated_bytes"`
+}
+
+// PlanStep is a package of a plan, in the order it is installed.
+type PlanStep struct {
+	Package string `json:"package"`
+	// Dependency is set for packages only installed as a dependency.
+	Dependency bool `json:"dependency,omitempty"`
+	// Action is install, or skip for packages already installed this way.
+	Action       string `json:"action"`
+	Script       string `json:"script"`
+	ScriptSHA256 string `json:"script_sha256"`
+	// Settings are the options passed to the script, such as
+	// NODE_VERSION=22.
+	Settings         []string `json:"settings,omitempty"`
+	InstalledVersion string   `json:"installed_version,omitempty"`
+	EstimatedSize    string   `json:"estimated_size,omitempty"`
+	// StepHash covers the script, its whole environment and the installed
+	// version; `run apply` refuses the plan when it changed.
+	StepHash string `json:"step_hash"`
+}
+
+// BuildPlan resolves the install of packages and their dependencies into a
+// plan. settings returns the options of each package's script.
+func BuildPlan(packages []string, settings func(packageName string) []string) (*Plan, error) {
+	order, err := NewDependencyGraph().ResolveOrder(packages)
+	if err != nil {
+		return nil, err
+	}
+	state, err := LoadState()
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	plan := &Plan{
+		Version:   PlanVersion,
+		CreatedAt: time.Now().UTC(),
+		Host:      hostname,
+		Context:   CurrentContext(),
+		Packages:  packages,
+	}
+	for _, packageName := range order {
+		step, err := planStep(packageName, settings(packageName))
+		if err != nil {
+			return nil, err
+		}
+		step.Dependency = !containsString(packages, packageName)
+		if state.StepUnchanged(packageName, step.StepHash) {
+			step.Action = PlanSkip
+		} else if size, err := ParseSize(step.EstimatedSize); err == nil {
+			plan.EstimatedBytes += size
+		}
+		plan.Steps = append(plan.Steps, step)
+	}
+	return plan, nil
+}
+
+// planStep describes the install of a package as it would run now. Its
+// action is install.
+func planStep(packageName string, settings []string) (PlanStep, error) {
+	step := PlanStep{
+		Package:          packageName,
+		Action:           PlanInstall,
+		Settings:         settings,
+		InstalledVersion: PackageVersion(packageName),
+		EstimatedSize:    PackageSupport[packageName].Size,
+	}
+	scriptPath, err := GetScriptPath("install", packageName)
+	if err != nil {
+		return step, err
+	}
+	step.Script = filepath.Base(scriptPath)
+	data, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return step, fmt.Errorf("failed to read the install script of %s: %v", packageName, err)
+	}
+	sum := sha256.Sum256(data)
+	step.ScriptSHA256 = hex.EncodeToString(sum[:])
+
+	env, err := PackageScriptEnv(packageName)
+	if err != nil {
+		return step, err
+	}
+	if step.StepHash, err = StepHash(packageName, append(env, settings...)); err != nil {
+		return step, err
+	}
+	return step, nil
+}
+
+// Changes returns how the host differs from when the plan was made: another
+// host or context, changed dependencies, scripts, settings or installed
+// versions. A plan is only applied while there are none.
+func (p *Plan) Changes() ([]string, error) {
+	var changes []string
+	if hostname, _ := os.Hostname(); hostname != p.Host {
+		changes = append(changes, fmt.Sprintf("the plan was made on %s, not %s", p.Host, hostname))
+	}
+	if context := CurrentContext(); context != p.Context {
+		changes = append(changes, fmt.Sprintf("the plan was made in context %s, not %s", p.Context, context))
+	}
+	order, err := NewDependencyGraph().ResolveOrder(p.Packages)
+	if err != nil {
+		return nil, err
+	}
+	var planned []string
+	for _, step := range p.Steps {
+		planned = append(planned, step.Package)
+	}
+	if strings.Join(order, " ") != strings.Join(planned, " ") {
+		return append(changes, fmt.Sprintf("the packages to install are now %s, planned %s", strings.Join(order, ", "), strings.Join(planned, ", "))), nil
+	}
+
+	state, err := LoadState()
+	if err != nil {
+		return nil, err
+	}
+	for _, planned := range p.Steps {
+		step, err := planStep(planned.Package, planned.Settings)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case step.ScriptSHA256 != planned.ScriptSHA256:
+			changes = append(changes, fmt.Sprintf("%s: the install script %s changed", planned.Package, step.Script))
+		case step.InstalledVersion != planned.InstalledVersion:
+			changes = append(changes, fmt.Sprintf("%s: version %s is installed, planned with %s", planned.Package, describeVersion(step.InstalledVersion), describeVersion(planned.InstalledVersion)))
+		case step.StepHash != planned.StepHash:
+			changes = append(changes, fmt.Sprintf("%s: the settings of its script changed", planned.Package))
+		case planned.Action == PlanSkip && !state.StepUnchanged(planned.Package, planned.StepHash):
+			changes = append(changes, fmt.Sprintf("%s: no longer installed as planned", planned.Package))
+		}
+	}
+	return changes, nil
+}
+
+// describeVersion returns a version for messages, "none" when empty.
+func describeVersion(version string) string {
+	if version == "" {
+		return "none"
+	}
+	return version
+}
+
+// WritePlan writes a plan as indented JSON.
+func WritePlan(plan *Plan, path string) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write plan %s: %v", path, err)
+	}
+	return nil
+}
+
+// ReadPlan reads a plan written by WritePlan.
+func ReadPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan %s: %v", path, err)
+	}
+	var plan Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan %s: %v", path, err)
+	}
+	if plan.Version != PlanVersion {
+		return nil, fmt.Errorf("plan %s has format version %d; this run reads version %d", path, plan.Version, PlanVersion)
+	}
+	return &plan, nil
+}
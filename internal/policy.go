@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+)
+
+// PolicyConfig restricts what run may do on a host. It is meant for golden
+// images where the binary is baked in and the host has a fixed role.
+type PolicyConfig struct {
+	// Role selects the entry of Roles that applies to this host.
+	Role  string                `yaml:"role"`
+	Roles map[string]RolePolicy `yaml:"roles"`
+}
+
+// RolePolicy lists what a role may use. An empty list allows everything.
+type RolePolicy struct {
+	Commands []string `yaml:"commands"`
+	Packages []string `yaml:"packages"`
+}
+
+// PolicyViolation is returned when the host's role does not allow an action.
+type PolicyViolation struct {
+	Role    string
+	Command string
+	Package string
+}
+
+func (v *PolicyViolation) Error() string {
+	if v.Package != "" {
+		return fmt.Sprintf("policy violation: role '%s' may not %s package '%s'", v.Role, v.Command, v.Package)
+	}
+	return fmt.Sprintf("policy violation: role '%s' may not use 'run %s'", v.Role, v.Command)
+}
+
+// IsPolicyViolation reports whether err is a PolicyViolation.
+func IsPolicyViolation(err error) bool {
+	var violation *PolicyViolation
+	return errors.As(err, &violation)
+}
+
+// policyExemptCommands are always allowed so users can inspect the policy.
+var policyExemptCommands = []string{"help", "version", "verify", "completion"}
+
+// activeRole returns the role policy of this host, or nil when no role is set.
+func activeRole(config *Config) (*RolePolicy, error) {
+	if config.Policy.Role == "" {
+		return nil, nil
+	}
+	role, exists := config.Policy.Roles[config.Policy.Role]
+	if !exists {
+		return nil, fmt.Errorf("role '%s' is not defined under policy.roles in config", config.Policy.Role)
+	}
+	return &role, nil
+}
+
+// CheckCommandPolicy verifies the host's role may run a top-level command.
+func CheckCommandPolicy(config *Config, command string) error {
+	role, err := activeRole(config)
+	if err != nil || role == nil {
+		return err
+	}
+	if containsString(policyExemptCommands, command) {
+		return nil
+	}
+	if len(role.Commands) > 0 && !containsString(role.Commands, command) {
+		return &PolicyViolation{Role: config.Policy.Role, Command: command}
+	}
+	return nil
+}
+
+// CheckPackagePolicy verifies the host's role may install or remove a package.
+func CheckPackagePolicy(config *Config, command, packageName string) error {
+	role, err := activeRole(config)
+	if err != nil || role == nil {
+		return err
+	}
+	if len(role.Packages) > 0 && !containsString(role.Packages, packageName) {
+		return &PolicyViolation{Role: config.Policy.Role, Command: command, Package: packageName}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os/exec"
+	"strings"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// PostgresQuoteIdentifier quotes a role or database name for use in SQL.
+func PostgresQuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// PostgresQuoteLiteral quotes a string value for use in SQL.
+func PostgresQuoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// GeneratePassword returns a random alphanumeric password.
+func GeneratePassword(length int) (string, error) {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	password := make([]byte, length)
+	for i := range password {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate password: %v", err)
+		}
+		password[i] = alphabet[n.Int64()]
+	}
+	return string(password), nil
+}
+
+// runPostgresSQL executes SQL as the postgres superuser. The statement is
+// passed on stdin so it never goes through a shell.
+func runPostgresSQL(sql string) (string, error) {
+	if _, err := exec.LookPath("psql"); err != nil {
+		return "", fmt.Errorf("postgres is not installed. Install it with: run install postgres")
+	}
+
+	output, err := system.Command("psql", "-v", "ON_ERROR_STOP=1", "-tA", "-f", "-").AsUser("postgres").WithStdin(strings.NewReader(sql)).CombinedOutput()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// PostgresRoleExists reports whether a role with the given name exists.
+func PostgresRoleExists(name string) (bool, error) {
+	output, err := runPostgresSQL(fmt.Sprintf("SELECT 1 FROM pg_roles WHERE rolname = %s;", PostgresQuoteLiteral(name)))
+	if err != nil {
+		return false, err
+	}
+	return output == "1", nil
+}
+
+// PostgresDatabaseExists reports whether a database with the given name exists.
+func PostgresDatabaseExists(name string) (bool, error) {
+	output, err := runPostgresSQL(fmt.Sprintf("SELECT 1 FROM pg_database WHERE datname = %s;", PostgresQuoteLiteral(name)))
+	if err != nil {
+		return false, err
+	}
+	return output == "1", nil
+}
+
+// CreatePostgresUser creates a login role. An empty password creates a role
+// that can only authenticate through peer/ident auth.
+func CreatePostgresUser(name, password string, superuser bool) error {
+	exists, err := PostgresRoleExists(name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("postgres user '%s' already exists", name)
+	}
+
+	sql := "CREATE ROLE " + PostgresQuoteIdentifier(name) + " WITH LOGIN"
+	if superuser {
+		sql += " SUPERUSER"
+	}
+	if password != "" {
+		sql += " PASSWORD " + PostgresQuoteLiteral(password)
+	}
+	_, err = runPostgresSQL(sql + ";")
+	return err
+}
+
+// CreatePostgresDatabase creates a database owned by owner.
+func CreatePostgresDatabase(name, owner string) error {
+	exists, err := PostgresDatabaseExists(name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("postgres database '%s' already exists", name)
+	}
+
+	_, err = runPostgresSQL(fmt.Sprintf("CREATE DATABASE %s OWNER %s;", PostgresQuoteIdentifier(name), PostgresQuoteIdentifier(owner)))
+	return err
+}
+
+// PostgresConnectionEnv returns the connection settings of a database in the
+// variable names most frameworks understand. Without a password, the keys
+// that hold it, DB_PASSWORD and DATABASE_URL, are left out so that values
+// already set are kept.
+func PostgresConnectionEnv(database, user, password string) map[string]string {
+	env := map[string]string{
+		"DB_HOST": "localhost",
+		"DB_PORT": "5432",
+		"DB_NAME": database,
+		"DB_USER": user,
+	}
+	if password != "" {
+		databaseURL := url.URL{
+			Scheme: "postgresql",
+			User:   url.UserPassword(user, password),
+			Host:   "localhost:5432",
+			Path:   "/" + database,
+		}
+		env["DATABASE_URL"] = databaseURL.String()
+		env["DB_PASSWORD"] = password
+	}
+	return env
+}
@@ -0,0 +1,220 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// PostgresCluster is a cluster as reported by pg_lsclusters.
+type PostgresCluster struct {
+	Version string
+	Name    string
+	Port    int
+	Status  string
+	DataDir string
+}
+
+// ListPostgresClusters returns the clusters managed by postgresql-common.
+func ListPostgresClusters() ([]PostgresCluster, error) {
+	output, err := exec.Command("pg_lsclusters", "--no-header").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list postgres clusters (is postgresql-common installed?): %v", err)
+	}
+
+	var clusters []PostgresCluster
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		port, _ := strconv.Atoi(fields[2])
+		clusters = append(clusters, PostgresCluster{
+			Version: fields[0],
+			Name:    fields[1],
+			Port:    port,
+			Status:  fields[3],
+			DataDir: fields[5],
+		})
+	}
+	return clusters, nil
+}
+
+// findPostgresCluster returns the cluster with the given version and name.
+func findPostgresCluster(version, name string) (*PostgresCluster, error) {
+	clusters, err := ListPostgresClusters()
+	if err != nil {
+		return nil, err
+	}
+	for _, cluster := range clusters {
+		if cluster.Version == version && cluster.Name == name {
+			return &cluster, nil
+		}
+	}
+	return nil, nil
+}
+
+// UpgradePostgres upgrades a cluster to a new major version. The new version
+// is installed side by side, the data is migrated with pg_upgrade (falling
+// back to dump/restore), the result is validated and the services are
+// switched. The old cluster is stopped but kept; DropPostgresCluster removes
+// it once the user has confirmed the upgrade.
+func UpgradePostgres(from, to, name string) error {
+	fromVersion, err := strconv.Atoi(from)
+	if err != nil {
+		return fmt.Errorf("invalid version '%s'", from)
+	}
+	toVersion, err := strconv.Atoi(to)
+	if err != nil {
+		return fmt.Errorf("invalid version '%s'", to)
+	}
+	if toVersion <= fromVersion {
+		return fmt.Errorf("target version %s must be newer than %s", to, from)
+	}
+
+	oldCluster, err := findPostgresCluster(from, name)
+	if err != nil {
+		return err
+	}
+	if oldCluster == nil {
+		return fmt.Errorf("postgres cluster %s/%s does not exist", from, name)
+	}
+
+	// Step 1: install the new version next to the old one
+	if _, err := os.Stat(fmt.Sprintf("/usr/lib/postgresql/%s/bin/postgres", to)); os.IsNotExist(err) {
+		fmt.Printf("📦 Installing PostgreSQL %s...\n", to)
+		if err := runAptGet("install", "-y", "postgresql-"+to); err != nil {
+			return fmt.Errorf("failed to install postgresql-%s: %v", to, err)
+		}
+	}
+
+	// Step 2: drop the empty cluster the package creates by default
+	if err := dropEmptyPostgresCluster(to, name); err != nil {
+		return err
+	}
+
+	// Step 3: migrate the data
+	fmt.Printf("🔄 Upgrading cluster %s/%s to %s with pg_upgrade...\n", from, name, to)
+	if err := runRootStreaming("pg_upgradecluster", "-m", "upgrade", "-v", to, from, name); err != nil {
+		fmt.Printf("⚠️  pg_upgrade failed (%v), falling back to dump and restore...\n", err)
+		if newCluster, _ := findPostgresCluster(to, name); newCluster != nil {
+			if err := runRootStreaming("pg_dropcluster", "--stop", to, name); err != nil {
+				return fmt.Errorf("failed to clean up partial cluster %s/%s: %v", to, name, err)
+			}
+		}
+		if err := runRootStreaming("pg_upgradecluster", "-m", "dump", "-v", to, from, name); err != nil {
+			return fmt.Errorf("upgrade failed, cluster %s/%s is unchanged: %v", from, name, err)
+		}
+	}
+
+	// Step 4: validate the new cluster
+	newCluster, err := findPostgresCluster(to, name)
+	if err != nil {
+		return err
+	}
+	if newCluster == nil || newCluster.Status != "online" {
+		return fmt.Errorf("new cluster %s/%s is not online after the upgrade", to, name)
+	}
+	if _, err := runPostgresSQLOnPort(newCluster.Port, "SELECT count(*) FROM pg_database;"); err != nil {
+		return fmt.Errorf("new cluster %s/%s does not accept queries: %v", to, name, err)
+	}
+	fmt.Printf("✅ Cluster %s/%s is online on port %d\n", to, name, newCluster.Port)
+
+	// Step 5: start the new version at boot instead of the old one
+	oldService := fmt.Sprintf("postgresql@%s-%s", from, name)
+	newService := fmt.Sprintf("postgresql@%s-%s", to, name)
+	if err := system.Command("systemctl", "disable", "--now", oldService).WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to disable %s: %v", oldService, err)
+	}
+	if err := system.Command("systemctl", "enable", newService).WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to enable %s: %v", newService, err)
+	}
+
+	return nil
+}
+
+// dropEmptyPostgresCluster removes a cluster that holds no user databases,
+// such as the one created automatically when installing a new major version.
+// A stopped cluster is started to count its databases: one that cannot be
+// inspected is never dropped.
+func dropEmptyPostgresCluster(version, name string) error {
+	cluster, err := findPostgresCluster(version, name)
+	if err != nil || cluster == nil {
+		return err
+	}
+
+	if cluster.Status != "online" {
+		fmt.Printf("▶️  Starting cluster %s/%s to check it is empty...\n", version, name)
+		if err := runRootStreaming("pg_ctlcluster", version, name, "start"); err != nil {
+			return fmt.Errorf("cluster %s/%s is %s and could not be started to check it is empty; refusing to replace it: %v", version, name, cluster.Status, err)
+		}
+	}
+	count, err := runPostgresSQLOnPort(cluster.Port,
+		"SELECT count(*) FROM pg_database WHERE datname NOT IN ('postgres', 'template0', 'template1');")
+	if err != nil {
+		return fmt.Errorf("cannot check that cluster %s/%s is empty; refusing to replace it: %v", version, name, err)
+	}
+	if count != "0" {
+		return fmt.Errorf("cluster %s/%s already contains databases; refusing to replace it", version, name)
+	}
+
+	fmt.Printf("🧹 Removing empty default cluster %s/%s...\n", version, name)
+	if err := runRootStreaming("pg_dropcluster", "--stop", version, name); err != nil {
+		return fmt.Errorf("failed to drop cluster %s/%s: %v", version, name, err)
+	}
+	return nil
+}
+
+// CheckPostgresUpgraded checks that the upgrade of a cluster from one
+// version to another went through: the new cluster exists, is online and
+// answers queries. It guards deleting the old cluster.
+func CheckPostgresUpgraded(from, to, name string) error {
+	if from == to {
+		return fmt.Errorf("--from and --to are both %s", from)
+	}
+	newCluster, err := findPostgresCluster(to, name)
+	if err != nil {
+		return err
+	}
+	if newCluster == nil {
+		return fmt.Errorf("postgres cluster %s/%s does not exist; upgrade to it before finalizing", to, name)
+	}
+	if newCluster.Status != "online" {
+		return fmt.Errorf("postgres cluster %s/%s is %s, not online; refusing to delete %s/%s", to, name, newCluster.Status, from, name)
+	}
+	if _, err := runPostgresSQLOnPort(newCluster.Port, "SELECT count(*) FROM pg_database;"); err != nil {
+		return fmt.Errorf("postgres cluster %s/%s does not accept queries; refusing to delete %s/%s: %v", to, name, from, name, err)
+	}
+	return nil
+}
+
+// DropPostgresCluster permanently deletes a cluster and its data directory.
+func DropPostgresCluster(version, name string) error {
+	cluster, err := findPostgresCluster(version, name)
+	if err != nil {
+		return err
+	}
+	if cluster == nil {
+		return fmt.Errorf("postgres cluster %s/%s does not exist", version, name)
+	}
+	if err := runRootStreaming("pg_dropcluster", "--stop", version, name); err != nil {
+		return fmt.Errorf("failed to drop cluster %s/%s: %v", version, name, err)
+	}
+	return nil
+}
+
+// runPostgresSQLOnPort runs SQL against the cluster listening on port.
+func runPostgresSQLOnPort(port int, sql string) (string, error) {
+	output, err := system.Command("psql", "-p", strconv.Itoa(port), "-v", "ON_ERROR_STOP=1", "-tA", "-f", "-").AsUser("postgres").WithStdin(strings.NewReader(sql)).CombinedOutput()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
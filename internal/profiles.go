@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Profile is a named set of packages installed together, such as everything
+// a CI runner needs.
+type Profile struct {
+	Description string   `yaml:"description"`
+	Packages    []string `yaml:"packages"`
+	// Versions pin package versions, passed to scripts as <PACKAGE>_VERSION.
+	Versions map[string]string `yaml:"versions"`
+	// Users are created before the packages are installed, keyed by name,
+	// with the supplementary groups to add them to.
+	Users map[string][]string `yaml:"users"`
+	// SSHKeys are added to the authorized_keys of users, keyed by user: a
+	// key, a file or https URL of keys, or github:<username>.
+	SSHKeys map[string][]string `yaml:"ssh_keys"`
+	// Images are container images pulled once docker is installed, so apps
+	// do not wait for downloads on first start. Pin them with @sha256:...
+	Images []string `yaml:"images"`
+}
+
+// BuiltinProfiles are the presets shipped with run. Profiles of the same
+// name under profiles in ~/.run/config.yaml replace them.
+var BuiltinProfiles = map[string]Profile{
+	"gha-runner": {
+		Description: "Self-hosted GitHub Actions runner: build tools, docker, node, python and java",
+		Packages:    []string{"essentials", "docker", "node", "python", "java"},
+		Versions:    map[string]string{"java": "17"},
+	},
+}
+
+// Profiles returns the built-in profiles merged with those from config.
+func Profiles(config *Config) map[string]Profile {
+	profiles := make(map[string]Profile)
+	for name, profile := range BuiltinProfiles {
+		profiles[name] = profile
+	}
+	for name, profile := range config.Profiles {
+		profiles[name] = profile
+	}
+	return profiles
+}
+
+// GetProfile returns a profile by name, checking that its packages exist.
+func GetProfile(config *Config, name string) (Profile, error) {
+	profiles := Profiles(config)
+	profile, exists := profiles[name]
+	if !exists {
+		var names []string
+		for profileName := range profiles {
+			names = append(names, profileName)
+		}
+		sort.Strings(names)
+		return Profile{}, fmt.Errorf("unknown profile '%s'. Available profiles: %s", name, strings.Join(names, ", "))
+	}
+	for _, packageName := range profile.Packages {
+		if _, exists := InstallPackageRegistry[packageName]; !exists {
+			return Profile{}, fmt.Errorf("profile '%s' lists unknown package '%s'", name, packageName)
+		}
+	}
+	for _, image := range profile.Images {
+		if strings.Contains(image, "@") && !imageDigestPattern.MatchString(image) {
+			return Profile{}, fmt.Errorf("profile '%s' has an invalid image digest in '%s': expected @sha256:<64 hex digits>", name, image)
+		}
+	}
+	if len(profile.Images) > 0 && !containsString(profile.Packages, "docker") {
+		return Profile{}, fmt.Errorf("profile '%s' lists images but does not install docker", name)
+	}
+	return profile, nil
+}
@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// rebootRequiredFile is created by kernel, libc and driver packages (and
+// scripts such as nvidia.sh) when the host has to reboot to finish.
+const rebootRequiredFile = "/var/run/reboot-required"
+
+// rebootRequiredPkgsFile lists the packages that asked for the reboot.
+const rebootRequiredPkgsFile = "/var/run/reboot-required.pkgs"
+
+// RebootRequired reports whether a reboot is pending, with the packages
+// that asked for it.
+func RebootRequired() (bool, []string) {
+	if _, err := os.Stat(rebootRequiredFile); err != nil {
+		return false, nil
+	}
+	var packages []string
+	if data, err := os.ReadFile(rebootRequiredPkgsFile); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !containsString(packages, line) {
+				packages = append(packages, line)
+			}
+		}
+	}
+	return true, packages
+}
+
+// rebootRequiredFor reports whether a pending reboot was requested by a
+// package whose name contains the given text.
+func rebootRequiredFor(text string) bool {
+	_, packages := RebootRequired()
+	for _, packageName := range packages {
+		if strings.Contains(packageName, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScheduleReboot reboots the host after delay, rounded up to whole minutes
+// as shutdown counts them, and returns when. A pending reboot can be
+// cancelled with `sudo shutdown -c`.
+func ScheduleReboot(delay time.Duration) (time.Time, error) {
+	minutes := int((delay + time.Minute - 1) / time.Minute)
+	when := "now"
+	if minutes > 0 {
+		when = fmt.Sprintf("+%d", minutes)
+	}
+	if err := system.Command("shutdown", "-r", when, "Rebooting to finish provisioning by "+CLIName).WithSudo().Run(); err != nil {
+		return time.Time{}, fmt.Errorf("failed to schedule a reboot: %v", err)
+	}
+	return time.Now().Add(time.Duration(minutes) * time.Minute), nil
+}
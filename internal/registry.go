@@ -1,17 +1,339 @@
 package internal
 
-var InstallPackageRegistry = map[string]string{
-	"docker":   "docker.sh",
-	"java":     "java.sh",
-	"nginx":    "nginx.sh",
-	"node":     "node.sh",
-	"php":      "php.sh",
-	"pm2":      "pm2.sh",
-	"postgres": "postgres17.sh",
-}
-
-var RemovePackageRegistry = map[string]string{
-	"nginx":    "remove-nginx.sh",
-	"node":     "remove-node.sh",
-	"postgres": "remove-postgres.sh",
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultRegistryURL is where `run registry update` fetches the canonical
+// registry from, unless registry.url is set in the config.
+const DefaultRegistryURL = "https://raw.githubusercontent.com/amoga-io/run/main/internal/registry.yaml"
+
+//go:embed registry.yaml
+var embeddedRegistry []byte
+
+// InstallPackageRegistry maps packages to their install script. It is built
+// from the embedded registry and its overlays.
+var InstallPackageRegistry = map[string]string{}
+
+// RemovePackageRegistry maps packages to their removal script, for packages
+// that have one.
+var RemovePackageRegistry = map[string]string{}
+
+// PackageNextSteps maps packages to guidance shown after they are installed.
+var PackageNextSteps = map[string][]string{}
+
+// PackageSuggestions maps packages to packages that are often installed
+// alongside them, offered as tips after an install.
+var PackageSuggestions = map[string][]string{}
+
+// PackageSysctls maps packages to the kernel parameters they need, applied
+// from /etc/sysctl.d while they are installed.
+var PackageSysctls = map[string]map[string]string{}
+
+// PackageFiles maps packages to their key files (binaries, unit files and
+// configs), as paths or globs, recorded after installs for `run verify`.
+var PackageFiles = map[string][]string{}
+
+// PackageBackends maps packages to the ways they can be installed, for
+// packages with a choice (`run install --backend`).
+var PackageBackends = map[string]map[string]RegistryBackend{}
+
+// PackageSupport maps packages to the versions, platforms and services
+// they support, for `run capabilities`.
+var PackageSupport = map[string]RegistrySupport{}
+
+// PackageDependencies maps packages to the packages they need installed
+// first, for packages that have any.
+var PackageDependencies = map[string][]string{}
+
+// RegistryFile is the format of the embedded registry and its overlays,
+// described by internal/registry.schema.json.
+type RegistryFile struct {
+	Packages map[string]RegistryPackage `yaml:"packages"`
+}
+
+// RegistryPackage names the scripts of a package, relative to the scripts directory.
+type RegistryPackage struct {
+	Install  string   `yaml:"install"`
+	Remove   string   `yaml:"remove,omitempty"`
+	Depends  []string `yaml:"depends,omitempty"`
+	Suggests []string `yaml:"suggests,omitempty"`
+	// Sysctls are kernel parameters set while the package is installed.
+	Sysctls map[string]string `yaml:"sysctls,omitempty"`
+	// Backends are alternative install methods, passed to the install
+	// script as <PACKAGE>_BACKEND.
+	Backends map[string]RegistryBackend `yaml:"backends,omitempty"`
+	// NextSteps are shown after a successful install.
+	NextSteps []string `yaml:"next_steps,omitempty"`
+	// Files are key files of the package, as absolute paths or globs,
+	// checked by `run verify`.
+	Files   []string        `yaml:"files,omitempty"`
+	Support RegistrySupport `yaml:",inline"`
+}
+
+// RegistrySupport describes what a package supports. Empty platform lists
+// mean every platform run supports.
+type RegistrySupport struct {
+	// Versions can be requested with <PACKAGE>_VERSION; the first is the
+	// default.
+	Versions []string `yaml:"versions,omitempty"`
+	// Releases are Ubuntu codenames, such as noble.
+	Releases      []string `yaml:"releases,omitempty"`
+	Architectures []string `yaml:"architectures,omitempty"`
+	// Services are the systemd units the package runs, with <version> or
+	// <user> where the name depends on them.
+	Services []string `yaml:"services,omitempty"`
+	// Size estimates the disk space an install takes, such as 350M, for
+	// `run plan`.
+	Size string `yaml:"size,omitempty"`
+}
+
+// SupportedReleases are the Ubuntu releases run and its scripts support.
+var SupportedReleases = []string{"jammy", "noble"}
+
+// SupportedArchitectures are the architectures run is built for.
+var SupportedArchitectures = []string{"amd64", "arm64"}
+
+// RegistryBackend describes what an install backend of a package provides.
+type RegistryBackend struct {
+	Description string `yaml:"description"`
+	// Scope is global for a system-wide install, user for the home of the
+	// installing user.
+	Scope string `yaml:"scope"`
+	// Versions is how precisely a version can be chosen: exact, major, or
+	// distro for whatever the distribution ships.
+	Versions string `yaml:"versions"`
+	Default  bool   `yaml:"default,omitempty"`
+}
+
+// backendCapabilities lists the values allowed for each backend capability.
+var backendCapabilities = map[string][]string{
+	"scope":    {"global", "user"},
+	"versions": {"exact", "major", "distro"},
+}
+
+func init() {
+	registry, _, err := decodeRegistry(embeddedRegistry)
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded registry: %v", err))
+	}
+	registry.apply()
+}
+
+// apply adds the packages of a registry file, replacing existing entries.
+func (r *RegistryFile) apply() {
+	for name, pkg := range r.Packages {
+		InstallPackageRegistry[name] = pkg.Install
+		if pkg.Remove != "" {
+			RemovePackageRegistry[name] = pkg.Remove
+		} else {
+			delete(RemovePackageRegistry, name)
+		}
+		if len(pkg.Depends) > 0 {
+			PackageDependencies[name] = pkg.Depends
+		} else {
+			delete(PackageDependencies, name)
+		}
+		if len(pkg.Suggests) > 0 {
+			PackageSuggestions[name] = pkg.Suggests
+		} else {
+			delete(PackageSuggestions, name)
+		}
+		if len(pkg.Sysctls) > 0 {
+			PackageSysctls[name] = pkg.Sysctls
+		} else {
+			delete(PackageSysctls, name)
+		}
+		if len(pkg.Backends) > 0 {
+			PackageBackends[name] = pkg.Backends
+		} else {
+			delete(PackageBackends, name)
+		}
+		PackageSupport[name] = pkg.Support
+		if len(pkg.Files) > 0 {
+			PackageFiles[name] = pkg.Files
+		} else {
+			delete(PackageFiles, name)
+		}
+		if len(pkg.NextSteps) > 0 {
+			PackageNextSteps[name] = pkg.NextSteps
+		} else {
+			delete(PackageNextSteps, name)
+		}
+	}
+}
+
+// ListPackages returns the names of all registry packages, sorted.
+func ListPackages() []string {
+	return mapKeys(InstallPackageRegistry)
+}
+
+// ListBackendPackages returns the names of packages with install backends, sorted.
+func ListBackendPackages() []string {
+	return mapKeys(PackageBackends)
+}
+
+// ListRemovablePackages returns the names of packages with a removal script, sorted.
+func ListRemovablePackages() []string {
+	return mapKeys(RemovePackageRegistry)
+}
+
+// RegistryOverlayPaths returns the overlay files in the order they are
+// applied: the remote overlay from `run registry update`, then the user's.
+func RegistryOverlayPaths() ([]string, error) {
+	runDir, err := RunDir()
+	if err != nil {
+		return nil, err
+	}
+	registryDir := filepath.Join(runDir, "registry")
+	return []string{
+		filepath.Join(registryDir, "remote.yaml"),
+		filepath.Join(registryDir, "user.yaml"),
+	}, nil
+}
+
+// LoadRegistryOverlays merges the overlay files that exist into the
+// registry. A broken overlay is skipped and reported, so it cannot lock the
+// user out of the CLI.
+func LoadRegistryOverlays() []error {
+	paths, err := RegistryOverlayPaths()
+	if err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				errs = append(errs, fmt.Errorf("failed to read registry overlay %s: %v", path, err))
+			}
+			continue
+		}
+		registry, migrated, err := decodeRegistry(data)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid registry overlay %s: %v", path, err))
+			continue
+		}
+		if migrated != nil {
+			if err := os.WriteFile(path, migrated, 0644); err != nil {
+				errs = append(errs, fmt.Errorf("failed to save migrated registry overlay %s: %v", path, err))
+			}
+		}
+		registry.apply()
+	}
+	return errs
+}
+
+// UpdateRemoteRegistry downloads the registry from url and stores it as the
+// remote overlay. It returns the packages whose scripts are not in the
+// scripts directory yet.
+func UpdateRemoteRegistry(url string) ([]string, error) {
+	if err := RequireOnline(); err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch registry: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch registry: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch registry: %v", err)
+	}
+	registry, migrated, err := decodeRegistry(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid registry from %s: %v", url, err)
+	}
+	if migrated != nil {
+		data = migrated
+	}
+
+	paths, err := RegistryOverlayPaths()
+	if err != nil {
+		return nil, err
+	}
+	remotePath := paths[0]
+	if err := os.MkdirAll(filepath.Dir(remotePath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create registry directory: %v", err)
+	}
+	tempPath := remotePath + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write registry: %v", err)
+	}
+	if err := os.Rename(tempPath, remotePath); err != nil {
+		return nil, fmt.Errorf("failed to write registry: %v", err)
+	}
+	registry.apply()
+
+	scriptsDir, err := ScriptsDir()
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for name, pkg := range registry.Packages {
+		for _, script := range []string{pkg.Install, pkg.Remove} {
+			if script == "" {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(scriptsDir, script)); err != nil {
+				missing = append(missing, name)
+				break
+			}
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// DefaultBackend returns the backend of a package marked as default, or an
+// empty string when the package has no backends.
+func DefaultBackend(packageName string) string {
+	for name, backend := range PackageBackends[packageName] {
+		if backend.Default {
+			return name
+		}
+	}
+	return ""
+}
+
+// BackendScriptEnv returns the <PACKAGE>_BACKEND setting of an install
+// script: backend, or the default backend when it is empty. Packages without
+// backends get none.
+func BackendScriptEnv(packageName, backend string) ([]string, error) {
+	backends := PackageBackends[packageName]
+	if len(backends) == 0 {
+		if backend != "" {
+			return nil, fmt.Errorf("package '%s' has no install backends", packageName)
+		}
+		return nil, nil
+	}
+	if backend == "" {
+		backend = DefaultBackend(packageName)
+	}
+	if _, exists := backends[backend]; !exists {
+		return nil, fmt.Errorf("unknown backend '%s' for %s. Available backends: %s", backend, packageName, strings.Join(mapKeys(backends), ", "))
+	}
+	return []string{strings.ToUpper(packageName) + "_BACKEND=" + backend}, nil
+}
+
+// JavaVendors lists the JDK distributions the java package can install,
+// selected with `run install java --vendor <name>`.
+var JavaVendors = map[string]string{
+	"openjdk":  "OpenJDK from the distribution repositories",
+	"temurin":  "Eclipse Adoptium Temurin",
+	"corretto": "Amazon Corretto",
 }
@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CheckRegistry verifies the integrity of the merged registry: every script
+// must be reachable, dependencies must be known and acyclic, and
+// package-specific tables (hooks, checks, versions) must only refer to
+// registry packages.
+func CheckRegistry() []CheckResult {
+	scriptsDir, err := ScriptsDir()
+	if err != nil {
+		return []CheckResult{{Name: "scripts", OK: false, Message: err.Error()}}
+	}
+
+	var results []CheckResult
+	for _, name := range mapKeys(InstallPackageRegistry) {
+		result := CheckResult{Name: name, OK: true, Message: "scripts found"}
+		var problems []string
+		for _, script := range []string{InstallPackageRegistry[name], RemovePackageRegistry[name]} {
+			if script == "" {
+				continue
+			}
+			if err := ValidateScriptPath(scriptsDir, script); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+		if len(problems) > 0 {
+			result.OK = false
+			result.Message = strings.Join(problems, "; ")
+		}
+		results = append(results, result)
+	}
+
+	graph := NewDependencyGraph()
+	for _, unknown := range graph.UnknownDependencies() {
+		results = append(results, CheckResult{Name: "dependencies", OK: false, Message: "unknown package: " + unknown})
+	}
+	for _, name := range mapKeys(PackageSuggestions) {
+		for _, suggested := range PackageSuggestions[name] {
+			if _, exists := InstallPackageRegistry[suggested]; !exists {
+				results = append(results, CheckResult{Name: "suggestions", OK: false, Message: "unknown package: " + name + " -> " + suggested})
+			}
+		}
+	}
+	if cycle := graph.FindCycle(); cycle != nil {
+		results = append(results, CheckResult{Name: "dependencies", OK: false, Message: "circular dependency: " + strings.Join(cycle, " -> ")})
+	}
+
+	tables := map[string][]string{
+		"remove script":       mapKeys(RemovePackageRegistry),
+		"post-install hook":   mapKeys(PostInstallHooks),
+		"script environment":  mapKeys(ScriptEnvProviders),
+		"package check":       mapKeys(PackageChecks),
+		"package command":     mapKeys(PackageCommands),
+		"version command":     mapKeys(PackageVersionCommands),
+		"apt package mapping": mapKeys(PackageAptPatterns),
+	}
+	var tableNames []string
+	for table := range tables {
+		tableNames = append(tableNames, table)
+	}
+	sort.Strings(tableNames)
+
+	for _, table := range tableNames {
+		for _, name := range tables[table] {
+			if _, exists := InstallPackageRegistry[name]; !exists {
+				results = append(results, CheckResult{
+					Name:    name,
+					OK:      false,
+					Message: fmt.Sprintf("%s refers to a package that is not in the registry", table),
+				})
+			}
+		}
+	}
+	return results
+}
+
+// mapKeys returns the keys of a package-keyed map, sorted.
+func mapKeys[V any](m map[string]V) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
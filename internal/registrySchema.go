@@ -0,0 +1,351 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RegistrySchemaVersion is the registry format this binary reads. The format
+// is published as JSON Schema in internal/registry.schema.json.
+const RegistrySchemaVersion = 1
+
+// registryMigrations upgrade a registry document from the version of their
+// key to the next one.
+var registryMigrations = map[int]func(root *yaml.Node) error{
+	// Files written before schema_version existed could list a package as
+	// `name: script`; these become `name: {install: script}`.
+	0: func(root *yaml.Node) error {
+		packages := mappingValue(root, "packages")
+		if packages == nil || packages.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 1; i < len(packages.Content); i += 2 {
+			value := packages.Content[i]
+			if value.Kind == yaml.ScalarNode {
+				packages.Content[i] = &yaml.Node{
+					Kind: yaml.MappingNode,
+					Tag:  "!!map",
+					Content: []*yaml.Node{
+						{Kind: yaml.ScalarNode, Tag: "!!str", Value: "install"},
+						{Kind: yaml.ScalarNode, Tag: "!!str", Value: value.Value, Line: value.Line, LineComment: value.LineComment},
+					},
+					Line: value.Line,
+				}
+			}
+		}
+		return nil
+	},
+}
+
+// RegistryError points at the key of a registry file that is invalid.
+type RegistryError struct {
+	Line    int
+	Key     string
+	Message string
+}
+
+func (e *RegistryError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Key, e.Message)
+}
+
+// decodeRegistry parses a registry file, migrates it to the current schema
+// version and validates it. When the file was older than the current
+// version, migrated holds the migrated document to write back; otherwise it
+// is nil.
+func decodeRegistry(data []byte) (registry *RegistryFile, migrated []byte, err error) {
+	document, wasOlder, err := migrateRegistryDocument(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	root := document.Content[0]
+
+	if errs := validateRegistryNode(root); len(errs) > 0 {
+		return nil, nil, errs[0]
+	}
+
+	registry = &RegistryFile{}
+	if err := root.Decode(registry); err != nil {
+		return nil, nil, err
+	}
+
+	if wasOlder {
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(document); err != nil {
+			return nil, nil, err
+		}
+		migrated = buf.Bytes()
+	}
+	return registry, migrated, nil
+}
+
+// migrateRegistryDocument parses a registry file and applies the migrations
+// from its schema_version to the current one. wasOlder reports whether any
+// were needed.
+func migrateRegistryDocument(data []byte) (document *yaml.Node, wasOlder bool, err error) {
+	document = &yaml.Node{}
+	if err := yaml.Unmarshal(data, document); err != nil {
+		return nil, false, err
+	}
+	if len(document.Content) == 0 {
+		return nil, false, fmt.Errorf("file is empty")
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, false, &RegistryError{Line: root.Line, Message: "expected a mapping with schema_version and packages"}
+	}
+
+	version := 0
+	if node := mappingValue(root, "schema_version"); node != nil {
+		version, err = strconv.Atoi(node.Value)
+		if err != nil || node.Kind != yaml.ScalarNode {
+			return nil, false, &RegistryError{Line: node.Line, Key: "schema_version", Message: "must be an integer"}
+		}
+	}
+	if version > RegistrySchemaVersion {
+		return nil, false, fmt.Errorf("schema_version %d is newer than this run supports (%d); run 'run update'", version, RegistrySchemaVersion)
+	}
+
+	wasOlder = version < RegistrySchemaVersion
+	for ; version < RegistrySchemaVersion; version++ {
+		if err := registryMigrations[version](root); err != nil {
+			return nil, false, fmt.Errorf("failed to migrate from schema_version %d: %v", version, err)
+		}
+	}
+	setMappingValue(root, "schema_version", strconv.Itoa(RegistrySchemaVersion), "!!int")
+	return document, wasOlder, nil
+}
+
+// validateRegistryNode checks a registry document against the schema and
+// returns every offending key with its line.
+func validateRegistryNode(root *yaml.Node) []error {
+	errs := checkKnownKeys(root, "", []string{"schema_version", "packages"})
+
+	packages := mappingValue(root, "packages")
+	if packages == nil {
+		return append(errs, &RegistryError{Line: root.Line, Message: "missing required key 'packages'"})
+	}
+	if packages.Kind != yaml.MappingNode {
+		return append(errs, &RegistryError{Line: packages.Line, Key: "packages", Message: "must be a mapping of package names"})
+	}
+
+	for i := 0; i < len(packages.Content); i += 2 {
+		name, pkg := packages.Content[i], packages.Content[i+1]
+		key := "packages." + name.Value
+		if pkg.Kind != yaml.MappingNode {
+			errs = append(errs, &RegistryError{Line: name.Line, Key: key, Message: "must be a mapping with install and remove"})
+			continue
+		}
+		errs = append(errs, checkKnownKeys(pkg, key, []string{"install", "remove", "depends", "suggests", "sysctls", "backends", "next_steps", "files", "versions", "releases", "architectures", "services", "size"})...)
+		if mappingValue(pkg, "install") == nil {
+			errs = append(errs, &RegistryError{Line: name.Line, Key: key, Message: "missing required key 'install'"})
+		}
+		for _, field := range []string{"install", "remove"} {
+			value := mappingValue(pkg, field)
+			if value == nil {
+				continue
+			}
+			if value.Kind != yaml.ScalarNode || value.Tag != "!!str" || value.Value == "" {
+				errs = append(errs, &RegistryError{Line: value.Line, Key: key + "." + field, Message: "must be a script file name"})
+			}
+		}
+		errs = append(errs, checkStringList(pkg, key, "depends", "must be a list of package names")...)
+		errs = append(errs, checkStringList(pkg, key, "suggests", "must be a list of package names")...)
+		errs = append(errs, checkStringList(pkg, key, "next_steps", "must be a list of strings")...)
+		errs = append(errs, checkStringList(pkg, key, "versions", "must be a list of versions")...)
+		errs = append(errs, checkStringList(pkg, key, "services", "must be a list of systemd unit names")...)
+		errs = append(errs, checkFiles(pkg, key)...)
+		errs = append(errs, checkSupportedList(pkg, key, "releases", SupportedReleases)...)
+		errs = append(errs, checkSupportedList(pkg, key, "architectures", SupportedArchitectures)...)
+		errs = append(errs, checkSysctls(pkg, key)...)
+		errs = append(errs, checkBackends(pkg, key)...)
+		if value := mappingValue(pkg, "size"); value != nil && !sizePattern.MatchString(value.Value) {
+			errs = append(errs, &RegistryError{Line: value.Line, Key: key + ".size", Message: "must be a size in K, M or G, such as 350M"})
+		}
+	}
+	return errs
+}
+
+// sizePattern matches the size estimate of a package, such as 350M.
+var sizePattern = regexp.MustCompile(`^[1-9][0-9]*[KMG]$`)
+
+// checkBackends reports a backends field whose entries are not mappings of
+// known capabilities, or that does not mark exactly one backend as default.
+func checkBackends(pkg *yaml.Node, key string) []error {
+	backends := mappingValue(pkg, "backends")
+	if backends == nil {
+		return nil
+	}
+	if backends.Kind != yaml.MappingNode || len(backends.Content) == 0 {
+		return []error{&RegistryError{Line: backends.Line, Key: key + ".backends", Message: "must be a mapping of backend names"}}
+	}
+	var errs []error
+	defaults := 0
+	for i := 0; i+1 < len(backends.Content); i += 2 {
+		name, backend := backends.Content[i], backends.Content[i+1]
+		backendKey := key + ".backends." + name.Value
+		if backend.Kind != yaml.MappingNode {
+			errs = append(errs, &RegistryError{Line: name.Line, Key: backendKey, Message: "must be a mapping with description, scope and versions"})
+			continue
+		}
+		errs = append(errs, checkKnownKeys(backend, backendKey, []string{"description", "scope", "versions", "default"})...)
+		for _, field := range mapKeys(backendCapabilities) {
+			allowed := backendCapabilities[field]
+			value := mappingValue(backend, field)
+			if value == nil {
+				errs = append(errs, &RegistryError{Line: name.Line, Key: backendKey, Message: fmt.Sprintf("missing required key '%s'", field)})
+			} else if !containsString(allowed, value.Value) {
+				errs = append(errs, &RegistryError{Line: value.Line, Key: backendKey + "." + field, Message: fmt.Sprintf("must be one of: %s", strings.Join(allowed, ", "))})
+			}
+		}
+		if value := mappingValue(backend, "default"); value != nil {
+			if value.Tag != "!!bool" {
+				errs = append(errs, &RegistryError{Line: value.Line, Key: backendKey + ".default", Message: "must be true or false"})
+			} else if value.Value == "true" {
+				defaults++
+			}
+		}
+	}
+	if defaults != 1 {
+		errs = append(errs, &RegistryError{Line: backends.Line, Key: key + ".backends", Message: "exactly one backend must be the default"})
+	}
+	return errs
+}
+
+// checkSupportedList reports a field of a package that is not a list of
+// values from supported.
+func checkSupportedList(pkg *yaml.Node, key, field string, supported []string) []error {
+	message := "must be a list of: " + strings.Join(supported, ", ")
+	if errs := checkStringList(pkg, key, field, message); len(errs) > 0 {
+		return errs
+	}
+	list := mappingValue(pkg, field)
+	if list == nil {
+		return nil
+	}
+	var errs []error
+	for _, item := range list.Content {
+		if !containsString(supported, item.Value) {
+			errs = append(errs, &RegistryError{Line: item.Line, Key: key + "." + field, Message: fmt.Sprintf("'%s' is not one of: %s", item.Value, strings.Join(supported, ", "))})
+		}
+	}
+	return errs
+}
+
+// sysctlKeyPattern matches kernel parameter names such as vm.max_map_count.
+var sysctlKeyPattern = regexp.MustCompile(`^[a-z0-9_]+(\.[a-zA-Z0-9_-]+)+$`)
+
+// checkSysctls reports a sysctls field that is not a mapping of kernel
+// parameters to single-line values.
+func checkSysctls(pkg *yaml.Node, key string) []error {
+	sysctls := mappingValue(pkg, "sysctls")
+	if sysctls == nil {
+		return nil
+	}
+	if sysctls.Kind != yaml.MappingNode {
+		return []error{&RegistryError{Line: sysctls.Line, Key: key + ".sysctls", Message: "must be a mapping of kernel parameters to values"}}
+	}
+	var errs []error
+	for i := 0; i+1 < len(sysctls.Content); i += 2 {
+		name, value := sysctls.Content[i], sysctls.Content[i+1]
+		if !sysctlKeyPattern.MatchString(name.Value) {
+			errs = append(errs, &RegistryError{Line: name.Line, Key: key + ".sysctls." + name.Value, Message: "is not a kernel parameter name"})
+		}
+		if value.Kind != yaml.ScalarNode || value.Value == "" || strings.ContainsAny(value.Value, "\n\r") {
+			errs = append(errs, &RegistryError{Line: value.Line, Key: key + ".sysctls." + name.Value, Message: "must be a single-line value"})
+		}
+	}
+	return errs
+}
+
+// checkFiles reports a files field that is not a list of absolute paths or
+// globs.
+func checkFiles(pkg *yaml.Node, key string) []error {
+	errs := checkStringList(pkg, key, "files", "must be a list of absolute paths")
+	if list := mappingValue(pkg, "files"); list != nil && list.Kind == yaml.SequenceNode {
+		for _, item := range list.Content {
+			if _, err := filepath.Match(item.Value, ""); !strings.HasPrefix(item.Value, "/") || err != nil {
+				errs = append(errs, &RegistryError{Line: item.Line, Key: key + ".files", Message: fmt.Sprintf("'%s' must be an absolute path or glob", item.Value)})
+			}
+		}
+	}
+	return errs
+}
+
+// checkStringList reports a field of a package that is present but not a
+// list of non-empty strings.
+func checkStringList(pkg *yaml.Node, key, field, message string) []error {
+	list := mappingValue(pkg, field)
+	if list == nil {
+		return nil
+	}
+	if list.Kind != yaml.SequenceNode {
+		return []error{&RegistryError{Line: list.Line, Key: key + "." + field, Message: message}}
+	}
+	var errs []error
+	for _, item := range list.Content {
+		if item.Kind != yaml.ScalarNode || item.Value == "" {
+			errs = append(errs, &RegistryError{Line: item.Line, Key: key + "." + field, Message: message})
+		}
+	}
+	return errs
+}
+
+// checkKnownKeys reports the keys of a mapping that are not in known.
+func checkKnownKeys(node *yaml.Node, path string, known []string) []error {
+	sorted := append([]string(nil), known...)
+	sort.Strings(sorted)
+
+	var errs []error
+	for i := 0; i < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if containsString(known, key.Value) {
+			continue
+		}
+		fullKey := key.Value
+		if path != "" {
+			fullKey = path + "." + key.Value
+		}
+		errs = append(errs, &RegistryError{
+			Line:    key.Line,
+			Key:     fullKey,
+			Message: fmt.Sprintf("unknown key (expected one of: %s)", strings.Join(sorted, ", ")),
+		})
+	}
+	return errs
+}
+
+// mappingValue returns the value of key in a mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue sets a scalar key of a mapping node, adding it first if
+// missing. A comment heading the mapping stays at the top.
+func setMappingValue(node *yaml.Node, key, value, tag string) {
+	if existing := mappingValue(node, key); existing != nil {
+		existing.Kind, existing.Value, existing.Tag = yaml.ScalarNode, value, tag
+		return
+	}
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+	if len(node.Content) > 0 {
+		keyNode.HeadComment, node.Content[0].HeadComment = node.Content[0].HeadComment, ""
+	}
+	node.Content = append([]*yaml.Node{keyNode, {Kind: yaml.ScalarNode, Tag: tag, Value: value}}, node.Content...)
+}
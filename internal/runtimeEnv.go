@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Runtime selects an installed version of a package for `run exec --with`.
+type Runtime struct {
+	Package string
+	Version string
+}
+
+// ParseRuntime parses a spec like node@20, java@17, php@8.2 or
+// python@3.11.
+func ParseRuntime(spec string) (Runtime, error) {
+	name, version, found := strings.Cut(spec, "@")
+	if !found || name == "" || version == "" {
+		return Runtime{}, fmt.Errorf("invalid runtime '%s': expected <package>@<version>, e.g. node@20", spec)
+	}
+	return Runtime{Package: name, Version: version}, nil
+}
+
+func (r Runtime) String() string {
+	return r.Package + "@" + r.Version
+}
+
+// Env returns the directory to put first on PATH for the runtime, and the
+// variables it needs. Versions installed with nvm, pyenv or sdkman are
+// preferred; otherwise the system install of the version is used through a
+// shim directory under ~/.run/shims.
+func (r Runtime) Env() (string, []string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", nil, fmt.Errorf("error getting home directory: %v", err)
+	}
+	switch r.Package {
+	case "java":
+		if dir := matchingVersionDir(filepath.Join(home, ".sdkman", "candidates", "java"), r.Version, "-"); dir != "" {
+			return filepath.Join(dir, "bin"), []string{"JAVA_HOME=" + dir}, nil
+		}
+		major, err := strconv.Atoi(r.Version)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid java version '%s': expected a major version such as 17", r.Version)
+		}
+		jdks, err := ListJDKs()
+		if err != nil {
+			return "", nil, err
+		}
+		for _, jdk := range jdks {
+			if jdk.Major == major {
+				return filepath.Join(jdk.Home, "bin"), []string{"JAVA_HOME=" + jdk.Home}, nil
+			}
+		}
+	case "node":
+		if dir := matchingVersionDir(filepath.Join(home, ".nvm", "versions", "node"), "v"+r.Version, "."); dir != "" {
+			return filepath.Join(dir, "bin"), nil, nil
+		}
+		if activeVersion("node") == r.Version {
+			return "/usr/bin", nil, nil
+		}
+	case "python":
+		if dir := matchingVersionDir(filepath.Join(home, ".pyenv", "versions"), r.Version, "."); dir != "" {
+			return filepath.Join(dir, "bin"), nil, nil
+		}
+		binary := "/usr/bin/python" + r.Version
+		if _, err := os.Stat(binary); err == nil {
+			dir, err := r.shimDir(binary, "python", "python3")
+			return dir, nil, err
+		}
+	case "php":
+		binary := "/usr/bin/php" + r.Version
+		if _, err := os.Stat(binary); err == nil {
+			dir, err := r.shimDir(binary, "php")
+			return dir, nil, err
+		}
+	default:
+		return "", nil, fmt.Errorf("runtimes are not supported for package '%s'; supported: java, node, php, python", r.Package)
+	}
+	return "", nil, fmt.Errorf("%s is not installed", r)
+}
+
+// matchingVersionDir returns the newest directory in parent named version or
+// starting with version followed by separator, e.g. v20.11.1 for v20.
+func matchingVersionDir(parent, version, separator string) string {
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		return ""
+	}
+	var matches []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == version || strings.HasPrefix(name, version+separator) {
+			matches = append(matches, name)
+		}
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return compareVersions(strings.TrimPrefix(matches[i], "v"), strings.TrimPrefix(matches[j], "v")) < 0
+	})
+	return filepath.Join(parent, matches[len(matches)-1])
+}
+
+// shimDir returns ~/.run/shims/<package>@<version>, holding links under the
+// given names to a versioned system binary such as /usr/bin/php8.2.
+func (r Runtime) shimDir(binary string, names ...string) (string, error) {
+	runDir, err := ContextDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(runDir, "shims", r.String())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+	for _, name := range names {
+		link := filepath.Join(dir, name)
+		if target, err := os.Readlink(link); err == nil && target == binary {
+			continue
+		}
+		os.Remove(link)
+		if err := os.Symlink(binary, link); err != nil {
+			return "", fmt.Errorf("failed to create %s: %v", link, err)
+		}
+	}
+	return dir, nil
+}
+
+// ApplyRuntimes puts the runtimes first on the PATH of this process, in
+// the order given, and sets the variables they need, so commands started
+// from here use them.
+func ApplyRuntimes(runtimes []Runtime) error {
+	var dirs []string
+	for _, runtime := range runtimes {
+		dir, vars, err := runtime.Env()
+		if err != nil {
+			return err
+		}
+		dirs = append(dirs, dir)
+		for _, entry := range vars {
+			key, value, _ := strings.Cut(entry, "=")
+			os.Setenv(key, value)
+		}
+	}
+	return os.Setenv("PATH", strings.Join(append(dirs, os.Getenv("PATH")), string(os.PathListSeparator)))
+}
@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// SBOMFormats lists the supported `run sbom --format` values.
+var SBOMFormats = []string{"cyclonedx", "spdx"}
+
+// SBOMComponent is an apt package attributed to the run package that installed it.
+type SBOMComponent struct {
+	RunPackage string
+	AptPackage
+}
+
+// CollectSBOMComponents returns the apt packages of all installed run packages.
+func CollectSBOMComponents() []SBOMComponent {
+	var components []SBOMComponent
+	for _, runPackage := range ListPackages() {
+		for _, aptPackage := range InstalledAptPackages(runPackage) {
+			components = append(components, SBOMComponent{RunPackage: runPackage, AptPackage: aptPackage})
+		}
+	}
+	return components
+}
+
+// GenerateSBOM renders the components in the given format as JSON.
+func GenerateSBOM(format string, components []SBOMComponent) ([]byte, error) {
+	host, _ := os.Hostname()
+	switch format {
+	case "cyclonedx":
+		return json.MarshalIndent(cycloneDXDocument(host, components), "", "  ")
+	case "spdx":
+		return json.MarshalIndent(spdxDocument(host, components), "", "  ")
+	default:
+		return nil, fmt.Errorf("unknown SBOM format '%s': use %s", format, strings.Join(SBOMFormats, " or "))
+	}
+}
+
+// distroID returns the ID from /etc/os-release, used as the purl namespace.
+func distroID() string {
+	if id := osRelease("ID"); id != "" {
+		return id
+	}
+	return "debian"
+}
+
+// purl returns the package URL of an apt package.
+func (c SBOMComponent) purl(distro string) string {
+	return fmt.Sprintf("pkg:deb/%s/%s@%s?arch=%s", distro, c.Name, url.PathEscape(c.Version), c.Architecture)
+}
+
+// newUUID returns a random RFC 4122 version 4 UUID.
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func cycloneDXDocument(host string, components []SBOMComponent) map[string]any {
+	distro := distroID()
+	var bomComponents []map[string]any
+	for _, c := range components {
+		properties := []map[string]string{{"name": "run:package", "value": c.RunPackage}}
+		if c.Origin != "" {
+			properties = append(properties, map[string]string{"name": "apt:origin", "value": c.Origin})
+		}
+		bomComponents = append(bomComponents, map[string]any{
+			"type":       "application",
+			"bom-ref":    c.purl(distro),
+			"name":       c.Name,
+			"version":    c.Version,
+			"supplier":   map[string]string{"name": c.Maintainer},
+			"purl":       c.purl(distro),
+			"properties": properties,
+		})
+	}
+
+	return map[string]any{
+		"bomFormat":    "CycloneDX",
+		"specVersion":  "1.5",
+		"serialNumber": "urn:uuid:" + newUUID(),
+		"version":      1,
+		"metadata": map[string]any{
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"tools":     []map[string]string{{"name": CLIName}},
+			"component": map[string]string{"type": "device", "name": host},
+		},
+		"components": bomComponents,
+	}
+}
+
+func spdxDocument(host string, components []SBOMComponent) map[string]any {
+	distro := distroID()
+	var packages []map[string]any
+	for i, c := range components {
+		downloadLocation := "NOASSERTION"
+		if c.Origin != "" {
+			downloadLocation = c.Origin
+		}
+		packages = append(packages, map[string]any{
+			"name":             c.Name,
+			"SPDXID":           fmt.Sprintf("SPDXRef-Package-%d", i+1),
+			"versionInfo":      c.Version,
+			"supplier":         "Organization: " + c.Maintainer,
+			"downloadLocation": downloadLocation,
+			"filesAnalyzed":    false,
+			"licenseConcluded": "NOASSERTION",
+			"licenseDeclared":  "NOASSERTION",
+			"copyrightText":    "NOASSERTION",
+			"comment":          "Installed by run package " + c.RunPackage,
+			"externalRefs": []map[string]string{{
+				"referenceCategory": "PACKAGE-MANAGER",
+				"referenceType":     "purl",
+				"referenceLocator":  c.purl(distro),
+			}},
+		})
+	}
+
+	return map[string]any{
+		"spdxVersion":       "SPDX-2.3",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              "run-managed-software-" + host,
+		"documentNamespace": fmt.Sprintf("https://github.com/amoga-io/run/spdx/%s-%s", host, newUUID()),
+		"creationInfo": map[string]any{
+			"created":  time.Now().UTC().Format(time.RFC3339),
+			"creators": []string{"Tool: " + CLIName},
+		},
+		"packages": packages,
+	}
+}
@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// packageNamePattern is what `run registry new` accepts. Names become
+// environment variables such as <NAME>_VERSION, so they have no dashes.
+var packageNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// scaffoldData fills the package templates.
+type scaffoldData struct {
+	Name          string
+	Env           string
+	SchemaVersion int
+	Dir           string
+}
+
+var scaffoldRegistry = template.Must(template.New("registry").Parse(`# {{.Name}} for the run registry. Merge it into ~/.run/registry/user.yaml and
+# copy the scripts to ~/.run/scripts, or add both to a run checkout. Check it
+# with: run validate --scripts-dir {{.Dir}}/scripts {{.Dir}}/{{.Name}}.yaml
+# yaml-language-server: $schema=https://raw.githubusercontent.com/amoga-io/run/main/internal/registry.schema.json
+schema_version: {{.SchemaVersion}}
+packages:
+  {{.Name}}:
+    install: {{.Name}}.sh
+    remove: remove-{{.Name}}.sh
+    # depends: [essentials]
+    # suggests: [hardening]
+    next_steps:
+      - "Describe what to do after installing {{.Name}}"
+`))
+
+var scaffoldInstall = template.Must(template.New("install").Parse(`#!/bin/bash
+
+# {{.Name}} installation
+#
+# Environment (set by ` + "`run install {{.Name}}`" + `):
+#   {{.Env}}_VERSION  version to install, from a profile's versions
+
+set -euo pipefail
+
+{{.Env}}_VERSION="${ {{- .Env}}_VERSION:-latest}"
+
+# Never stop at a debconf prompt or a changed config file
+export DEBIAN_FRONTEND=noninteractive
+apt_install() {
+    sudo DEBIAN_FRONTEND=noninteractive apt-get install -y \
+        -o Dpkg::Options::=--force-confdef -o Dpkg::Options::=--force-confold "$@"
+}
+
+# Step markers make the package log in ~/.run/logs easy to follow
+step() {
+    echo "==> $*"
+}
+
+step "Installing prerequisites"
+sudo apt-get update
+apt_install ca-certificates curl
+
+step "Installing {{.Name}} ${ {{- .Env}}_VERSION}"
+# TODO: install {{.Name}}, e.g. from its vendor's apt repository
+
+step "Verifying"
+# TODO: fail unless {{.Name}} works, e.g. {{.Name}} --version
+
+echo "{{.Name}} installed successfully"
+`))
+
+var scaffoldRemove = template.Must(template.New("remove").Parse(`#!/bin/bash
+
+# {{.Name}} removal. run cleans up unused dependencies afterwards.
+
+set -euo pipefail
+
+step() {
+    echo "==> $*"
+}
+
+step "Removing {{.Name}}"
+# TODO: remove what {{.Name}}.sh installed, e.g.
+# sudo DEBIAN_FRONTEND=noninteractive apt-get purge -y {{.Name}}
+
+echo "{{.Name}} has been removed"
+`))
+
+// ScaffoldPackage writes a registry file for a new package and its install
+// and removal scripts into dir: <name>.yaml and scripts/<name>.sh and
+// scripts/remove-<name>.sh. Existing files are never overwritten. It
+// returns the files written.
+func ScaffoldPackage(name, dir string) ([]string, error) {
+	if !packageNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid package name '%s': use lowercase letters, digits and underscores", name)
+	}
+	if _, exists := InstallPackageRegistry[name]; exists {
+		return nil, fmt.Errorf("'%s' is already a registry package", name)
+	}
+
+	data := scaffoldData{Name: name, Env: strings.ToUpper(name), SchemaVersion: RegistrySchemaVersion, Dir: dir}
+	files := []struct {
+		path     string
+		template *template.Template
+		mode     os.FileMode
+	}{
+		{filepath.Join(dir, name+".yaml"), scaffoldRegistry, 0644},
+		{filepath.Join(dir, "scripts", name+".sh"), scaffoldInstall, 0755},
+		{filepath.Join(dir, "scripts", "remove-"+name+".sh"), scaffoldRemove, 0755},
+	}
+	for _, file := range files {
+		if _, err := os.Stat(file.path); err == nil {
+			return nil, fmt.Errorf("%s already exists", file.path)
+		}
+	}
+
+	var written []string
+	for _, file := range files {
+		var content bytes.Buffer
+		if err := file.template.Execute(&content, data); err != nil {
+			return written, fmt.Errorf("failed to render %s: %v", file.path, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(file.path), 0755); err != nil {
+			return written, fmt.Errorf("failed to create %s: %v", filepath.Dir(file.path), err)
+		}
+		if err := os.WriteFile(file.path, content.Bytes(), file.mode); err != nil {
+			return written, fmt.Errorf("failed to write %s: %v", file.path, err)
+		}
+		written = append(written, file.path)
+	}
+	return written, nil
+}
@@ -0,0 +1,305 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ScriptFinding is a problem found in a package script.
+type ScriptFinding struct {
+	Line    int
+	Rule    string
+	Message string
+}
+
+func (f ScriptFinding) String() string {
+	return fmt.Sprintf("line %d: %s [%s]", f.Line, f.Message, f.Rule)
+}
+
+// errexitPattern matches the ways of making a script stop at the first
+// failing command.
+var errexitPattern = regexp.MustCompile(`(?m)^\s*set\s+(-[a-zA-Z]*e[a-zA-Z]*\b|-o\s+errexit\b)|^#!\S+\s+-[a-zA-Z]*e`)
+
+// heredocPattern matches the start of a here-document and its delimiter.
+var heredocPattern = regexp.MustCompile(`<<-?\s*['"]?([A-Za-z_][A-Za-z0-9_]*)['"]?`)
+
+// assignmentPattern matches a word that assigns a variable, where an
+// unquoted expansion is not split.
+var assignmentPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*\+?=`)
+
+// interactiveCommand is a command that waits for input unless told not to.
+type interactiveCommand struct {
+	pattern *regexp.Regexp
+	// unless is an option that makes the command non-interactive.
+	unless  *regexp.Regexp
+	message string
+}
+
+// commandStart matches where a command begins on a line: at its start,
+// after a separator or after sudo and its options.
+const commandStart = `(?:^\s*|[;&|(]\s*|\bsudo\s+(?:-\S+\s+)*)`
+
+// interactiveCommands hang an unattended install waiting for a prompt.
+var interactiveCommands = []interactiveCommand{
+	{
+		pattern: regexp.MustCompile(`\bapt(?:-get)?\s+(?:\S+\s+)*?(?:install|upgrade|dist-upgrade|full-upgrade|remove|purge|autoremove)\b`),
+		unless:  regexp.MustCompile(`\s(?:-[a-zA-Z]*y[a-zA-Z]*|--yes|--assume-yes)\b`),
+		message: "apt-get asks for confirmation without -y",
+	},
+	{
+		pattern: regexp.MustCompile(commandStart + `apt\s`),
+		message: "apt has no stable interface for scripts; use apt-get",
+	},
+	{
+		pattern: regexp.MustCompile(`\badd-apt-repository\b`),
+		unless:  regexp.MustCompile(`\s(?:-y|--yes)\b`),
+		message: "add-apt-repository asks for confirmation without -y",
+	},
+	{
+		pattern: regexp.MustCompile(`\bdpkg-reconfigure\b`),
+		unless:  regexp.MustCompile(`noninteractive`),
+		message: "dpkg-reconfigure prompts unless run with -f noninteractive",
+	},
+	{
+		pattern: regexp.MustCompile(`\bssh-keygen\b`),
+		unless:  regexp.MustCompile(`\s-N\b`),
+		message: "ssh-keygen asks for a passphrase without -N",
+	},
+	{
+		pattern: regexp.MustCompile(commandStart + `read\s(?:.*\s)?-p\b`),
+		message: "read -p prompts for input",
+	},
+	{
+		pattern: regexp.MustCompile(commandStart + `(?:passwd|visudo|vipw|nano|vim?|crontab\s+-e)\b`),
+		message: "opens an interactive prompt or editor",
+	},
+}
+
+// LintScript checks a package script for problems that make unattended
+// installs unreliable: a missing set -e (when errexit is required),
+// interactive commands and unquoted variables. When shellcheck is
+// installed it replaces the built-in check for unquoted variables and adds
+// its other warnings.
+func LintScript(path string, errexit bool) ([]ScriptFinding, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	script := string(data)
+
+	var findings []ScriptFinding
+	if errexit && !errexitPattern.MatchString(script) {
+		findings = append(findings, ScriptFinding{Line: 1, Rule: "errexit", Message: "no set -e: the script continues after a failing command"})
+	}
+	lines := scriptLines(script)
+	for number, line := range lines {
+		if line == "" {
+			continue
+		}
+		for _, command := range interactiveCommands {
+			if command.pattern.MatchString(line) && (command.unless == nil || !command.unless.MatchString(line)) {
+				findings = append(findings, ScriptFinding{Line: number + 1, Rule: "interactive", Message: command.message})
+			}
+		}
+	}
+
+	if _, err := exec.LookPath("shellcheck"); err == nil {
+		checked, err := shellcheck(path)
+		if err != nil {
+			return findings, err
+		}
+		return append(findings, checked...), nil
+	}
+	return append(findings, unquotedVariables(script)...), nil
+}
+
+// shellcheck runs shellcheck on a script and returns its warnings and errors.
+func shellcheck(path string) ([]ScriptFinding, error) {
+	// shellcheck exits with 1 when it has findings
+	output, _ := exec.Command("shellcheck", "--format=json1", "--severity=warning", path).Output()
+	var report struct {
+		Comments []struct {
+			Line    int    `json:"line"`
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"comments"`
+	}
+	if err := json.Unmarshal(output, &report); err != nil {
+		return nil, fmt.Errorf("failed to read shellcheck output for %s: %v", path, err)
+	}
+	var findings []ScriptFinding
+	for _, comment := range report.Comments {
+		findings = append(findings, ScriptFinding{Line: comment.Line, Rule: fmt.Sprintf("SC%d", comment.Code), Message: comment.Message})
+	}
+	return findings, nil
+}
+
+// scriptLines returns the lines of a script with comments and the bodies
+// of here-documents blanked, keeping line numbers.
+func scriptLines(script string) []string {
+	lines := strings.Split(script, "\n")
+	terminator := ""
+	for i, line := range lines {
+		if terminator != "" {
+			if strings.TrimSpace(line) == terminator {
+				terminator = ""
+			}
+			lines[i] = ""
+			continue
+		}
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "#") {
+			lines[i] = ""
+			continue
+		}
+		if match := heredocPattern.FindStringSubmatch(line); match != nil {
+			terminator = match[1]
+		}
+	}
+	return lines
+}
+
+// unquotedVariables finds variables expanded outside double quotes, where
+// values with spaces or globs split into several words. Assignments,
+// [[ ]] tests, arithmetic, case and for lines, and special parameters such
+// as $? are left alone, as are lines after a shellcheck directive
+// disabling SC2086, for intended splitting.
+func unquotedVariables(script string) []ScriptFinding {
+	var findings []ScriptFinding
+	raw := strings.Split(script, "\n")
+	lines := scriptLines(script)
+	inSingle, inDouble, inTest := false, false, false
+	// Command substitutions start a new quoting context; this holds whether
+	// each open one is inside double quotes
+	var substitutions []bool
+	for number, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "case ") || strings.HasPrefix(trimmed, "for ") {
+			continue
+		}
+		if number > 0 && strings.Contains(raw[number-1], "shellcheck disable=") && strings.Contains(raw[number-1], "SC2086") {
+			continue
+		}
+		wordStart := 0
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			switch {
+			case c == '\\' && !inSingle:
+				i++
+			case c == '\'' && !inDouble:
+				inSingle = !inSingle
+			case c == '"' && !inSingle:
+				inDouble = !inDouble
+			case strings.HasPrefix(line[i:], "$(") && !strings.HasPrefix(line[i:], "$((") && !inSingle:
+				substitutions = append(substitutions, inDouble)
+				inDouble = false
+				i++
+			case c == ')' && !inSingle && !inDouble && len(substitutions) > 0:
+				inDouble = substitutions[len(substitutions)-1]
+				substitutions = substitutions[:len(substitutions)-1]
+			case inSingle || inDouble:
+			case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+				i = len(line)
+			case c == ' ' || c == '\t' || c == ';':
+				wordStart = i + 1
+			case strings.HasPrefix(line[i:], "[["):
+				inTest = true
+			case strings.HasPrefix(line[i:], "]]"):
+				inTest = false
+			case strings.HasPrefix(line[i:], "$(("):
+				if end := strings.Index(line[i:], "))"); end >= 0 {
+					i += end + 1
+				}
+			case c == '$' && !inTest && !assignmentPattern.MatchString(line[wordStart:]):
+				if name := expansionName(line[i+1:]); name != "" {
+					findings = append(findings, ScriptFinding{
+						Line:    number + 1,
+						Rule:    "quote",
+						Message: fmt.Sprintf("unquoted $%s is split into words; quote it as \"$%s\"", name, name),
+					})
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// expansionName returns the name of the variable expanded by the text
+// following a $, or an empty string for command substitutions and special
+// parameters that need no quoting.
+func expansionName(text string) string {
+	if text == "" {
+		return ""
+	}
+	if text[0] == '{' {
+		if end := strings.IndexByte(text, '}'); end > 0 {
+			if strings.HasPrefix(text[1:end], "#") {
+				// ${#var} is a length
+				return ""
+			}
+			return expansionName(text[1:end])
+		}
+		return ""
+	}
+	if text[0] == '@' || text[0] == '*' || text[0] >= '0' && text[0] <= '9' {
+		return text[:1]
+	}
+	end := 0
+	for end < len(text) && (text[end] == '_' || text[end] >= 'a' && text[end] <= 'z' || text[end] >= 'A' && text[end] <= 'Z' || end > 0 && text[end] >= '0' && text[end] <= '9') {
+		end++
+	}
+	return text[:end]
+}
+
+// LintRegistryScripts lints the install and removal scripts of every
+// registry package in the scripts directory, keyed by script name.
+// Removal scripts are best effort, so they may leave out set -e.
+func LintRegistryScripts() (map[string][]ScriptFinding, error) {
+	scriptsDir, err := ScriptsDir()
+	if err != nil {
+		return nil, err
+	}
+	findings := map[string][]ScriptFinding{}
+	for _, name := range ListPackages() {
+		scripts := map[string]bool{InstallPackageRegistry[name]: true}
+		if remove := RemovePackageRegistry[name]; remove != "" {
+			scripts[remove] = false
+		}
+		for script, errexit := range scripts {
+			scriptFindings, err := LintScript(filepath.Join(scriptsDir, script), errexit)
+			if err != nil {
+				// Missing scripts are reported by the registry check
+				continue
+			}
+			findings[script] = scriptFindings
+		}
+	}
+	return findings, nil
+}
+
+// checkScriptLint reports the scripts with findings, for `run doctor`.
+func checkScriptLint() []CheckResult {
+	findings, err := LintRegistryScripts()
+	if err != nil {
+		return []CheckResult{{Name: "scripts", OK: false, Message: err.Error()}}
+	}
+	var results []CheckResult
+	for _, script := range mapKeys(findings) {
+		if len(findings[script]) == 0 {
+			continue
+		}
+		results = append(results, CheckResult{
+			Name:    script,
+			OK:      false,
+			Message: fmt.Sprintf("%d finding(s), first %s; see run validate", len(findings[script]), findings[script][0]),
+		})
+	}
+	if len(results) == 0 {
+		return []CheckResult{{Name: "scripts", OK: true, Message: fmt.Sprintf("%d script(s) without findings", len(findings))}}
+	}
+	return results
+}
@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/amoga-io/run/internal/output"
+)
+
+// PackageLogPath returns the log file that receives the script output of a
+// package (~/.run/logs/<package>.log).
+func PackageLogPath(packageName string) (string, error) {
+	logsDir, err := LogsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(logsDir, packageName+".log"), nil
+}
+
+// openPackageLog opens the log of a package for appending.
+func openPackageLog(packageName string) (*os.File, error) {
+	setCurrentPackage(packageName)
+	logPath, err := PackageLogPath(packageName)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", filepath.Dir(logPath), err)
+	}
+	file, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", logPath, err)
+	}
+	return file, nil
+}
+
+// logWriter writes complete lines to a log, each prefixed with a timestamp
+// and the stream it came from. Partial lines are held until their newline
+// arrives or the writer is flushed. Writers sharing a log share its mutex,
+// so lines from stdout and stderr never interleave.
+type logWriter struct {
+	mu     *sync.Mutex
+	log    io.Writer
+	stream string
+	buf    []byte
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.writeLine(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+}
+
+// flush writes a trailing line that has no newline.
+func (w *logWriter) flush() {
+	if len(w.buf) > 0 {
+		w.writeLine(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *logWriter) writeLine(line []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Log failures must not fail the script, so errors are dropped here
+	fmt.Fprintf(w.log, "%s [%s] %s\n", time.Now().Format(time.RFC3339), w.stream, bytes.TrimRight(line, "\r"))
+}
+
+// Script output modes for ScriptOutputMode.
+const (
+	// ScriptOutputRaw passes script output through unchanged.
+	ScriptOutputRaw = "raw"
+	// ScriptOutputPrefix prefixes each line with the package name.
+	ScriptOutputPrefix = "prefix"
+	// ScriptOutputGroup holds a package's output back and prints it as one
+	// block once its script finishes.
+	ScriptOutputGroup = "group"
+	// ScriptOutputActions wraps each package's output in a GitHub Actions
+	// log group.
+	ScriptOutputActions = "actions"
+)
+
+// ScriptOutputMode sets how script output reaches the console.
+var ScriptOutputMode = ScriptOutputRaw
+
+// scriptConsole returns the console writers for the stdout and stderr of a
+// package script, and a function that writes out anything they hold back.
+func scriptConsole(packageName string) (io.Writer, io.Writer, func()) {
+	switch ScriptOutputMode {
+	case ScriptOutputPrefix:
+		stdout := output.NewPrefixWriter(Console, packageName, output.ColorEnabled(Console))
+		stderr := output.NewPrefixWriter(os.Stderr, packageName, output.ColorEnabled(os.Stderr))
+		return stdout, stderr, func() {
+			stdout.Flush()
+			stderr.Flush()
+		}
+	case ScriptOutputGroup:
+		group := output.NewGroupWriter(Console, packageName, output.ColorEnabled(Console))
+		return group, group, func() { group.Flush() }
+	case ScriptOutputActions:
+		fmt.Fprintln(Console, output.ActionsGroupStart(packageName))
+		return Console, os.Stderr, func() { fmt.Fprintln(Console, output.ActionsGroupEnd) }
+	}
+	return Console, os.Stderr, func() {}
+}
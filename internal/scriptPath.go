@@ -2,13 +2,84 @@ package internal
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/amoga-io/run/internal/system"
 )
 
 var CLIName = "run"
 
+// ScriptsDirEnv names the environment variable that points run at a scripts
+// directory other than ~/.run/scripts, for developing scripts in a checkout.
+const ScriptsDirEnv = "RUN_SCRIPTS_DIR"
+
+// ScriptsDirOverride is set from --scripts-dir and takes precedence over
+// RUN_SCRIPTS_DIR.
+var ScriptsDirOverride string
+
+// CustomScriptsDir returns the overriding scripts directory, or an empty
+// string when the official scripts in ~/.run, those installed with a
+// packaged run or those embedded in the binary are used.
+func CustomScriptsDir() string {
+	if ScriptsDirOverride != "" {
+		return ScriptsDirOverride
+	}
+	return os.Getenv(ScriptsDirEnv)
+}
+
+// ScriptsDir returns the directory package scripts are run from: the
+// overriding directory, the checkout in ~/.run/scripts, the scripts of a
+// packaged run, or else those embedded in the binary.
+func ScriptsDir() (string, error) {
+	if custom := CustomScriptsDir(); custom != "" {
+		dir, err := filepath.Abs(custom)
+		if err != nil {
+			return "", err
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return "", fmt.Errorf("scripts directory not found: %s", dir)
+		}
+		return dir, nil
+	}
+
+	runDir, err := RunDir()
+	if err != nil {
+		return "", err
+	}
+	scriptsDir := filepath.Join(runDir, "scripts")
+	if _, err := os.Stat(scriptsDir); os.IsNotExist(err) {
+		if packaged := PackagedScriptsDir(); packaged != "" {
+			return packaged, nil
+		}
+		return EmbeddedScriptsDir()
+	}
+	return scriptsDir, nil
+}
+
+// PackagedScriptsDir returns the scripts installed with run by a package
+// manager, in share/run/scripts next to the bin directory of the binary
+// (/usr/share/run/scripts, or the Homebrew keg), or an empty string when run
+// was not installed that way.
+func PackagedScriptsDir() string {
+	executable, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+	dir := filepath.Join(filepath.Dir(executable), "..", "share", CLIName, "scripts")
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return ""
+	}
+	return filepath.Clean(dir)
+}
+
 func getScriptName(command, packageName string) (string, bool) {
 	if command == "install" {
 		script, exists := InstallPackageRegistry[packageName]
@@ -25,17 +96,19 @@ func GetScriptPath(command, packageName string) (string, error) {
 	if !exists {
 		return "", fmt.Errorf("no script found for command '%s' and package '%s'", command, packageName)
 	}
-	home, err := os.UserHomeDir()
+	scriptDir, err := ScriptsDir()
 	if err != nil {
-		return "", fmt.Errorf("error getting home directory: %v", err)
+		return "", err
 	}
-	scriptDir := filepath.Join(home, "."+CLIName, "scripts")
 	scriptPath := filepath.Join(scriptDir, script)
 
 	return scriptPath, nil
 }
 
-func ExecuteScript(scriptPath string) error {
+// ExecuteScript runs a script of a package. Its output streams to the console
+// as set by ScriptOutputMode and is appended, line by line with timestamps, to the package's log in
+// ~/.run/logs. A log that cannot be opened only costs the log.
+func ExecuteScript(packageName, scriptPath string, env ...string) error {
 	// Check if script exists
 	if _, err := os.Stat(scriptPath); os.IsNotExist(err) {
 		return fmt.Errorf("script not found: %s", scriptPath)
@@ -46,17 +119,94 @@ func ExecuteScript(scriptPath string) error {
 		return fmt.Errorf("failed to make script executable: %v", err)
 	}
 
-	fmt.Printf("Executing script: %s\n", scriptPath)
+	fmt.Fprintf(Console, "Executing script: %s\n", scriptPath)
 
 	// Execute the script
+	consoleOut, consoleErr, flushConsole := scriptConsole(packageName)
+	defer flushConsole()
 	cmd := exec.Command(scriptPath)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = consoleOut
+	cmd.Stderr = consoleErr
 	cmd.Stdin = os.Stdin
+	cmd.Env = append(os.Environ(), env...)
+	if Strict {
+		cmd.Env = append(cmd.Env, StrictEnv+"=1")
+	}
+	// Scripts call back into run, as with `run download`, through RUN_BIN
+	if executable, err := os.Executable(); err == nil {
+		cmd.Env = append(cmd.Env, "RUN_BIN="+executable)
+	}
+	cmd.Env = append(cmd.Env, PackageEnv+"="+packageName)
+	if shimDir, err := sudoShimDir(); err != nil {
+		Warn(packageName, "%v", err)
+	} else if shimDir != "" {
+		cmd.Env = append(cmd.Env, "PATH="+shimDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	}
 
-	if err := cmd.Run(); err != nil {
+	var logs []*logWriter
+	if logFile, err := openPackageLog(packageName); err != nil {
+		Warn(packageName, "%v", err)
+	} else {
+		defer logFile.Close()
+		var mu sync.Mutex
+		fmt.Fprintf(logFile, "%s [run] %s\n", time.Now().Format(time.RFC3339), scriptPath)
+		stdout := &logWriter{mu: &mu, log: logFile, stream: "stdout"}
+		stderr := &logWriter{mu: &mu, log: logFile, stream: "stderr"}
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, stdout)
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, stderr)
+		logs = append(logs, stdout, stderr)
+	}
+
+	err := cmd.Run()
+	for _, log := range logs {
+		log.flush()
+	}
+	if err != nil {
 		return fmt.Errorf("failed to execute script: %v", err)
 	}
 
 	return nil
 }
+
+// sudoShim stands in for sudo in package scripts when run is root and sudo
+// is not installed: it runs the command directly, or with runuser for -u.
+const sudoShim = `#!/bin/sh
+# Managed by run - stands in for sudo when running as root without it
+user=""
+while [ $# -gt 0 ]; do
+    case "$1" in
+        -u) user="$2"; shift 2 ;;
+        --) shift; break ;;
+        -*) shift ;;
+        *) break ;;
+    esac
+done
+if [ -n "$user" ]; then
+    exec runuser -u "$user" -- "$@"
+fi
+exec "$@"
+`
+
+// sudoShimDir returns a directory holding the sudo stand-in, to put first
+// on the PATH of scripts, or an empty string when scripts can use sudo.
+func sudoShimDir() (string, error) {
+	if !system.IsRoot() {
+		return "", nil
+	}
+	if _, err := exec.LookPath("sudo"); err == nil {
+		return "", nil
+	}
+	runDir, err := RunDir()
+	if err != nil {
+		return "", err
+	}
+	// Kept out of the shim directory on PATH, so only scripts see it
+	dir := filepath.Join(runDir, "shims", "root")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sudo"), []byte(sudoShim), 0755); err != nil {
+		return "", fmt.Errorf("failed to write the sudo stand-in: %v", err)
+	}
+	return dir, nil
+}
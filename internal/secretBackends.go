@@ -0,0 +1,225 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// Secret backends for secrets.backend.
+const (
+	SecretBackendLocal    = "local"
+	SecretBackendVault    = "vault"
+	SecretBackendKeyVault = "azure-keyvault"
+	SecretBackendSSM      = "aws-ssm"
+)
+
+// VaultConfig configures the vault secret backend, which reads KV version 2
+// secrets over the HTTP API.
+type VaultConfig struct {
+	// Address of the Vault server; VAULT_ADDR when unset.
+	Address string `yaml:"address"`
+	// Mount is the path of the KV engine; "secret" when unset.
+	Mount string `yaml:"mount"`
+}
+
+// secretBackends resolve a secret by name from each backend.
+var secretBackends = map[string]func(settings SecretsConfig, name string) (string, error){
+	SecretBackendLocal:    localSecret,
+	SecretBackendVault:    vaultSecret,
+	SecretBackendKeyVault: keyVaultSecret,
+	SecretBackendSSM:      ssmSecret,
+}
+
+// secretNamePattern limits secret names to what every backend can look up:
+// db/password, db_password.
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_/-]*$`)
+
+// resolvedSecrets caches secrets for the life of the process, so a template
+// asks the backend once per secret. They are never written to disk.
+var resolvedSecrets = map[string]string{}
+
+// GetSecret resolves a secret from secrets.backend: the local secrets
+// store, Vault, Azure Key Vault or AWS SSM Parameter Store.
+func GetSecret(name string) (string, error) {
+	if value, exists := resolvedSecrets[name]; exists {
+		return value, nil
+	}
+	if !secretNamePattern.MatchString(name) || strings.Contains(name, "//") || strings.HasSuffix(name, "/") {
+		return "", fmt.Errorf("invalid secret name '%s': use letters, digits, _, - and / between parts", name)
+	}
+	settings, err := secretsSettings()
+	if err != nil {
+		return "", err
+	}
+	value, err := secretBackends[settings.SecretBackend()](settings, name)
+	if err != nil {
+		return "", err
+	}
+	resolvedSecrets[name] = value
+	return value, nil
+}
+
+// SecretBackend returns the configured backend, local when unset.
+func (c SecretsConfig) SecretBackend() string {
+	if c.Backend == "" {
+		return SecretBackendLocal
+	}
+	return c.Backend
+}
+
+// validateSecretBackend checks the settings of the configured backend.
+func (c SecretsConfig) validateSecretBackend() error {
+	switch c.SecretBackend() {
+	case SecretBackendLocal, SecretBackendVault, SecretBackendSSM:
+		return nil
+	case SecretBackendKeyVault:
+		if c.KeyVault == "" {
+			return fmt.Errorf("secrets.key_vault must name the Azure Key Vault to read secrets from")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown secrets.backend '%s': use local, vault, azure-keyvault or aws-ssm", c.Backend)
+}
+
+// localSecret reads a secret from the secrets store.
+func localSecret(settings SecretsConfig, name string) (string, error) {
+	secrets, err := LoadSecrets()
+	if err != nil {
+		return "", err
+	}
+	value, exists := secrets[name]
+	if !exists {
+		return "", fmt.Errorf("secret '%s' is not in the secrets store", name)
+	}
+	return value, nil
+}
+
+// vaultToken returns the token of VAULT_TOKEN or ~/.vault-token, where
+// `vault login` keeps it.
+func vaultToken() (string, error) {
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(filepath.Join(home, ".vault-token"))
+	if err != nil {
+		return "", fmt.Errorf("no Vault token: set VAULT_TOKEN or run vault login")
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// vaultSecret reads a field of a KV version 2 secret: db/password is the
+// field password of the secret db. A name without / reads the field value.
+func vaultSecret(settings SecretsConfig, name string) (string, error) {
+	address := settings.Vault.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if address == "" {
+		return "", fmt.Errorf("set secrets.vault.address or VAULT_ADDR to read secrets from Vault")
+	}
+	mount := settings.Vault.Mount
+	if mount == "" {
+		mount = "secret"
+	}
+	token, err := vaultToken()
+	if err != nil {
+		return "", err
+	}
+	secretPath, field := name, "value"
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		secretPath, field = name[:i], name[i+1:]
+	}
+
+	endpoint, err := url.JoinPath(address, "v1", mount, "data", secretPath)
+	if err != nil {
+		return "", fmt.Errorf("invalid Vault address %s: %v", address, err)
+	}
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach Vault: %v", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", fmt.Errorf("secret '%s' not found in Vault at %s/%s", name, mount, secretPath)
+	case http.StatusForbidden:
+		return "", fmt.Errorf("access to %s/%s denied by Vault: check the token and its policies", mount, secretPath)
+	default:
+		return "", fmt.Errorf("Vault returned %s reading %s/%s", resp.Status, mount, secretPath)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to parse the Vault response: %v", err)
+	}
+	value, exists := body.Data.Data[field]
+	if !exists {
+		return "", fmt.Errorf("secret %s/%s in Vault has no field '%s'", mount, secretPath, field)
+	}
+	return fmt.Sprint(value), nil
+}
+
+// keyVaultSecret reads a secret from Azure Key Vault with the az CLI, signed
+// in as the VM's managed identity or a user. Key Vault names only allow
+// letters, digits and dashes, so db/password reads db-password. Names with
+// _ or - are refused: db_password and db-password would read it too.
+func keyVaultSecret(settings SecretsConfig, name string) (string, error) {
+	if strings.ContainsAny(name, "_-") {
+		return "", fmt.Errorf("secret name '%s' cannot be read from Key Vault: separate its parts with / only, e.g. db/password reads the Key Vault secret db-password", name)
+	}
+	secretName := strings.ReplaceAll(name, "/", "-")
+	output, err := system.Command("az", "keyvault", "secret", "show",
+		"--vault-name", settings.KeyVault, "--name", secretName, "--query", "value", "--output", "tsv").CaptureOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s from Key Vault %s: %v", secretName, settings.KeyVault, err)
+	}
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
+
+// ssmSecret reads a SecureString parameter from AWS SSM Parameter Store
+// with the aws CLI, using the instance role or configured credentials. The
+// parameter is secrets.ssm_prefix followed by the name: /prod/db/password.
+func ssmSecret(settings SecretsConfig, name string) (string, error) {
+	prefix := settings.SSMPrefix
+	if prefix == "" {
+		prefix = "/"
+	}
+	parameter := strings.TrimSuffix(prefix, "/") + "/" + name
+	args := []string{"ssm", "get-parameter", "--name", parameter, "--with-decryption",
+		"--query", "Parameter.Value", "--output", "text"}
+	if settings.SSMRegion != "" {
+		args = append(args, "--region", settings.SSMRegion)
+	}
+	output, err := system.Command("aws", args...).CaptureOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to read parameter %s from SSM: %v", parameter, err)
+	}
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
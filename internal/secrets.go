@@ -0,0 +1,318 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/amoga-io/run/internal/system"
+	"gopkg.in/yaml.v3"
+)
+
+// Encryptions of the secrets store.
+const (
+	SecretsAge = "age"
+	SecretsGPG = "gpg"
+)
+
+// SecretsConfig configures encryption at rest of the secrets store. The
+// store is decrypted in memory only, when a template is rendered.
+type SecretsConfig struct {
+	// Encryption is age or gpg; the store is plaintext YAML when unset.
+	Encryption string `yaml:"encryption"`
+	// AgeIdentity is the age identity file that decrypts the store.
+	AgeIdentity string `yaml:"age_identity"`
+	// Recipients are the age public keys or GPG key ids the store is
+	// encrypted to. For age they default to the public key of AgeIdentity.
+	// GPG keys only need to be imported: they are trusted as listed.
+	Recipients []string `yaml:"recipients"`
+
+	// Backend is where secrets are read from: local (the secrets store, the
+	// default), vault, azure-keyvault or aws-ssm.
+	Backend string `yaml:"backend"`
+	// Vault configures the vault backend.
+	Vault VaultConfig `yaml:"vault"`
+	// KeyVault is the Azure Key Vault of the azure-keyvault backend.
+	KeyVault string `yaml:"key_vault"`
+	// SSMPrefix is prepended to names by the aws-ssm backend, e.g. /prod.
+	SSMPrefix string `yaml:"ssm_prefix"`
+	// SSMRegion is the AWS region of the aws-ssm backend; that of the AWS
+	// CLI config when unset.
+	SSMRegion string `yaml:"ssm_region"`
+}
+
+// secretsStoreNames maps each encryption to the file name of its store.
+var secretsStoreNames = map[string]string{
+	"":         "secrets.yaml",
+	SecretsAge: "secrets.yaml.age",
+	SecretsGPG: "secrets.yaml.gpg",
+}
+
+// WithDefaults expands ~ in the age identity path.
+func (c SecretsConfig) WithDefaults() SecretsConfig {
+	if home, err := os.UserHomeDir(); err == nil {
+		if rest, found := strings.CutPrefix(c.AgeIdentity, "~/"); found {
+			c.AgeIdentity = filepath.Join(home, rest)
+		}
+	}
+	return c
+}
+
+// Validate checks the backend and that the configured encryption has the
+// keys it needs.
+func (c SecretsConfig) Validate() error {
+	if err := c.validateSecretBackend(); err != nil {
+		return err
+	}
+	switch c.Encryption {
+	case "":
+		return nil
+	case SecretsAge:
+		if c.AgeIdentity == "" {
+			return fmt.Errorf("secrets.age_identity must be set to encrypt secrets with age")
+		}
+		info, err := os.Stat(c.AgeIdentity)
+		if err != nil {
+			return fmt.Errorf("secrets.age_identity: %v", err)
+		}
+		if info.Mode().Perm()&0077 != 0 {
+			return fmt.Errorf("secrets.age_identity %s must only be readable by its owner: chmod 600 %s", c.AgeIdentity, c.AgeIdentity)
+		}
+	case SecretsGPG:
+		if len(c.Recipients) == 0 {
+			return fmt.Errorf("secrets.recipients must list the GPG keys to encrypt secrets to")
+		}
+	default:
+		return fmt.Errorf("unknown secrets.encryption '%s': use age or gpg", c.Encryption)
+	}
+	return nil
+}
+
+// secretsSettings returns the validated secrets config.
+func secretsSettings() (SecretsConfig, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return SecretsConfig{}, err
+	}
+	settings := config.Secrets.WithDefaults()
+	return settings, settings.Validate()
+}
+
+// secretsStorePath returns the store file of an encryption.
+func secretsStorePath(encryption string) (string, error) {
+	runDir, err := ContextDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(runDir, secretsStoreNames[encryption]), nil
+}
+
+// SecretsPath returns the location of the secrets store, a flat YAML
+// mapping readable by its owner only, encrypted with secrets.encryption
+// when set.
+func SecretsPath() (string, error) {
+	settings, err := secretsSettings()
+	if err != nil {
+		return "", err
+	}
+	return secretsStorePath(settings.Encryption)
+}
+
+// existingSecretsStore returns the encryption and path of the secrets store
+// that exists, whichever encryption it has, or an empty path.
+func existingSecretsStore() (string, string, error) {
+	for _, encryption := range []string{"", SecretsAge, SecretsGPG} {
+		path, err := secretsStorePath(encryption)
+		if err != nil {
+			return "", "", err
+		}
+		if _, err := os.Stat(path); err == nil {
+			return encryption, path, nil
+		}
+	}
+	return "", "", nil
+}
+
+// LoadSecrets reads the secrets store, returning no secrets when it does not
+// exist. A plaintext store readable by other users is refused, and so is a
+// store whose encryption differs from secrets.encryption until it is
+// converted with `run secret rekey`.
+func LoadSecrets() (map[string]string, error) {
+	settings, err := secretsSettings()
+	if err != nil {
+		return nil, err
+	}
+	path, err := secretsStorePath(settings.Encryption)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		_, existing, err := existingSecretsStore()
+		if err != nil {
+			return nil, err
+		}
+		if existing != "" {
+			return nil, fmt.Errorf("secrets %s does not match secrets.encryption; convert it with: run secret rekey", existing)
+		}
+		return map[string]string{}, nil
+	}
+	return readSecrets(settings.Encryption, path, settings.AgeIdentity)
+}
+
+// readSecrets decrypts a secrets store in memory and parses it.
+func readSecrets(encryption, path, ageIdentity string) (map[string]string, error) {
+	var data []byte
+	var err error
+	switch encryption {
+	case "":
+		info, statErr := os.Stat(path)
+		if statErr != nil {
+			return nil, fmt.Errorf("failed to read secrets %s: %v", path, statErr)
+		}
+		if info.Mode().Perm()&0077 != 0 {
+			return nil, fmt.Errorf("secrets %s must only be readable by its owner: chmod 600 %s", path, path)
+		}
+		data, err = os.ReadFile(path)
+	default:
+		if data, err = os.ReadFile(path); err == nil {
+			data, err = decryptSecretData(encryption, ageIdentity, data)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets %s: %v", path, err)
+	}
+	secrets := map[string]string{}
+	if err := yaml.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets %s: %v", path, err)
+	}
+	return secrets, nil
+}
+
+// encryptSecretData encrypts data to the recipients of secrets.encryption,
+// returning it unchanged when it is unset.
+func encryptSecretData(settings SecretsConfig, data []byte) ([]byte, error) {
+	switch settings.Encryption {
+	case SecretsAge:
+		recipients := settings.Recipients
+		if len(recipients) == 0 {
+			output, err := system.Command("age-keygen", "-y", settings.AgeIdentity).CaptureOutput()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the public key of %s: %v", settings.AgeIdentity, err)
+			}
+			recipients = strings.Fields(string(output))
+		}
+		args := []string{"--encrypt"}
+		for _, recipient := range recipients {
+			args = append(args, "--recipient", recipient)
+		}
+		return system.Command("age", args...).WithStdin(bytes.NewReader(data)).CaptureOutput()
+	case SecretsGPG:
+		// The recipients are configured explicitly, so imported keys need
+		// not be certified in the user's web of trust.
+		args := []string{"--batch", "--yes", "--trust-model", "always", "--encrypt", "--output", "-"}
+		for _, recipient := range settings.Recipients {
+			args = append(args, "--recipient", recipient)
+		}
+		return system.Command("gpg", args...).WithStdin(bytes.NewReader(data)).CaptureOutput()
+	}
+	return data, nil
+}
+
+// decryptSecretData decrypts data encrypted by encryptSecretData, with
+// ageIdentity for age and the user's GPG keys for gpg.
+func decryptSecretData(encryption, ageIdentity string, data []byte) ([]byte, error) {
+	switch encryption {
+	case SecretsAge:
+		if ageIdentity == "" {
+			return nil, fmt.Errorf("an age identity is needed to decrypt, set secrets.age_identity")
+		}
+		return system.Command("age", "--decrypt", "--identity", ageIdentity).WithStdin(bytes.NewReader(data)).CaptureOutput()
+	case SecretsGPG:
+		return system.Command("gpg", "--batch", "--quiet", "--decrypt").WithStdin(bytes.NewReader(data)).CaptureOutput()
+	}
+	return nil, fmt.Errorf("unknown encryption '%s'", encryption)
+}
+
+// SaveSecrets writes the secrets store with the configured encryption. The
+// plaintext only ever reaches age or gpg through a pipe.
+func SaveSecrets(secrets map[string]string) error {
+	settings, err := secretsSettings()
+	if err != nil {
+		return err
+	}
+	path, err := secretsStorePath(settings.Encryption)
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+
+	if data, err = encryptSecretData(settings, data); err != nil {
+		return fmt.Errorf("failed to encrypt secrets: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write secrets %s: %v", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write secrets %s: %v", path, err)
+	}
+	return nil
+}
+
+// RekeySecrets re-encrypts the secrets store with the configured
+// encryption and recipients, converting a store of another encryption,
+// such as plaintext, and removing it. oldIdentity decrypts an age store
+// encrypted to a previous identity. It returns the old and new paths.
+func RekeySecrets(oldIdentity string) (string, string, error) {
+	settings, err := secretsSettings()
+	if err != nil {
+		return "", "", err
+	}
+	if settings.Encryption == "" {
+		return "", "", fmt.Errorf("set secrets.encryption to age or gpg in ~/.run/config.yaml first")
+	}
+	encryption, oldPath, err := existingSecretsStore()
+	if err != nil {
+		return "", "", err
+	}
+	// The configured store takes precedence when another is also present
+	if path, err := secretsStorePath(settings.Encryption); err == nil {
+		if _, err := os.Stat(path); err == nil {
+			encryption, oldPath = settings.Encryption, path
+		}
+	}
+	if oldPath == "" {
+		return "", "", fmt.Errorf("there is no secrets store to rekey")
+	}
+	if oldIdentity == "" {
+		oldIdentity = settings.AgeIdentity
+	}
+	secrets, err := readSecrets(encryption, oldPath, oldIdentity)
+	if err != nil {
+		return "", "", err
+	}
+	if err := SaveSecrets(secrets); err != nil {
+		return "", "", err
+	}
+
+	newPath, err := secretsStorePath(settings.Encryption)
+	if err != nil {
+		return "", "", err
+	}
+	if oldPath != newPath {
+		if err := os.Remove(oldPath); err != nil {
+			return oldPath, newPath, fmt.Errorf("failed to remove %s: %v", oldPath, err)
+		}
+	}
+	return oldPath, newPath, nil
+}
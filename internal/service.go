@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// ManagedService is a systemd service created with `run service create`.
+type ManagedService struct {
+	Exec             string    `json:"exec"`
+	User             string    `json:"user"`
+	EnvFile          string    `json:"env_file,omitempty"`
+	WorkingDirectory string    `json:"working_directory,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// serviceNamePattern limits service names to what is safe in a unit name.
+var serviceNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// systemdUnitDirs are searched for units a new service would shadow.
+var systemdUnitDirs = []string{"/etc/systemd/system", "/lib/systemd/system", "/usr/lib/systemd/system"}
+
+// serviceUnitPath returns where the unit of a managed service is written.
+func serviceUnitPath(name string) string {
+	return filepath.Join("/etc/systemd/system", name+".service")
+}
+
+// renderServiceUnit renders a unit that runs the service sandboxed: the
+// file system is read-only apart from its working directory and the state
+// and log directories systemd creates for it.
+func renderServiceUnit(name string, service ManagedService, execStart string) string {
+	var unit strings.Builder
+	fmt.Fprintf(&unit, `# Managed by run - removed by 'run service remove %s'
+[Unit]
+Description=%s
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+User=%s
+SyslogIdentifier=%s
+`, name, name, service.User, name)
+	if service.WorkingDirectory != "" {
+		fmt.Fprintf(&unit, "WorkingDirectory=%s\n", service.WorkingDirectory)
+	}
+	if service.EnvFile != "" {
+		fmt.Fprintf(&unit, "EnvironmentFile=%s\n", service.EnvFile)
+	}
+	fmt.Fprintf(&unit, `ExecStart=%s
+Restart=on-failure
+RestartSec=5
+StateDirectory=%s
+LogsDirectory=%s
+
+# Sandboxing
+NoNewPrivileges=true
+PrivateTmp=true
+PrivateDevices=true
+ProtectSystem=strict
+ProtectHome=read-only
+`, execStart, name, name)
+	if service.WorkingDirectory != "" {
+		fmt.Fprintf(&unit, "ReadWritePaths=%s\n", service.WorkingDirectory)
+	}
+	unit.WriteString(`ProtectKernelTunables=true
+ProtectKernelModules=true
+ProtectControlGroups=true
+RestrictSUIDSGID=true
+RestrictNamespaces=true
+RestrictRealtime=true
+RestrictAddressFamilies=AF_UNIX AF_INET AF_INET6
+LockPersonality=true
+SystemCallArchitectures=native
+
+[Install]
+WantedBy=multi-user.target
+`)
+	return unit.String()
+}
+
+// resolveExecStart makes the command of a service absolute, as systemd
+// requires, by looking its program up in PATH.
+func resolveExecStart(command string) (string, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("--exec must not be empty")
+	}
+	program, err := exec.LookPath(fields[0])
+	if err != nil {
+		return "", fmt.Errorf("cannot find '%s': %v", fields[0], err)
+	}
+	program, err = filepath.Abs(program)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(append([]string{program}, fields[1:]...), " "), nil
+}
+
+// CreateService writes the unit of a new service, enables and starts it,
+// and records it in the state.
+func CreateService(name string, service ManagedService) error {
+	if !serviceNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid service name '%s': use letters, digits, '.', '_' and '-'", name)
+	}
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	if _, exists := state.Services[name]; exists {
+		return fmt.Errorf("service '%s' already exists. Remove it first with: run service remove %s", name, name)
+	}
+	for _, dir := range systemdUnitDirs {
+		if _, err := os.Stat(filepath.Join(dir, name+".service")); err == nil {
+			return fmt.Errorf("a systemd unit named %s.service already exists", name)
+		}
+	}
+	if service.EnvFile != "" {
+		if service.EnvFile, err = filepath.Abs(service.EnvFile); err != nil {
+			return err
+		}
+		if _, err := os.Stat(service.EnvFile); err != nil {
+			return fmt.Errorf("cannot read env file: %v", err)
+		}
+	}
+	if service.WorkingDirectory != "" {
+		if service.WorkingDirectory, err = filepath.Abs(service.WorkingDirectory); err != nil {
+			return err
+		}
+	}
+	execStart, err := resolveExecStart(service.Exec)
+	if err != nil {
+		return err
+	}
+
+	if err := system.WriteFileAsRoot(serviceUnitPath(name), []byte(renderServiceUnit(name, service, execStart)), 0644); err != nil {
+		return err
+	}
+	if err := system.Command("systemctl", "daemon-reload").WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to reload systemd: %v", err)
+	}
+	if err := system.Command("systemctl", "enable", "--now", name+".service").WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to start %s: %v", name, err)
+	}
+
+	if state.Services == nil {
+		state.Services = make(map[string]ManagedService)
+	}
+	service.CreatedAt = time.Now().UTC()
+	state.Services[name] = service
+	return state.Save()
+}
+
+// RemoveService stops a service created with CreateService, deletes its
+// unit and forgets it.
+func RemoveService(name string) error {
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	if _, exists := state.Services[name]; !exists {
+		return fmt.Errorf("service '%s' was not created by run", name)
+	}
+
+	system.Command("systemctl", "disable", "--now", name+".service").WithSudo().Run()
+	if err := system.RemoveFileAsRoot(serviceUnitPath(name)); err != nil {
+		return err
+	}
+	if err := system.Command("systemctl", "daemon-reload").WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to reload systemd: %v", err)
+	}
+
+	delete(state.Services, name)
+	return state.Save()
+}
+
+// ServiceActive reports whether a service is running.
+func ServiceActive(name string) bool {
+	return exec.Command("systemctl", "is-active", "--quiet", name+".service").Run() == nil
+}
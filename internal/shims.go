@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// shimMarker identifies the shims run writes, so other files in the shim
+// directory are left alone.
+const shimMarker = "# Managed by run - points at the active version, updated by 'run use'"
+
+// ShimDir returns the directory of version manager shims (~/.run/shims). It
+// is the single PATH entry run adds for them, and cron jobs and systemd
+// units can call the shims there by absolute path.
+func ShimDir() (string, error) {
+	runDir, err := ContextDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(runDir, "shims"), nil
+}
+
+// Shim is a static script running a binary of the active version.
+type Shim struct {
+	Name   string
+	Target string
+	// Env is set before the target runs, e.g. JAVA_HOME.
+	Env []string
+}
+
+// script returns the content of the shim.
+func (s Shim) script() string {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n" + shimMarker + "\n")
+	for _, entry := range s.Env {
+		key, value, _ := strings.Cut(entry, "=")
+		fmt.Fprintf(&b, "export %s=\"%s\"\n", key, escapeShellValue(value))
+	}
+	fmt.Fprintf(&b, "exec \"%s\" \"$@\"\n", escapeShellValue(s.Target))
+	return b.String()
+}
+
+// activeManagerBinDirs returns the bin directory of the default version of
+// each version manager in use: nvm for node, pyenv for python and sdkman
+// for java, with the variables their binaries need.
+func activeManagerBinDirs() (map[string]string, map[string][]string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting home directory: %v", err)
+	}
+	dirs := make(map[string]string)
+	env := make(map[string][]string)
+
+	if alias, err := os.ReadFile(filepath.Join(home, ".nvm", "alias", "default")); err == nil {
+		version := strings.TrimPrefix(strings.TrimSpace(string(alias)), "v")
+		if dir := matchingVersionDir(filepath.Join(home, ".nvm", "versions", "node"), "v"+version, "."); dir != "" {
+			dirs["node"] = filepath.Join(dir, "bin")
+		}
+	}
+	if version, err := os.ReadFile(filepath.Join(home, ".pyenv", "version")); err == nil {
+		fields := strings.Fields(string(version))
+		if len(fields) > 0 && fields[0] != "system" {
+			dir := filepath.Join(home, ".pyenv", "versions", fields[0], "bin")
+			if _, err := os.Stat(dir); err == nil {
+				dirs["python"] = dir
+			}
+		}
+	}
+	if home := sdkmanJavaHome(); home != "" {
+		if resolved, err := filepath.EvalSymlinks(home); err == nil {
+			home = resolved
+		}
+		dirs["java"] = filepath.Join(home, "bin")
+		env["java"] = []string{"JAVA_HOME=" + home}
+	}
+	return dirs, env, nil
+}
+
+// ActiveShims returns the shims for the executables of the active version
+// of each version manager.
+func ActiveShims() ([]Shim, error) {
+	dirs, env, err := activeManagerBinDirs()
+	if err != nil {
+		return nil, err
+	}
+	var shims []Shim
+	for _, packageName := range mapKeys(dirs) {
+		entries, err := os.ReadDir(dirs[packageName])
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			target := filepath.Join(dirs[packageName], entry.Name())
+			if info, err := os.Stat(target); err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+				continue
+			}
+			shims = append(shims, Shim{Name: entry.Name(), Target: target, Env: env[packageName]})
+		}
+	}
+	return shims, nil
+}
+
+// SyncShims rewrites the shims for the active versions, removes shims of
+// versions no longer active and puts the shim directory on the managed
+// PATH once there are any.
+func SyncShims() ([]Shim, error) {
+	shims, err := ActiveShims()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := ShimDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+
+	wanted := make(map[string]bool)
+	for _, shim := range shims {
+		wanted[shim.Name] = true
+		path := filepath.Join(dir, shim.Name)
+		if current, err := os.ReadFile(path); err == nil && !strings.Contains(string(current), shimMarker) {
+			continue
+		}
+		if err := os.WriteFile(path, []byte(shim.script()), 0755); err != nil {
+			return nil, fmt.Errorf("failed to write shim %s: %v", path, err)
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", dir, err)
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() || wanted[entry.Name()] {
+			continue
+		}
+		if current, err := os.ReadFile(path); err == nil && strings.Contains(string(current), shimMarker) {
+			os.Remove(path)
+		}
+	}
+
+	if len(shims) == 0 {
+		return nil, nil
+	}
+	env, err := LoadManagedEnv()
+	if err != nil {
+		return nil, err
+	}
+	if !containsString(env.Path, dir) {
+		env.AddPath(dir)
+		if err := env.Save(); err != nil {
+			return nil, err
+		}
+	}
+	return shims, nil
+}
+
+// setupNode runs after the node package is installed. An nvm install gets
+// shims, which also put its npm on PATH for the global packages. pm2, when
+// among them, is then set up to start at boot.
+func setupNode() error {
+	shims, err := SyncShims()
+	if err != nil {
+		return err
+	}
+	if len(shims) > 0 {
+		dir, err := ShimDir()
+		if err != nil {
+			return err
+		}
+		os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	}
+	if err := SyncNpmGlobals(); err != nil {
+		return err
+	}
+	return EnablePM2Startup()
+}
@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PackageVersionCommands print the version of an installed package. Some
+// tools (java, nginx) write it to stderr.
+var PackageVersionCommands = map[string][]string{
+	"docker":   {"docker", "--version"},
+	"java":     {"java", "-version"},
+	"nginx":    {"nginx", "-v"},
+	"node":     {"node", "--version"},
+	"nvidia":   {"nvidia-smi", "--query-gpu=driver_version", "--format=csv,noheader"},
+	"php":      {"php", "-v"},
+	"pm2":      {"pm2", "--version"},
+	"postgres": {"psql", "--version"},
+	"python":   {"python3", "--version"},
+}
+
+var versionPattern = regexp.MustCompile(`\d+\.\d+(?:\.\d+)?`)
+
+// Snapshot records the packages installed on a host at a point in time, as
+// produced by `run snapshot`.
+type Snapshot struct {
+	Host      string                     `json:"host"`
+	CreatedAt time.Time                  `json:"created_at"`
+	Packages  map[string]PackageSnapshot `json:"packages"`
+}
+
+// PackageSnapshot is the state of one package in a snapshot.
+type PackageSnapshot struct {
+	Installed bool   `json:"installed"`
+	Version   string `json:"version,omitempty"`
+}
+
+// PackageVersion returns the installed version of a package, or an empty
+// string when it is not installed or its version cannot be determined.
+func PackageVersion(packageName string) string {
+	argv, exists := PackageVersionCommands[packageName]
+	if !exists {
+		return ""
+	}
+	output, err := exec.Command(argv[0], argv[1:]...).CombinedOutput()
+	if err != nil {
+		return ""
+	}
+	return versionPattern.FindString(string(output))
+}
+
+// TakeSnapshot inspects every package in the registry on this host.
+func TakeSnapshot() *Snapshot {
+	return SnapshotPackages(ListPackages())
+}
+
+// SnapshotPackages inspects the given packages on this host.
+func SnapshotPackages(packages []string) *Snapshot {
+	host, _ := os.Hostname()
+	snapshot := &Snapshot{
+		Host:      host,
+		CreatedAt: time.Now().UTC(),
+		Packages:  make(map[string]PackageSnapshot),
+	}
+
+	for _, packageName := range packages {
+		installed := true
+		if results, err := CheckPackage(packageName); err == nil {
+			for _, result := range results {
+				installed = installed && result.OK
+			}
+		}
+		snapshot.Packages[packageName] = PackageSnapshot{
+			Installed: installed,
+			Version:   PackageVersion(packageName),
+		}
+	}
+	return snapshot
+}
+
+// LoadSnapshot reads a snapshot from a source: a JSON file, "local" for this
+// host, or "ssh://[user@]host" to run `run snapshot` on a remote host.
+func LoadSnapshot(source string) (*Snapshot, error) {
+	if source == "local" {
+		return TakeSnapshot(), nil
+	}
+
+	var data []byte
+	var err error
+	if host, found := strings.CutPrefix(source, "ssh://"); found {
+		data, err = exec.Command("ssh", "-o", "BatchMode=yes", host, CLIName, "snapshot").Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to take snapshot on %s: %v", host, err)
+		}
+	} else if data, err = os.ReadFile(source); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %v", source, err)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot from %s: %v", source, err)
+	}
+	return &snapshot, nil
+}
+
+// SnapshotDifference describes a package whose state differs between two snapshots.
+type SnapshotDifference struct {
+	Package string
+	Left    PackageSnapshot
+	Right   PackageSnapshot
+}
+
+// DiffSnapshots returns the packages that differ, sorted by name.
+func DiffSnapshots(left, right *Snapshot) []SnapshotDifference {
+	names := make(map[string]bool)
+	for name := range left.Packages {
+		names[name] = true
+	}
+	for name := range right.Packages {
+		names[name] = true
+	}
+
+	var differences []SnapshotDifference
+	for name := range names {
+		l, r := left.Packages[name], right.Packages[name]
+		if l != r {
+			differences = append(differences, SnapshotDifference{Package: name, Left: l, Right: r})
+		}
+	}
+	sort.Slice(differences, func(i, j int) bool { return differences[i].Package < differences[j].Package })
+	return differences
+}
+
+// Describe renders the package state for diff output.
+func (p PackageSnapshot) Describe() string {
+	if !p.Installed {
+		return "not installed"
+	}
+	if p.Version == "" {
+		return "installed"
+	}
+	return p.Version
+}
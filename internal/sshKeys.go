@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// isPublicKey reports whether a line is an OpenSSH public key.
+func isPublicKey(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return false
+	}
+	return strings.HasPrefix(fields[0], "ssh-") || strings.HasPrefix(fields[0], "ecdsa-") || strings.HasPrefix(fields[0], "sk-")
+}
+
+// ResolveSSHKeys returns the public keys of a source: github:<username> for
+// the keys of a GitHub account, an https URL or a file listing keys, or a
+// key itself.
+func ResolveSSHKeys(source string) ([]string, error) {
+	var data string
+	switch {
+	case isPublicKey(source):
+		return []string{strings.TrimSpace(source)}, nil
+	case strings.HasPrefix(source, "github:"):
+		body, err := fetchSSHKeys("https://github.com/" + strings.TrimPrefix(source, "github:") + ".keys")
+		if err != nil {
+			return nil, err
+		}
+		data = body
+	case strings.HasPrefix(source, "https://"):
+		body, err := fetchSSHKeys(source)
+		if err != nil {
+			return nil, err
+		}
+		data = body
+	case strings.HasPrefix(source, "http://"):
+		return nil, fmt.Errorf("refusing to fetch SSH keys over plain http: %s", source)
+	default:
+		content, err := os.ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SSH keys: %v", err)
+		}
+		data = string(content)
+	}
+
+	var keys []string
+	for _, line := range strings.Split(data, "\n") {
+		if isPublicKey(line) {
+			keys = append(keys, strings.TrimSpace(line))
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no SSH public keys found in %s", source)
+	}
+	return keys, nil
+}
+
+// fetchSSHKeys downloads a list of keys.
+func fetchSSHKeys(url string) (string, error) {
+	if err := RequireOnline(); err != nil {
+		return "", err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch SSH keys: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch SSH keys from %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch SSH keys: %v", err)
+	}
+	return string(data), nil
+}
+
+// DeploySSHKeys creates a user unless it exists and adds the keys of the
+// sources to its authorized_keys. Keys already present are left alone.
+func DeploySSHKeys(user string, sources []string) error {
+	var keys []string
+	for _, source := range sources {
+		resolved, err := ResolveSSHKeys(source)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, resolved...)
+	}
+	if err := system.EnsureUser(user); err != nil {
+		return err
+	}
+	added, err := system.AddAuthorizedKeys(user, keys)
+	if err != nil {
+		return err
+	}
+	if added > 0 {
+		fmt.Fprintf(Console, "Added %d SSH key(s) for %s\n", added, user)
+	}
+	return nil
+}
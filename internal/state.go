@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// State records what run has set up on this host, in ~/.run/state.json.
+type State struct {
+	// PHPExtensions are extensions added with `run php ext add`. They are
+	// reinstalled whenever the active PHP version changes.
+	PHPExtensions []string `json:"php_extensions,omitempty"`
+	// LocalPackages are packages installed with `run install --from-file`,
+	// keyed by package name.
+	LocalPackages map[string]LocalPackage `json:"local_packages,omitempty"`
+	// DismissedSuggestions are install tips ("package:suggested") that were
+	// already shown.
+	DismissedSuggestions []string `json:"dismissed_suggestions,omitempty"`
+	// Services are systemd services created with `run service create`,
+	// keyed by name.
+	Services map[string]ManagedService `json:"services,omitempty"`
+	// ComposeProjects are Docker Compose stacks registered with
+	// `run compose register`, keyed by name.
+	ComposeProjects map[string]ComposeProject `json:"compose_projects,omitempty"`
+	// GeneratedFiles are files written by `run generate env`, keyed by path.
+	GeneratedFiles map[string]GeneratedFile `json:"generated_files,omitempty"`
+	// UpdateBuild is the last binary built by `run update`.
+	UpdateBuild *UpdateBuild `json:"update_build,omitempty"`
+	// AppliedSteps are the step hashes packages were last installed with by
+	// `run profile apply`, keyed by package name.
+	AppliedSteps map[string]string `json:"applied_steps,omitempty"`
+	// Versions are the versions of java, node and php installed side by
+	// side, keyed by package name.
+	Versions map[string]PackageVersions `json:"versions,omitempty"`
+	// Files are the recorded key files of installed packages, keyed by
+	// package name, for `run verify`.
+	Files map[string][]FileRecord `json:"files,omitempty"`
+	// Artifacts are the cached downloads package scripts used, keyed by
+	// package name; `run cache gc` keeps them.
+	Artifacts map[string][]ArtifactRef `json:"artifacts,omitempty"`
+}
+
+// StatePath returns the location of the state file.
+func StatePath() (string, error) {
+	runDir, err := ContextDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(runDir, "state.json"), nil
+}
+
+// LoadState reads the state file, returning an empty state when it does not exist.
+func LoadState() (*State, error) {
+	statePath, err := StatePath()
+	if err != nil {
+		return nil, err
+	}
+	return loadStateFile(statePath)
+}
+
+// loadStateFile reads a state file, such as that of another context.
+func loadStateFile(statePath string) (*State, error) {
+	state := &State{}
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, fmt.Errorf("failed to read state %s: %v", statePath, err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse state %s: %v", statePath, err)
+	}
+	return state, nil
+}
+
+// Save writes the state file atomically.
+func (s *State) Save() error {
+	statePath, err := StatePath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(statePath), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %v", err)
+	}
+
+	tempPath := statePath + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state: %v", err)
+	}
+	if err := os.Rename(tempPath, statePath); err != nil {
+		return fmt.Errorf("failed to write state: %v", err)
+	}
+	return nil
+}
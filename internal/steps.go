@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// StepHash identifies how a package was last installed: the checksum of its
+// install script, the settings passed to it and the version it left
+// installed. Re-applying a step with an unchanged hash would do nothing new.
+func StepHash(packageName string, env []string) (string, error) {
+	script, err := GetScriptPath("install", packageName)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Open(script)
+	if err != nil {
+		return "", fmt.Errorf("failed to read script: %v", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to read script: %v", err)
+	}
+	fmt.Fprintf(hash, "\x00%s\x00%s", strings.Join(env, "\x00"), PackageVersion(packageName))
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// StepUnchanged reports whether a package was last applied with hash and
+// still passes its install checks.
+func (s *State) StepUnchanged(packageName, hash string) bool {
+	if s.AppliedSteps[packageName] != hash {
+		return false
+	}
+	results, err := CheckPackage(packageName)
+	if err != nil {
+		// No checks: the version in the hash is all there is to go on
+		return true
+	}
+	for _, result := range results {
+		if !result.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// RecordStep remembers the hash a package was applied with.
+func RecordStep(packageName, hash string) error {
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	if state.AppliedSteps == nil {
+		state.AppliedSteps = make(map[string]string)
+	}
+	state.AppliedSteps[packageName] = hash
+	return state.Save()
+}
@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Strict is set by --strict, for CI and golden-image builds that must be
+// deterministic: images must be pinned by digest, local packages need a
+// checksum, packages get their registry default version instead of the
+// one a script would pick, and scripts see RUN_STRICT=1 and refuse their
+// fallbacks.
+var Strict bool
+
+// StrictEnv tells package scripts to fail instead of falling back.
+const StrictEnv = "RUN_STRICT"
+
+// verifyChecksum checks a file against the sha256 sum in <file>.sha256, in
+// the format sha256sum writes.
+func verifyChecksum(path string) error {
+	data, err := os.ReadFile(path + ".sha256")
+	if err != nil {
+		return fmt.Errorf("%s.sha256 is required with --strict: %v", path, err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return fmt.Errorf("%s.sha256 holds no checksum", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(sum, fields[0]) {
+		return fmt.Errorf("checksum of %s is %s, expected %s", path, sum, fields[0])
+	}
+	return nil
+}
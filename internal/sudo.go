@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// SudoState is what run can do as root.
+type SudoState int
+
+// Sudo states, from DetectSudo.
+const (
+	// SudoRoot means run is root and needs no sudo.
+	SudoRoot SudoState = iota
+	// SudoPasswordless means sudo works without a password.
+	SudoPasswordless
+	// SudoPassword means sudo works once the password is entered.
+	SudoPassword
+	// SudoUnavailable means sudo is missing or the user may not use it.
+	SudoUnavailable
+)
+
+// sudoRefreshInterval keeps the sudo timestamp (15 minutes by default)
+// from expiring during long installs.
+const sudoRefreshInterval = time.Minute
+
+// DetectSudo probes sudo without prompting. The reason explains
+// SudoUnavailable.
+func DetectSudo() (SudoState, string) {
+	if os.Geteuid() == 0 {
+		return SudoRoot, ""
+	}
+	if _, err := exec.LookPath("sudo"); err != nil {
+		return SudoUnavailable, "sudo is not installed"
+	}
+	output, err := exec.Command("sudo", "-n", "true").CombinedOutput()
+	if err == nil {
+		return SudoPasswordless, ""
+	}
+	message := strings.TrimSpace(string(output))
+	if strings.Contains(message, "password is required") {
+		return SudoPassword, ""
+	}
+	if message == "" {
+		message = err.Error()
+	}
+	return SudoUnavailable, message
+}
+
+// checkSudo reports whether run can act as root, and whether it will ask
+// for a password.
+func checkSudo() []CheckResult {
+	state, reason := DetectSudo()
+	switch state {
+	case SudoRoot:
+		return []CheckResult{{Name: "sudo", OK: true, Message: "running as root"}}
+	case SudoPasswordless:
+		return []CheckResult{{Name: "sudo", OK: true, Message: "passwordless"}}
+	case SudoPassword:
+		return []CheckResult{{Name: "sudo", OK: true, Message: "password required; run asks once per command"}}
+	}
+	return []CheckResult{{Name: "sudo", OK: false, Message: reason}}
+}
+
+// PrepareSudo makes sure the commands run is about to run can use sudo: it
+// asks for the password once, up front, if sudo needs one, and keeps the
+// sudo timestamp fresh until the returned function is called, so long
+// installs do not stop half way to prompt again.
+func PrepareSudo() (func(), error) {
+	state, reason := DetectSudo()
+	switch state {
+	case SudoRoot, SudoPasswordless:
+		return func() {}, nil
+	case SudoUnavailable:
+		return nil, fmt.Errorf("run needs sudo: %s", reason)
+	}
+
+	if os.Getenv(NonInteractiveEnv) != "" || !stdinIsTerminal() {
+		return nil, fmt.Errorf("sudo needs a password but run is not interactive: run it from a terminal or allow passwordless sudo")
+	}
+	validate := exec.Command("sudo", "-v", "-p", "[sudo] password for %u (needed by run): ")
+	validate.Stdin, validate.Stdout, validate.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := validate.Run(); err != nil {
+		return nil, fmt.Errorf("sudo authentication failed: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(sudoRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				exec.Command("sudo", "-n", "-v").Run()
+			}
+		}
+	}()
+	return func() { close(done) }, nil
+}
@@ -0,0 +1,68 @@
+package internal
+
+// Suggestion is a tip to install a package alongside one just installed.
+type Suggestion struct {
+	Package   string
+	Suggested string
+}
+
+func (s Suggestion) key() string {
+	return s.Package + ":" + s.Suggested
+}
+
+// isInstalled reports whether every install check of a package passes.
+func isInstalled(packageName string) bool {
+	results, err := checkInstalled(packageName)
+	if err != nil {
+		return false
+	}
+	for _, result := range results {
+		if !result.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// Suggestions returns the registry suggestions for the installed packages,
+// leaving out tips already shown and packages that are already installed.
+func Suggestions(installed []string) ([]Suggestion, error) {
+	state, err := LoadState()
+	if err != nil {
+		return nil, err
+	}
+
+	var suggestions []Suggestion
+	seen := make(map[string]bool)
+	for _, packageName := range installed {
+		for _, suggested := range PackageSuggestions[packageName] {
+			suggestion := Suggestion{Package: packageName, Suggested: suggested}
+			if seen[suggested] || containsString(installed, suggested) || containsString(state.DismissedSuggestions, suggestion.key()) {
+				continue
+			}
+			seen[suggested] = true
+			if !isInstalled(suggested) {
+				suggestions = append(suggestions, suggestion)
+			}
+		}
+	}
+	return suggestions, nil
+}
+
+// DismissSuggestions records suggestions as shown, so they are not repeated
+// on later installs.
+func DismissSuggestions(suggestions []Suggestion) error {
+	if len(suggestions) == 0 {
+		return nil
+	}
+	state, err := LoadState()
+	if err != nil {
+		return err
+	}
+	for _, suggestion := range suggestions {
+		if !containsString(state.DismissedSuggestions, suggestion.key()) {
+			state.DismissedSuggestions = append(state.DismissedSuggestions, suggestion.key())
+		}
+	}
+	return state.Save()
+}
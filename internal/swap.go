@@ -0,0 +1,257 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// SwapFile is the swapfile `run system swap` manages.
+const SwapFile = "/swapfile"
+
+// swapFstabEntry mounts SwapFile at boot.
+const swapFstabEntry = SwapFile + " none swap sw 0 0"
+
+// minBuildMemory is the memory plus swap below which builds of native
+// modules (node-gyp, pip wheels) tend to be killed by the OOM killer.
+const minBuildMemory = 2 << 30
+
+// defaultSwapSize is the swapfile `run doctor --fix` creates.
+const defaultSwapSize = "2G"
+
+// btrfsMagic is the statfs type of btrfs.
+const btrfsMagic = 0x9123683e
+
+// ParseSize parses sizes such as 512M or 2G, in powers of 1024.
+func ParseSize(size string) (int64, error) {
+	value := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(size)), "IB"), "B")
+	multiplier := int64(1)
+	if value != "" {
+		switch value[len(value)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		}
+		if multiplier > 1 {
+			value = value[:len(value)-1]
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size '%s': use a number with K, M or G, such as 2G", size)
+	}
+	if n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("invalid size '%s': too large", size)
+	}
+	return n * multiplier, nil
+}
+
+// formatSize formats a byte count in MiB or GiB.
+func formatSize(bytes int64) string {
+	if bytes >= 1<<30 && bytes%(1<<30) == 0 {
+		return fmt.Sprintf("%dG", bytes>>30)
+	}
+	return fmt.Sprintf("%dM", bytes>>20)
+}
+
+// SwapArea is an active swap file or partition from /proc/swaps.
+type SwapArea struct {
+	Path string
+	Size int64
+	Used int64
+}
+
+// SwapAreas returns the active swap areas.
+func SwapAreas() ([]SwapArea, error) {
+	file, err := os.Open("/proc/swaps")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /proc/swaps: %v", err)
+	}
+	defer file.Close()
+
+	var areas []SwapArea
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[2], 10, 64)
+		used, _ := strconv.ParseInt(fields[3], 10, 64)
+		areas = append(areas, SwapArea{Path: fields[0], Size: size << 10, Used: used << 10})
+	}
+	return areas, scanner.Err()
+}
+
+// swapFstabLine reports whether a line of /etc/fstab mounts SwapFile.
+func swapFstabLine(line string) bool {
+	fields := strings.Fields(line)
+	return len(fields) >= 3 && fields[0] == SwapFile && fields[2] == "swap"
+}
+
+// SwapInFstab reports whether SwapFile is enabled at boot.
+func SwapInFstab() bool {
+	data, err := os.ReadFile("/etc/fstab")
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if swapFstabLine(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateSwap creates SwapFile of the given size, readable by root only,
+// enables it and adds it to /etc/fstab.
+func CreateSwap(size int64) error {
+	if _, err := os.Stat(SwapFile); err == nil {
+		return fmt.Errorf("%s already exists; remove it first with: run system swap remove", SwapFile)
+	}
+
+	fmt.Fprintf(Console, "Creating %s swapfile at %s...\n", formatSize(size), SwapFile)
+	// fallocate fails on some file systems, and on others, such as xfs or
+	// older kernels' btrfs, leaves a file that swapon refuses: write it
+	// with dd then
+	if err := createSwapFile(size, true); err != nil {
+		system.Command("rm", "-f", SwapFile).WithSudo().Run()
+		fmt.Fprintf(Console, "⚠️  %v; writing the swapfile with dd instead\n", err)
+		if err := createSwapFile(size, false); err != nil {
+			system.Command("rm", "-f", SwapFile).WithSudo().Run()
+			return err
+		}
+	}
+
+	if SwapInFstab() {
+		return nil
+	}
+	data, err := os.ReadFile("/etc/fstab")
+	if err != nil {
+		return fmt.Errorf("failed to read /etc/fstab: %v", err)
+	}
+	fstab := string(data)
+	if fstab != "" && !strings.HasSuffix(fstab, "\n") {
+		fstab += "\n"
+	}
+	return syncRootFile("/etc/fstab", fstab+swapFstabEntry+"\n")
+}
+
+// createSwapFile allocates SwapFile with fallocate, or dd when fallocate is
+// false, then formats and enables it.
+func createSwapFile(size int64, fallocate bool) error {
+	var steps [][]string
+	var stat syscall.Statfs_t
+	if syscall.Statfs(filepath.Dir(SwapFile), &stat) == nil && uint32(stat.Type) == btrfsMagic {
+		// btrfs only swaps to files without copy-on-write, which can only
+		// be turned off while they are empty
+		steps = append(steps, []string{"truncate", "-s", "0", SwapFile}, []string{"chattr", "+C", SwapFile})
+	}
+	if fallocate {
+		steps = append(steps, []string{"fallocate", "-l", strconv.FormatInt(size, 10), SwapFile})
+	} else {
+		steps = append(steps, []string{"dd", "if=/dev/zero", "of=" + SwapFile, "bs=1M", "count=" + strconv.FormatInt(size>>20, 10)})
+	}
+	steps = append(steps,
+		[]string{"chmod", "600", SwapFile},
+		[]string{"mkswap", SwapFile},
+		[]string{"swapon", SwapFile},
+	)
+	for _, step := range steps {
+		if err := system.Command(step[0], step[1:]...).WithSudo().Run(); err != nil {
+			return fmt.Errorf("failed to run %s: %v", step[0], err)
+		}
+	}
+	return nil
+}
+
+// RemoveSwap disables SwapFile, removes it from /etc/fstab and deletes it.
+func RemoveSwap() error {
+	areas, err := SwapAreas()
+	if err != nil {
+		return err
+	}
+	for _, area := range areas {
+		if area.Path != SwapFile {
+			continue
+		}
+		fmt.Fprintf(Console, "Disabling %s (moving %s back into memory)...\n", SwapFile, formatSize(area.Used))
+		if err := system.Command("swapoff", SwapFile).WithSudo().Run(); err != nil {
+			return fmt.Errorf("failed to disable %s: %v", SwapFile, err)
+		}
+	}
+
+	if data, err := os.ReadFile("/etc/fstab"); err == nil {
+		var kept []string
+		for _, line := range strings.Split(string(data), "\n") {
+			if !swapFstabLine(line) {
+				kept = append(kept, line)
+			}
+		}
+		if err := syncRootFile("/etc/fstab", strings.Join(kept, "\n")); err != nil {
+			return err
+		}
+	}
+
+	if _, err := os.Stat(SwapFile); os.IsNotExist(err) {
+		return nil
+	}
+	return system.RemoveFileAsRoot(SwapFile)
+}
+
+// memoryTotals returns MemTotal and SwapTotal from /proc/meminfo.
+func memoryTotals() (int64, int64, error) {
+	data, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read /proc/meminfo: %v", err)
+	}
+	var memory, swap int64
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		value, _ := strconv.ParseInt(fields[1], 10, 64)
+		switch fields[0] {
+		case "MemTotal:":
+			memory = value << 10
+		case "SwapTotal:":
+			swap = value << 10
+		}
+	}
+	return memory, swap, nil
+}
+
+// checkMemory reports whether memory plus swap is enough to build native
+// node modules and Python wheels, which small VMs run out of.
+func checkMemory() []CheckResult {
+	memory, swap, err := memoryTotals()
+	if err != nil {
+		return []CheckResult{{Name: "memory", OK: false, Message: err.Error()}}
+	}
+	message := fmt.Sprintf("%s of memory, %s of swap", formatSize(memory), formatSize(swap))
+	if memory+swap < minBuildMemory {
+		return []CheckResult{{Name: "memory", OK: false, Message: message + "; native module builds may run out (fix with run system swap create " + defaultSwapSize + " or run doctor --fix)"}}
+	}
+	return []CheckResult{{Name: "memory", OK: true, Message: message}}
+}
+
+// fixMemory adds a defaultSwapSize swapfile.
+func fixMemory() error {
+	size, err := ParseSize(defaultSwapSize)
+	if err != nil {
+		return err
+	}
+	return CreateSwap(size)
+}
@@ -0,0 +1,19 @@
+package internal
+
+import "testing"
+
+func FuzzParseSize(f *testing.F) {
+	for _, seed := range []string{"2G", "512M", "64k", "1GiB", "8589934592G", "9223372036854775807", "0", "-1G", "G", ""} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, size string) {
+		bytes, err := ParseSize(size)
+		if err != nil {
+			return
+		}
+		// An accepted size never wrapped around
+		if bytes <= 0 {
+			t.Fatalf("ParseSize(%q) = %d", size, bytes)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// sysctlConfPath returns the sysctl.d file holding a package's kernel
+// parameters.
+func sysctlConfPath(packageName string) string {
+	return "/etc/sysctl.d/run-" + packageName + ".conf"
+}
+
+// renderSysctlConf returns the sysctl.d file for a set of kernel parameters,
+// sorted so the file only changes when the parameters do.
+func renderSysctlConf(packageName string, sysctls map[string]string) string {
+	if len(sysctls) == 0 {
+		return ""
+	}
+	var conf strings.Builder
+	fmt.Fprintf(&conf, "# Managed by run - kernel parameters of %s\n", packageName)
+	for _, key := range mapKeys(sysctls) {
+		fmt.Fprintf(&conf, "%s = %s\n", key, sysctls[key])
+	}
+	return conf.String()
+}
+
+// ApplyPackageSysctls writes the registry sysctls of a package to
+// /etc/sysctl.d and loads them. The file is only rewritten, and the values
+// only loaded, when they changed, so it is safe to call on every install.
+func ApplyPackageSysctls(packageName string) error {
+	path := sysctlConfPath(packageName)
+	content := renderSysctlConf(packageName, PackageSysctls[packageName])
+	current, _ := os.ReadFile(path)
+	if string(current) == content {
+		return nil
+	}
+	if err := syncRootFile(path, content); err != nil {
+		return err
+	}
+	if content == "" {
+		return nil
+	}
+	if err := system.Command("sysctl", "--load", path).WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to load %s: %v", path, err)
+	}
+	return nil
+}
+
+// RemovePackageSysctls removes the sysctl.d file of a package. The values
+// stay in effect until the next reboot, since their previous values are not
+// known.
+func RemovePackageSysctls(packageName string) error {
+	return syncRootFile(sysctlConfPath(packageName), "")
+}
@@ -0,0 +1,123 @@
+package system
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Alternative is a single candidate registered in an alternatives group.
+type Alternative struct {
+	Path     string
+	Priority int
+}
+
+// AlternativeGroup describes a group managed by update-alternatives, e.g. "java".
+type AlternativeGroup struct {
+	Name         string
+	Link         string
+	Mode         string // "auto" or "manual"
+	Current      string // path of the active alternative
+	Alternatives []Alternative
+}
+
+// ListAlternativeGroups returns the names of all alternatives groups on the system.
+func ListAlternativeGroups() ([]string, error) {
+	output, err := Command("update-alternatives", "--get-selections").CaptureOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alternatives: %v", err)
+	}
+
+	var groups []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 {
+			groups = append(groups, fields[0])
+		}
+	}
+	return groups, nil
+}
+
+// QueryAlternatives returns the registered alternatives of a group and which
+// one is active. A group that does not exist yields an error.
+func QueryAlternatives(name string) (*AlternativeGroup, error) {
+	output, err := Command("update-alternatives", "--query", name).CaptureOutput()
+	if err != nil {
+		return nil, fmt.Errorf("no alternatives registered for '%s'", name)
+	}
+	return parseAlternativesQuery(output), nil
+}
+
+// parseAlternativesQuery parses the RFC822-style output of
+// `update-alternatives --query <name>`.
+func parseAlternativesQuery(output []byte) *AlternativeGroup {
+	group := &AlternativeGroup{}
+	var current *Alternative
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "Name":
+			group.Name = value
+		case "Link":
+			group.Link = value
+		case "Status":
+			group.Mode = value
+		case "Value":
+			group.Current = value
+		case "Alternative":
+			group.Alternatives = append(group.Alternatives, Alternative{Path: value})
+			current = &group.Alternatives[len(group.Alternatives)-1]
+		case "Priority":
+			if current != nil {
+				current.Priority, _ = strconv.Atoi(value)
+			}
+		}
+	}
+	return group
+}
+
+// HasAlternative reports whether path is registered in the group.
+func (g *AlternativeGroup) HasAlternative(path string) bool {
+	for _, alt := range g.Alternatives {
+		if alt.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// RegisterAlternative registers path as a candidate for the group, creating
+// the group with the given link if needed.
+func RegisterAlternative(link, name, path string, priority int) error {
+	if err := Command("update-alternatives", "--install", link, name, path, strconv.Itoa(priority)).WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to register alternative %s for '%s': %w", path, name, err)
+	}
+	return nil
+}
+
+// SetAlternative makes path the active alternative of the group.
+func SetAlternative(name, path string) error {
+	if err := Command("update-alternatives", "--set", name, path).WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to set alternative %s for '%s': %w", path, name, err)
+	}
+	return nil
+}
+
+// RemoveAlternative unregisters a single path from the group, leaving the
+// other alternatives in place.
+func RemoveAlternative(name, path string) error {
+	if err := Command("update-alternatives", "--remove", name, path).WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to remove alternative %s from '%s': %w", path, name, err)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+	"time"
+)
+
+// Cmd builds an external command with consistent sudo, environment,
+// timeout and error handling:
+//
+//	output, err := system.Command("apt-get", "install", "-y", "git").WithSudo().WithTimeout(10 * time.Minute).CaptureOutput()
+type Cmd struct {
+	name    string
+	args    []string
+	sudo    bool
+	user    string
+	env     []string
+	dir     string
+	stdin   io.Reader
+	timeout time.Duration
+}
+
+// Trace, when set, is called with every command line before it runs, e.g.
+// to print commands under --debug or record them.
+var Trace func(command string)
+
+// Execute runs a prepared command. Replace it to stub out commands.
+var Execute = func(cmd *exec.Cmd) error {
+	return cmd.Run()
+}
+
+// Command starts building a command.
+func Command(name string, args ...string) *Cmd {
+	return &Cmd{name: name, args: args}
+}
+
+// WithSudo runs the command as root: through sudo, or directly when run is
+// root.
+func (c *Cmd) WithSudo() *Cmd {
+	c.sudo = true
+	return c
+}
+
+// AsUser runs the command as another user with that user's HOME.
+func (c *Cmd) AsUser(username string) *Cmd {
+	c.user = username
+	return c
+}
+
+// WithEnv adds KEY=value entries to the environment of run. Through sudo,
+// which resets the environment, they are passed with env(1).
+func (c *Cmd) WithEnv(env ...string) *Cmd {
+	c.env = append(c.env, env...)
+	return c
+}
+
+// WithDir sets the working directory.
+func (c *Cmd) WithDir(dir string) *Cmd {
+	c.dir = dir
+	return c
+}
+
+// WithStdin feeds the command's standard input.
+func (c *Cmd) WithStdin(stdin io.Reader) *Cmd {
+	c.stdin = stdin
+	return c
+}
+
+// WithTimeout kills the command once it runs longer than timeout.
+func (c *Cmd) WithTimeout(timeout time.Duration) *Cmd {
+	c.timeout = timeout
+	return c
+}
+
+// String returns the command line as it is run, without the values of
+// the environment passed through sudo.
+func (c *Cmd) String() string {
+	return strings.Join(c.commandLine(true), " ")
+}
+
+// throughSudo reports whether the command runs through sudo.
+func (c *Cmd) throughSudo() bool {
+	return (c.user != "" || c.sudo) && !IsRoot()
+}
+
+// argv returns the command line with the sudo or runuser prefix.
+func (c *Cmd) argv() []string {
+	return c.commandLine(false)
+}
+
+// commandLine returns the command line, with the values of the environment
+// passed through sudo replaced by ... when redact is set.
+func (c *Cmd) commandLine(redact bool) []string {
+	argv := append([]string{c.name}, c.args...)
+	if c.throughSudo() && len(c.env) > 0 {
+		env := []string{"env"}
+		for _, entry := range c.env {
+			if key, _, found := strings.Cut(entry, "="); found && redact {
+				entry = key + "=..."
+			}
+			env = append(env, entry)
+		}
+		argv = append(env, argv...)
+	}
+	switch {
+	case c.user != "" && IsRoot():
+		return append([]string{"runuser", "-u", c.user, "--"}, argv...)
+	case c.user != "":
+		return append([]string{"sudo", "-u", c.user, "-H"}, argv...)
+	case c.sudo && !IsRoot():
+		return append([]string{"sudo"}, argv...)
+	}
+	return argv
+}
+
+// build prepares the exec.Cmd; the returned function releases the timeout.
+func (c *Cmd) build() (*exec.Cmd, context.CancelFunc) {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if c.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+	}
+	argv := c.argv()
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = c.dir
+	cmd.Stdin = c.stdin
+
+	env := c.env
+	if c.user != "" && IsRoot() {
+		// runuser keeps the caller's HOME
+		if account, err := user.Lookup(c.user); err == nil {
+			env = append([]string{"HOME=" + account.HomeDir}, env...)
+		}
+	}
+	if len(env) > 0 && !c.throughSudo() {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	return cmd, cancel
+}
+
+// run executes a prepared command, tracing it and turning a timeout into a
+// readable error.
+func (c *Cmd) run(cmd *exec.Cmd) error {
+	if Trace != nil {
+		Trace(c.String())
+	}
+	err := Execute(cmd)
+	if err != nil && c.timeout > 0 && cmd.ProcessState != nil && !cmd.ProcessState.Exited() {
+		return fmt.Errorf("timed out after %s", c.timeout)
+	}
+	return err
+}
+
+// Run runs the command, discarding its output. On failure the error names
+// the command and holds its output.
+func (c *Cmd) Run() error {
+	_, err := c.CombinedOutput()
+	return err
+}
+
+// CombinedOutput runs the command and returns its stdout and stderr
+// together. On failure the error names the command and holds the output.
+func (c *Cmd) CombinedOutput() ([]byte, error) {
+	cmd, cancel := c.build()
+	defer cancel()
+	var output bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &output, &output
+	if err := c.run(cmd); err != nil {
+		return output.Bytes(), c.error(err, output.Bytes())
+	}
+	return output.Bytes(), nil
+}
+
+// CaptureOutput runs the command and returns its stdout. On failure the
+// error names the command and holds its stderr.
+func (c *Cmd) CaptureOutput() ([]byte, error) {
+	cmd, cancel := c.build()
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := c.run(cmd); err != nil {
+		return stdout.Bytes(), c.error(err, stderr.Bytes())
+	}
+	return stdout.Bytes(), nil
+}
+
+// Stream runs the command with its output going to stdout and stderr, for
+// long-running commands whose progress should be seen.
+func (c *Cmd) Stream(stdout, stderr io.Writer) error {
+	cmd, cancel := c.build()
+	defer cancel()
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if err := c.run(cmd); err != nil {
+		return c.error(err, nil)
+	}
+	return nil
+}
+
+// error formats a failure of the command.
+func (c *Cmd) error(err error, output []byte) error {
+	if message := strings.TrimSpace(string(output)); message != "" {
+		return fmt.Errorf("%s failed: %v: %s", c.name, err, message)
+	}
+	return fmt.Errorf("%s failed: %v", c.name, err)
+}
@@ -0,0 +1,39 @@
+package system
+
+import (
+	"fmt"
+	"os"
+)
+
+// WriteFileAsRoot writes data to a root-owned path as root. The content
+// is staged in a temporary file and moved into place with install(1), so the
+// destination is never left half-written.
+func WriteFileAsRoot(path string, data []byte, mode os.FileMode) error {
+	temp, err := os.CreateTemp("", "run-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write temporary file: %v", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write temporary file: %v", err)
+	}
+
+	if err := Command("install", "-m", fmt.Sprintf("%04o", mode.Perm()), temp.Name(), path).WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// RemoveFileAsRoot deletes a root-owned file as root. A missing file is
+// not an error.
+func RemoveFileAsRoot(path string) error {
+	if err := Command("rm", "-f", path).WithSudo().Run(); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+package system
+
+import "os"
+
+// IsRoot reports whether run is running as root, as under cloud-init or in
+// containers, where sudo is unnecessary and often not installed.
+func IsRoot() bool {
+	return os.Geteuid() == 0
+}
@@ -0,0 +1,93 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// authorizedKey returns the type and key of an authorized_keys line, which
+// identify it regardless of options and comment.
+func authorizedKey(line string) string {
+	fields := strings.Fields(line)
+	for i := 0; i+1 < len(fields); i++ {
+		if strings.HasPrefix(fields[i], "ssh-") || strings.HasPrefix(fields[i], "ecdsa-") || strings.HasPrefix(fields[i], "sk-") {
+			return fields[i] + " " + fields[i+1]
+		}
+	}
+	return ""
+}
+
+// AddAuthorizedKeys appends the keys a user's ~/.ssh/authorized_keys lacks.
+// ~/.ssh is created with mode 0700 and the file written with mode 0600,
+// both owned by the user, as sshd's StrictModes requires. It returns the
+// number of keys added; each addition is recorded in the audit log.
+func AddAuthorizedKeys(username string, keys []string) (int, error) {
+	account, err := user.Lookup(username)
+	if err != nil {
+		return 0, fmt.Errorf("unknown user '%s'", username)
+	}
+	sshDir := filepath.Join(account.HomeDir, ".ssh")
+	if info, err := os.Lstat(sshDir); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return 0, fmt.Errorf("refusing to write keys: %s is a symlink", sshDir)
+	}
+	path := filepath.Join(sshDir, "authorized_keys")
+
+	// The file is only readable by its owner
+	current, _ := Command("cat", path).WithSudo().CaptureOutput()
+	existing := map[string]bool{}
+	for _, line := range strings.Split(string(current), "\n") {
+		if key := authorizedKey(line); key != "" {
+			existing[key] = true
+		}
+	}
+	content := string(current)
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	var added []string
+	for _, line := range keys {
+		key := authorizedKey(line)
+		if key == "" || existing[key] {
+			continue
+		}
+		existing[key] = true
+		content += strings.TrimSpace(line) + "\n"
+		added = append(added, line)
+	}
+	if len(added) == 0 {
+		return 0, nil
+	}
+
+	owner := []string{"-o", account.Uid, "-g", account.Gid}
+	if err := runAsRoot("install", append([]string{"-d", "-m", "0700"}, append(owner, sshDir)...)...); err != nil {
+		return 0, fmt.Errorf("failed to create %s: %v", sshDir, err)
+	}
+	temp, err := os.CreateTemp("", "run-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.WriteString(content); err != nil {
+		temp.Close()
+		return 0, fmt.Errorf("failed to write temporary file: %v", err)
+	}
+	if err := temp.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write temporary file: %v", err)
+	}
+	if err := runAsRoot("install", append([]string{"-m", "0600"}, append(owner, temp.Name(), path)...)...); err != nil {
+		return 0, fmt.Errorf("failed to write %s: %v", path, err)
+	}
+
+	for _, line := range added {
+		fields := strings.Fields(authorizedKey(line))
+		fingerprint := fields[1]
+		if len(fingerprint) > 16 {
+			fingerprint = "..." + fingerprint[len(fingerprint)-16:]
+		}
+		audit("added %s key %s to %s", fields[0], fingerprint, path)
+	}
+	return len(added), nil
+}
@@ -0,0 +1,101 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// AuditLogPath is the file changes to users and groups are appended to.
+// Empty disables the audit log.
+var AuditLogPath string
+
+// accountNamePattern matches names useradd and groupadd accept by default.
+var accountNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+
+// audit records a change to users or groups, and who ran it, in the audit
+// log. Failing to write the log does not fail the change.
+func audit(format string, args ...interface{}) {
+	if AuditLogPath == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(AuditLogPath), 0755); err != nil {
+		return
+	}
+	file, err := os.OpenFile(AuditLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	by := os.Getenv("SUDO_USER")
+	if by == "" {
+		by = os.Getenv("USER")
+	}
+	fmt.Fprintf(file, "%s %s (by %s)\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...), by)
+}
+
+// runAsRoot runs a command as root, returning its output in the error.
+func runAsRoot(name string, args ...string) error {
+	return Command(name, args...).WithSudo().Run()
+}
+
+// EnsureGroup creates a group unless it exists.
+func EnsureGroup(name string) error {
+	if _, err := user.LookupGroup(name); err == nil {
+		return nil
+	}
+	if !accountNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid group name '%s'", name)
+	}
+	if err := runAsRoot("groupadd", name); err != nil {
+		return fmt.Errorf("failed to create group %s: %v", name, err)
+	}
+	audit("created group %s", name)
+	return nil
+}
+
+// EnsureUser creates a user with a home directory, a bash shell and a group
+// of the same name, unless it exists.
+func EnsureUser(name string) error {
+	if _, err := user.Lookup(name); err == nil {
+		return nil
+	}
+	if !accountNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid user name '%s'", name)
+	}
+	if err := runAsRoot("useradd", "--create-home", "--shell", "/bin/bash", "--user-group", name); err != nil {
+		return fmt.Errorf("failed to create user %s: %v", name, err)
+	}
+	audit("created user %s", name)
+	return nil
+}
+
+// AddUserToGroup adds a user to a supplementary group unless it is already a
+// member. Running sessions of the user only see the group after logging in
+// again.
+func AddUserToGroup(username, group string) error {
+	account, err := user.Lookup(username)
+	if err != nil {
+		return fmt.Errorf("unknown user '%s'", username)
+	}
+	target, err := user.LookupGroup(group)
+	if err != nil {
+		return fmt.Errorf("unknown group '%s'", group)
+	}
+	if groupIDs, err := account.GroupIds(); err == nil {
+		for _, gid := range groupIDs {
+			if gid == target.Gid {
+				return nil
+			}
+		}
+	}
+	if err := runAsRoot("usermod", "--append", "--groups", group, username); err != nil {
+		return fmt.Errorf("failed to add %s to group %s: %v", username, group, err)
+	}
+	audit("added user %s to group %s", username, group)
+	return nil
+}
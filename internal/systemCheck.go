@@ -0,0 +1,23 @@
+package internal
+
+// SystemCheck is a named group of host-level checks shown by
+// `run check --system` and `run doctor`.
+type SystemCheck struct {
+	Name  string
+	Check func() []CheckResult
+	// Fix repairs what Check found, for `run doctor --fix`. Optional.
+	Fix func() error
+}
+
+// SystemChecks are run in order by `run check --system` and `run doctor`.
+var SystemChecks = []SystemCheck{
+	{Name: "registry", Check: CheckRegistry},
+	{Name: "scripts", Check: checkScriptLint},
+	{Name: "sudo", Check: checkSudo},
+	{Name: "hardening", Check: checkHardening},
+	{Name: "memory", Check: checkMemory, Fix: fixMemory},
+	{Name: "network", Check: checkNetwork},
+	{Name: "clock", Check: checkClock, Fix: fixClock},
+	{Name: "locale", Check: checkLocale, Fix: fixLocale},
+	{Name: "unattended-upgrades", Check: checkUnattendedUpgrades, Fix: fixUnattendedUpgrades},
+}
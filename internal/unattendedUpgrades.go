@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// aptTimers start apt's periodic jobs: apt-daily refreshes the package lists
+// and apt-daily-upgrade runs unattended-upgrade, both holding the dpkg lock.
+var aptTimers = []string{"apt-daily.timer", "apt-daily-upgrade.timer"}
+
+// unattendedUpgradeWait is how long run waits for a running unattended
+// upgrade to release the dpkg lock before giving up.
+const unattendedUpgradeWait = 15 * time.Minute
+
+// unitProperty returns a property of a systemd unit, or an empty string.
+func unitProperty(unit, property string) string {
+	output, err := exec.Command("systemctl", "show", unit, "--property="+property, "--value").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// UnattendedUpgradeRunning reports whether apt's periodic jobs are updating
+// or upgrading packages right now.
+func UnattendedUpgradeRunning() bool {
+	for _, unit := range []string{"apt-daily.service", "apt-daily-upgrade.service"} {
+		if state := unitProperty(unit, "ActiveState"); state == "activating" || state == "active" {
+			return true
+		}
+	}
+	// Started by hand or from cron; unattended-upgrade-shutdown, which only
+	// waits for shutdown, does not match
+	return exec.Command("pgrep", "-f", "/usr/bin/unattended-upgrade( |$)").Run() == nil
+}
+
+// WaitForUnattendedUpgrades waits for a running unattended upgrade to
+// finish, so installs do not fail on the dpkg lock.
+func WaitForUnattendedUpgrades() error {
+	if !UnattendedUpgradeRunning() {
+		return nil
+	}
+	fmt.Fprintln(Console, "⏳ Waiting for unattended-upgrades to release the dpkg lock...")
+	deadline := time.Now().Add(unattendedUpgradeWait)
+	for UnattendedUpgradeRunning() {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("unattended-upgrades still running after %s; try again later", unattendedUpgradeWait)
+		}
+		time.Sleep(5 * time.Second)
+	}
+	return nil
+}
+
+// PauseUnattendedUpgrades stops apt's timers, so no unattended upgrade
+// starts during run's operations, and waits for one already running. The
+// returned function starts the timers again. Timers are stopped, not
+// disabled, so a reboot resumes them too.
+func PauseUnattendedUpgrades() (func(), error) {
+	var paused []string
+	resume := func() {
+		for _, timer := range paused {
+			if err := system.Command("systemctl", "start", timer).WithSudo().Run(); err != nil {
+				Warn("", "%v; start it with: sudo systemctl start %s", err, timer)
+			}
+		}
+	}
+	for _, timer := range aptTimers {
+		if unitProperty(timer, "ActiveState") != "active" {
+			continue
+		}
+		if err := system.Command("systemctl", "stop", timer).WithSudo().Run(); err != nil {
+			resume()
+			return nil, fmt.Errorf("failed to pause unattended-upgrades: %v", err)
+		}
+		paused = append(paused, timer)
+	}
+	if err := WaitForUnattendedUpgrades(); err != nil {
+		resume()
+		return nil, err
+	}
+	return resume, nil
+}
+
+// PauseUnattendedUpgradesConfigured reports whether system.pause_unattended_upgrades
+// is set in the config.
+func PauseUnattendedUpgradesConfigured() bool {
+	config, err := LoadConfig()
+	return err == nil && config.System.PauseUnattendedUpgrades
+}
+
+// unattendedUpgradesEnabled reports whether APT::Periodic::Unattended-Upgrade
+// is on.
+func unattendedUpgradesEnabled() bool {
+	output, err := exec.Command("apt-config", "dump", "APT::Periodic::Unattended-Upgrade").Output()
+	if err != nil {
+		return false
+	}
+	value := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(output)), "APT::Periodic::Unattended-Upgrade"))
+	value = strings.Trim(value, `";`)
+	return value != "" && value != "0"
+}
+
+// checkUnattendedUpgrades reports when unattended-upgrades runs next,
+// whether it is running now and whether run left its timers stopped.
+func checkUnattendedUpgrades() []CheckResult {
+	if _, err := exec.LookPath("unattended-upgrade"); err != nil {
+		return []CheckResult{{Name: "unattended-upgrades", OK: true, Message: "not installed"}}
+	}
+	if !unattendedUpgradesEnabled() {
+		return []CheckResult{{Name: "unattended-upgrades", OK: true, Message: "installed, disabled in APT::Periodic"}}
+	}
+
+	var results []CheckResult
+	for _, timer := range aptTimers {
+		result := CheckResult{Name: timer, OK: true}
+		switch {
+		case unitProperty(timer, "UnitFileState") != "enabled":
+			result.Message = "disabled"
+		case unitProperty(timer, "ActiveState") != "active":
+			result.OK = false
+			result.Message = "enabled but stopped, likely paused by an interrupted run (fix with run doctor --fix)"
+		default:
+			result.Message = "next run " + unitProperty(timer, "NextElapseUSecRealtime")
+			if last := unitProperty(timer, "LastTriggerUSec"); last != "" && last != "n/a" {
+				result.Message += ", last " + last
+			}
+		}
+		results = append(results, result)
+	}
+
+	status := CheckResult{Name: "unattended upgrade", OK: true, Message: "idle"}
+	if UnattendedUpgradeRunning() {
+		status.Message = "running now; installs wait for it to finish"
+	}
+	return append(results, status)
+}
+
+// fixUnattendedUpgrades starts enabled apt timers left stopped.
+func fixUnattendedUpgrades() error {
+	for _, timer := range aptTimers {
+		if unitProperty(timer, "UnitFileState") == "enabled" && unitProperty(timer, "ActiveState") != "active" {
+			if err := system.Command("systemctl", "start", timer).WithSudo().Run(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
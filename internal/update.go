@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ModulePath is the Go module of run. `run update` refuses remotes that
+// serve another module.
+const ModulePath = "github.com/amoga-io/run"
+
+// Defaults for the update section of the config.
+const (
+	DefaultUpdateRemote = "https://github.com/amoga-io/run.git"
+	DefaultUpdateBranch = "main"
+)
+
+// UpdateConfig configures where `run update` fetches run from, such as an
+// internal fork or mirror.
+type UpdateConfig struct {
+	// Remote is the git URL of the repository; DefaultUpdateRemote when unset.
+	Remote string `yaml:"remote"`
+	// Branch is built from; DefaultUpdateBranch when unset.
+	Branch string `yaml:"branch"`
+	// SSHKey is a private key for ssh remotes, used instead of the keys of
+	// the ssh agent.
+	SSHKey string `yaml:"ssh_key"`
+	// Prefix is where the binary is installed, in <prefix>/bin;
+	// DefaultInstallPrefix when unset. ~/.local or /opt/run suit hosts where
+	// /usr/local is read-only or managed by other tooling.
+	Prefix string `yaml:"prefix"`
+}
+
+// DefaultInstallPrefix is the prefix the binary is installed under.
+const DefaultInstallPrefix = "/usr/local"
+
+// WithDefaults fills in the remote, branch and prefix when they are not
+// configured and expands ~ in the ssh key path and the prefix.
+func (c UpdateConfig) WithDefaults() UpdateConfig {
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, path := range []*string{&c.SSHKey, &c.Prefix} {
+			if rest, found := strings.CutPrefix(*path, "~/"); found {
+				*path = filepath.Join(home, rest)
+			}
+		}
+	}
+	if c.Prefix == "" {
+		c.Prefix = DefaultInstallPrefix
+	}
+	if c.Remote == "" {
+		c.Remote = DefaultUpdateRemote
+	}
+	if c.Branch == "" {
+		c.Branch = DefaultUpdateBranch
+	}
+	return c
+}
+
+// Validate checks the prefix and the ssh key of the update config.
+func (c UpdateConfig) Validate() error {
+	if c.Prefix != "" && !filepath.IsAbs(c.Prefix) {
+		return fmt.Errorf("update.prefix must be an absolute path or start with ~/: %s", c.Prefix)
+	}
+	if c.SSHKey == "" {
+		return nil
+	}
+	info, err := os.Stat(c.SSHKey)
+	if err != nil {
+		return fmt.Errorf("update.ssh_key: %v", err)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("update.ssh_key %s must only be readable by its owner: chmod 600 %s", c.SSHKey, c.SSHKey)
+	}
+	return nil
+}
+
+// BinDir returns the directory the binary is installed in.
+func (c UpdateConfig) BinDir() string {
+	return filepath.Join(c.Prefix, "bin")
+}
+
+// GitEnv returns the environment git needs to reach the remote.
+func (c UpdateConfig) GitEnv() []string {
+	if c.SSHKey == "" {
+		return nil
+	}
+	return []string{"GIT_SSH_COMMAND=ssh -i " + c.SSHKey + " -o IdentitiesOnly=yes"}
+}
+
+// CheckUpdateModule checks that a ref of the repository in repoDir is run,
+// by the module path in its go.mod.
+func CheckUpdateModule(repoDir, ref string) error {
+	cmd := exec.Command("git", "show", ref+":go.mod")
+	cmd.Dir = repoDir
+	data, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("%s has no go.mod: is the remote a copy of run?", ref)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if module, found := strings.CutPrefix(strings.TrimSpace(line), "module "); found {
+			if strings.TrimSpace(module) != ModulePath {
+				return fmt.Errorf("%s is module %s, not %s", ref, strings.TrimSpace(module), ModulePath)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%s has no module line in go.mod", ref)
+}
+
+// UpdateBuild records the last binary `run update` built.
+type UpdateBuild struct {
+	Commit string `json:"commit"`
+	// Seconds is how long the last build took.
+	Seconds float64 `json:"seconds"`
+	// SlowestSeconds is the longest build so far, usually the first one
+	// with an empty cache.
+	SlowestSeconds float64 `json:"slowest_seconds"`
+}
+
+// UpdateCacheEnv returns the environment that keeps the Go module download
+// directory and build cache under ~/.run/cache between updates.
+func UpdateCacheEnv() ([]string, error) {
+	cacheDir, err := CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		"GOMODCACHE=" + filepath.Join(cacheDir, "mod"),
+		"GOCACHE=" + filepath.Join(cacheDir, "build"),
+	}, nil
+}
@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"path/filepath"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+func init() {
+	if runDir, err := RunDir(); err == nil {
+		system.AuditLogPath = filepath.Join(runDir, "logs", "audit.log")
+	}
+}
+
+// EnsureUser creates a user unless it exists and adds it to groups,
+// creating those that do not exist. Changes are recorded in
+// ~/.run/logs/audit.log.
+func EnsureUser(name string, groups []string) error {
+	if err := system.EnsureUser(name); err != nil {
+		return err
+	}
+	for _, group := range groups {
+		if err := system.EnsureGroup(group); err != nil {
+			return err
+		}
+		if err := system.AddUserToGroup(name, group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appUser returns the app user of the active cloud profile.
+func appUser() (string, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return "", err
+	}
+	_, profile, err := ActiveCloudProfile(config)
+	if err != nil {
+		return "", err
+	}
+	return profile.AppUser(), nil
+}
+
+// ensureAppUser creates the app user that package scripts run apps as or
+// hand files to.
+func ensureAppUser() error {
+	user, err := appUser()
+	if err != nil {
+		return err
+	}
+	return EnsureUser(user, nil)
+}
+
+// setupDockerGroup lets the app user use docker without sudo.
+func setupDockerGroup() error {
+	user, err := appUser()
+	if err != nil {
+		return err
+	}
+	return EnsureUser(user, []string{"docker"})
+}
@@ -1,13 +1,65 @@
 package internal
 
-func GetScriptAndExecute(command, packageName string) error {
+import (
+	"io"
+	"os"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// Console receives progress messages and the output of scripts and commands
+// run for the user. Commands with machine-readable output point it at
+// stderr so stdout holds only the result.
+var Console io.Writer = os.Stdout
+
+// GetScriptAndExecute resolves the script of a package and runs it. env holds
+// extra KEY=value settings passed to the script.
+func GetScriptAndExecute(command, packageName string, env ...string) error {
 	script, err := GetScriptPath(command, packageName)
 	if err != nil {
 		return err
 	}
 
-	if err := ExecuteScript(script); err != nil {
+	if err := ExecuteScript(packageName, script, env...); err != nil {
 		return err
 	}
 	return nil
 }
+
+// containsString reports whether list contains value.
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// removeString returns list without any occurrence of value.
+func removeString(list []string, value string) []string {
+	var result []string
+	for _, item := range list {
+		if item != value {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// runRootStreaming runs a command as root with its output attached to the
+// console.
+func runRootStreaming(name string, args ...string) error {
+	return system.Command(name, args...).WithSudo().Stream(Console, os.Stderr)
+}
+
+// DirWritable reports whether the user can create files in dir.
+func DirWritable(dir string) bool {
+	file, err := os.CreateTemp(dir, ".run-write-check-*")
+	if err != nil {
+		return false
+	}
+	file.Close()
+	os.Remove(file.Name())
+	return true
+}
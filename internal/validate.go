@@ -0,0 +1,159 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValidateScriptPath checks that a script named in a package definition is a
+// plain path inside dir and looks like a shell script.
+func ValidateScriptPath(dir, script string) error {
+	if filepath.IsAbs(script) || strings.HasPrefix(filepath.Clean(script), "..") {
+		return fmt.Errorf("script '%s' must be relative to the scripts directory", script)
+	}
+
+	file, err := os.Open(filepath.Join(dir, script))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("script '%s' not found in %s", script, dir)
+		}
+		return fmt.Errorf("cannot read script '%s': %v", script, err)
+	}
+	defer file.Close()
+
+	firstLine, _ := bufio.NewReader(file).ReadString('\n')
+	if !strings.HasPrefix(firstLine, "#!") {
+		return fmt.Errorf("script '%s' has no #! line", script)
+	}
+	return nil
+}
+
+// ValidateFile lints a package definition file and returns all problems
+// found. Files named run.yaml are checked as local package manifests, any
+// other file as a registry overlay.
+func ValidateFile(path string) []error {
+	if filepath.Base(path) == LocalManifestName {
+		return ValidateLocalManifest(path)
+	}
+	return ValidateRegistryFile(path)
+}
+
+// ValidateRegistryFile checks a registry file against the schema and checks
+// that its scripts exist in the scripts directory.
+func ValidateRegistryFile(path string) []error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []error{err}
+	}
+
+	document, _, err := migrateRegistryDocument(data)
+	if err != nil {
+		return []error{err}
+	}
+	root := document.Content[0]
+	errs := validateRegistryNode(root)
+
+	registry := &RegistryFile{}
+	if err := root.Decode(registry); err != nil {
+		return append(errs, err)
+	}
+
+	scriptsDir, err := ScriptsDir()
+	if err != nil {
+		return append(errs, err)
+	}
+
+	var names []string
+	for name := range registry.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		pkg := registry.Packages[name]
+		for _, script := range []string{pkg.Install, pkg.Remove} {
+			if script == "" {
+				continue
+			}
+			if err := ValidateScriptPath(scriptsDir, script); err != nil {
+				errs = append(errs, fmt.Errorf("packages.%s: %v", name, err))
+				continue
+			}
+			errs = append(errs, lintErrors(filepath.Join(scriptsDir, script), script == pkg.Install)...)
+		}
+		for _, dependency := range pkg.Depends {
+			_, inFile := registry.Packages[dependency]
+			_, inRegistry := InstallPackageRegistry[dependency]
+			if !inFile && !inRegistry {
+				errs = append(errs, fmt.Errorf("packages.%s: depends on unknown package '%s'", name, dependency))
+			}
+		}
+	}
+	return errs
+}
+
+// ValidateLocalManifest checks a run.yaml manifest for `run install
+// --from-file`, with its scripts relative to the manifest.
+func ValidateLocalManifest(path string) []error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []error{err}
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return []error{err}
+	}
+	if len(document.Content) == 0 || document.Content[0].Kind != yaml.MappingNode {
+		return []error{fmt.Errorf("expected a mapping with name and install")}
+	}
+	root := document.Content[0]
+
+	errs := checkKnownKeys(root, "", []string{"name", "version", "install", "remove", "check"})
+	manifest := &LocalManifest{}
+	if err := root.Decode(manifest); err != nil {
+		return append(errs, err)
+	}
+
+	if manifest.Name == "" {
+		errs = append(errs, fmt.Errorf("missing required key 'name'"))
+	} else if _, exists := InstallPackageRegistry[manifest.Name]; exists {
+		errs = append(errs, fmt.Errorf("name: '%s' is a registry package", manifest.Name))
+	}
+	if manifest.Install == "" {
+		errs = append(errs, fmt.Errorf("missing required key 'install'"))
+	}
+
+	dir := filepath.Dir(path)
+	for _, script := range []string{manifest.Install, manifest.Remove} {
+		if script == "" {
+			continue
+		}
+		if err := ValidateScriptPath(dir, script); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, lintErrors(filepath.Join(dir, script), script == manifest.Install)...)
+	}
+	return errs
+}
+
+// lintErrors returns the lint findings of a script as errors naming it.
+// Install scripts must stop on errors; removal scripts are best effort.
+func lintErrors(path string, install bool) []error {
+	findings, err := LintScript(path, install)
+	if err != nil {
+		return []error{err}
+	}
+	var errs []error
+	for _, finding := range findings {
+		errs = append(errs, fmt.Errorf("%s %s", filepath.Base(path), finding))
+	}
+	return errs
+}
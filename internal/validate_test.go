@@ -0,0 +1,28 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func FuzzValidateScriptPath(f *testing.F) {
+	for _, seed := range []string{"node.sh", "sub/node.sh", "../node.sh", "/etc/passwd", "a/../../node.sh", "./node.sh", "..node.sh", ""} {
+		f.Add(seed)
+	}
+	dir := f.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "node.sh"), []byte("#!/bin/bash\n"), 0755); err != nil {
+		f.Fatal(err)
+	}
+	f.Fuzz(func(t *testing.T, script string) {
+		if err := ValidateScriptPath(dir, script); err != nil {
+			return
+		}
+		// An accepted script is a file inside dir
+		rel, err := filepath.Rel(dir, filepath.Join(dir, script))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			t.Fatalf("ValidateScriptPath accepted %q, outside %s", script, dir)
+		}
+	})
+}
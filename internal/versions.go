@@ -0,0 +1,291 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// jvmDir is where Debian/Ubuntu JDK packages install their Java homes.
+const jvmDir = "/usr/lib/jvm"
+
+// javaTools are switched together when changing the active Java version.
+var javaTools = []string{"java", "javac", "jar"}
+
+var jdkMajorPattern = regexp.MustCompile(`(?:^|-)(\d+)(?:[-.]|$)`)
+var pythonBinaryPattern = regexp.MustCompile(`^python(3\.\d+)$`)
+
+// JDK is a Java installation found under /usr/lib/jvm.
+type JDK struct {
+	Home  string
+	Major int
+}
+
+// ListJDKs returns the JDKs installed under /usr/lib/jvm, oldest first.
+// Symlinked aliases such as default-java are skipped.
+func ListJDKs() ([]JDK, error) {
+	entries, err := os.ReadDir(jvmDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %v", jvmDir, err)
+	}
+
+	var jdks []JDK
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		match := jdkMajorPattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		major, _ := strconv.Atoi(match[1])
+		jdks = append(jdks, JDK{Home: filepath.Join(jvmDir, entry.Name()), Major: major})
+	}
+
+	sort.Slice(jdks, func(i, j int) bool { return jdks[i].Major < jdks[j].Major })
+	return jdks, nil
+}
+
+// RegisterJavaAlternatives makes sure every installed JDK is registered with
+// update-alternatives, so side-by-side installs (e.g. 11 and 17) can be
+// switched with `run use java <version>`.
+func RegisterJavaAlternatives() error {
+	jdks, err := ListJDKs()
+	if err != nil {
+		return err
+	}
+
+	for _, tool := range javaTools {
+		group, _ := system.QueryAlternatives(tool)
+		for _, jdk := range jdks {
+			binary := filepath.Join(jdk.Home, "bin", tool)
+			if _, err := os.Stat(binary); err != nil {
+				continue
+			}
+			if group != nil && group.HasAlternative(binary) {
+				continue
+			}
+			if err := system.RegisterAlternative(filepath.Join("/usr/bin", tool), tool, binary, jdk.Major*100); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sdkmanJavaHome returns the "current" java candidate of sdkman, or an
+// empty string when java is not managed by sdkman.
+func sdkmanJavaHome() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	current := filepath.Join(home, ".sdkman", "candidates", "java", "current")
+	if _, err := os.Stat(filepath.Join(current, "bin", "java")); err != nil {
+		return ""
+	}
+	return current
+}
+
+// ActiveJavaHome returns the home of the active JDK: the sdkman "current"
+// candidate when sdkman manages java, otherwise the JDK selected through
+// update-alternatives (or found in PATH).
+func ActiveJavaHome() (string, error) {
+	if home := sdkmanJavaHome(); home != "" {
+		return home, nil
+	}
+
+	javaPath := ""
+	if group, err := system.QueryAlternatives("java"); err == nil && group.Current != "" {
+		javaPath = group.Current
+	} else if javaPath, err = exec.LookPath("java"); err != nil {
+		return "", fmt.Errorf("java is not installed")
+	}
+
+	resolved, err := filepath.EvalSymlinks(javaPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %v", javaPath, err)
+	}
+
+	home := filepath.Dir(filepath.Dir(resolved))
+	// Java 8 layouts keep the binary in <home>/jre/bin
+	if filepath.Base(home) == "jre" {
+		home = filepath.Dir(home)
+	}
+	return home, nil
+}
+
+// UpdateJavaHome exports JAVA_HOME for the active JDK in the managed env file
+// and puts its bin directory on PATH, replacing the previous JDK's entry.
+func UpdateJavaHome() error {
+	home, err := ActiveJavaHome()
+	if err != nil {
+		return err
+	}
+
+	env, err := LoadManagedEnv()
+	if err != nil {
+		return err
+	}
+	if previous := env.Vars["JAVA_HOME"]; previous != "" {
+		env.RemovePath(filepath.Join(previous, "bin"))
+	}
+	env.Set("JAVA_HOME", home)
+	env.AddPath(filepath.Join(home, "bin"))
+	if err := env.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(Console, "JAVA_HOME set to %s\n", home)
+	return nil
+}
+
+// setupJava runs after the java package is installed.
+func setupJava() error {
+	if err := RegisterJavaAlternatives(); err != nil {
+		return err
+	}
+	return UpdateJavaHome()
+}
+
+// RegisterPythonAlternatives registers every /usr/bin/python3.X interpreter in
+// the "python" alternatives group. The system python3 link is left untouched
+// because apt tooling depends on it.
+func RegisterPythonAlternatives() error {
+	interpreters, err := listPythonInterpreters()
+	if err != nil {
+		return err
+	}
+
+	group, _ := system.QueryAlternatives("python")
+	for version, binary := range interpreters {
+		if group != nil && group.HasAlternative(binary) {
+			continue
+		}
+		if err := system.RegisterAlternative("/usr/bin/python", "python", binary, pythonPriority(version)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listPythonInterpreters maps versions like "3.11" to their /usr/bin binary.
+func listPythonInterpreters() (map[string]string, error) {
+	entries, err := os.ReadDir("/usr/bin")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /usr/bin: %v", err)
+	}
+
+	interpreters := make(map[string]string)
+	for _, entry := range entries {
+		if match := pythonBinaryPattern.FindStringSubmatch(entry.Name()); match != nil {
+			interpreters[match[1]] = filepath.Join("/usr/bin", entry.Name())
+		}
+	}
+	return interpreters, nil
+}
+
+// pythonPriority turns "3.11" into 311 so newer interpreters win in auto mode.
+func pythonPriority(version string) int {
+	var major, minor int
+	fmt.Sscanf(version, "%d.%d", &major, &minor)
+	return major*100 + minor
+}
+
+// UseVersion switches the active version of a package system-wide.
+func UseVersion(packageName, version string) error {
+	switch packageName {
+	case "java":
+		return useJava(version)
+	case "node":
+		return useNode(version)
+	case "php":
+		return usePHP(version)
+	case "python":
+		return usePython(version)
+	default:
+		return fmt.Errorf("switching versions is not supported for package '%s'", packageName)
+	}
+}
+
+func useJava(version string) error {
+	if sdkmanJavaHome() != "" {
+		return fmt.Errorf("java is managed by sdkman; use 'sdk default java <version>' instead")
+	}
+
+	major, err := strconv.Atoi(version)
+	if err != nil {
+		return fmt.Errorf("invalid java version '%s': expected a major version such as 17", version)
+	}
+
+	if err := RegisterJavaAlternatives(); err != nil {
+		return err
+	}
+
+	jdks, err := ListJDKs()
+	if err != nil {
+		return err
+	}
+	for _, jdk := range jdks {
+		if jdk.Major != major {
+			continue
+		}
+		for _, tool := range javaTools {
+			binary := filepath.Join(jdk.Home, "bin", tool)
+			if _, err := os.Stat(binary); err != nil {
+				continue
+			}
+			if err := system.SetAlternative(tool, binary); err != nil {
+				return err
+			}
+		}
+		return UpdateJavaHome()
+	}
+	return fmt.Errorf("java %s is not installed", version)
+}
+
+// useNode makes a node version installed with nvm its default, which the
+// shims in ~/.run/shims then point at.
+func useNode(version string) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("error getting home directory: %v", err)
+	}
+	nvmDir := filepath.Join(home, ".nvm")
+	dir := matchingVersionDir(filepath.Join(nvmDir, "versions", "node"), "v"+strings.TrimPrefix(version, "v"), ".")
+	if dir == "" {
+		return fmt.Errorf("node %s is not installed with nvm; switching node versions requires nvm", version)
+	}
+	if err := os.MkdirAll(filepath.Join(nvmDir, "alias"), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Join(nvmDir, "alias"), err)
+	}
+	if err := os.WriteFile(filepath.Join(nvmDir, "alias", "default"), []byte(filepath.Base(dir)+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to set the nvm default: %v", err)
+	}
+	return nil
+}
+
+func usePython(version string) error {
+	if err := RegisterPythonAlternatives(); err != nil {
+		return err
+	}
+
+	interpreters, err := listPythonInterpreters()
+	if err != nil {
+		return err
+	}
+	binary, exists := interpreters[version]
+	if !exists {
+		return fmt.Errorf("python %s is not installed", version)
+	}
+	return system.SetAlternative("python", binary)
+}
@@ -0,0 +1,40 @@
+package internal
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Warning is a problem that did not stop an operation, such as a package
+// held back from autoremove or state that could not be recorded.
+type Warning struct {
+	// Package is the package the warning is about, or empty for the host.
+	Package string
+	Message string
+}
+
+var (
+	warningsMu sync.Mutex
+	warnings   []Warning
+)
+
+// Warn prints a warning about a package, or the host when packageName is
+// empty, and keeps it for the summary of the command.
+func Warn(packageName, format string, args ...any) {
+	warning := Warning{Package: packageName, Message: fmt.Sprintf(format, args...)}
+	fmt.Fprintf(Console, "⚠️  %s\n", warning.Message)
+
+	warningsMu.Lock()
+	defer warningsMu.Unlock()
+	warnings = append(warnings, warning)
+}
+
+// TakeWarnings returns the warnings given since the last call and forgets
+// them.
+func TakeWarnings() []Warning {
+	warningsMu.Lock()
+	defer warningsMu.Unlock()
+	taken := warnings
+	warnings = nil
+	return taken
+}
@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Ownership is what provided a file or command, for `run which`.
+type Ownership struct {
+	Path string `json:"path"`
+	// Resolved is the file Path leads to through symlinks, alternatives and
+	// run's shims.
+	Resolved string `json:"resolved,omitempty"`
+	// Package is the run package that provided it, if any.
+	Package string `json:"package,omitempty"`
+	// AptPackage is the Debian package that owns the file, if any.
+	AptPackage string `json:"apt_package,omitempty"`
+	// Source is how the file got there: apt, nvm, pyenv, sdkman, npm,
+	// local (run install --from-file) or unmanaged.
+	Source string `json:"source"`
+	// RequiredBy are installed run packages that depend on Package.
+	RequiredBy []string `json:"required_by,omitempty"`
+}
+
+// shimTargetPattern finds the binary a run shim executes.
+var shimTargetPattern = regexp.MustCompile(`(?m)^exec "([^"]+)"`)
+
+// versionManagerDirs are where version managers keep the versions they
+// install, relative to the home directory, with the run package using them.
+var versionManagerDirs = []struct {
+	dir, source, packageName string
+}{
+	{".nvm", "nvm", "node"},
+	{".pyenv", "pyenv", "python"},
+	{".sdkman", "sdkman", "java"},
+}
+
+// Which finds what provided a file or a command on PATH: a run package
+// through apt, a version manager or npm, a local package, or nothing run
+// manages.
+func Which(target string) (Ownership, error) {
+	filePath := target
+	if !strings.Contains(target, "/") {
+		found, err := exec.LookPath(target)
+		if err != nil {
+			return Ownership{}, fmt.Errorf("%s not found on PATH", target)
+		}
+		filePath = found
+	}
+	filePath, err := filepath.Abs(filePath)
+	if err != nil {
+		return Ownership{}, err
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		return Ownership{}, fmt.Errorf("%s: %v", target, err)
+	}
+
+	ownership := Ownership{Path: filePath, Source: "unmanaged"}
+	resolved := resolveShim(filePath)
+	if evaluated, err := filepath.EvalSymlinks(resolved); err == nil {
+		resolved = evaluated
+	}
+	if resolved != filePath {
+		ownership.Resolved = resolved
+	}
+
+	home, _ := os.UserHomeDir()
+	for _, manager := range versionManagerDirs {
+		if home != "" && strings.HasPrefix(resolved, filepath.Join(home, manager.dir)+"/") {
+			ownership.Source, ownership.Package = manager.source, manager.packageName
+			ownership.RequiredBy = installedDependents(ownership.Package)
+			return ownership, nil
+		}
+	}
+	if strings.Contains(resolved, "/node_modules/pm2/") {
+		ownership.Source, ownership.Package = "npm", "pm2"
+		ownership.RequiredBy = installedDependents(ownership.Package)
+		return ownership, nil
+	}
+
+	// dpkg knows the path it unpacked, which may be either end of a symlink
+	// or, on merged-/usr systems, the path without /usr
+	candidates := []string{filePath, resolved}
+	for _, candidate := range []string{filePath, resolved} {
+		if rest, found := strings.CutPrefix(candidate, "/usr"); found {
+			candidates = append(candidates, rest)
+		}
+	}
+	for _, candidate := range candidates {
+		if aptPackage := dpkgOwner(candidate); aptPackage != "" {
+			ownership.AptPackage = aptPackage
+			ownership.Source = "apt"
+			break
+		}
+	}
+	if ownership.AptPackage == "" {
+		return ownership, nil
+	}
+	if state, err := LoadState(); err == nil {
+		if pkg, exists := state.LocalPackages[ownership.AptPackage]; exists && pkg.Kind == "deb" {
+			ownership.Source, ownership.Package = "local", ownership.AptPackage
+			return ownership, nil
+		}
+	}
+	ownership.Package = aptPackageOwner(ownership.AptPackage)
+	if ownership.Package != "" {
+		ownership.RequiredBy = installedDependents(ownership.Package)
+	}
+	return ownership, nil
+}
+
+// resolveShim returns the binary a run shim in ~/.run/shims executes, or
+// the path itself when it is not a shim.
+func resolveShim(filePath string) string {
+	shimDir, err := ShimDir()
+	if err != nil || !strings.HasPrefix(filePath, shimDir+"/") {
+		return filePath
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil || !strings.Contains(string(data), shimMarker) {
+		return filePath
+	}
+	if match := shimTargetPattern.FindStringSubmatch(string(data)); match != nil {
+		return unescapeShellValue(match[1])
+	}
+	return filePath
+}
+
+// dpkgOwner returns the Debian package that owns a path, or an empty string.
+func dpkgOwner(filePath string) string {
+	output, err := exec.Command("dpkg-query", "-S", filePath).Output()
+	if err != nil {
+		return ""
+	}
+	// "nodejs: /usr/bin/node"; several owners are listed comma-separated,
+	// multi-arch packages carry :<arch> and diversions get lines of their own
+	for _, line := range strings.Split(string(output), "\n") {
+		owners, _, found := strings.Cut(line, ": ")
+		if !found || strings.HasPrefix(line, "diversion by ") {
+			continue
+		}
+		owner := strings.TrimSpace(strings.Split(owners, ",")[0])
+		owner, _, _ = strings.Cut(owner, ":")
+		return owner
+	}
+	return ""
+}
+
+// aptPackageOwner returns the run package whose apt patterns match a Debian
+// package, or an empty string.
+func aptPackageOwner(aptPackage string) string {
+	for _, packageName := range append(mapKeys(PackageAptPatterns), "essentials") {
+		for _, pattern := range packageAptPatterns(packageName) {
+			if matched, _ := path.Match(pattern, aptPackage); matched {
+				return packageName
+			}
+		}
+	}
+	return ""
+}
+
+// installedDependents returns the installed run packages that depend on a
+// package, so removing it would break them.
+func installedDependents(packageName string) []string {
+	graph := NewDependencyGraph()
+	var dependents []string
+	for _, name := range graph.Packages() {
+		for _, dependency := range graph.Dependencies(name) {
+			if dependency == packageName && isInstalled(name) {
+				dependents = append(dependents, name)
+			}
+		}
+	}
+	return dependents
+}
@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/amoga-io/run/internal/system"
+)
+
+// DefaultWSLDistro is the distribution `run bootstrap wsl` creates.
+const DefaultWSLDistro = "Ubuntu-24.04"
+
+// installScriptURL is the one-line installer of run.
+const installScriptURL = "https://raw.githubusercontent.com/amoga-io/run/main/scripts/install.sh"
+
+var (
+	wslDistroPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+	wslUserPattern   = regexp.MustCompile(`^[a-z_][a-z0-9_-]*$`)
+	profilePattern   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+)
+
+// WSLBootstrap is a WSL distribution to set up with run: created when
+// missing, updated, with run installed for User and Profile applied.
+type WSLBootstrap struct {
+	Distro string
+	User   string
+	// Profile is applied when set.
+	Profile string
+}
+
+// WSLStep is one wsl.exe invocation of a bootstrap.
+type WSLStep struct {
+	Description string
+	Args        []string
+	// IfMissing runs the step only when the distribution does not exist.
+	IfMissing bool
+}
+
+// DefaultWSLUser returns the Windows user name as a Linux user name, or
+// "dev" when it cannot be used as one.
+func DefaultWSLUser() string {
+	name := strings.ToLower(os.Getenv("USERNAME"))
+	if !wslUserPattern.MatchString(name) {
+		return "dev"
+	}
+	return name
+}
+
+// Validate checks the names that end up in the commands run in the
+// distribution.
+func (b WSLBootstrap) Validate() error {
+	if !wslDistroPattern.MatchString(b.Distro) {
+		return fmt.Errorf("invalid distribution name '%s'", b.Distro)
+	}
+	if !wslUserPattern.MatchString(b.User) {
+		return fmt.Errorf("invalid user name '%s': use lowercase letters, digits, - and _", b.User)
+	}
+	if b.Profile != "" && !profilePattern.MatchString(b.Profile) {
+		return fmt.Errorf("invalid profile name '%s'", b.Profile)
+	}
+	return nil
+}
+
+// Steps returns the wsl.exe invocations of the bootstrap. The shell scripts
+// they run hold no double quotes, which PowerShell mangles when passing
+// arguments to wsl.exe.
+func (b WSLBootstrap) Steps() []WSLStep {
+	// The user gets passwordless sudo so package scripts run unattended, and
+	// systemd is enabled because packages run services
+	setup := strings.Join([]string{
+		"set -e",
+		"id -u " + b.User + " >/dev/null 2>&1 || useradd -m -s /bin/bash -G sudo " + b.User,
+		"echo '" + b.User + " ALL=(ALL) NOPASSWD:ALL' > /etc/sudoers.d/90-run-" + b.User,
+		"chmod 440 /etc/sudoers.d/90-run-" + b.User,
+		"grep -q '^systemd=true' /etc/wsl.conf 2>/dev/null || printf '[boot]\\nsystemd=true\\n' >> /etc/wsl.conf",
+		"grep -q '^default=' /etc/wsl.conf 2>/dev/null || printf '[user]\\ndefault=%s\\n' " + b.User + " >> /etc/wsl.conf",
+		"apt-get update",
+		"DEBIAN_FRONTEND=noninteractive apt-get -y upgrade",
+	}, "; ")
+	install := "if command -v run >/dev/null; then run update --yes; else curl -fsSL " + installScriptURL + " | bash; fi"
+
+	steps := []WSLStep{
+		{Description: "Creating " + b.Distro, Args: []string{"--install", "--distribution", b.Distro, "--no-launch"}, IfMissing: true},
+		{Description: "Updating " + b.Distro + " and setting up " + b.User, Args: []string{"--distribution", b.Distro, "--user", "root", "--", "bash", "-c", setup}},
+		{Description: "Restarting " + b.Distro + " with systemd", Args: []string{"--terminate", b.Distro}},
+		{Description: "Installing run", Args: []string{"--distribution", b.Distro, "--user", b.User, "--", "bash", "-lc", install}},
+	}
+	if b.Profile != "" {
+		steps = append(steps, WSLStep{
+			Description: "Applying the " + b.Profile + " profile",
+			Args:        []string{"--distribution", b.Distro, "--user", b.User, "--", "bash", "-lc", "run profile apply " + b.Profile + " --yes"},
+		})
+	}
+	return steps
+}
+
+// wslCommand runs wsl.exe with UTF-8 output; it writes UTF-16 otherwise.
+func wslCommand(args ...string) *system.Cmd {
+	return system.Command("wsl.exe", args...).WithEnv("WSL_UTF8=1")
+}
+
+// WSLDistroExists reports whether a WSL distribution is registered.
+func WSLDistroExists(distro string) (bool, error) {
+	output, err := wslCommand("--list", "--quiet").CaptureOutput()
+	if err != nil {
+		return false, fmt.Errorf("failed to list WSL distributions: %v", err)
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(string(output), "\x00", ""), "\n") {
+		if strings.EqualFold(strings.TrimSpace(line), distro) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RunWSLBootstrap runs the bootstrap through wsl.exe, from Windows or from
+// inside WSL with Windows interop.
+func RunWSLBootstrap(b WSLBootstrap, stdout, stderr io.Writer) error {
+	exists, err := WSLDistroExists(b.Distro)
+	if err != nil {
+		return err
+	}
+	for _, step := range b.Steps() {
+		if step.IfMissing && exists {
+			continue
+		}
+		fmt.Fprintf(stdout, "==> %s\n", step.Description)
+		if err := wslCommand(step.Args...).Stream(stdout, stderr); err != nil {
+			return fmt.Errorf("%s failed: %v", strings.ToLower(step.Description[:1])+step.Description[1:], err)
+		}
+	}
+	return nil
+}
+
+// WSLBootstrapScript renders the bootstrap as a PowerShell script, for
+// Windows machines without run.
+func WSLBootstrapScript(b WSLBootstrap) string {
+	var script strings.Builder
+	fmt.Fprintf(&script, "# Sets up the WSL distribution %s with run. Generated by: run bootstrap wsl --script\n", b.Distro)
+	script.WriteString("$ErrorActionPreference = 'Stop'\n")
+	script.WriteString("$env:WSL_UTF8 = '1'\n")
+	fmt.Fprintf(&script, "$exists = @(wsl.exe --list --quiet) -replace \"`0\", '' | Where-Object { $_.Trim() -eq %s }\n", powerShellQuote(b.Distro))
+	for _, step := range b.Steps() {
+		var args []string
+		for _, arg := range step.Args {
+			args = append(args, powerShellQuote(arg))
+		}
+		call := fmt.Sprintf("Write-Host %s\nwsl.exe %s\nif ($LASTEXITCODE -ne 0) { throw %s }\n",
+			powerShellQuote("==> "+step.Description), strings.Join(args, " "), powerShellQuote(step.Description+" failed"))
+		if step.IfMissing {
+			call = "if (-not $exists) {\n" + call + "}\n"
+		}
+		script.WriteString(call)
+	}
+	return script.String()
+}
+
+// powerShellQuote quotes a string for PowerShell, where only ' is special
+// inside single quotes.
+func powerShellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
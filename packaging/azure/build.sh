@@ -0,0 +1,25 @@
+#!/usr/bin/env bash
+# Package run as an Azure VM extension handler.
+#
+# Usage: packaging/azure/build.sh [version]
+#
+# Writes dist/run-azure-extension-<version>.zip holding HandlerManifest.json,
+# bin/run (linux/amd64) and the package scripts. Publish it with the Azure
+# extension publishing tools; the handler is `run azure-extension`.
+set -euo pipefail
+
+VERSION="${1:-1.0.0}"
+ROOT="$(cd "$(dirname "${BASH_SOURCE[0]}")/../.." && pwd)"
+STAGE="$(mktemp -d)"
+trap 'rm -rf "$STAGE"' EXIT
+
+mkdir -p "$STAGE/bin" "$ROOT/dist"
+(cd "$ROOT" && CGO_ENABLED=0 GOOS=linux GOARCH=amd64 go build -o "$STAGE/bin/run" .)
+cp "$ROOT/packaging/azure/HandlerManifest.json" "$STAGE/"
+cp -r "$ROOT/scripts" "$STAGE/scripts"
+rm -f "$STAGE/scripts/install.sh"
+
+ARCHIVE="$ROOT/dist/run-azure-extension-$VERSION.zip"
+rm -f "$ARCHIVE"
+(cd "$STAGE" && zip -qr "$ARCHIVE" .)
+echo "Wrote $ARCHIVE"
@@ -1,5 +1,7 @@
 #!/bin/bash
 
+set -e
+
 # Install dependencies
 sudo apt-get update
 sudo apt-get install -y ca-certificates curl gnupg
@@ -19,16 +21,14 @@ echo \
 sudo apt-get update
 sudo apt-get install -y docker-ce docker-ce-cli containerd.io docker-buildx-plugin docker-compose-plugin
 
-# Setup user permissions
-sudo groupadd -f docker
-sudo usermod -aG docker $USER
+# run adds the app user to the docker group once this script succeeds
 
 # Ensure docker.sock has correct permissions
 sudo chmod 666 /var/run/docker.sock
 
 # Create default Docker config directory
 sudo mkdir -p /etc/docker
-sudo chown -R $USER:docker /etc/docker
+sudo chown -R "$USER:docker" /etc/docker
 
 # Start and enable Docker service
 sudo systemctl enable docker
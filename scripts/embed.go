@@ -0,0 +1,10 @@
+// Package scripts embeds the first-party package scripts, so a standalone
+// run binary can install packages without the checkout in ~/.run.
+package scripts
+
+import "embed"
+
+// FS holds the install and removal scripts.
+//
+//go:embed *.sh
+var FS embed.FS
@@ -1,34 +1,44 @@
 #!/bin/bash
 
-# Update package lists and install essential development tools
-# build-essential: provides compiler and libraries needed for building packages
-# python3: Python programming language interpreter
-# g++: GNU C++ compiler
-# make: utility to maintain groups of programs
-sudo apt-get update
-sudo apt-get install -y build-essential python3 g++ make
+# Essential tools installation
+#
+# Environment (set by `run install essentials` from essentials.items in
+# ~/.run/config.yaml):
+#   ESSENTIALS_PACKAGES  apt packages to install
+#   ESSENTIALS_SERVICES  systemd services to enable and start
+#   RUN_NTP_SERVERS      time servers of the cloud profile, if any
+
+set -e
+
+ESSENTIALS_PACKAGES="${ESSENTIALS_PACKAGES-build-essential g++ make python3 redis-server ncdu jq curl wget git}"
+ESSENTIALS_SERVICES="${ESSENTIALS_SERVICES-redis-server}"
+
+# Install the selected packages
+if [ -n "$ESSENTIALS_PACKAGES" ]; then
+    echo "Installing: $ESSENTIALS_PACKAGES"
+    sudo apt-get update
+    # shellcheck disable=SC2086
+    sudo apt-get install -y $ESSENTIALS_PACKAGES
+fi
+
+# Enable and start services of the selected items (e.g. redis-server)
+for service in $ESSENTIALS_SERVICES; do
+    sudo systemctl enable "$service"
+    sudo systemctl start "$service"
+done
 
 # Configure system logs to prevent disk space issues
 # This limits the maximum size of the systemd journal logs to 512MB
-# Prevents logs from consuming too much disk space
-echo "SystemMaxUse=512M" >> /etc/systemd/journald.conf
+grep -q "^SystemMaxUse=" /etc/systemd/journald.conf || echo "SystemMaxUse=512M" | sudo tee -a /etc/systemd/journald.conf > /dev/null
 sudo systemctl restart systemd-journald
 
-# Install and configure Redis server
-# Redis is an in-memory data structure store used as database, cache, and message broker
-sudo apt-get install -y redis-server
-sudo systemctl enable redis-server  # Configure Redis to start on boot
-sudo systemctl start redis-server   # Start the Redis service
-
-# Install system utility packages
-# ncdu: NCurses Disk Usage - interactive disk usage analyzer
-# jq: lightweight command-line JSON processor
-# curl: tool for transferring data with URLs
-# wget: non-interactive network downloader
-# git: distributed version control system
-sudo apt install ncdu jq curl wget git -y
-
 # Disable core dumps for security
-# This prevents the system from creating core dump files when programs crash
 # Core dumps can contain sensitive information and consume disk space
-grep -q "* hard core 0" /etc/security/limits.conf || echo "* hard core 0" | sudo tee -a /etc/security/limits.conf
\ No newline at end of file
+grep -q "* hard core 0" /etc/security/limits.conf || echo "* hard core 0" | sudo tee -a /etc/security/limits.conf > /dev/null
+
+# Use the cloud provider's time servers
+if [ -n "$RUN_NTP_SERVERS" ]; then
+    sudo mkdir -p /etc/systemd/timesyncd.conf.d
+    printf '[Time]\nNTP=%s\n' "$RUN_NTP_SERVERS" | sudo tee /etc/systemd/timesyncd.conf.d/60-run-cloud.conf > /dev/null
+    sudo systemctl restart systemd-timesyncd || true
+fi
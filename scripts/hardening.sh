@@ -0,0 +1,68 @@
+#!/bin/bash
+
+# System hardening: fail2ban, unattended-upgrades and an sshd drop-in
+#
+# Environment (set by `run install hardening` from hardening.* in
+# ~/.run/config.yaml):
+#   HARDENING_SSH_KEY_ONLY  "true" disables SSH password authentication
+
+set -e
+
+# sshd keeps the first value it reads, so the drop-in sorts before others
+# such as 50-cloud-init.conf
+SSHD_DROPIN="/etc/ssh/sshd_config.d/01-run-hardening.conf"
+HARDENING_SSH_KEY_ONLY="${HARDENING_SSH_KEY_ONLY:-false}"
+
+# Refuse key-only auth when it would lock the current user out
+if [ "$HARDENING_SSH_KEY_ONLY" = "true" ] && [ ! -s "$HOME/.ssh/authorized_keys" ]; then
+    echo "Refusing to disable SSH password authentication: $HOME/.ssh/authorized_keys is empty"
+    exit 1
+fi
+
+# Install fail2ban and unattended-upgrades
+export DEBIAN_FRONTEND=noninteractive
+sudo apt-get update
+sudo apt-get install -y fail2ban unattended-upgrades
+
+# Enable automatic security updates
+echo 'APT::Periodic::Update-Package-Lists "1";
+APT::Periodic::Unattended-Upgrade "1";' | sudo tee /etc/apt/apt.conf.d/20auto-upgrades > /dev/null
+sudo systemctl enable --now unattended-upgrades
+
+# Protect sshd with fail2ban's default jail
+echo '[sshd]
+enabled = true' | sudo tee /etc/fail2ban/jail.d/run-sshd.conf > /dev/null
+sudo systemctl enable fail2ban
+sudo systemctl restart fail2ban
+
+# Apply the opinionated sshd drop-in. PasswordAuthentication is only set
+# for key-only auth, leaving the image's own setting alone otherwise. The
+# drop-in of earlier versions, which sorted after cloud-init's, is replaced.
+sudo rm -f /etc/ssh/sshd_config.d/60-run-hardening.conf
+{
+    echo "# Managed by run - removed by 'run remove hardening'
+PermitRootLogin no
+MaxAuthTries 3
+X11Forwarding no
+PermitEmptyPasswords no"
+    if [ "$HARDENING_SSH_KEY_ONLY" = "true" ]; then
+        echo "PasswordAuthentication no"
+    fi
+} | sudo tee "$SSHD_DROPIN" > /dev/null
+
+# Validate before reloading so a bad config never reaches sshd
+if ! sudo sshd -t; then
+    echo "sshd configuration test failed, removing $SSHD_DROPIN"
+    sudo rm -f "$SSHD_DROPIN"
+    exit 1
+fi
+
+# Check that the drop-in wins over the rest of the sshd configuration
+if [ "$HARDENING_SSH_KEY_ONLY" = "true" ] && ! sudo sshd -T | grep -qx 'passwordauthentication no'; then
+    echo "SSH password authentication is still enabled by another sshd configuration file, removing $SSHD_DROPIN"
+    sudo rm -f "$SSHD_DROPIN"
+    exit 1
+fi
+sudo systemctl reload ssh
+
+echo "System hardening applied"
@@ -3,7 +3,10 @@ set -euo pipefail
 
 # Configuration
 BINARY_NAME="run"
-INSTALL_DIR="/usr/local/bin"
+# RUN_PREFIX installs to $RUN_PREFIX/bin, e.g. ~/.local where /usr/local is
+# read-only or managed by other tooling; it is kept as update.prefix
+RUN_PREFIX="${RUN_PREFIX:-/usr/local}"
+INSTALL_DIR="$RUN_PREFIX/bin"
 REPO_URL="https://github.com/amoga-io/run.git"
 PERSISTENT_DIR="$HOME/.run"
 
@@ -12,6 +15,15 @@ export DEBIAN_FRONTEND=noninteractive
 
 echo "Installing run CLI..."
 
+# Runs a command on the install directory, with sudo unless it is writable
+install_cmd() {
+    if [ -w "$INSTALL_DIR" ]; then
+        "$@"
+    else
+        sudo "$@"
+    fi
+}
+
 # Step 1: Check if binary and directory already exist, remove them
 BINARY_PATH="$INSTALL_DIR/$BINARY_NAME"
 existing_installation=false
@@ -21,7 +33,7 @@ if [ -f "$BINARY_PATH" ] || [ -d "$PERSISTENT_DIR" ]; then
     echo "Existing installation detected - cleaning up..."
     
     if [ -f "$BINARY_PATH" ]; then
-        sudo rm -f "$BINARY_PATH"
+        install_cmd rm -f "$BINARY_PATH"
     fi
     
     if [ -d "$PERSISTENT_DIR" ]; then
@@ -34,8 +46,8 @@ fi
 # Cleanup function for failed installations
 cleanup_on_error() {
     echo "✗ Installation failed. Cleaning up..."
-    sudo rm -f "$INSTALL_DIR/${BINARY_NAME}.new" 2>/dev/null || true
-    sudo rm -f "$BINARY_PATH" 2>/dev/null || true
+    install_cmd rm -f "$INSTALL_DIR/${BINARY_NAME}.new" 2>/dev/null || true
+    install_cmd rm -f "$BINARY_PATH" 2>/dev/null || true
     rm -rf "$PERSISTENT_DIR" 2>/dev/null || true
     exit 1
 }
@@ -144,14 +156,32 @@ fi
 
 # Step 4: Install binary and let CLI handle advanced dependency management
 echo "Installing binary to $INSTALL_DIR..."
-sudo mkdir -p "$INSTALL_DIR"
+mkdir -p "$INSTALL_DIR" 2>/dev/null || sudo mkdir -p "$INSTALL_DIR"
 
 # Use atomic installation to prevent "text file busy" errors
 TEMP_BINARY="$INSTALL_DIR/${BINARY_NAME}.new"
 
-sudo cp "$BINARY_NAME" "$TEMP_BINARY"
-sudo chmod +x "$TEMP_BINARY"
-sudo mv "$TEMP_BINARY" "$BINARY_PATH"
+install_cmd cp "$BINARY_NAME" "$TEMP_BINARY"
+install_cmd chmod +x "$TEMP_BINARY"
+install_cmd mv "$TEMP_BINARY" "$BINARY_PATH"
+
+# Keep a custom prefix for run update, and put it on PATH through the
+# managed env file that ~/.profile sources
+if [ "$RUN_PREFIX" != "/usr/local" ]; then
+    if [ ! -f "$PERSISTENT_DIR/config.yaml" ]; then
+        printf 'update:\n  prefix: %s\n' "$RUN_PREFIX" > "$PERSISTENT_DIR/config.yaml"
+    fi
+    case ":$PATH:" in
+        *":$INSTALL_DIR:"*) ;;
+        *)
+            printf '# Managed by run - changes are overwritten. Sourced from ~/.profile.\nexport PATH="%s:$PATH"\n' "$INSTALL_DIR" > "$PERSISTENT_DIR/env"
+            if ! grep -qF '.run/env' "$HOME/.profile" 2>/dev/null; then
+                printf '\n# Added by run\n[ -f "$HOME/.run/env" ] && . "$HOME/.run/env"\n' >> "$HOME/.profile"
+            fi
+            export PATH="$INSTALL_DIR:$PATH"
+            ;;
+    esac
+fi
 
 # Step 5: Let CLI verify its own installation
 echo "Finalizing installation..."
@@ -1,31 +1,30 @@
 #!/bin/bash
 
+# Java installation
+#
+# Environment (set by `run install java`):
+#   JAVA_VENDOR   openjdk (default), temurin or corretto
+#   JAVA_VERSION  major version to install: 11, 17 or 21 (default)
+
+set -e
+
+JAVA_VENDOR="${JAVA_VENDOR:-openjdk}"
+
 # Function to check if Java is installed
 check_java() {
     if java -version &>/dev/null; then
         echo "Java is already installed."
         java -version
-        return 0
-    else
-        echo "Java is not installed."
-        return 1
     fi
 }
 
-# Function to install Java
-install_java() {
-    echo "Available Java versions to install: 11, 17, 21"
-    read -p "Enter the Java version you want to install: " java_version
+# Function to check the requested Java version; a prompt would hang
+# unattended installs, so an unset version means the latest LTS
+select_java_version() {
+    JAVA_VERSION="${JAVA_VERSION:-21}"
 
-    case "$java_version" in
-        11)
-            sudo apt install -y openjdk-11-jdk
-            ;;
-        17)
-            sudo apt install -y openjdk-17-jdk
-            ;;
-        21)
-            sudo apt install -y openjdk-21-jdk
+    case "$JAVA_VERSION" in
+        11|17|21)
             ;;
         *)
             echo "Invalid selection. Please choose 11, 17, or 21."
@@ -34,18 +33,57 @@ install_java() {
     esac
 }
 
-# Function to set default Java version
-set_java_version() {
-    echo "Setting Java version..."
-    sudo update-alternatives --config java
+# Install OpenJDK from the distribution repositories
+install_openjdk() {
+    sudo apt-get update
+    sudo apt-get install -y "openjdk-${JAVA_VERSION}-jdk"
+}
+
+# Install Eclipse Temurin from the Adoptium repository
+install_temurin() {
+    sudo apt-get update
+    sudo apt-get install -y wget apt-transport-https gpg
+    sudo install -m 0755 -d /etc/apt/keyrings
+    wget -qO - https://packages.adoptium.net/artifactory/api/gpg/key/public | sudo gpg --dearmor --yes -o /etc/apt/keyrings/adoptium.gpg
+    echo "deb [signed-by=/etc/apt/keyrings/adoptium.gpg] https://packages.adoptium.net/artifactory/deb $(. /etc/os-release && echo "$VERSION_CODENAME") main" | \
+        sudo tee /etc/apt/sources.list.d/adoptium.list > /dev/null
+    sudo apt-get update
+    sudo apt-get install -y "temurin-${JAVA_VERSION}-jdk"
+}
+
+# Install Amazon Corretto from the Corretto repository
+install_corretto() {
+    sudo apt-get update
+    sudo apt-get install -y wget gpg
+    sudo install -m 0755 -d /etc/apt/keyrings
+    wget -qO - https://apt.corretto.aws/corretto.key | sudo gpg --dearmor --yes -o /etc/apt/keyrings/corretto.gpg
+    echo "deb [signed-by=/etc/apt/keyrings/corretto.gpg] https://apt.corretto.aws stable main" | \
+        sudo tee /etc/apt/sources.list.d/corretto.list > /dev/null
+    sudo apt-get update
+    sudo apt-get install -y "java-${JAVA_VERSION}-amazon-corretto-jdk"
 }
 
 # Main script execution
 echo "Checking if Java is installed..."
-check_java || install_java
+check_java
+select_java_version
 
-# Activate the selected Java version
-set_java_version
+echo "Installing Java $JAVA_VERSION ($JAVA_VENDOR)..."
+case "$JAVA_VENDOR" in
+    openjdk)
+        install_openjdk
+        ;;
+    temurin)
+        install_temurin
+        ;;
+    corretto)
+        install_corretto
+        ;;
+    *)
+        echo "Unknown Java vendor: $JAVA_VENDOR"
+        exit 1
+        ;;
+esac
 
-echo "Java installation and setup complete!"
-java -version
+# Alternatives registration and JAVA_HOME are handled by run after this script.
+echo "Java installation complete!"
@@ -1,32 +1,76 @@
 #!/bin/bash
 
-# Install Node.js 20
-curl -fsSL https://deb.nodesource.com/setup_20.x | sudo -E bash -
-sudo apt-get install -y nodejs
+# Node.js installation
+#
+# Environment (set by `run install node`):
+#   NODE_BACKEND  nodesource (default), nvm or apt; see `run list --backends`
+#   NODE_VERSION  version to install (default 20): a major version for
+#                 nodesource, any nvm version for nvm, ignored for apt
 
-# Create npm global directory in user's home
-mkdir -p ~/.npm-global
-npm config set prefix ~/.npm-global
+set -e
 
-# Add npm global bin to PATH in ~/.profile if not already present
-if ! grep -q "PATH=~/.npm-global/bin:\$PATH" ~/.profile; then
-    echo 'export PATH=~/.npm-global/bin:$PATH' >> ~/.profile
-fi
+NODE_BACKEND="${NODE_BACKEND:-nodesource}"
+NODE_VERSION="${NODE_VERSION:-20}"
+NVM_VERSION="v0.40.1"
+
+# Install a major version from the NodeSource repository
+install_nodesource() {
+    curl -fsSL "https://deb.nodesource.com/setup_${NODE_VERSION%%.*}.x" | sudo -E bash -
+    sudo apt-get install -y nodejs
+}
+
+# Install the nodejs package of the Ubuntu release
+install_apt() {
+    sudo apt-get update
+    sudo apt-get install -y nodejs npm
+}
 
-# Source the updated profile
-source ~/.profile
+# Install nvm in the user's home and the version with it; earlier versions
+# stay installed side by side
+install_nvm() {
+    export NVM_DIR="$HOME/.nvm"
+    if [ ! -s "$NVM_DIR/nvm.sh" ]; then
+        curl -fsSL "https://raw.githubusercontent.com/nvm-sh/nvm/${NVM_VERSION}/install.sh" | PROFILE=/dev/null bash
+    fi
+    # shellcheck disable=SC1091
+    . "$NVM_DIR/nvm.sh"
+    nvm install "$NODE_VERSION"
+    nvm alias default "$NODE_VERSION"
 
-# Install pnpm 9.10.0 using npm
-npm install -g pnpm@9.10.0
+    # nvm.sh is only sourced by interactive shells; `run shims` covers the rest
+    if ! grep -q 'NVM_DIR' ~/.profile 2>/dev/null; then
+        {
+            echo 'export NVM_DIR="$HOME/.nvm"'
+            echo '[ -s "$NVM_DIR/nvm.sh" ] && . "$NVM_DIR/nvm.sh"'
+        } >> ~/.profile
+    fi
+}
 
-# Add pnpm to PATH
-export PATH=~/.npm-global/bin:$PATH
+case "$NODE_BACKEND" in
+    nodesource) install_nodesource ;;
+    apt) install_apt ;;
+    nvm) install_nvm ;;
+    *)
+        echo "Unknown node backend '$NODE_BACKEND'. Choose nodesource, nvm or apt."
+        exit 1
+        ;;
+esac
 
-# Install pm2
-npm install -g pm2
+# nvm keeps global packages per version in the user's home already
+if [ "$NODE_BACKEND" != "nvm" ]; then
+    # Create npm global directory in user's home
+    mkdir -p ~/.npm-global
+    npm config set prefix ~/.npm-global
 
-# Setup PM2 startup script
-pm2 startup
+    # Add npm global bin to PATH in ~/.profile if not already present
+    if ! grep -q "PATH=~/.npm-global/bin:\$PATH" ~/.profile; then
+        echo 'export PATH=~/.npm-global/bin:$PATH' >> ~/.profile
+    fi
+
+    # Source the updated profile
+    source ~/.profile
+fi
 
-# Execute the generated PM2 startup command
-sudo env PATH=$PATH:/usr/bin /home/azureuser/.npm-global/lib/node_modules/pm2/bin/pm2 startup systemd -u azureuser --hp /home/azureuser
\ No newline at end of file
+# Global npm packages (pnpm, pm2, ...) are installed by run from
+# node.global_packages in ~/.run/config.yaml, after which run configures
+# pm2 startup and pm2 save so apps come back after a reboot
@@ -0,0 +1,73 @@
+#!/bin/bash
+
+# NVIDIA driver, CUDA toolkit and cuDNN from NVIDIA's CUDA repository
+#
+# Environment (set by `run install nvidia`):
+#   NVIDIA_VERSION  CUDA toolkit version to install (default 12.6)
+#   NVIDIA_DRIVER   driver branch such as 550; defaults to the newest driver
+#                   the CUDA repository recommends (cuda-drivers), which
+#                   RUN_STRICT=1 (run --strict) refuses
+#
+# run refuses the package on hosts without an NVIDIA GPU before this runs.
+
+set -e
+
+CUDA_VERSION="${NVIDIA_VERSION:-12.6}"
+CUDA_MAJOR="${CUDA_VERSION%%.*}"
+
+. /etc/os-release
+case "$(dpkg --print-architecture)" in
+    amd64) CUDA_ARCH="x86_64" ;;
+    arm64) CUDA_ARCH="sbsa" ;;
+    *)
+        echo "CUDA packages are not available for $(dpkg --print-architecture)"
+        exit 1
+        ;;
+esac
+CUDA_REPO="https://developer.download.nvidia.com/compute/cuda/repos/ubuntu${VERSION_ID//./}/${CUDA_ARCH}"
+
+echo "GPU(s) found:"
+lspci | grep -i nvidia | grep -Ei 'vga|3d controller'
+
+# Headers for the kernel the DKMS module is built against
+sudo apt-get update
+sudo apt-get install -y "linux-headers-$(uname -r)" dkms curl
+
+# NVIDIA's repository signing key and source
+if ! dpkg -s cuda-keyring &>/dev/null; then
+    curl -fsSL -o /tmp/cuda-keyring.deb "${CUDA_REPO}/cuda-keyring_1.1-1_all.deb"
+    sudo dpkg -i /tmp/cuda-keyring.deb
+    rm -f /tmp/cuda-keyring.deb
+fi
+sudo apt-get update
+
+# Driver
+if [ -z "$NVIDIA_DRIVER" ] && [ "${RUN_STRICT:-}" = "1" ]; then
+    echo "❌ Set NVIDIA_DRIVER to a driver branch such as 550; --strict does not pick the newest." >&2
+    exit 1
+fi
+if [ -n "$NVIDIA_DRIVER" ]; then
+    sudo apt-get install -y "cuda-drivers-${NVIDIA_DRIVER}"
+else
+    sudo apt-get install -y cuda-drivers
+fi
+
+# CUDA toolkit and the matching cuDNN
+sudo apt-get install -y "cuda-toolkit-${CUDA_VERSION//./-}"
+sudo apt-get install -y "cudnn9-cuda-${CUDA_MAJOR}"
+
+# The module is loaded on the next boot; say so instead of failing checks
+if ! nvidia-smi &>/dev/null; then
+    echo "nvidia-dkms" | sudo tee -a /var/run/reboot-required.pkgs > /dev/null
+    sudo touch /var/run/reboot-required
+    echo ""
+    echo "⚠️  The NVIDIA driver is installed but not loaded yet."
+    echo "   Reboot when convenient (sudo reboot), then verify with: run check nvidia"
+    if mokutil --sb-state 2>/dev/null | grep -q enabled; then
+        echo "   Secure Boot is enabled: the unsigned module will not load until its"
+        echo "   key is enrolled (sudo mokutil --import /var/lib/shim-signed/mok/MOK.der)"
+        echo "   or Secure Boot is turned off for this VM."
+    fi
+fi
+
+echo "CUDA ${CUDA_VERSION} installed successfully"
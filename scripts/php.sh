@@ -1,28 +1,169 @@
 #!/bin/bash
-# Simple script to install latest PHP on Ubuntu
+# PHP installation from ppa:ondrej/php
+#
+# Environment (set by `run install php`):
+#   PHP_VERSION       major.minor version to install (default 8.3)
+#   PHP_BUILD_COMMIT  full hash of the reviewed php-build commit to build
+#                     with, required to build from source
+#
+# When the PPA has no packages of PHP_VERSION for this Ubuntu release, PHP is
+# built from source with php-build into /opt/php/<version>, laid out like the
+# PPA for `run php pool`: php-fpm<version> and /etc/php/<version>/fpm/pool.d.
+# Its extensions are those compiled in. Builds are cached in
+# ~/.run/cache/php-build, with their checksums in /var/lib/run/php-build, and
+# reused by later installs while they match. With RUN_STRICT=1 (run --strict)
+# the script fails instead.
 
 # Exit on error
 set -e
 
+PHP_VERSION="${PHP_VERSION:-8.3}"
+PHP_BUILD_REPO="https://github.com/php-build/php-build.git"
+PHP_BUILD_DIR="/usr/local/src/php-build"
+PHP_BUILD_CACHE="$HOME/.run/cache/php-build"
+PHP_BUILD_SUMS="/var/lib/run/php-build"
+PHP_BUILD_DEPS="build-essential autoconf bison re2c pkg-config git libxml2-dev libsqlite3-dev \
+  libssl-dev libcurl4-openssl-dev libonig-dev libzip-dev libpng-dev libjpeg-dev libfreetype-dev \
+  libreadline-dev zlib1g-dev libsodium-dev"
+
+# Install PHP and the common extensions from the PPA
+install_from_ppa() {
+    sudo apt-get install -y "php${PHP_VERSION}" "php${PHP_VERSION}-fpm" "php${PHP_VERSION}-common" \
+      "php${PHP_VERSION}-mysql" "php${PHP_VERSION}-curl" "php${PHP_VERSION}-gd" \
+      "php${PHP_VERSION}-mbstring" "php${PHP_VERSION}-xml" "php${PHP_VERSION}-zip"
+
+    # Enable and start PHP-FPM
+    sudo systemctl enable "php${PHP_VERSION}-fpm"
+    sudo systemctl start "php${PHP_VERSION}-fpm"
+}
+
+# Check out php-build at PHP_BUILD_COMMIT. It runs as root, so never an
+# unreviewed branch head
+checkout_php_build() {
+    if ! [[ "${PHP_BUILD_COMMIT:-}" =~ ^[0-9a-f]{40}$ ]]; then
+        echo "❌ Building PHP ${PHP_VERSION} from source runs php-build as root: set PHP_BUILD_COMMIT" >&2
+        echo "   to the full hash of the php-build commit you reviewed, and install again." >&2
+        exit 1
+    fi
+    if [ "$(sudo git -C "$PHP_BUILD_DIR" rev-parse HEAD 2>/dev/null)" != "$PHP_BUILD_COMMIT" ]; then
+        sudo rm -rf "$PHP_BUILD_DIR"
+        sudo git init -q "$PHP_BUILD_DIR"
+        sudo git -C "$PHP_BUILD_DIR" fetch -q --depth 1 "$PHP_BUILD_REPO" "$PHP_BUILD_COMMIT"
+        sudo git -C "$PHP_BUILD_DIR" checkout -q --detach FETCH_HEAD
+    fi
+    if [ "$(sudo git -C "$PHP_BUILD_DIR" rev-parse HEAD)" != "$PHP_BUILD_COMMIT" ]; then
+        echo "❌ php-build is not at commit ${PHP_BUILD_COMMIT}" >&2
+        exit 1
+    fi
+}
+
+# Lay a source build out like the PPA: php-fpm<version>, and pools in
+# /etc/php/<version>/fpm/pool.d, starting with the default www pool
+setup_fpm_layout() {
+    local prefix="$1" pool_dir="/etc/php/${PHP_VERSION}/fpm/pool.d"
+
+    sudo ln -sf "$prefix/sbin/php-fpm" "/usr/sbin/php-fpm${PHP_VERSION}"
+    sudo mkdir -p "$pool_dir"
+    if [ ! -f "$pool_dir/www.conf" ] && [ -f "$prefix/etc/php-fpm.d/www.conf.default" ]; then
+        sed -e "s|^user = .*|user = www-data|" -e "s|^group = .*|group = www-data|" \
+          -e "s|^listen = .*|listen = /run/php/php${PHP_VERSION}-fpm.sock|" \
+          -e "s|^;listen.owner = .*|listen.owner = www-data|" -e "s|^;listen.group = .*|listen.group = www-data|" \
+          "$prefix/etc/php-fpm.d/www.conf.default" | sudo tee "$pool_dir/www.conf" > /dev/null
+    fi
+    if [ ! -f "$prefix/etc/php-fpm.conf" ] && [ -f "$prefix/etc/php-fpm.conf.default" ]; then
+        sudo cp "$prefix/etc/php-fpm.conf.default" "$prefix/etc/php-fpm.conf"
+    fi
+    sudo sed -i "s|^include=.*|include=${pool_dir}/*.conf|" "$prefix/etc/php-fpm.conf"
+}
+
+# Build PHP from source with php-build, or unpack a cached build
+install_from_source() {
+    local codename arch full prefix archive checksum
+    codename="$(. /etc/os-release && echo "$VERSION_CODENAME")"
+    arch="$(dpkg --print-architecture)"
+
+    if [ "${RUN_STRICT:-}" = "1" ]; then
+        echo "❌ ppa:ondrej/php has no PHP ${PHP_VERSION} for ${codename}; not building from source with --strict." >&2
+        exit 1
+    fi
+    echo "⚠️  ppa:ondrej/php has no PHP ${PHP_VERSION} for ${codename}; building it from source."
+    checkout_php_build
+    echo "⏳ This takes 10-30 minutes instead of about one; later installs reuse the build."
+
+    # shellcheck disable=SC2086
+    sudo apt-get install -y $PHP_BUILD_DEPS
+
+    # php-build needs a full version: the newest release of PHP_VERSION
+    full="$("$PHP_BUILD_DIR/bin/php-build" --definitions | grep -E "^${PHP_VERSION//./\\.}\.[0-9]+$" | sort -V | tail -1)"
+    if [ -z "$full" ]; then
+        echo "PHP ${PHP_VERSION} is not available from php-build either"
+        exit 1
+    fi
+
+    prefix="/opt/php/${PHP_VERSION}"
+    archive="${PHP_BUILD_CACHE}/php-${full}-${codename}-${arch}.tar.gz"
+    checksum="${PHP_BUILD_SUMS}/$(basename "$archive").sha256"
+    # The cache is writable by the user: a build is only unpacked as root
+    # while it matches the checksum root recorded when it was made
+    if [ -f "$archive" ] && [ -f "$checksum" ] && [ "$(sha256sum < "$archive" | cut -d' ' -f1)" = "$(cat "$checksum")" ]; then
+        echo "Reusing the cached build of PHP ${full}"
+        sudo mkdir -p "$prefix"
+        sudo tar -xzf "$archive" -C "$prefix"
+    else
+        if [ -f "$archive" ]; then
+            echo "⚠️  The cached build of PHP ${full} does not match its checksum; building it again."
+        fi
+        sudo "$PHP_BUILD_DIR/bin/php-build" "$full" "$prefix"
+        mkdir -p "$PHP_BUILD_CACHE"
+        sudo tar -czf "$archive" -C "$prefix" .
+        sudo chown "$(id -u):$(id -g)" "$archive"
+        sudo mkdir -p "$PHP_BUILD_SUMS"
+        sha256sum < "$archive" | cut -d' ' -f1 | sudo tee "$checksum" > /dev/null
+    fi
+
+    # Make the build look like a PPA install to `run use php` and `run php`
+    sudo ln -sf "$prefix/bin/php" "/usr/bin/php${PHP_VERSION}"
+    sudo update-alternatives --install /usr/bin/php php "/usr/bin/php${PHP_VERSION}" "${PHP_VERSION//./}"
+
+    if [ -x "$prefix/sbin/php-fpm" ]; then
+        setup_fpm_layout "$prefix"
+        sudo tee "/etc/systemd/system/php${PHP_VERSION}-fpm.service" > /dev/null <<EOF
+[Unit]
+Description=PHP ${full} FastCGI Process Manager (built from source by run)
+After=network.target
+
+[Service]
+Type=simple
+RuntimeDirectory=php
+RuntimeDirectoryPreserve=yes
+ExecStart=${prefix}/sbin/php-fpm --nodaemonize --fpm-config ${prefix}/etc/php-fpm.conf
+ExecReload=/bin/kill -USR2 \$MAINPID
+
+[Install]
+WantedBy=multi-user.target
+EOF
+        sudo systemctl daemon-reload
+        sudo systemctl enable --now "php${PHP_VERSION}-fpm"
+    fi
+}
+
 # Update package lists
-apt update
+sudo apt-get update
 
 # Install prerequisites
-apt install -y software-properties-common
+sudo apt-get install -y software-properties-common
 
 # Add PHP repository
-add-apt-repository -y ppa:ondrej/php
-apt update
-
-# Install PHP 8.3 (latest stable as of April 2025)
-apt install -y php8.3 php8.3-fpm php8.3-common php8.3-mysql php8.3-curl php8.3-gd \
-  php8.3-mbstring php8.3-xml php8.3-zip
+sudo add-apt-repository -y ppa:ondrej/php
+sudo apt-get update
 
-# Enable and start PHP-FPM
-systemctl enable php8.3-fpm
-systemctl start php8.3-fpm
+if apt-cache show "php${PHP_VERSION}" &>/dev/null; then
+    install_from_ppa
+else
+    install_from_source
+fi
 
 # Show installed PHP version
-php -v
+"php${PHP_VERSION}" -v
 
-echo "PHP 8.3 installed successfully"
\ No newline at end of file
+echo "PHP ${PHP_VERSION} installed successfully"
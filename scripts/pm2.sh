@@ -1,8 +1,16 @@
+#!/bin/bash
 # Install and configure pm2
+#
+# Environment (set by `run install pm2` from the cloud profile):
+#   RUN_APP_USER  user pm2 runs apps as
+
+set -e
+
+APP_USER="${RUN_APP_USER:-$USER}"
 sudo npm install -g pm2
-sudo -u azureuser pm2 save
+sudo -u "$APP_USER" pm2 save
 sudo chmod 755 $(which pm2)
 sudo chmod -R 755 $(dirname $(which pm2))/../lib/node_modules/pm2
 sudo mkdir -p /var/log/pm2
 sudo chmod 777 /var/log/pm2
-sudo -u azureuser pm2 startup systemd
\ No newline at end of file
+sudo -u "$APP_USER" pm2 startup systemd
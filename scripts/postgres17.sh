@@ -1,5 +1,7 @@
 #!/bin/bash
 
+set -e
+
 # Generate random 20 character password
 POSTGRES_PASSWORD=$(openssl rand -base64 20 | tr -dc 'a-zA-Z0-9' | head -c 20)
 
@@ -11,15 +13,15 @@ sudo sh -c 'echo "deb [signed-by=/usr/share/keyrings/postgresql-keyring.gpg] htt
 
 # Update package lists
 echo "Updating package lists..."
-sudo apt update
+sudo apt-get update
 
 # Install PostgreSQL 17
 echo "Installing PostgreSQL 17..."
-sudo apt install -y postgresql-17
+sudo apt-get install -y postgresql-17
 
 # Check PostgreSQL service status
 echo "Checking PostgreSQL service status..."
-sudo systemctl status postgresql@17-main
+sudo systemctl status --no-pager postgresql@17-main
 
 # Configure PostgreSQL to listen on all interfaces
 echo "Configuring PostgreSQL to listen on all interfaces..."
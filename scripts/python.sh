@@ -1,6 +1,9 @@
 #!/bin/bash
 
-# Script to install Python, pip, gunicorn and venv on azureuser
+# Script to install Python, pip, gunicorn and venv
+#
+# Environment (set by `run install python` from the cloud profile):
+#   RUN_APP_USER  user owning the Django and Celery log directories
 # Exit immediately if a command exits with a non-zero status
 set -e
 
@@ -28,9 +31,10 @@ fi
 mkdir -p /var/log/django
 mkdir -p /var/log/celery
 
-# Set permissions (adjust user/group as needed)
-chown -R azureuser:azureuser /var/log/django
-chown -R azureuser:azureuser /var/log/celery
+# Set permissions for the app user
+APP_USER="${RUN_APP_USER:-${SUDO_USER:-root}}"
+chown -R "$APP_USER:$APP_USER" /var/log/django
+chown -R "$APP_USER:$APP_USER" /var/log/celery
 chmod 755 /var/log/django
 chmod 755 /var/log/celery
 
@@ -0,0 +1,17 @@
+#!/bin/bash
+
+# Remove the system hardening applied by hardening.sh
+# unattended-upgrades is kept since Ubuntu ships with it enabled.
+
+# Remove the sshd drop-in and reload sshd
+echo "Removing sshd hardening drop-in..."
+sudo rm -f /etc/ssh/sshd_config.d/01-run-hardening.conf /etc/ssh/sshd_config.d/60-run-hardening.conf
+sudo sshd -t && sudo systemctl reload ssh
+
+# Remove fail2ban
+echo "Removing fail2ban..."
+sudo systemctl stop fail2ban
+sudo apt-get purge fail2ban -y
+sudo rm -f /etc/fail2ban/jail.d/run-sshd.conf
+
+echo "System hardening removed"
@@ -8,7 +8,6 @@ sudo systemctl disable nginx
 
 # Remove Nginx packages
 sudo apt-get purge nginx nginx-common nginx-full nginx-core -y
-sudo apt-get autoremove -y
 
 # Clean up configuration files
 [ -d "/etc/nginx" ] && sudo rm -rf /etc/nginx
@@ -6,13 +6,13 @@ killall -9 node 2>/dev/null || echo "No Node processes were running"
 
 # Remove Node.js using apt
 echo "Removing Node.js packages..."
-sudo apt-get purge --auto-remove nodejs npm -y
+sudo apt-get purge nodejs npm -y
 
 # Remove Node.js installed via NVM if it exists
 echo "Checking for NVM installations..."
 if [ -d "$HOME/.nvm" ]; then
   echo "Removing NVM and all Node versions installed with it..."
-  rm -rf $HOME/.nvm
+  rm -rf "$HOME/.nvm"
   # Remove NVM references from profile files
   sed -i '/NVM_DIR/d' ~/.profile ~/.bashrc ~/.zshrc 2>/dev/null
 fi
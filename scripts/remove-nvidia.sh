@@ -0,0 +1,12 @@
+#!/bin/bash
+
+# Remove the NVIDIA driver, CUDA toolkit, cuDNN and NVIDIA's repository
+
+echo "Removing CUDA, cuDNN and NVIDIA driver packages..."
+sudo apt-get purge -y 'cuda-*' 'libcudnn*' 'cudnn*' 'nvidia-*' 'libnvidia-*' 2>/dev/null || true
+sudo apt-get purge -y cuda-keyring 2>/dev/null || true
+sudo apt-get update
+
+sudo rm -rf /usr/local/cuda /usr/local/cuda-*
+
+echo "NVIDIA driver and CUDA have been removed. Reboot to unload the driver."
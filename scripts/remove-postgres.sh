@@ -13,7 +13,6 @@ sudo rm -rf /var/log/postgresql/
 sudo userdel -r postgres
 sudo groupdel postgres
 
-# Clean up any remaining packages
-echo "Cleaning up remaining packages..."
-sudo apt-get autoremove -y
+# Clean up the package cache (unused dependencies are removed by run)
+echo "Cleaning up package cache..."
 sudo apt-get autoclean